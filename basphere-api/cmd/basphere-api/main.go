@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/basphere/basphere-api/internal/catalog"
 	"github.com/basphere/basphere-api/internal/config"
 	"github.com/basphere/basphere-api/internal/handler"
+	"github.com/basphere/basphere-api/internal/ipam"
 	"github.com/basphere/basphere-api/internal/provisioner"
 	"github.com/basphere/basphere-api/internal/store"
 )
@@ -18,6 +24,9 @@ var (
 	version = "0.1.0"
 )
 
+// How long in-flight requests may take to finish on shutdown
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	// Command line flags
 	configPath := flag.String("config", "/etc/basphere/api.yaml", "Path to config file")
@@ -25,6 +34,8 @@ func main() {
 	port := flag.Int("port", 0, "Server port (overrides config)")
 	showVersion := flag.Bool("version", false, "Show version")
 	devMode := flag.Bool("dev", false, "Development mode (uses mock provisioner)")
+	issueToken := flag.String("issue-token", "", "Issue an API token for the given user and exit")
+	importJSON := flag.Bool("import-json", false, "Import JSON requests from storage.pending_dir into the SQLite database and exit")
 	flag.Parse()
 
 	if *showVersion {
@@ -46,11 +57,20 @@ func main() {
 		cfg.Server.Port = *port
 	}
 
-	// Initialize store
-	fileStore, err := store.NewFileStore(cfg.Storage.PendingDir)
+	// Initialize stores
+	requestStore, keyChangeStore, closeStore, err := openStores(cfg.Storage)
 	if err != nil {
 		log.Fatalf("Failed to initialize store: %v", err)
 	}
+	defer closeStore()
+
+	// Copy requests written by the file driver into SQLite and exit (one-shot migration)
+	if *importJSON {
+		if err := importJSONRequests(cfg.Storage, requestStore, keyChangeStore); err != nil {
+			log.Fatalf("Failed to import requests: %v", err)
+		}
+		return
+	}
 
 	// Initialize provisioner
 	var prov provisioner.Provisioner
@@ -58,31 +78,74 @@ func main() {
 		log.Println("Running in development mode with mock provisioner")
 		prov = provisioner.NewMockProvisioner()
 	} else {
-		bashProv, err := provisioner.NewBashProvisioner(cfg.Provisioner.AdminScript)
+		prov, err = openProvisioner(cfg)
 		if err != nil {
 			log.Fatalf("Failed to initialize provisioner: %v", err)
 		}
-		prov = bashProv
 	}
 
 	// Find template directory
 	templateDir := findTemplateDir()
 
 	// Initialize handler
-	h, err := handler.NewHandler(fileStore, prov, templateDir, cfg)
+	h, err := handler.NewHandler(requestStore, keyChangeStore, prov, templateDir, cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize handler: %v", err)
 	}
 
+	// Issue an API token and exit (admin bootstrap for CLI users)
+	if *issueToken != "" {
+		token, _, err := h.IssueToken(*issueToken, "issued by admin", 0)
+		if err != nil {
+			log.Fatalf("Failed to issue token: %v", err)
+		}
+		fmt.Println(token)
+		os.Exit(0)
+	}
+
+	// Start background jobs (resumes work interrupted by the last shutdown)
+	if err := h.Start(); err != nil {
+		log.Fatalf("Failed to start jobs: %v", err)
+	}
+
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	log.Printf("Starting basphere-api server on %s", addr)
 	log.Printf("Registration form: http://%s/register", addr)
 	log.Printf("API endpoint: http://%s/api/v1", addr)
 
-	if err := http.ListenAndServe(addr, h.Router()); err != nil {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: h.Router(),
+	}
+	// Log streams never finish on their own; end them when shutdown starts
+	srv.RegisterOnShutdown(h.CloseStreams)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		log.Fatalf("Server failed: %v", err)
+	case <-ctx.Done():
 	}
+
+	// Graceful shutdown: finish short requests, then cancel running scripts so they clean up
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: HTTP shutdown: %v", err)
+	}
+	h.Close()
+
+	log.Println("Server stopped")
 }
 
 // findTemplateDir finds the template directory
@@ -113,3 +176,91 @@ func findTemplateDir() string {
 	// Default fallback
 	return "./web/templates"
 }
+
+// openStores creates the request stores for the configured storage driver
+func openStores(cfg config.StorageConfig) (store.Store, store.KeyChangeStore, func(), error) {
+	switch cfg.Driver {
+	case "", "file":
+		fileStore, err := store.NewFileStore(cfg.PendingDir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		keyChangeStore, err := store.NewFileKeyChangeStore(cfg.PendingDir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return fileStore, keyChangeStore, func() {}, nil
+
+	case "sqlite":
+		log.Printf("Using SQLite storage: %s", cfg.SQLitePath)
+		sqliteStore, err := store.NewSQLiteStore(cfg.SQLitePath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return sqliteStore, sqliteStore.KeyChanges(), func() { sqliteStore.Close() }, nil
+
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+// importJSONRequests copies the JSON request files in storage.pending_dir into the SQLite stores
+func importJSONRequests(cfg config.StorageConfig, requests store.Store, keyChanges store.KeyChangeStore) error {
+	if cfg.Driver != "sqlite" {
+		return fmt.Errorf("storage.driver must be sqlite to import JSON requests (current: %q)", cfg.Driver)
+	}
+
+	fileStore, err := store.NewFileStore(cfg.PendingDir)
+	if err != nil {
+		return err
+	}
+	fileKeyChanges, err := store.NewFileKeyChangeStore(cfg.PendingDir)
+	if err != nil {
+		return err
+	}
+
+	result, err := store.Import(requests, keyChanges, fileStore, fileKeyChanges)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d registration requests and %d key change requests from %s (%d already present)\n",
+		result.Requests, result.KeyChanges, cfg.PendingDir, result.Skipped)
+	return nil
+}
+
+// openProvisioner creates the VM provisioner selected by provisioner.driver
+// Users and clusters always go through the scripts; the vsphere driver handles VMs natively.
+func openProvisioner(cfg *config.Config) (provisioner.Provisioner, error) {
+	bashProv, err := provisioner.NewBashProvisioner(cfg.Provisioner)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Provisioner.Driver {
+	case "", "script":
+		return bashProv, nil
+
+	case "vsphere":
+		specs, err := catalog.Load(cfg.Catalog.SpecsFile, cfg.Catalog.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load specs: %w", err)
+		}
+		networkCfg, err := ipam.LoadConfig(cfg.IPAM.NetworkConfig)
+		if err != nil {
+			return nil, err
+		}
+		m, err := ipam.New(cfg.IPAM.Dir, networkCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Using native vSphere provisioner")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return provisioner.NewVSphereProvisioner(ctx, cfg.Provisioner, bashProv, specs, m)
+
+	default:
+		return nil, fmt.Errorf("unknown provisioner driver: %s", cfg.Provisioner.Driver)
+	}
+}
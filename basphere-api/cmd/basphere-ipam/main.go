@@ -0,0 +1,185 @@
+// basphere-ipam is the command line front end of the IPAM package for the CLI scripts.
+// It shares allocations.tsv, leases.tsv and the lock with allocate-block, allocate-ip
+// and release-ip, which delegate to it when it is installed.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/ipam"
+)
+
+const usage = `Usage: basphere-ipam [flags] <command> [args]
+
+Commands:
+  allocate-block <username>                            Assign an IP block (prints block start)
+  allocate-ip <username> <resource-name> [type]        Lease an IP in the user's block (prints IP)
+  release-ip <ip-address> [username]                   Release a lease
+  block <username>                                     Print the user's block start
+  leases [username]                                    Print leases as TSV
+
+Flags:
+`
+
+func main() {
+	configPath := flag.String("config", ipam.DefaultConfigPath, "Path to the CLI config file (network section)")
+	dir := flag.String("dir", ipam.DefaultDir, "IPAM data directory")
+	auditLog := flag.String("audit-log", "/var/log/basphere/audit.log", "Audit log file (empty to disable)")
+	maxIPs := flag.Int("max-ips", 0, "IP quota of the user for allocate-ip (0 = quotas.default.max_ips)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := ipam.LoadConfig(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+	m, err := ipam.New(*dir, cfg)
+	if err != nil {
+		fatal(err)
+	}
+
+	a := &app{ipam: m, auditLog: *auditLog, maxIPs: *maxIPs}
+	if err := a.run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+type app struct {
+	ipam     *ipam.IPAM
+	auditLog string
+	// Resolved IP quota passed by the API server or the CLI scripts (0 = configured default)
+	maxIPs int
+}
+
+func (a *app) run(command string, args []string) error {
+	switch command {
+	case "allocate-block":
+		if len(args) != 1 {
+			return errUsage
+		}
+		alloc, created, err := a.ipam.AllocateBlock(args[0])
+		if err != nil {
+			return err
+		}
+		if created {
+			a.audit("ALLOCATE_BLOCK", args[0], fmt.Sprintf("block_start=%s,block_size=%d", alloc.BlockStart, a.ipam.BlockSize()))
+		} else {
+			fmt.Fprintf(os.Stderr, "block already allocated to %s: %s\n", args[0], alloc.BlockStart)
+		}
+		fmt.Println(alloc.BlockStart)
+
+	case "allocate-ip":
+		if len(args) < 2 || len(args) > 3 {
+			return errUsage
+		}
+		resourceType := "vm"
+		if len(args) == 3 {
+			resourceType = args[2]
+		}
+		lease, created, err := a.ipam.AllocateIP(args[0], args[1], resourceType, a.maxIPs)
+		if err != nil {
+			return err
+		}
+		if created {
+			a.audit("ALLOCATE_IP", args[1], fmt.Sprintf("user=%s,ip=%s,type=%s", args[0], lease.IP, lease.ResourceType))
+		} else {
+			fmt.Fprintf(os.Stderr, "IP already leased to %s: %s\n", args[1], lease.IP)
+		}
+		fmt.Println(lease.IP)
+
+	case "release-ip":
+		if len(args) < 1 || len(args) > 2 {
+			return errUsage
+		}
+		ip, err := netip.ParseAddr(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid IP address: %s", args[0])
+		}
+		user := ""
+		if len(args) == 2 {
+			user = args[1]
+		}
+		lease, err := a.ipam.ReleaseIP(ip, user)
+		if err != nil {
+			return err
+		}
+		if user == "" {
+			user = "unknown"
+		}
+		a.audit("RELEASE_IP", lease.ResourceName, fmt.Sprintf("ip=%s,user=%s", ip, user))
+
+	case "block":
+		if len(args) != 1 {
+			return errUsage
+		}
+		alloc, err := a.ipam.UserBlock(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(alloc.BlockStart)
+
+	case "leases":
+		if len(args) > 1 {
+			return errUsage
+		}
+		user := ""
+		if len(args) == 1 {
+			user = args[0]
+		}
+		leases, err := a.ipam.Leases(user)
+		if err != nil {
+			return err
+		}
+		for _, l := range leases {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", l.IP, l.User, l.ResourceName, l.ResourceType, l.AllocatedAt.Format(time.RFC3339))
+		}
+
+	default:
+		return errUsage
+	}
+
+	return nil
+}
+
+var errUsage = errors.New("invalid arguments (see basphere-ipam -h)")
+
+// audit appends to the audit log in the format of audit_log in common.sh
+func (a *app) audit(action, resource, details string) {
+	if a.auditLog == "" {
+		return
+	}
+
+	user := os.Getenv("SUDO_USER")
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	if user == "" {
+		user = "unknown"
+	}
+
+	f, err := os.OpenFile(a.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "%s|%s|%s|%s|%s\n", time.Now().UTC().Format("2006-01-02T15:04:05Z"), user, action, resource, details)
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "basphere-ipam: %v\n", err)
+	os.Exit(1)
+}
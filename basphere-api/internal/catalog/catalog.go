@@ -0,0 +1,402 @@
+// Package catalog reads the VM and cluster specs from the CLI specs.yaml and the
+// OS templates from the templates.os section of the CLI config.yaml.
+//
+// Clusters are described in two layouts: cluster_types with cluster_node_specs (written by
+// setup-management-cluster and read by create-cluster), and the older cluster_specs whose
+// node specs refer to vm_specs. cluster_types wins when a type is defined in both.
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// DefaultSpecsPath is the CLI specs file
+const DefaultSpecsPath = "/etc/basphere/specs.yaml"
+
+// DefaultConfigPath is the CLI config file with the OS templates
+const DefaultConfigPath = "/etc/basphere/config.yaml"
+
+// defaultVMSpecs are offered by create-vm when specs.yaml is missing (get_vm_specs)
+var defaultVMSpecs = []string{"small", "medium", "large"}
+
+// Defaults used by the scripts when a spec or field is missing
+// (get_spec fallbacks in create-vm and cluster-common.sh)
+const (
+	defaultCPU               = 2
+	defaultMemoryMB          = 4096
+	defaultDiskGB            = 50
+	defaultControlPlaneCount = 1
+	defaultWorkerCount       = 2
+	defaultControlPlaneSpec  = "medium"
+)
+
+// Spec is the size of a VM or cluster node
+type Spec struct {
+	Description string `yaml:"description"`
+	CPU         int    `yaml:"cpu"`
+	MemoryMB    int    `yaml:"memory_mb"`
+	DiskGB      int    `yaml:"disk_gb"`
+}
+
+// resources returns the resources of the spec with missing fields defaulted
+func (s Spec) resources() model.Resources {
+	r := model.Resources{CPU: s.CPU, MemoryMB: s.MemoryMB, DiskGB: s.DiskGB}
+	if r.CPU <= 0 {
+		r.CPU = defaultCPU
+	}
+	if r.MemoryMB <= 0 {
+		r.MemoryMB = defaultMemoryMB
+	}
+	if r.DiskGB <= 0 {
+		r.DiskGB = defaultDiskGB
+	}
+	return r
+}
+
+// ClusterType is an entry of cluster_types
+type ClusterType struct {
+	Description       string `yaml:"description"`
+	ControlPlaneCount int    `yaml:"control_plane_count"`
+	WorkerCount       int    `yaml:"worker_count"`
+	ControlPlaneSpec  string `yaml:"control_plane_spec"`
+	WorkerSpecDefault string `yaml:"worker_spec_default"`
+}
+
+// NodeGroup is the control plane or worker part of a cluster_specs entry
+type NodeGroup struct {
+	Count int    `yaml:"count"`
+	Spec  string `yaml:"spec"`
+}
+
+// ClusterSpec is an entry of cluster_specs
+type ClusterSpec struct {
+	Description  string    `yaml:"description"`
+	ControlPlane NodeGroup `yaml:"control_plane"`
+	Worker       NodeGroup `yaml:"worker"`
+}
+
+// ClusterLayout is the number and size of nodes a cluster is created with
+type ClusterLayout struct {
+	ControlPlaneCount int
+	ControlPlaneSpec  string
+	WorkerCount       int
+	WorkerSpec        string
+}
+
+// Nodes returns the total number of nodes
+func (l ClusterLayout) Nodes() int {
+	return l.ControlPlaneCount + l.WorkerCount
+}
+
+// OSTemplate is an entry of templates.os in config.yaml
+type OSTemplate struct {
+	Template    string `yaml:"template"`
+	DefaultUser string `yaml:"default_user"`
+	Description string `yaml:"description"`
+	Interface   string `yaml:"interface"`
+}
+
+// Catalog is the parsed specs.yaml along with the OS templates
+type Catalog struct {
+	VMSpecs          map[string]Spec        `yaml:"vm_specs"`
+	ClusterSpecs     map[string]ClusterSpec `yaml:"cluster_specs"`
+	ClusterTypes     map[string]ClusterType `yaml:"cluster_types"`
+	ClusterNodeSpecs map[string]Spec        `yaml:"cluster_node_specs"`
+	OS               map[string]OSTemplate  `yaml:"-"`
+}
+
+// Load reads specs.yaml and the OS templates from config.yaml
+// A missing file yields an empty section, for which every spec gets the script defaults
+// and no OS is available (validate_os in create-vm requires a template).
+func Load(specsPath, configPath string) (*Catalog, error) {
+	c := &Catalog{}
+
+	data, err := os.ReadFile(specsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read specs: %w", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("failed to parse specs: %w", err)
+		}
+	}
+
+	data, err = os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err == nil {
+		var file struct {
+			Templates struct {
+				OS map[string]OSTemplate `yaml:"os"`
+			} `yaml:"templates"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+		c.OS = file.Templates.OS
+	}
+
+	return c, nil
+}
+
+// VMResources returns the resources of a VM created with spec
+func (c *Catalog) VMResources(spec string) model.Resources {
+	return c.VMSpecs[spec].resources()
+}
+
+// VMSize returns the resources of an existing VM including its data disks
+func (c *Catalog) VMSize(vm *model.VM) model.Resources {
+	r := c.VMRootSize(vm)
+	r.DiskGB += vm.DataDiskGB()
+	return r
+}
+
+// VMRootSize returns the resources of an existing VM without its data disks
+// A disk grown by a resize counts with its actual size.
+func (c *Catalog) VMRootSize(vm *model.VM) model.Resources {
+	r := c.VMResources(vm.Spec)
+	if vm.DiskGB > r.DiskGB {
+		r.DiskGB = vm.DiskGB
+	}
+	return r
+}
+
+// NodeResources returns the resources of a cluster node created with spec
+// cluster_node_specs is checked first, then vm_specs (used by cluster_specs).
+func (c *Catalog) NodeResources(spec string) model.Resources {
+	if s, ok := c.ClusterNodeSpecs[spec]; ok {
+		return s.resources()
+	}
+	return c.VMSpecs[spec].resources()
+}
+
+// ClusterLayout returns the nodes of a cluster of clusterType
+// workerSpec is the spec chosen by the user; when empty the type's default applies.
+func (c *Catalog) ClusterLayout(clusterType, workerSpec string) ClusterLayout {
+	layout := ClusterLayout{
+		ControlPlaneCount: defaultControlPlaneCount,
+		ControlPlaneSpec:  defaultControlPlaneSpec,
+		WorkerCount:       defaultWorkerCount,
+	}
+	defaultWorkerSpec := ""
+
+	if t, ok := c.ClusterTypes[clusterType]; ok {
+		if t.ControlPlaneCount > 0 {
+			layout.ControlPlaneCount = t.ControlPlaneCount
+		}
+		if t.WorkerCount > 0 {
+			layout.WorkerCount = t.WorkerCount
+		}
+		if t.ControlPlaneSpec != "" {
+			layout.ControlPlaneSpec = t.ControlPlaneSpec
+		}
+		defaultWorkerSpec = t.WorkerSpecDefault
+	} else if s, ok := c.ClusterSpecs[clusterType]; ok {
+		if s.ControlPlane.Count > 0 {
+			layout.ControlPlaneCount = s.ControlPlane.Count
+		}
+		if s.Worker.Count > 0 {
+			layout.WorkerCount = s.Worker.Count
+		}
+		if s.ControlPlane.Spec != "" {
+			layout.ControlPlaneSpec = s.ControlPlane.Spec
+		}
+		defaultWorkerSpec = s.Worker.Spec
+	}
+
+	layout.WorkerSpec = workerSpec
+	if layout.WorkerSpec == "" {
+		layout.WorkerSpec = defaultWorkerSpec
+	}
+	return layout
+}
+
+// LayoutResources returns the resources of all nodes in a cluster layout
+func (c *Catalog) LayoutResources(l ClusterLayout) model.Resources {
+	cp := c.NodeResources(l.ControlPlaneSpec).Times(l.ControlPlaneCount)
+	workers := c.NodeResources(l.WorkerSpec).Times(l.WorkerCount)
+	return cp.Add(workers)
+}
+
+// ClusterResources returns the resources of an existing cluster
+// The recorded node counts are used; the control plane spec comes from the cluster type.
+func (c *Catalog) ClusterResources(cluster *model.Cluster) model.Resources {
+	layout := c.ClusterLayout(cluster.Type, cluster.WorkerSpec)
+	if cluster.ControlPlaneCount > 0 {
+		layout.ControlPlaneCount = cluster.ControlPlaneCount
+	}
+	if cluster.WorkerCount > 0 {
+		layout.WorkerCount = cluster.WorkerCount
+	}
+	return c.LayoutResources(layout)
+}
+
+// OSList returns the OS templates that VMs can be created from
+// Entries without a template are skipped, as create-vm rejects them.
+func (c *Catalog) OSList() []model.OSInfo {
+	var list []model.OSInfo
+	for _, name := range sortedKeys(c.OS) {
+		t := c.OS[name]
+		if t.Template == "" {
+			continue
+		}
+		info := model.OSInfo{Name: name, Description: t.Description, DefaultUser: t.DefaultUser}
+		if info.Description == "" {
+			info.Description = name
+		}
+		if info.DefaultUser == "" {
+			info.DefaultUser = "ubuntu"
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
+// VMSpecList returns the specs VMs can be created with
+func (c *Catalog) VMSpecList() []model.SpecInfo {
+	names := sortedKeys(c.VMSpecs)
+	if len(names) == 0 {
+		names = defaultVMSpecs
+	}
+
+	list := make([]model.SpecInfo, 0, len(names))
+	for _, name := range names {
+		list = append(list, model.SpecInfo{
+			Name:        name,
+			Description: c.VMSpecs[name].Description,
+			Resources:   c.VMResources(name),
+		})
+	}
+	return list
+}
+
+// NodeSpecList returns the specs cluster workers can be created with
+// These are the cluster_node_specs, or the vm_specs when none are defined (cluster_specs layout).
+func (c *Catalog) NodeSpecList() []model.SpecInfo {
+	if len(c.ClusterNodeSpecs) == 0 {
+		return c.VMSpecList()
+	}
+
+	var list []model.SpecInfo
+	for _, name := range sortedKeys(c.ClusterNodeSpecs) {
+		list = append(list, model.SpecInfo{
+			Name:        name,
+			Description: c.ClusterNodeSpecs[name].Description,
+			Resources:   c.NodeResources(name),
+		})
+	}
+	return list
+}
+
+// ClusterTypeList returns the cluster types from cluster_types and cluster_specs
+func (c *Catalog) ClusterTypeList() []model.ClusterTypeInfo {
+	names := sortedKeys(c.ClusterTypes)
+	for name := range c.ClusterSpecs {
+		if _, ok := c.ClusterTypes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var list []model.ClusterTypeInfo
+	for _, name := range names {
+		description := c.ClusterSpecs[name].Description
+		if t, ok := c.ClusterTypes[name]; ok {
+			description = t.Description
+		}
+		layout := c.ClusterLayout(name, "")
+		list = append(list, model.ClusterTypeInfo{
+			Name:              name,
+			Description:       description,
+			ControlPlaneCount: layout.ControlPlaneCount,
+			ControlPlaneSpec:  layout.ControlPlaneSpec,
+			WorkerCount:       layout.WorkerCount,
+			DefaultWorkerSpec: layout.WorkerSpec,
+		})
+	}
+	return list
+}
+
+// ValidateVM checks the OS and spec of a VM creation against the catalog
+func (c *Catalog) ValidateVM(input *model.CreateVMInput) []string {
+	var errors []string
+
+	var osNames []string
+	for _, o := range c.OSList() {
+		osNames = append(osNames, o.Name)
+	}
+	if msg := checkOneOf("os", input.OS, osNames); msg != "" {
+		errors = append(errors, msg)
+	}
+
+	return append(errors, c.ValidateVMSpec(input.Spec)...)
+}
+
+// ValidateVMSpec checks a VM spec against the catalog
+func (c *Catalog) ValidateVMSpec(spec string) []string {
+	if msg := checkOneOf("spec", spec, specNames(c.VMSpecList())); msg != "" {
+		return []string{msg}
+	}
+	return nil
+}
+
+// ValidateCluster checks the type and worker spec of a cluster creation against the catalog
+func (c *Catalog) ValidateCluster(input *model.CreateClusterInput) []string {
+	var errors []string
+
+	var typeNames []string
+	for _, t := range c.ClusterTypeList() {
+		typeNames = append(typeNames, t.Name)
+	}
+	if msg := checkOneOf("type", input.Type, typeNames); msg != "" {
+		errors = append(errors, msg)
+	}
+
+	if msg := checkOneOf("worker_spec", input.WorkerSpec, specNames(c.NodeSpecList())); msg != "" {
+		errors = append(errors, msg)
+	}
+
+	return errors
+}
+
+// checkOneOf returns a validation error unless value is one of names
+// Empty values are left to the input's own Validate.
+func checkOneOf(field, value string, names []string) string {
+	if value == "" {
+		return ""
+	}
+	for _, name := range names {
+		if value == name {
+			return ""
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%s %q is not available: none are configured", field, value)
+	}
+	return fmt.Sprintf("%s must be one of: %s", field, strings.Join(names, ", "))
+}
+
+func specNames(specs []model.SpecInfo) []string {
+	names := make([]string, 0, len(specs))
+	for _, s := range specs {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,350 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+// testSpecs mirrors specs.yaml.example plus the cluster_types section added by setup-management-cluster
+const testSpecs = `
+vm_specs:
+  tiny:
+    cpu: 2
+    memory_mb: 4096
+    disk_gb: 50
+  huge:
+    cpu: 16
+    memory_mb: 65536
+    disk_gb: 200
+  partial:
+    cpu: 6
+
+cluster_specs:
+  dev:
+    control_plane:
+      count: 1
+      spec: "tiny"
+    worker:
+      count: 2
+      spec: "tiny"
+  legacy:
+    control_plane:
+      count: 3
+      spec: "tiny"
+    worker:
+      count: 4
+      spec: "huge"
+
+cluster_types:
+  dev:
+    control_plane_count: 1
+    worker_count: 2
+    control_plane_spec: medium
+    worker_spec_default: medium
+
+cluster_node_specs:
+  small:
+    cpu: 2
+    memory_mb: 4096
+    disk_gb: 50
+  medium:
+    cpu: 4
+    memory_mb: 8192
+    disk_gb: 100
+`
+
+// testConfig mirrors the templates section of config.yaml.example
+const testConfig = `
+templates:
+  os:
+    ubuntu-24.04:
+      template: "ubuntu-24.04-template"
+      default_user: "ubuntu"
+      description: "Ubuntu 24.04 LTS"
+    rocky-10:
+      template: "rocky-10-template"
+      default_user: "rocky"
+    broken:
+      description: "No template yet"
+`
+
+func loadTestCatalog(t *testing.T) *Catalog {
+	t.Helper()
+
+	dir := t.TempDir()
+	specsPath := filepath.Join(dir, "specs.yaml")
+	if err := os.WriteFile(specsPath, []byte(testSpecs), 0644); err != nil {
+		t.Fatalf("Failed to write specs: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(testConfig), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	c, err := Load(specsPath, configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return c
+}
+
+// =============================================================================
+// Load Tests
+// =============================================================================
+
+func TestLoad_MissingFile(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Load(filepath.Join(dir, "specs.yaml"), filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(c.OSList()) != 0 {
+		t.Errorf("Expected no OS without config.yaml, got %+v", c.OSList())
+	}
+
+	// Script defaults: 2 vCPU, 4096 MB, 50 GB
+	expected := model.Resources{CPU: 2, MemoryMB: 4096, DiskGB: 50}
+	if got := c.VMResources("small"); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestLoad_InvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "specs.yaml")
+	os.WriteFile(path, []byte("vm_specs: [unclosed"), 0644)
+
+	if _, err := Load(path, filepath.Join(t.TempDir(), "config.yaml")); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+// =============================================================================
+// Resource Tests
+// =============================================================================
+
+func TestVMResources(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	tests := []struct {
+		spec     string
+		expected model.Resources
+	}{
+		{"tiny", model.Resources{CPU: 2, MemoryMB: 4096, DiskGB: 50}},
+		{"huge", model.Resources{CPU: 16, MemoryMB: 65536, DiskGB: 200}},
+		{"partial", model.Resources{CPU: 6, MemoryMB: 4096, DiskGB: 50}},
+		{"unknown", model.Resources{CPU: 2, MemoryMB: 4096, DiskGB: 50}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if got := c.VMResources(tt.spec); got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestVMSize_GrownDisk(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	tests := []struct {
+		name     string
+		vm       model.VM
+		expected model.Resources
+	}{
+		{"spec size", model.VM{Spec: "huge"}, model.Resources{CPU: 16, MemoryMB: 65536, DiskGB: 200}},
+		{"grown disk", model.VM{Spec: "tiny", DiskGB: 120}, model.Resources{CPU: 2, MemoryMB: 4096, DiskGB: 120}},
+		{"disk below spec", model.VM{Spec: "huge", DiskGB: 100}, model.Resources{CPU: 16, MemoryMB: 65536, DiskGB: 200}},
+		{"data disks", model.VM{Spec: "tiny", DiskGB: 60, DataDisks: []model.Disk{{Name: "data", SizeGB: 100}, {Name: "logs", SizeGB: 20}}},
+			model.Resources{CPU: 2, MemoryMB: 4096, DiskGB: 60 + 100 + 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.VMSize(&tt.vm); got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestClusterLayout(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	tests := []struct {
+		name        string
+		clusterType string
+		workerSpec  string
+		expected    ClusterLayout
+	}{
+		{"cluster_types wins", "dev", "small", ClusterLayout{1, "medium", 2, "small"}},
+		{"type default worker spec", "dev", "", ClusterLayout{1, "medium", 2, "medium"}},
+		{"cluster_specs fallback", "legacy", "", ClusterLayout{3, "tiny", 4, "huge"}},
+		{"unknown type", "standard", "large", ClusterLayout{1, "medium", 2, "large"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.ClusterLayout(tt.clusterType, tt.workerSpec); got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLayoutResources(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	// 1 medium control plane + 2 small workers from cluster_node_specs
+	got := c.LayoutResources(c.ClusterLayout("dev", "small"))
+	expected := model.Resources{CPU: 4 + 2*2, MemoryMB: 8192 + 2*4096, DiskGB: 100 + 2*50}
+	if got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	// cluster_specs nodes refer to vm_specs
+	got = c.LayoutResources(c.ClusterLayout("legacy", ""))
+	expected = model.Resources{CPU: 3*2 + 4*16, MemoryMB: 3*4096 + 4*65536, DiskGB: 3*50 + 4*200}
+	if got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestClusterResources_UsesRecordedCounts(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	cluster := &model.Cluster{Type: "dev", ControlPlaneCount: 1, WorkerCount: 5, WorkerSpec: "small"}
+	expected := model.Resources{CPU: 4 + 5*2, MemoryMB: 8192 + 5*4096, DiskGB: 100 + 5*50}
+	if got := c.ClusterResources(cluster); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+// =============================================================================
+// List Tests
+// =============================================================================
+
+func TestOSList(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	expected := []model.OSInfo{
+		{Name: "rocky-10", Description: "rocky-10", DefaultUser: "rocky"},
+		{Name: "ubuntu-24.04", Description: "Ubuntu 24.04 LTS", DefaultUser: "ubuntu"},
+	}
+
+	got := c.OSList()
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d OS, got %+v", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], got[i])
+		}
+	}
+}
+
+func TestVMSpecList_DefaultsWithoutSpecs(t *testing.T) {
+	c := &Catalog{}
+
+	got := specNames(c.VMSpecList())
+	if strings.Join(got, ",") != "small,medium,large" {
+		t.Errorf("Expected script default specs, got %v", got)
+	}
+}
+
+func TestClusterTypeList(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	got := c.ClusterTypeList()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 cluster types, got %+v", got)
+	}
+	if got[0].Name != "dev" || got[0].ControlPlaneSpec != "medium" || got[0].DefaultWorkerSpec != "medium" {
+		t.Errorf("Expected dev from cluster_types, got %+v", got[0])
+	}
+	if got[1].Name != "legacy" || got[1].ControlPlaneCount != 3 || got[1].WorkerCount != 4 {
+		t.Errorf("Expected legacy from cluster_specs, got %+v", got[1])
+	}
+}
+
+// =============================================================================
+// Validation Tests
+// =============================================================================
+
+func TestValidateVM(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	tests := []struct {
+		name       string
+		os         string
+		spec       string
+		wantErrors []string
+	}{
+		{"valid", "ubuntu-24.04", "tiny", nil},
+		{"unknown os", "windows", "tiny", []string{"os must be one of: rocky-10, ubuntu-24.04"}},
+		{"os without template", "broken", "tiny", []string{"os must be one of"}},
+		{"unknown spec", "rocky-10", "small", []string{"spec must be one of: huge, partial, tiny"}},
+		{"empty fields left to Validate", "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := c.ValidateVM(&model.CreateVMInput{Name: "web", OS: tt.os, Spec: tt.spec})
+			checkErrors(t, errors, tt.wantErrors)
+		})
+	}
+}
+
+func TestValidateCluster(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	tests := []struct {
+		name       string
+		typ        string
+		workerSpec string
+		wantErrors []string
+	}{
+		{"valid", "dev", "small", nil},
+		{"cluster_specs type", "legacy", "medium", nil},
+		{"unknown type", "standard", "small", []string{"type must be one of: dev, legacy"}},
+		{"vm spec as worker", "dev", "huge", []string{"worker_spec must be one of: medium, small"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := c.ValidateCluster(&model.CreateClusterInput{Name: "k8s", Type: tt.typ, WorkerSpec: tt.workerSpec})
+			checkErrors(t, errors, tt.wantErrors)
+		})
+	}
+}
+
+func TestValidateVM_NoOSConfigured(t *testing.T) {
+	c := &Catalog{}
+
+	errors := c.ValidateVM(&model.CreateVMInput{Name: "web", OS: "ubuntu-24.04", Spec: "small"})
+	if len(errors) != 1 || !strings.Contains(errors[0], "none are configured") {
+		t.Errorf("Expected OS error when no templates are configured, got %v", errors)
+	}
+}
+
+func checkErrors(t *testing.T, errors, wantErrors []string) {
+	t.Helper()
+
+	if len(errors) != len(wantErrors) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(wantErrors), len(errors), errors)
+	}
+	for i, want := range wantErrors {
+		if !strings.Contains(errors[i], want) {
+			t.Errorf("Expected error containing %q, got %q", want, errors[i])
+		}
+	}
+}
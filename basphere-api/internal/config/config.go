@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +15,132 @@ type Config struct {
 	Recaptcha   RecaptchaConfig   `yaml:"recaptcha"`
 	Validation  ValidationConfig  `yaml:"validation"`
 	Bastion     BastionConfig     `yaml:"bastion"`
+	Auth        AuthConfig        `yaml:"auth"`
+	SSHCA       SSHCAConfig       `yaml:"ssh_ca"`
+	OIDC        OIDCConfig        `yaml:"oidc"`
+	Jobs        JobsConfig        `yaml:"jobs"`
+	IPAM        IPAMConfig        `yaml:"ipam"`
+	Quotas      QuotasConfig      `yaml:"quotas"`
+	Catalog     CatalogConfig     `yaml:"catalog"`
+	Leases      LeasesConfig      `yaml:"leases"`
+	Terminal    TerminalConfig    `yaml:"terminal"`
+	Inventory   InventoryConfig   `yaml:"inventory"`
+	Drift       DriftConfig       `yaml:"drift"`
+}
+
+// DriftConfig represents the periodic comparison of VM records with vCenter and IPAM
+// It reads vCenter through the inventory, so inventory.enabled is required.
+type DriftConfig struct {
+	// How often drift is checked and new drift logged (0 disables the periodic check)
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+// InventoryConfig represents the live VM state read from vCenter for VM responses
+// vCenter is reached with the provisioner.vsphere settings, whichever driver is used.
+type InventoryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// How long one vCenter read is reused by VM list and detail responses
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// TerminalConfig represents the browser SSH terminal
+// The API server signs in to the bastion and the VM with a short-lived certificate from ssh_ca,
+// so ssh_ca must be enabled and both must trust the CA.
+type TerminalConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Bastion sshd the API server connects to, host:port (default: bastion.address and bastion.port)
+	BastionAddr string `yaml:"bastion_addr"`
+	// known_hosts file holding the bastion host key (required: empty disables the terminal)
+	KnownHostsFile string `yaml:"known_hosts_file"`
+	// Sessions without keyboard input for this long are closed
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+// LeasesConfig represents how long VMs and clusters may live before the reaper deletes them
+type LeasesConfig struct {
+	// Lease given to resources created without ttl or expires_at (0 = no expiry)
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	// Longest lease users may request or renew to (0 = unlimited)
+	MaxTTL time.Duration `yaml:"max_ttl"`
+	// Per VM spec and cluster type overrides of MaxTTL (e.g., large: "168h")
+	SpecMaxTTL        map[string]time.Duration `yaml:"spec_max_ttl"`
+	ClusterTypeMaxTTL map[string]time.Duration `yaml:"cluster_type_max_ttl"`
+	// How long before expiry the owner is warned
+	WarnBefore time.Duration `yaml:"warn_before"`
+	// How often the reaper looks for expiring leases (0 disables the reaper)
+	CheckInterval time.Duration `yaml:"check_interval"`
+	// Command run to notify owners, with --user, --kind, --name, --expires-at and --event (warn or expired)
+	NotifyCommand string `yaml:"notify_command"`
+}
+
+// CatalogConfig represents where the VM and cluster specs are defined
+type CatalogConfig struct {
+	// CLI specs file with vm_specs, cluster_specs, cluster_types and cluster_node_specs
+	SpecsFile string `yaml:"specs_file"`
+	// CLI config file with the OS templates (templates.os)
+	ConfigFile string `yaml:"config_file"`
+}
+
+// QuotasConfig represents where the quota limits come from
+type QuotasConfig struct {
+	// CLI config file with the quotas section (default, teams, users)
+	ConfigFile string `yaml:"config_file"`
+}
+
+// IPAMConfig represents where the IP allocation data and network settings live
+type IPAMConfig struct {
+	// Directory with allocations.tsv and leases.tsv (shared with the CLI scripts)
+	Dir string `yaml:"dir"`
+	// CLI config file with the network section and quotas.default.max_ips
+	NetworkConfig string `yaml:"network_config"`
+}
+
+// JobsConfig represents the background job configuration
+type JobsConfig struct {
+	// Number of jobs executed concurrently
+	Workers int `yaml:"workers"`
+	// How long finished jobs are kept (e.g., "168h")
+	Retention time.Duration `yaml:"retention"`
+}
+
+// OIDCConfig represents the OpenID Connect login configuration for the web portal
+type OIDCConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	IssuerURL    string `yaml:"issuer_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// Public callback URL (e.g., "https://basphere.company.local/auth/callback")
+	RedirectURL string   `yaml:"redirect_url"`
+	Scopes      []string `yaml:"scopes"`
+	// Web session lifetime after login
+	SessionTTL time.Duration `yaml:"session_ttl"`
+}
+
+// SSHCAConfig represents the SSH certificate authority configuration
+type SSHCAConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path to the CA private key (generated on first start if missing)
+	KeyPath string `yaml:"key_path"`
+	// Default certificate lifetime (e.g., "8h")
+	CertTTL time.Duration `yaml:"cert_ttl"`
+	// Upper bound for lifetimes requested by users
+	MaxCertTTL time.Duration `yaml:"max_cert_ttl"`
+}
+
+// AuthConfig represents the authentication and authorization configuration
+type AuthConfig struct {
+	// Usernames granted the admin role (approve/reject registrations and key changes)
+	Admins []string `yaml:"admins"`
+}
+
+// IsAdmin checks if the username is configured as an admin
+func (a *AuthConfig) IsAdmin(username string) bool {
+	for _, admin := range a.Admins {
+		if admin == username {
+			return true
+		}
+	}
+	return false
 }
 
 // BastionConfig represents the bastion server configuration for display
@@ -45,14 +172,59 @@ type ServerConfig struct {
 
 // StorageConfig represents the storage configuration
 type StorageConfig struct {
+	// Storage backend for registration and key change requests: "file" (default) or "sqlite"
+	Driver string `yaml:"driver"`
+
 	// Path to store pending requests
 	PendingDir string `yaml:"pending_dir"`
+
+	// Path to the SQLite database (driver: sqlite)
+	SQLitePath string `yaml:"sqlite_path"`
+
+	// Directory for VM and cluster provisioning logs
+	LogDir string `yaml:"log_dir"`
 }
 
 // ProvisionerConfig represents the provisioner configuration
 type ProvisionerConfig struct {
+	// How VMs are provisioned: "script" (CLI scripts with Terraform) or "vsphere" (govmomi)
+	// Users and clusters always go through the scripts.
+	Driver string `yaml:"driver"`
 	// Path to basphere-admin script
 	AdminScript string `yaml:"admin_script"`
+	// vSphere connection for the vsphere driver
+	VSphere VSphereConfig `yaml:"vsphere"`
+	// Per-operation script timeouts
+	Timeouts TimeoutsConfig `yaml:"timeouts"`
+}
+
+// VSphereConfig represents where the vsphere driver finds vCenter
+// The inventory settings and credentials are shared with the CLI scripts.
+type VSphereConfig struct {
+	// CLI config file with the vsphere section and network.mtu/dns
+	ConfigFile string `yaml:"config_file"`
+	// Credentials file with VSPHERE_USER, VSPHERE_PASSWORD and VSPHERE_ALLOW_UNVERIFIED_SSL
+	CredentialsFile string `yaml:"credentials_file"`
+	// How the guest network is configured: "guestinfo" (cloud-init reads guestinfo.metadata,
+	// as with Terraform) or "linuxprep" (vSphere guest customization, needs open-vm-tools)
+	Customization string `yaml:"customization"`
+}
+
+// TimeoutsConfig represents how long each provisioning script may run (e.g., "30m")
+type TimeoutsConfig struct {
+	CreateVM      time.Duration `yaml:"create_vm"`
+	DeleteVM      time.Duration `yaml:"delete_vm"`
+	PowerVM       time.Duration `yaml:"power_vm"`
+	ResizeVM      time.Duration `yaml:"resize_vm"`
+	Snapshot      time.Duration `yaml:"snapshot"`
+	Disk          time.Duration `yaml:"disk"`
+	Label         time.Duration `yaml:"label"`
+	CreateCluster time.Duration `yaml:"create_cluster"`
+	DeleteCluster time.Duration `yaml:"delete_cluster"`
+	// User management (basphere-admin, id, getent, chown)
+	User time.Duration `yaml:"user"`
+	// How long a cancelled script may run its cleanup before it is killed
+	CancelGrace time.Duration `yaml:"cancel_grace"`
 }
 
 // DefaultConfig returns the default configuration
@@ -63,15 +235,74 @@ func DefaultConfig() *Config {
 			Port: 8080,
 		},
 		Storage: StorageConfig{
+			Driver:     "file",
 			PendingDir: "/var/lib/basphere/pending",
+			SQLitePath: "/var/lib/basphere/basphere.db",
+			LogDir:     "/var/lib/basphere/logs",
 		},
 		Provisioner: ProvisionerConfig{
+			Driver:      "script",
 			AdminScript: "/usr/local/bin/basphere-admin",
+			VSphere: VSphereConfig{
+				ConfigFile:      "/etc/basphere/config.yaml",
+				CredentialsFile: "/etc/basphere/vsphere.env",
+				Customization:   "guestinfo",
+			},
+			Timeouts: TimeoutsConfig{
+				CreateVM:      30 * time.Minute,
+				DeleteVM:      15 * time.Minute,
+				PowerVM:       2 * time.Minute,
+				ResizeVM:      20 * time.Minute,
+				Snapshot:      10 * time.Minute,
+				Disk:          15 * time.Minute,
+				Label:         time.Minute,
+				CreateCluster: 10 * time.Minute,
+				DeleteCluster: 15 * time.Minute,
+				User:          time.Minute,
+				CancelGrace:   30 * time.Second,
+			},
 		},
 		Bastion: BastionConfig{
 			Address: "bastion-server",
 			Port:    22,
 		},
+		SSHCA: SSHCAConfig{
+			KeyPath:    "/etc/basphere/ssh/user_ca",
+			CertTTL:    8 * time.Hour,
+			MaxCertTTL: 24 * time.Hour,
+		},
+		OIDC: OIDCConfig{
+			Scopes:     []string{"email", "profile"},
+			SessionTTL: 8 * time.Hour,
+		},
+		Jobs: JobsConfig{
+			Workers:   2,
+			Retention: 7 * 24 * time.Hour,
+		},
+		IPAM: IPAMConfig{
+			Dir:           "/var/lib/basphere/ipam",
+			NetworkConfig: "/etc/basphere/config.yaml",
+		},
+		Quotas: QuotasConfig{
+			ConfigFile: "/etc/basphere/config.yaml",
+		},
+		Catalog: CatalogConfig{
+			SpecsFile:  "/etc/basphere/specs.yaml",
+			ConfigFile: "/etc/basphere/config.yaml",
+		},
+		Leases: LeasesConfig{
+			WarnBefore:    24 * time.Hour,
+			CheckInterval: 5 * time.Minute,
+		},
+		Terminal: TerminalConfig{
+			IdleTimeout: 30 * time.Minute,
+		},
+		Inventory: InventoryConfig{
+			CacheTTL: 15 * time.Second,
+		},
+		Drift: DriftConfig{
+			CheckInterval: 15 * time.Minute,
+		},
 	}
 }
 
@@ -0,0 +1,218 @@
+// Package drift compares the VM records with vCenter and IPAM.
+//
+// The records under <dataDir>/terraform/<user> are what basphere believes exists. They can
+// diverge from vCenter and the IP leases after manual changes or failed Terraform runs:
+//
+//	missing_vm    a record whose VM is not in the owner's vSphere folder
+//	unmanaged_vm  a VM in a user's vSphere folder that no record points at
+//	orphan_ip     an IP lease whose VM record or cluster is gone
+//
+// VMs placed directly in the basphere folder are Kubernetes cluster nodes created by
+// Cluster API and are not checked.
+package drift
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/ipam"
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/vsphere"
+)
+
+// IP lease resource types of cluster nodes, named <cluster>-cp and <cluster>-worker-<n>
+const (
+	leaseTypeVM            = "vm"
+	leaseTypeClusterCP     = "cluster-cp"
+	leaseTypeClusterWorker = "cluster-worker"
+)
+
+var workerSuffix = regexp.MustCompile(`-worker-[0-9]+$`)
+
+// State is what the drift check compares
+type State struct {
+	// VM records of all users
+	Records []model.VM
+	// VMs below the basphere folder
+	Inventory *vsphere.Snapshot
+	// IP leases of all users (nil when IPAM is not configured)
+	Leases []ipam.Lease
+	// Reports whether a cluster record exists; errors should count as existing
+	ClusterExists func(owner, name string) bool
+}
+
+// Detect returns the drift between records, vCenter and IPAM as of now
+// Records still being created or deleted and records already marked failed are not reported
+// as missing. IP leases younger than grace are skipped: a VM or cluster being created holds
+// its address before its record is written.
+func Detect(s *State, now time.Time, grace time.Duration) []model.DriftItem {
+	items := []model.DriftItem{}
+
+	recorded := make(map[string]bool, len(s.Records))
+	managed := make(map[string]bool, len(s.Records))
+	for i := range s.Records {
+		vm := &s.Records[i]
+		recorded[vm.Owner+"/"+vm.Name] = true
+		managed[vm.Owner+"/"+vsphere.VMName(vm)] = true
+
+		switch vm.Status {
+		case model.VMStatusCreating, model.VMStatusDeleting, model.VMStatusFailed:
+			continue
+		}
+		if s.Inventory.LiveState(vm, now).Found {
+			continue
+		}
+		items = append(items, newItem(model.DriftItem{
+			Kind:          model.DriftKindMissingVM,
+			Owner:         vm.Owner,
+			Name:          vm.Name,
+			VsphereVMName: vsphere.VMName(vm),
+			IPAddress:     vm.IPAddress,
+			Status:        vm.Status,
+		}, vm.Owner+"/"+vm.Name))
+	}
+
+	for _, state := range s.Inventory.VMs {
+		if state.Owner == "" || state.Template || managed[state.Owner+"/"+state.Name] {
+			continue
+		}
+		items = append(items, newItem(model.DriftItem{
+			Kind:          model.DriftKindUnmanagedVM,
+			Owner:         state.Owner,
+			VsphereVMName: state.Name,
+			IPAddress:     state.GuestIP,
+			PowerState:    string(state.PowerState),
+		}, state.Owner+"/"+state.Name))
+	}
+
+	for _, lease := range s.Leases {
+		if now.Sub(lease.AllocatedAt) < grace {
+			continue
+		}
+
+		var exists bool
+		switch lease.ResourceType {
+		case leaseTypeVM:
+			exists = recorded[lease.User+"/"+lease.ResourceName]
+		case leaseTypeClusterCP, leaseTypeClusterWorker:
+			exists = s.ClusterExists != nil && s.ClusterExists(lease.User, ClusterName(lease.ResourceName))
+		default:
+			// Not allocated by basphere (e.g. added by hand)
+			continue
+		}
+		if exists {
+			continue
+		}
+		items = append(items, newItem(model.DriftItem{
+			Kind:         model.DriftKindOrphanIP,
+			Owner:        lease.User,
+			Name:         lease.ResourceName,
+			IPAddress:    lease.IP.String(),
+			ResourceType: lease.ResourceType,
+		}, lease.IP.String()))
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Owner != b.Owner {
+			return a.Owner < b.Owner
+		}
+		return a.Name+a.VsphereVMName < b.Name+b.VsphereVMName
+	})
+	return items
+}
+
+// ClusterName returns the cluster an IP lease of a cluster node belongs to
+func ClusterName(resourceName string) string {
+	if name, ok := strings.CutSuffix(resourceName, "-cp"); ok {
+		return name
+	}
+	return workerSuffix.ReplaceAllString(resourceName, "")
+}
+
+// newItem sets the ID derived from the kind and key, and the available actions
+func newItem(item model.DriftItem, key string) model.DriftItem {
+	sum := sha256.Sum256([]byte(string(item.Kind) + "/" + key))
+	item.ID = hex.EncodeToString(sum[:8])
+	item.Actions = item.Kind.Actions()
+	return item
+}
+
+// Tracker keeps the latest drift report and when each item was first seen
+type Tracker struct {
+	mu        sync.Mutex
+	report    *model.DriftReport
+	firstSeen map[string]time.Time
+}
+
+// NewTracker creates a tracker without a report
+func NewTracker() *Tracker {
+	return &Tracker{firstSeen: make(map[string]time.Time)}
+}
+
+// Update stores the items of a check and returns its report and the items not seen before
+// Items that disappeared are forgotten, so drift that comes back counts as new.
+func (t *Tracker) Update(items []model.DriftItem, checkedAt time.Time) (*model.DriftReport, []model.DriftItem) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var added []model.DriftItem
+	firstSeen := make(map[string]time.Time, len(items))
+	for i := range items {
+		seen, ok := t.firstSeen[items[i].ID]
+		if !ok {
+			seen = checkedAt
+			added = append(added, items[i])
+		}
+		firstSeen[items[i].ID] = seen
+		items[i].FirstSeenAt = seen
+	}
+	t.firstSeen = firstSeen
+	t.report = &model.DriftReport{Items: items, Total: len(items), CheckedAt: checkedAt}
+
+	return t.copyReport(), added
+}
+
+// Report returns the latest report, or nil before the first check
+func (t *Tracker) Report() *model.DriftReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.copyReport()
+}
+
+// Resolve removes an item from the latest report after an action resolved it
+func (t *Tracker) Resolve(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.firstSeen, id)
+	if t.report == nil {
+		return
+	}
+	items := make([]model.DriftItem, 0, len(t.report.Items))
+	for _, item := range t.report.Items {
+		if item.ID != id {
+			items = append(items, item)
+		}
+	}
+	t.report.Items = items
+	t.report.Total = len(items)
+}
+
+func (t *Tracker) copyReport() *model.DriftReport {
+	if t.report == nil {
+		return nil
+	}
+	report := *t.report
+	report.Items = append([]model.DriftItem{}, t.report.Items...)
+	return &report
+}
@@ -0,0 +1,193 @@
+package drift
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/basphere/basphere-api/internal/ipam"
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/vsphere"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+var testNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testState has one drift item of each kind beside records, VMs and leases that agree
+func testState() *State {
+	old := testNow.Add(-24 * time.Hour)
+	lease := func(ip, user, name, resourceType string, allocatedAt time.Time) ipam.Lease {
+		return ipam.Lease{IP: netip.MustParseAddr(ip), User: user, ResourceName: name, ResourceType: resourceType, AllocatedAt: allocatedAt}
+	}
+
+	return &State{
+		Records: []model.VM{
+			{Name: "web", VsphereVMName: "alice-web", Owner: "alice", Status: model.VMStatusRunning, IPAddress: "10.0.0.32"},
+			{Name: "db", VsphereVMName: "alice-db", Owner: "alice", Status: model.VMStatusStopped, IPAddress: "10.0.0.33"},
+			{Name: "new", VsphereVMName: "alice-new", Owner: "alice", Status: model.VMStatusCreating},
+			{Name: "broken", VsphereVMName: "alice-broken", Owner: "alice", Status: model.VMStatusFailed},
+			// Recorded before vsphere_vm_name
+			{Name: "api", Owner: "bob", Status: model.VMStatusRunning},
+		},
+		Inventory: vsphere.NewSnapshot([]vsphere.VMState{
+			{Owner: "alice", Name: "alice-web", PowerState: types.VirtualMachinePowerStatePoweredOn},
+			{Owner: "bob", Name: "bob-api", PowerState: types.VirtualMachinePowerStatePoweredOn},
+			{Owner: "bob", Name: "bob-manual", PowerState: types.VirtualMachinePowerStatePoweredOff, GuestIP: "10.0.0.70"},
+			{Owner: "bob", Name: "bob-tmpl", Template: true},
+			// Cluster API node in the basphere folder
+			{Owner: "", Name: "k8s-cp-x7k2p", PowerState: types.VirtualMachinePowerStatePoweredOn},
+		}, testNow),
+		Leases: []ipam.Lease{
+			lease("10.0.0.32", "alice", "web", "vm", old),
+			lease("10.0.0.33", "alice", "db", "vm", old),
+			lease("10.0.0.34", "alice", "gone", "vm", old),
+			lease("10.0.0.35", "alice", "pending", "vm", testNow.Add(-time.Minute)),
+			lease("10.0.0.64", "bob", "k8s-cp", "cluster-cp", old),
+			lease("10.0.0.65", "bob", "k8s-worker-1", "cluster-worker", old),
+			lease("10.0.0.66", "bob", "old-worker-2", "cluster-worker", old),
+		},
+		ClusterExists: func(owner, name string) bool { return owner == "bob" && name == "k8s" },
+	}
+}
+
+// =============================================================================
+// Detect Tests
+// =============================================================================
+
+func TestDetect(t *testing.T) {
+	items := Detect(testState(), testNow, 30*time.Minute)
+
+	want := []struct {
+		kind  model.DriftKind
+		owner string
+		name  string
+	}{
+		{model.DriftKindMissingVM, "alice", "db"},
+		{model.DriftKindOrphanIP, "alice", "gone"},
+		{model.DriftKindOrphanIP, "bob", "old-worker-2"},
+		{model.DriftKindUnmanagedVM, "bob", "bob-manual"},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("Expected %d items, got %+v", len(want), items)
+	}
+	for i, w := range want {
+		item := items[i]
+		name := item.Name
+		if item.Kind == model.DriftKindUnmanagedVM {
+			name = item.VsphereVMName
+		}
+		if item.Kind != w.kind || item.Owner != w.owner || name != w.name {
+			t.Errorf("Item %d: expected %s %s/%s, got %+v", i, w.kind, w.owner, w.name, item)
+		}
+		if item.ID == "" || len(item.Actions) == 0 {
+			t.Errorf("Expected an ID and actions, got %+v", item)
+		}
+	}
+
+	if missing := items[0]; missing.VsphereVMName != "alice-db" || missing.IPAddress != "10.0.0.33" || missing.Status != model.VMStatusStopped {
+		t.Errorf("Unexpected missing VM: %+v", missing)
+	}
+	if unmanaged := items[3]; unmanaged.IPAddress != "10.0.0.70" || unmanaged.PowerState != "poweredOff" {
+		t.Errorf("Unexpected unmanaged VM: %+v", unmanaged)
+	}
+	if orphan := items[2]; orphan.IPAddress != "10.0.0.66" || orphan.ResourceType != "cluster-worker" {
+		t.Errorf("Unexpected orphan IP: %+v", orphan)
+	}
+}
+
+func TestDetect_StableIDs(t *testing.T) {
+	first := Detect(testState(), testNow, 0)
+	second := Detect(testState(), testNow.Add(time.Hour), 0)
+
+	if len(first) != len(second) {
+		t.Fatalf("Expected the same items, got %d and %d", len(first), len(second))
+	}
+	seen := map[string]bool{}
+	for i := range first {
+		if first[i].ID != second[i].ID {
+			t.Errorf("Expected stable ID for %s, got %s and %s", first[i].Name, first[i].ID, second[i].ID)
+		}
+		if seen[first[i].ID] {
+			t.Errorf("Duplicate ID %s", first[i].ID)
+		}
+		seen[first[i].ID] = true
+	}
+}
+
+func TestDetect_NoDrift(t *testing.T) {
+	s := &State{
+		Records:   []model.VM{{Name: "web", VsphereVMName: "alice-web", Owner: "alice", Status: model.VMStatusRunning}},
+		Inventory: vsphere.NewSnapshot([]vsphere.VMState{{Owner: "alice", Name: "alice-web"}}, testNow),
+	}
+
+	if items := Detect(s, testNow, 0); len(items) != 0 {
+		t.Errorf("Expected no drift, got %+v", items)
+	}
+}
+
+func TestClusterName(t *testing.T) {
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{"k8s-cp", "k8s"},
+		{"k8s-worker-1", "k8s"},
+		{"my-worker-cluster-worker-12", "my-worker-cluster"},
+		{"dev-cp-cp", "dev-cp"},
+	}
+
+	for _, tt := range tests {
+		if got := ClusterName(tt.resource); got != tt.want {
+			t.Errorf("ClusterName(%s) = %s, expected %s", tt.resource, got, tt.want)
+		}
+	}
+}
+
+// =============================================================================
+// Tracker Tests
+// =============================================================================
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	if tracker.Report() != nil {
+		t.Error("Expected no report before the first check")
+	}
+
+	items := Detect(testState(), testNow, 0)
+	report, added := tracker.Update(items, testNow)
+	if report.Total != len(items) || len(added) != len(items) {
+		t.Fatalf("Expected %d new items, got %d of %d", len(items), len(added), report.Total)
+	}
+
+	// The second check keeps when the items were first seen and adds none
+	later := testNow.Add(time.Hour)
+	report, added = tracker.Update(Detect(testState(), later, 0), later)
+	if len(added) != 0 {
+		t.Errorf("Expected no new items, got %+v", added)
+	}
+	for _, item := range report.Items {
+		if !item.FirstSeenAt.Equal(testNow) {
+			t.Errorf("Expected %s first seen at %v, got %v", item.ID, testNow, item.FirstSeenAt)
+		}
+	}
+	if !report.CheckedAt.Equal(later) {
+		t.Errorf("Expected checked at %v, got %v", later, report.CheckedAt)
+	}
+
+	resolved := report.Items[0].ID
+	tracker.Resolve(resolved)
+	if got := tracker.Report(); got.Total != len(items)-1 {
+		t.Errorf("Expected %d items after resolving one, got %d", len(items)-1, got.Total)
+	}
+
+	// Drift that comes back after it was resolved is new again
+	_, added = tracker.Update(Detect(testState(), later, 0), later)
+	if len(added) != 1 || added[0].ID != resolved {
+		t.Errorf("Expected %s to be new again, got %+v", resolved, added)
+	}
+}
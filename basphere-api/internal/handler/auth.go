@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/store"
+)
+
+// tokenPrefix marks basphere API tokens so they are easy to spot in logs and secret scanners
+const tokenPrefix = "bsp_"
+
+type contextKey string
+
+const identityContextKey contextKey = "basphere-identity"
+
+// withIdentity returns a copy of ctx carrying the authenticated identity
+func withIdentity(ctx context.Context, identity *model.Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey, identity)
+}
+
+// currentIdentity returns the authenticated identity from the request context
+func currentIdentity(r *http.Request) *model.Identity {
+	identity, _ := r.Context().Value(identityContextKey).(*model.Identity)
+	return identity
+}
+
+// currentUser returns the authenticated username from the request context
+func currentUser(r *http.Request) string {
+	if identity := currentIdentity(r); identity != nil {
+		return identity.Username
+	}
+	return ""
+}
+
+// roleFor resolves the role of an authenticated user
+func (h *Handler) roleFor(username string) model.Role {
+	if h.config.Auth.IsAdmin(username) {
+		return model.RoleAdmin
+	}
+	return model.RoleUser
+}
+
+// authenticate is a middleware that resolves the bearer token into a user identity
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.tokenStore == nil {
+			h.jsonError(w, http.StatusServiceUnavailable, "Authentication is not available")
+			return
+		}
+
+		raw := bearerToken(r)
+		if raw == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="basphere"`)
+			h.jsonError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		token, err := h.tokenStore.GetByToken(raw)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="basphere", error="invalid_token"`)
+			h.jsonError(w, http.StatusUnauthorized, "Invalid API token")
+			return
+		}
+
+		if token.IsExpired(time.Now()) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="basphere", error="invalid_token"`)
+			h.jsonError(w, http.StatusUnauthorized, "API token expired")
+			return
+		}
+
+		identity := &model.Identity{
+			Username:       token.Username,
+			Role:           h.roleFor(token.Username),
+			TokenExpiresAt: token.ExpiresAt,
+		}
+		next.ServeHTTP(w, r.WithContext(withIdentity(r.Context(), identity)))
+	})
+}
+
+// requireAdmin is a middleware that only lets admin identities through
+// It must be mounted after authenticate
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !currentIdentity(r).IsAdmin() {
+			h.jsonError(w, http.StatusForbidden, "Admin role required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenUsername returns the owner of the request's API token, or "" if it carries no valid token
+// Used by public endpoints that accept, but do not require, an authenticated caller
+func (h *Handler) tokenUsername(r *http.Request) string {
+	raw := bearerToken(r)
+	if raw == "" || h.tokenStore == nil {
+		return ""
+	}
+
+	token, err := h.tokenStore.GetByToken(raw)
+	if err != nil || token.IsExpired(time.Now()) {
+		return ""
+	}
+	return token.Username
+}
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) >= 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+
+	// The web terminal's WebSocket handshake carries the token as a subprotocol
+	if websocket.IsWebSocketUpgrade(r) {
+		for _, protocol := range websocket.Subprotocols(r) {
+			if strings.HasPrefix(protocol, terminalTokenPrefix) {
+				return strings.TrimPrefix(protocol, terminalTokenPrefix)
+			}
+		}
+	}
+	return ""
+}
+
+// IssueToken creates and stores a new API token for a user
+// The raw token is returned once and cannot be recovered afterwards
+func (h *Handler) IssueToken(username, description string, ttl time.Duration) (string, *model.APIToken, error) {
+	var expiresAt *time.Time
+	if ttl > 0 {
+		at := time.Now().Add(ttl)
+		expiresAt = &at
+	}
+	return h.issueToken(username, description, expiresAt)
+}
+
+// issueToken creates a token expiring at expiresAt (nil = never)
+func (h *Handler) issueToken(username, description string, expiresAt *time.Time) (string, *model.APIToken, error) {
+	if h.tokenStore == nil {
+		return "", nil, fmt.Errorf("token store is not available")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	raw := tokenPrefix + hex.EncodeToString(buf)
+
+	token := &model.APIToken{
+		ID:          generateTokenID(),
+		Username:    username,
+		TokenHash:   store.HashToken(raw),
+		Description: description,
+		CreatedAt:   time.Now(),
+		ExpiresAt:   expiresAt,
+	}
+
+	if err := h.tokenStore.Create(token); err != nil {
+		return "", nil, err
+	}
+
+	return raw, token, nil
+}
+
+func generateTokenID() string {
+	return "tok-" + uuid.New().String()[:8]
+}
+
+// Token API handlers
+
+// apiCreateToken handles POST /api/v1/tokens
+func (h *Handler) apiCreateToken(w http.ResponseWriter, r *http.Request) {
+	username := currentUser(r)
+
+	var input model.CreateTokenInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+			return
+		}
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	var expiresAt *time.Time
+	if input.ExpiresInDays > 0 {
+		at := time.Now().Add(time.Duration(input.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &at
+	}
+
+	// A token cannot outlive the token it was issued with, so a leaked short-lived
+	// token cannot be turned into permanent access
+	if limit := currentIdentity(r).TokenExpiresAt; limit != nil {
+		if expiresAt == nil || expiresAt.After(*limit) {
+			at := *limit
+			expiresAt = &at
+		}
+	}
+
+	raw, token, err := h.issueToken(username, input.Description, expiresAt)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to issue token", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "Token issued. Store it now; it will not be shown again", model.CreateTokenResponse{
+		Token: raw,
+		Info:  token.Info(),
+	})
+}
+
+// apiListTokens handles GET /api/v1/tokens
+func (h *Handler) apiListTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.tokenStore.ListByUsername(currentUser(r))
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list tokens", err.Error())
+		return
+	}
+
+	infos := make([]model.TokenInfo, 0, len(tokens))
+	for _, token := range tokens {
+		infos = append(infos, token.Info())
+	}
+
+	h.jsonSuccess(w, "", infos)
+}
+
+// apiDeleteToken handles DELETE /api/v1/tokens/{id}
+func (h *Handler) apiDeleteToken(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.tokenStore.Delete(currentUser(r), id); err != nil {
+		h.jsonError(w, http.StatusNotFound, "Token not found", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "Token revoked", nil)
+}
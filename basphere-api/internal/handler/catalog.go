@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/basphere/basphere-api/internal/catalog"
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// specs returns the spec, OS and cluster type catalog
+func (h *Handler) specs() *catalog.Catalog {
+	if h.catalog == nil {
+		return &catalog.Catalog{}
+	}
+	return h.catalog
+}
+
+// Catalog API handlers
+
+// apiListSpecs handles GET /api/v1/specs
+func (h *Handler) apiListSpecs(w http.ResponseWriter, r *http.Request) {
+	specs := h.specs()
+	h.jsonSuccess(w, "", model.SpecListResponse{
+		VMSpecs:   specs.VMSpecList(),
+		NodeSpecs: specs.NodeSpecList(),
+	})
+}
+
+// apiListOS handles GET /api/v1/os
+func (h *Handler) apiListOS(w http.ResponseWriter, r *http.Request) {
+	list := h.specs().OSList()
+	if list == nil {
+		list = []model.OSInfo{}
+	}
+	h.jsonSuccess(w, "", list)
+}
+
+// apiListClusterTypes handles GET /api/v1/cluster-types
+func (h *Handler) apiListClusterTypes(w http.ResponseWriter, r *http.Request) {
+	list := h.specs().ClusterTypeList()
+	if list == nil {
+		list = []model.ClusterTypeInfo{}
+	}
+	h.jsonSuccess(w, "", list)
+}
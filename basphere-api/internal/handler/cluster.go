@@ -2,10 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/basphere/basphere-api/internal/labels"
+	"github.com/basphere/basphere-api/internal/logstream"
 	"github.com/basphere/basphere-api/internal/model"
 )
 
@@ -13,15 +16,11 @@ import (
 
 // apiCreateCluster handles POST /api/v1/clusters
 func (h *Handler) apiCreateCluster(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "Missing X-Basphere-User header")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -38,25 +37,66 @@ func (h *Handler) apiCreateCluster(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
-	if errors := input.Validate(); len(errors) > 0 {
+	// Validate input (type and worker spec must exist in the catalog)
+	errors := append(input.Validate(), h.specs().ValidateCluster(&input)...)
+	if len(errors) > 0 {
 		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
 		return
 	}
 
-	// Check quota
-	quota, err := h.provisioner.GetClusterQuota(username)
+	// Hold the owner's lock until the job is queued so the cluster counts as pending for the next request
+	defer h.lockOwner(username)()
+
+	// Check quota (VMs and clusters still being created by queued jobs count as used)
+	quota, err := h.getClusterQuota(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
+		return
+	}
+	vmQuota, err := h.getQuota(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
 		return
 	}
-	if quota.UsedClusters >= quota.MaxClusters {
+	pending, err := h.pendingUsage(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return
+	}
+	if quota.UsedClusters+pending.Clusters >= quota.MaxClusters {
 		h.jsonError(w, http.StatusForbidden, "Cluster quota exceeded")
 		return
 	}
 
+	layout := h.specs().ClusterLayout(input.Type, input.WorkerSpec)
+	if layout.Nodes() > quota.MaxNodesPerCluster {
+		h.jsonError(w, http.StatusForbidden, "Cluster node limit exceeded",
+			fmt.Sprintf("nodes: %d, max: %d", layout.Nodes(), quota.MaxNodesPerCluster))
+		return
+	}
+
+	// Every node takes one IP from the user's block
+	if vmQuota.UsedIPs+pending.IPs+layout.Nodes() > vmQuota.MaxIPs {
+		h.jsonError(w, http.StatusForbidden, "IP quota exceeded",
+			fmt.Sprintf("current: %d, in progress: %d, requested: %d, max: %d", vmQuota.UsedIPs, pending.IPs, layout.Nodes(), vmQuota.MaxIPs))
+		return
+	}
+
+	requested := h.specs().LayoutResources(layout)
+	if exceeded := vmQuota.ExceededResources(pending.Resources.Add(requested)); len(exceeded) > 0 {
+		h.jsonError(w, http.StatusForbidden, "Resource quota exceeded", exceeded...)
+		return
+	}
+
+	// Resolve the lease now so a queued job does not get extra lifetime
+	expiresAt, ok := h.resolveLease(w, model.LeaseKindCluster, input.Type, &input.LeaseInput)
+	if !ok {
+		return
+	}
+	input.LeaseInput = model.LeaseInput{ExpiresAt: expiresAt}
+
 	// Check if cluster already exists
-	clusterExists, err := h.provisioner.ClusterExists(username, input.Name)
+	clusterExists, err := h.provisioner.ClusterExists(r.Context(), username, input.Name)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check cluster", err.Error())
 		return
@@ -65,28 +105,28 @@ func (h *Handler) apiCreateCluster(w http.ResponseWriter, r *http.Request) {
 		h.jsonError(w, http.StatusConflict, "Cluster already exists")
 		return
 	}
+	if pending.ClusterNames[input.Name] {
+		h.jsonError(w, http.StatusConflict, "Cluster is already being created")
+		return
+	}
 
-	// Create cluster
-	cluster, err := h.provisioner.CreateCluster(username, &input)
+	// Create cluster in the background; progress is reported through the job
+	job, err := h.jobs.Submit(model.JobTypeCreateCluster, username, input)
 	if err != nil {
-		h.jsonError(w, http.StatusInternalServerError, "Failed to create cluster", err.Error())
+		h.jsonError(w, http.StatusInternalServerError, "Failed to queue cluster creation", err.Error())
 		return
 	}
 
-	h.jsonSuccess(w, "Cluster creation started", cluster)
+	h.acceptJob(w, "Cluster creation queued", job)
 }
 
 // apiListClusters handles GET /api/v1/clusters
 func (h *Handler) apiListClusters(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "Missing X-Basphere-User header")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -96,15 +136,26 @@ func (h *Handler) apiListClusters(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	selector, ok := h.labelSelector(w, r)
+	if !ok {
+		return
+	}
+
 	// List clusters
-	clusters, err := h.provisioner.ListClusters(username)
+	clusters, err := h.provisioner.ListClusters(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to list clusters", err.Error())
 		return
 	}
+	clusters = filterClusters(clusters, selector)
+
+	expiries := h.leaseExpiries(username, model.LeaseKindCluster)
+	for i := range clusters {
+		clusters[i].ExpiresAt = expiries[clusters[i].Name]
+	}
 
 	// Get quota
-	quota, err := h.provisioner.GetClusterQuota(username)
+	quota, err := h.getClusterQuota(r.Context(), username)
 	if err != nil {
 		quota = &model.ClusterQuota{} // Default empty quota on error
 	}
@@ -118,19 +169,30 @@ func (h *Handler) apiListClusters(w http.ResponseWriter, r *http.Request) {
 	h.jsonSuccess(w, "", response)
 }
 
+// filterClusters returns the clusters whose labels match the selector
+func filterClusters(clusters []model.Cluster, selector labels.Selector) []model.Cluster {
+	if selector.Empty() {
+		return clusters
+	}
+
+	matched := []model.Cluster{}
+	for _, c := range clusters {
+		if selector.Matches(c.Labels) {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
 // apiGetCluster handles GET /api/v1/clusters/{name}
 func (h *Handler) apiGetCluster(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "Missing X-Basphere-User header")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	clusterName := chi.URLParam(r, "name")
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -141,28 +203,64 @@ func (h *Handler) apiGetCluster(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get cluster
-	cluster, err := h.provisioner.GetCluster(username, clusterName)
+	cluster, err := h.provisioner.GetCluster(r.Context(), username, clusterName)
 	if err != nil {
 		h.jsonError(w, http.StatusNotFound, "Cluster not found", err.Error())
 		return
 	}
+	cluster.ExpiresAt = h.leaseExpiries(username, model.LeaseKindCluster)[cluster.Name]
 
 	h.jsonSuccess(w, "", cluster)
 }
 
-// apiDeleteCluster handles DELETE /api/v1/clusters/{name}
-func (h *Handler) apiDeleteCluster(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "Missing X-Basphere-User header")
+// apiUpdateCluster handles PATCH /api/v1/clusters/{name}
+func (h *Handler) apiUpdateCluster(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	clusterName := chi.URLParam(r, "name")
+
+	var input model.UpdateClusterInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	cluster, err := h.provisioner.GetCluster(r.Context(), username, clusterName)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "Cluster not found", err.Error())
+		return
+	}
+
+	newLabels, description := input.Apply(cluster.Labels, cluster.Description)
+	if errors := labels.Validate(newLabels); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	cluster, err = h.provisioner.UpdateClusterMetadata(r.Context(), username, clusterName, newLabels, description)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to update cluster", err.Error())
 		return
 	}
 
+	h.jsonSuccess(w, "Cluster updated", cluster)
+}
+
+// apiDeleteCluster handles DELETE /api/v1/clusters/{name}
+func (h *Handler) apiDeleteCluster(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
 	clusterName := chi.URLParam(r, "name")
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -173,7 +271,7 @@ func (h *Handler) apiDeleteCluster(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if cluster exists
-	clusterExists, err := h.provisioner.ClusterExists(username, clusterName)
+	clusterExists, err := h.provisioner.ClusterExists(r.Context(), username, clusterName)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check cluster", err.Error())
 		return
@@ -183,28 +281,29 @@ func (h *Handler) apiDeleteCluster(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete cluster
-	if err := h.provisioner.DeleteCluster(username, clusterName); err != nil {
+	// Delete cluster (not interrupted by a client disconnect, see detachedContext)
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	if err := h.provisioner.DeleteCluster(ctx, username, clusterName); err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to delete cluster", err.Error())
 		return
 	}
+	h.removeLog(username, logstream.KindCluster, clusterName)
+	h.dropLease(username, model.LeaseKindCluster, clusterName)
 
 	h.jsonSuccess(w, "Cluster deletion started", nil)
 }
 
 // apiGetKubeconfig handles GET /api/v1/clusters/{name}/kubeconfig
 func (h *Handler) apiGetKubeconfig(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "Missing X-Basphere-User header")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	clusterName := chi.URLParam(r, "name")
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -215,7 +314,7 @@ func (h *Handler) apiGetKubeconfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if cluster exists
-	clusterExists, err := h.provisioner.ClusterExists(username, clusterName)
+	clusterExists, err := h.provisioner.ClusterExists(r.Context(), username, clusterName)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check cluster", err.Error())
 		return
@@ -230,7 +329,7 @@ func (h *Handler) apiGetKubeconfig(w http.ResponseWriter, r *http.Request) {
 	_ = refresh // TODO: Use refresh parameter to force kubeconfig extraction
 
 	// Get kubeconfig
-	kubeconfig, err := h.provisioner.GetKubeconfig(username, clusterName)
+	kubeconfig, err := h.provisioner.GetKubeconfig(r.Context(), username, clusterName)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to get kubeconfig", err.Error())
 		return
@@ -243,17 +342,13 @@ func (h *Handler) apiGetKubeconfig(w http.ResponseWriter, r *http.Request) {
 
 // apiGetClusterStatus handles GET /api/v1/clusters/{name}/status
 func (h *Handler) apiGetClusterStatus(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "Missing X-Basphere-User header")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	clusterName := chi.URLParam(r, "name")
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -264,7 +359,7 @@ func (h *Handler) apiGetClusterStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get cluster status
-	cluster, err := h.provisioner.GetCluster(username, clusterName)
+	cluster, err := h.provisioner.GetCluster(r.Context(), username, clusterName)
 	if err != nil {
 		h.jsonError(w, http.StatusNotFound, "Cluster not found", err.Error())
 		return
@@ -280,15 +375,11 @@ func (h *Handler) apiGetClusterStatus(w http.ResponseWriter, r *http.Request) {
 
 // apiGetClusterQuota handles GET /api/v1/clusters/quota
 func (h *Handler) apiGetClusterQuota(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "Missing X-Basphere-User header")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -299,7 +390,7 @@ func (h *Handler) apiGetClusterQuota(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get quota
-	quota, err := h.provisioner.GetClusterQuota(username)
+	quota, err := h.getClusterQuota(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
 		return
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// diskTarget returns the VM named in the URL if its data disks can be changed right now
+// It writes the error response and returns nil otherwise.
+func (h *Handler) diskTarget(w http.ResponseWriter, r *http.Request, username string) *model.VM {
+	vmName := chi.URLParam(r, "name")
+
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return nil
+	}
+
+	if vm.Status != model.VMStatusRunning && vm.Status != model.VMStatusStopped {
+		h.jsonError(w, http.StatusConflict, "VM is not ready", fmt.Sprintf("VM is %s", vm.Status))
+		return nil
+	}
+
+	// Only one Terraform apply may run against the VM at a time
+	inProgress, err := h.updateInProgress(username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return nil
+	}
+	if inProgress {
+		h.jsonError(w, http.StatusConflict, "VM update already in progress", vmName)
+		return nil
+	}
+
+	return vm
+}
+
+// checkNoSnapshots writes a 409 and returns false if the VM has snapshots
+// vSphere cannot extend or remove a disk that is part of a snapshot chain, and a revert
+// would roll back an attached disk or new spec that the VM record and Terraform keep.
+func (h *Handler) checkNoSnapshots(w http.ResponseWriter, r *http.Request, username string, vm *model.VM, action string) bool {
+	snapshots, err := h.provisioner.ListSnapshots(r.Context(), username, vm.Name)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list snapshots", err.Error())
+		return false
+	}
+	if len(snapshots) > 0 {
+		h.jsonError(w, http.StatusConflict, "VM has snapshots",
+			fmt.Sprintf("delete the %d snapshot(s) of %s before %s", len(snapshots), vm.Name, action))
+		return false
+	}
+	return true
+}
+
+// checkDiskQuota writes a 403 and returns false if adding sizeGB would exceed the user's disk quota
+func (h *Handler) checkDiskQuota(w http.ResponseWriter, r *http.Request, username string, sizeGB int) bool {
+	quota, err := h.getQuota(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
+		return false
+	}
+
+	pending, err := h.pendingUsage(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return false
+	}
+
+	if exceeded := quota.ExceededResources(pending.Resources.Add(model.Resources{DiskGB: sizeGB})); len(exceeded) > 0 {
+		h.jsonError(w, http.StatusForbidden, "Resource quota exceeded", exceeded...)
+		return false
+	}
+	return true
+}
+
+// Disk API handlers
+
+// apiListDisks handles GET /api/v1/vms/{name}/disks
+func (h *Handler) apiListDisks(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+	vmName := chi.URLParam(r, "name")
+
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return
+	}
+
+	disks := vm.DataDisks
+	if disks == nil {
+		disks = []model.Disk{}
+	}
+
+	h.jsonSuccess(w, "", model.DiskListResponse{
+		Disks:   disks,
+		Total:   len(disks),
+		TotalGB: vm.DataDiskGB(),
+	})
+}
+
+// apiAttachDisk handles POST /api/v1/vms/{name}/disks
+func (h *Handler) apiAttachDisk(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	var input model.AttachDiskInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	vm := h.diskTarget(w, r, username)
+	if vm == nil {
+		return
+	}
+
+	if vm.FindDataDisk(input.Name) != nil {
+		h.jsonError(w, http.StatusConflict, "Disk already exists", input.Name)
+		return
+	}
+	if len(vm.DataDisks) >= model.MaxDataDisks {
+		h.jsonError(w, http.StatusConflict, "Too many disks",
+			fmt.Sprintf("a VM can have at most %d data disks", model.MaxDataDisks))
+		return
+	}
+	if !h.checkNoSnapshots(w, r, username, vm, "attaching a disk") {
+		return
+	}
+
+	if !h.checkDiskQuota(w, r, username, input.SizeGB) {
+		return
+	}
+
+	// Terraform applies the change, so it runs in the background like a resize
+	job, err := h.jobs.Submit(model.JobTypeVMDisk, username, model.DiskJobInput{
+		VM:     vm.Name,
+		Action: model.DiskActionAttach,
+		Disk:   input.Name,
+		SizeGB: input.SizeGB,
+		Added:  model.Resources{DiskGB: input.SizeGB},
+	})
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to queue disk attach", err.Error())
+		return
+	}
+
+	h.acceptJob(w, "Disk attach queued", job)
+}
+
+// apiResizeDisk handles PATCH /api/v1/vms/{name}/disks/{disk}
+func (h *Handler) apiResizeDisk(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+	diskName := chi.URLParam(r, "disk")
+
+	var input model.ResizeDiskInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	vm := h.diskTarget(w, r, username)
+	if vm == nil {
+		return
+	}
+
+	disk := vm.FindDataDisk(diskName)
+	if disk == nil {
+		h.jsonError(w, http.StatusNotFound, "Disk not found", diskName)
+		return
+	}
+
+	// Disks can only grow
+	if input.SizeGB <= disk.SizeGB {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed",
+			fmt.Sprintf("size_gb must be larger than the current size (%d GB)", disk.SizeGB))
+		return
+	}
+
+	if !h.checkNoSnapshots(w, r, username, vm, "growing a disk") {
+		return
+	}
+
+	added := input.SizeGB - disk.SizeGB
+	if !h.checkDiskQuota(w, r, username, added) {
+		return
+	}
+
+	job, err := h.jobs.Submit(model.JobTypeVMDisk, username, model.DiskJobInput{
+		VM:     vm.Name,
+		Action: model.DiskActionGrow,
+		Disk:   diskName,
+		SizeGB: input.SizeGB,
+		Added:  model.Resources{DiskGB: added},
+	})
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to queue disk resize", err.Error())
+		return
+	}
+
+	h.acceptJob(w, "Disk resize queued", job)
+}
+
+// apiDetachDisk handles DELETE /api/v1/vms/{name}/disks/{disk}
+// The disk and its data are deleted.
+func (h *Handler) apiDetachDisk(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+	diskName := chi.URLParam(r, "disk")
+
+	vm := h.diskTarget(w, r, username)
+	if vm == nil {
+		return
+	}
+
+	disk := vm.FindDataDisk(diskName)
+	if disk == nil {
+		h.jsonError(w, http.StatusNotFound, "Disk not found", diskName)
+		return
+	}
+
+	if !h.checkNoSnapshots(w, r, username, vm, "removing a disk") {
+		return
+	}
+
+	job, err := h.jobs.Submit(model.JobTypeVMDisk, username, model.DiskJobInput{
+		VM:     vm.Name,
+		Action: model.DiskActionDetach,
+		Disk:   diskName,
+		Added:  model.Resources{DiskGB: -disk.SizeGB},
+	})
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to queue disk detach", err.Error())
+		return
+	}
+
+	h.acceptJob(w, "Disk detach queued", job)
+}
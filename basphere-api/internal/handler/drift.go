@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/basphere/basphere-api/internal/drift"
+	"github.com/basphere/basphere-api/internal/ipam"
+	"github.com/basphere/basphere-api/internal/logstream"
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/vsphere"
+)
+
+// runDriftCheck checks for drift every interval until the server shuts down
+func (h *Handler) runDriftCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, _, err := h.checkDrift(h.lifetime); err != nil && h.lifetime.Err() == nil {
+			log.Printf("Warning: drift check failed: %v", err)
+		}
+
+		select {
+		case <-h.lifetime.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDrift compares the VM records with a fresh vCenter read and the IP leases
+// It updates the drift report, logs drift not seen before and returns the vCenter read
+// the report is based on.
+func (h *Handler) checkDrift(ctx context.Context) (*model.DriftReport, *vsphere.Snapshot, error) {
+	snapshot, err := h.inventory.Refresh(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read vSphere inventory: %w", err)
+	}
+
+	users, err := h.records.Users()
+	if err != nil {
+		return nil, nil, err
+	}
+	var records []model.VM
+	for _, user := range users {
+		vms, err := h.records.ListVMs(user)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, vms...)
+	}
+
+	var leases []ipam.Lease
+	if h.ipam != nil {
+		if leases, err = h.ipam.Leases(""); err != nil {
+			return nil, nil, fmt.Errorf("failed to list IP leases: %w", err)
+		}
+	}
+
+	state := &drift.State{
+		Records:   records,
+		Inventory: snapshot,
+		Leases:    leases,
+		ClusterExists: func(owner, name string) bool {
+			exists, err := h.provisioner.ClusterExists(ctx, owner, name)
+			if err != nil {
+				log.Printf("Warning: failed to check cluster %s/%s: %v", owner, name, err)
+				return true
+			}
+			return exists
+		},
+	}
+
+	// A VM or cluster being created holds its IP before its record is written
+	timeouts := h.config.Provisioner.Timeouts
+	grace := max(timeouts.CreateVM, timeouts.CreateCluster)
+
+	now := time.Now()
+	report, added := h.drift.Update(drift.Detect(state, now, grace), now)
+	for _, item := range added {
+		log.Printf("Drift found: %s %s", item.Kind, driftSubject(&item))
+	}
+
+	return report, snapshot, nil
+}
+
+// driftSubject names the record, VM or IP of a drift item for log messages
+func driftSubject(item *model.DriftItem) string {
+	switch item.Kind {
+	case model.DriftKindUnmanagedVM:
+		return item.Owner + "/" + item.VsphereVMName
+	case model.DriftKindOrphanIP:
+		return fmt.Sprintf("%s (%s/%s)", item.IPAddress, item.Owner, item.Name)
+	default:
+		return item.Owner + "/" + item.Name
+	}
+}
+
+// Drift API handlers
+
+// apiAdminDrift handles GET /api/v1/admin/drift
+func (h *Handler) apiAdminDrift(w http.ResponseWriter, r *http.Request) {
+	if h.inventory == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "vSphere inventory not available")
+		return
+	}
+
+	report, _, err := h.checkDrift(r.Context())
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check drift", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "", report)
+}
+
+// apiAdminDriftAction handles POST /api/v1/admin/drift/{id}/actions
+// The item is looked up in a fresh check, so an action never applies to drift that is already gone.
+func (h *Handler) apiAdminDriftAction(w http.ResponseWriter, r *http.Request) {
+	var input model.DriftActionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	if h.inventory == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "vSphere inventory not available")
+		return
+	}
+
+	report, snapshot, err := h.checkDrift(r.Context())
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check drift", err.Error())
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	var item *model.DriftItem
+	for i := range report.Items {
+		if report.Items[i].ID == id {
+			item = &report.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		h.jsonError(w, http.StatusNotFound, "Drift item not found", id)
+		return
+	}
+
+	if !item.Kind.Allows(input.Action) {
+		h.jsonError(w, http.StatusConflict, "Action not allowed",
+			fmt.Sprintf("cannot %s a %s item", input.Action, item.Kind))
+		return
+	}
+
+	// Not interrupted by a client disconnect, see detachedContext
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	result := model.DriftActionResult{Item: *item, Action: input.Action}
+	var ok bool
+	switch item.Kind {
+	case model.DriftKindMissingVM:
+		result.VM, ok = h.resolveMissingVM(ctx, w, item, input.Action)
+	case model.DriftKindUnmanagedVM:
+		result.VM, ok = h.resolveUnmanagedVM(ctx, w, item, snapshot, &input)
+	case model.DriftKindOrphanIP:
+		ok = h.releaseOrphanIP(w, item)
+	}
+	if !ok {
+		return
+	}
+
+	h.drift.Resolve(item.ID)
+	log.Printf("Drift resolved by %s: %s %s (%s)", currentUser(r), item.Kind, driftSubject(item), input.Action)
+
+	h.jsonSuccess(w, "Drift resolved", result)
+}
+
+// resolveMissingVM deletes a record without a VM, or marks it failed
+// It writes the error response and returns false if the action failed.
+func (h *Handler) resolveMissingVM(ctx context.Context, w http.ResponseWriter, item *model.DriftItem, action model.DriftAction) (*model.VM, bool) {
+	if action == model.DriftActionMarkFailed {
+		vm, err := h.records.GetVM(item.Owner, item.Name)
+		if err != nil {
+			h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+			return nil, false
+		}
+		vm.Status = model.VMStatusFailed
+		if err := h.records.SaveVM(vm); err != nil {
+			h.jsonError(w, http.StatusInternalServerError, "Failed to save VM", err.Error())
+			return nil, false
+		}
+		return vm, true
+	}
+
+	// Terraform owns the VM while it applies a resize or disk change
+	inProgress, err := h.updateInProgress(item.Owner, item.Name)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return nil, false
+	}
+	if inProgress {
+		h.jsonError(w, http.StatusConflict, "VM update in progress", item.Name)
+		return nil, false
+	}
+
+	// The provisioner removes the record, the Terraform state and the IP lease of a VM that is already gone
+	if err := h.provisioner.DeleteVM(ctx, item.Owner, item.Name); err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to delete VM", err.Error())
+		return nil, false
+	}
+	h.removeLog(item.Owner, logstream.KindVM, item.Name)
+	h.dropLease(item.Owner, model.LeaseKindVM, item.Name)
+	return nil, true
+}
+
+// resolveUnmanagedVM writes a record for a VM without one (adopt, or mark_failed), or destroys it
+// An adopted VM's guest IP is leased to it, unless it is outside the managed network.
+// It writes the error response and returns false if the action failed.
+func (h *Handler) resolveUnmanagedVM(ctx context.Context, w http.ResponseWriter, item *model.DriftItem, snapshot *vsphere.Snapshot, input *model.DriftActionInput) (*model.VM, bool) {
+	if input.Action == model.DriftActionCleanup {
+		state, _ := snapshot.Lookup(item.Owner, item.VsphereVMName)
+		if err := h.inventory.Destroy(ctx, state); err != nil {
+			h.jsonError(w, http.StatusInternalServerError, "Failed to destroy VM", err.Error())
+			return nil, false
+		}
+		return nil, true
+	}
+
+	name, ok := input.VMName(item)
+	if !ok {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed",
+			fmt.Sprintf("name is required: %s is not a valid VM name", name))
+		return nil, false
+	}
+	if input.Spec != "" {
+		if errors := h.catalog.ValidateVMSpec(input.Spec); len(errors) > 0 {
+			h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+			return nil, false
+		}
+	}
+	if _, err := h.records.GetVM(item.Owner, name); err == nil {
+		h.jsonError(w, http.StatusConflict, "VM already exists", name)
+		return nil, false
+	}
+
+	status, ok := model.PowerStateStatus(item.PowerState)
+	if !ok || input.Action == model.DriftActionMarkFailed {
+		status = model.VMStatusFailed
+	}
+	vm := &model.VM{
+		Name:          name,
+		VsphereVMName: item.VsphereVMName,
+		Owner:         item.Owner,
+		OS:            input.OS,
+		LoginUser:     input.LoginUser,
+		Spec:          input.Spec,
+		IPAddress:     item.IPAddress,
+		Status:        status,
+		CreatedAt:     time.Now(),
+	}
+
+	// Keep IPAM from handing the adopted VM's address to a new VM
+	var lease *ipam.Lease
+	if ip, err := netip.ParseAddr(item.IPAddress); err == nil && h.ipam != nil {
+		var created bool
+		lease, created, err = h.ipam.LeaseIP(ip, item.Owner, name, "vm")
+		if err != nil && !errors.Is(err, ipam.ErrOutsideNetwork) {
+			h.jsonError(w, http.StatusConflict, "Failed to lease IP", err.Error())
+			return nil, false
+		}
+		if !created {
+			lease = nil
+		}
+	}
+
+	if err := h.records.SaveVM(vm); err != nil {
+		if lease != nil {
+			h.ipam.ReleaseIP(lease.IP, item.Owner)
+		}
+		h.jsonError(w, http.StatusInternalServerError, "Failed to save VM", err.Error())
+		return nil, false
+	}
+	return vm, true
+}
+
+// releaseOrphanIP releases an IP lease whose VM or cluster is gone
+// It writes the error response and returns false if the release failed.
+func (h *Handler) releaseOrphanIP(w http.ResponseWriter, item *model.DriftItem) bool {
+	if h.ipam == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "IPAM not available")
+		return false
+	}
+
+	ip, err := netip.ParseAddr(item.IPAddress)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Invalid IP address", err.Error())
+		return false
+	}
+	if _, err := h.ipam.ReleaseIP(ip, item.Owner); err != nil && !errors.Is(err, ipam.ErrLeaseNotFound) {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to release IP", err.Error())
+		return false
+	}
+	return true
+}
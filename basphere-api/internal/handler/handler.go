@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -9,29 +10,66 @@ import (
 	"net/url"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 
+	"github.com/basphere/basphere-api/internal/catalog"
 	"github.com/basphere/basphere-api/internal/config"
+	"github.com/basphere/basphere-api/internal/drift"
+	"github.com/basphere/basphere-api/internal/ipam"
+	"github.com/basphere/basphere-api/internal/jobs"
+	"github.com/basphere/basphere-api/internal/logstream"
 	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/oidc"
 	"github.com/basphere/basphere-api/internal/provisioner"
+	"github.com/basphere/basphere-api/internal/quota"
+	"github.com/basphere/basphere-api/internal/sshca"
 	"github.com/basphere/basphere-api/internal/store"
+	"github.com/basphere/basphere-api/internal/vsphere"
 )
 
 // Handler handles HTTP requests
 type Handler struct {
 	store          store.Store
-	keyChangeStore *store.KeyChangeStore
+	keyChangeStore store.KeyChangeStore
+	tokenStore     *store.TokenStore
+	jobs           *jobs.Manager
+	logs           *logstream.Store
+	ipam           *ipam.IPAM
+	quotas         *quota.Config
+	quotaStore     *store.QuotaStore
+	leases         *store.LeaseStore
+	catalog        *catalog.Catalog
 	provisioner    provisioner.Provisioner
+	inventory      *vsphere.Inventory
+	records        *provisioner.Records
+	drift          *drift.Tracker
+	sshCA          *sshca.Authority
+	oidc           *oidc.Client
+	sessions       *sessionStore
 	templates      *template.Template
-	config         *config.Config
+	// Static files for web pages (xterm.js)
+	assetDir string
+	config   *config.Config
+
+	// Cancelled by Close; bounds operations that outlive their request
+	lifetime     context.Context
+	stopLifetime context.CancelFunc
+
+	// Serializes read-modify-write of leases between renew requests, the reaper and expire jobs
+	leaseMu sync.Mutex
+
+	// Per-owner locks held from the quota and name checks until a create job is queued
+	ownerMu    sync.Mutex
+	ownerLocks map[string]*sync.Mutex
 }
 
 // NewHandler creates a new handler
-func NewHandler(s store.Store, prov provisioner.Provisioner, templateDir string, cfg *config.Config) (*Handler, error) {
+func NewHandler(s store.Store, kc store.KeyChangeStore, prov provisioner.Provisioner, templateDir string, cfg *config.Config) (*Handler, error) {
 	tmpl, err := template.ParseGlob(filepath.Join(templateDir, "*.html"))
 	if err != nil {
 		// Templates might not exist yet, that's okay
@@ -39,21 +77,192 @@ func NewHandler(s store.Store, prov provisioner.Provisioner, templateDir string,
 		tmpl = template.New("empty")
 	}
 
-	// Initialize key change store in the same base directory
-	keyChangeStore, err := store.NewKeyChangeStore(cfg.Storage.PendingDir)
+	// Initialize API token store in the same base directory
+	tokenStore, err := store.NewTokenStore(cfg.Storage.PendingDir)
+	if err != nil {
+		log.Printf("Warning: failed to initialize token store: %v", err)
+	}
+
+	// Initialize SSH certificate authority (optional)
+	var ca *sshca.Authority
+	if cfg.SSHCA.Enabled {
+		ca, err = sshca.LoadOrCreate(cfg.SSHCA.KeyPath)
+		if err != nil {
+			log.Printf("Warning: failed to initialize SSH CA: %v", err)
+		}
+	}
+
+	if cfg.Terminal.Enabled && cfg.Terminal.KnownHostsFile == "" {
+		log.Printf("Warning: web terminal disabled: terminal.known_hosts_file is required to verify the bastion")
+	}
+
+	// Initialize provisioning log store (optional)
+	logs, err := logstream.NewStore(cfg.Storage.LogDir)
+	if err != nil {
+		log.Printf("Warning: failed to initialize log store: %v", err)
+	}
+
+	// Initialize IPAM (optional: shares the CLI's allocation files)
+	ipamMgr, err := openIPAM(cfg.IPAM)
 	if err != nil {
-		log.Printf("Warning: failed to initialize key change store: %v", err)
+		log.Printf("Warning: failed to initialize IPAM: %v", err)
 	}
 
-	return &Handler{
+	// Initialize quota limits (a broken file falls back to the defaults rather than unlimited)
+	quotas, err := quota.LoadConfig(cfg.Quotas.ConfigFile)
+	if err != nil {
+		log.Printf("Warning: failed to load quotas, using defaults: %v", err)
+		quotas = &quota.Config{}
+	}
+
+	// Initialize quota override store (optional: without it admins cannot change quotas)
+	quotaStore, err := store.NewQuotaStore(cfg.Storage.PendingDir)
+	if err != nil {
+		log.Printf("Warning: failed to initialize quota store: %v", err)
+	}
+
+	// Initialize lease store (optional: without it resources cannot be given a lease)
+	leases, err := store.NewLeaseStore(cfg.Storage.PendingDir)
+	if err != nil {
+		log.Printf("Warning: failed to initialize lease store: %v", err)
+	}
+
+	// Initialize VM and cluster specs and OS templates (missing specs get the script defaults)
+	specs, err := catalog.Load(cfg.Catalog.SpecsFile, cfg.Catalog.ConfigFile)
+	if err != nil {
+		log.Printf("Warning: failed to load specs, using defaults: %v", err)
+		specs = &catalog.Catalog{}
+	}
+
+	// Initialize live VM state from vCenter (optional: responses fall back to the stored records)
+	inventory, err := openInventory(cfg)
+	if err != nil {
+		log.Printf("Warning: failed to initialize vSphere inventory: %v", err)
+	}
+
+	// Initialize OIDC login for the web portal (optional)
+	// Unlike the optional stores above, a broken OIDC setup is fatal: the portal must not fall back to anonymous forms
+	var oidcClient *oidc.Client
+	var sessions *sessionStore
+	if cfg.OIDC.Enabled {
+		oidcClient, err = oidc.NewClient(context.Background(), oidc.Config{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sessions = newSessionStore(cfg.OIDC.SessionTTL)
+	}
+
+	// Initialize background jobs
+	// Required: VM and cluster creation only run as jobs
+	jobStore, err := store.NewJobStore(cfg.Storage.PendingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	lifetime, stopLifetime := context.WithCancel(context.Background())
+
+	h := &Handler{
 		store:          s,
-		keyChangeStore: keyChangeStore,
+		keyChangeStore: kc,
+		tokenStore:     tokenStore,
+		jobs:           jobs.NewManager(jobStore, cfg.Jobs.Workers),
+		logs:           logs,
+		ipam:           ipamMgr,
+		quotas:         quotas,
+		quotaStore:     quotaStore,
+		leases:         leases,
+		catalog:        specs,
 		provisioner:    prov,
+		inventory:      inventory,
+		records:        provisioner.NewRecords(provisioner.DataDir),
+		drift:          drift.NewTracker(),
+		sshCA:          ca,
+		oidc:           oidcClient,
+		sessions:       sessions,
 		templates:      tmpl,
+		assetDir:       filepath.Join(templateDir, "xterm"),
 		config:         cfg,
-	}, nil
+		lifetime:       lifetime,
+		stopLifetime:   stopLifetime,
+	}
+
+	h.registerJobRunners()
+
+	return h, nil
+}
+
+// Start prunes old jobs, starts the background job workers, the lease reaper and the drift check
+// Interrupted jobs from a previous run are resumed
+func (h *Handler) Start() error {
+	if h.config.Jobs.Retention > 0 {
+		if pruned, err := h.jobs.Prune(h.config.Jobs.Retention); err != nil {
+			log.Printf("Warning: failed to prune old jobs: %v", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d finished jobs", pruned)
+		}
+	}
+
+	if err := h.jobs.Start(); err != nil {
+		return err
+	}
+
+	if h.leases != nil && h.config.Leases.CheckInterval > 0 {
+		go h.runReaper(h.config.Leases.CheckInterval)
+	}
+
+	if h.inventory != nil && h.config.Drift.CheckInterval > 0 {
+		go h.runDriftCheck(h.config.Drift.CheckInterval)
+	}
+
+	if h.sessions != nil {
+		go h.runSessionPruning(sessionPruneInterval)
+	}
+
+	return nil
+}
+
+// Close cancels running provisioning operations and stops the background job workers
+// Cancelled scripts receive SIGTERM and run their cleanup; interrupted jobs resume on the next start
+func (h *Handler) Close() {
+	if h.stopLifetime != nil {
+		h.stopLifetime()
+	}
+	h.jobs.Stop()
 }
 
+// CloseStreams ends open log streams so an HTTP server shutdown does not wait for them
+func (h *Handler) CloseStreams() {
+	if h.logs != nil {
+		h.logs.Close()
+	}
+}
+
+// detachedContext returns a context for destructive operations that must not stop halfway
+// when the client disconnects or the request times out (a half-finished terraform destroy is
+// worse than a slow response). It is still cancelled when the server shuts down, and the
+// provisioner applies its own per-operation timeout.
+func (h *Handler) detachedContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	if h.lifetime == nil {
+		return ctx, cancel
+	}
+
+	stop := context.AfterFunc(h.lifetime, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// How long a request may take, except log streams and terminal sessions
+const requestTimeout = 60 * time.Second
+
 // Router returns the HTTP router
 func (h *Handler) Router() http.Handler {
 	r := chi.NewRouter()
@@ -63,55 +272,146 @@ func (h *Handler) Router() http.Handler {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(60 * time.Second))
-
-	// Web routes (HTML)
-	r.Get("/", h.indexPage)
-	r.Get("/register", h.registerPage)
-	r.Post("/register", h.registerFormSubmit)
-	r.Get("/success", h.successPage)
-	r.Get("/ssh-guide", h.sshGuidePage)
-	r.Get("/key-change", h.keyChangePage)
-	r.Post("/key-change", h.keyChangeFormSubmit)
-	r.Get("/key-change-success", h.keyChangeSuccessPage)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(requestTimeout))
+
+		// Web routes (HTML)
+		r.Get("/", h.indexPage)
+		r.Get("/register", h.registerPage)
+		r.Post("/register", h.registerFormSubmit)
+		r.Get("/success", h.successPage)
+		r.Get("/ssh-guide", h.sshGuidePage)
+		r.Get("/key-change", h.keyChangePage)
+		r.Post("/key-change", h.keyChangeFormSubmit)
+		r.Get("/key-change-success", h.keyChangeSuccessPage)
+		r.Get("/terminal", h.terminalPage)
+		r.Handle("/terminal/assets/*", http.StripPrefix("/terminal/assets/", http.FileServer(http.Dir(h.assetDir))))
+
+		// Web login (OIDC)
+		r.Get("/login", h.loginPage)
+		r.Get("/auth/callback", h.authCallback)
+		r.Get("/logout", h.logoutPage)
+
+		// Health check
+		r.Get("/health", h.healthCheck)
+	})
 
 	// API routes (JSON)
 	r.Route("/api/v1", func(r chi.Router) {
-		// User registration
-		r.Post("/register", h.apiRegister)
-		r.Get("/pending", h.apiListPending)
-		r.Get("/pending/{username}", h.apiGetPending)
-		r.Post("/users/{username}/approve", h.apiApprove)
-		r.Post("/users/{username}/reject", h.apiReject)
-
-		// Key change requests
-		r.Post("/key-change", h.apiKeyChangeRequest)
-		r.Get("/key-changes", h.apiListKeyChanges)
-		r.Get("/key-changes/{username}", h.apiGetKeyChange)
-		r.Post("/key-changes/{username}/approve", h.apiApproveKeyChange)
-		r.Post("/key-changes/{username}/reject", h.apiRejectKeyChange)
-
-		// VM management
-		r.Post("/vms", h.apiCreateVM)
-		r.Get("/vms", h.apiListVMs)
-		r.Get("/vms/{name}", h.apiGetVM)
-		r.Delete("/vms/{name}", h.apiDeleteVM)
-
-		// Quota
-		r.Get("/quota", h.apiGetQuota)
-
-		// Cluster management (Stage 2)
-		r.Post("/clusters", h.apiCreateCluster)
-		r.Get("/clusters", h.apiListClusters)
-		r.Get("/clusters/quota", h.apiGetClusterQuota)
-		r.Get("/clusters/{name}", h.apiGetCluster)
-		r.Delete("/clusters/{name}", h.apiDeleteCluster)
-		r.Get("/clusters/{name}/kubeconfig", h.apiGetKubeconfig)
-		r.Get("/clusters/{name}/status", h.apiGetClusterStatus)
-	})
+		// Log streams (SSE) stay open for as long as provisioning runs and terminal sessions
+		// (WebSocket) until the user leaves, so they are the only routes without the request timeout
+		r.Group(func(r chi.Router) {
+			r.Use(h.authenticate)
+
+			r.Get("/vms/{name}/logs", h.apiGetVMLogs)
+			r.Get("/vms/{name}/terminal", h.apiVMTerminal)
+			r.Get("/clusters/{name}/logs", h.apiGetClusterLogs)
+		})
 
-	// Health check
-	r.Get("/health", h.healthCheck)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(requestTimeout))
+
+			// User registration and key change requests (public)
+			r.Post("/register", h.apiRegister)
+			r.Post("/key-change", h.apiKeyChangeRequest)
+
+			// SSH CA public key for the bastion's TrustedUserCAKeys (public)
+			r.Get("/ssh/ca.pub", h.apiGetSSHCAPublicKey)
+
+			// Admin routes (approval workflow)
+			r.Group(func(r chi.Router) {
+				r.Use(h.authenticate)
+				r.Use(h.requireAdmin)
+
+				// User registration
+				r.Get("/pending", h.apiListPending)
+				r.Get("/pending/{username}", h.apiGetPending)
+				r.Post("/users/{username}/approve", h.apiApprove)
+				r.Post("/users/{username}/reject", h.apiReject)
+
+				// Key change requests
+				r.Get("/key-changes", h.apiListKeyChanges)
+				r.Get("/key-changes/{username}", h.apiGetKeyChange)
+				r.Post("/key-changes/{username}/approve", h.apiApproveKeyChange)
+				r.Post("/key-changes/{username}/reject", h.apiRejectKeyChange)
+
+				// IP allocation overview
+				r.Get("/admin/ipam", h.apiAdminIPAM)
+
+				// Drift between VM records, vCenter and IPAM
+				r.Get("/admin/drift", h.apiAdminDrift)
+				r.Post("/admin/drift/{id}/actions", h.apiAdminDriftAction)
+
+				// Quota overrides
+				r.Get("/admin/quotas", h.apiListQuotaOverrides)
+				r.Get("/admin/quotas/{username}", h.apiGetUserQuota)
+				r.Put("/admin/quotas/{username}", h.apiSetUserQuota)
+				r.Delete("/admin/quotas/{username}", h.apiDeleteUserQuota)
+			})
+
+			// Authenticated routes (identity comes from the API token)
+			r.Group(func(r chi.Router) {
+				r.Use(h.authenticate)
+
+				// API tokens
+				r.Post("/tokens", h.apiCreateToken)
+				r.Get("/tokens", h.apiListTokens)
+				r.Delete("/tokens/{id}", h.apiDeleteToken)
+
+				// SSH user certificates
+				r.Post("/ssh/cert", h.apiIssueSSHCert)
+
+				// OpenSSH client config for reaching VMs through the bastion
+				r.Get("/ssh-config", h.apiGetSSHConfig)
+
+				// VM management
+				r.Post("/vms", h.apiCreateVM)
+				r.Get("/vms", h.apiListVMs)
+				r.Delete("/vms", h.apiDeleteVMs)
+				r.Get("/vms/{name}", h.apiGetVM)
+				r.Patch("/vms/{name}", h.apiUpdateVM)
+				r.Delete("/vms/{name}", h.apiDeleteVM)
+				r.Post("/vms/{name}/renew", h.apiRenewVM)
+				r.Post("/vms/{name}/actions", h.apiVMAction)
+				r.Get("/vms/{name}/snapshots", h.apiListSnapshots)
+				r.Post("/vms/{name}/snapshots", h.apiCreateSnapshot)
+				r.Post("/vms/{name}/snapshots/{snapshot}/revert", h.apiRevertSnapshot)
+				r.Delete("/vms/{name}/snapshots/{snapshot}", h.apiDeleteSnapshot)
+				r.Get("/vms/{name}/disks", h.apiListDisks)
+				r.Post("/vms/{name}/disks", h.apiAttachDisk)
+				r.Patch("/vms/{name}/disks/{disk}", h.apiResizeDisk)
+				r.Delete("/vms/{name}/disks/{disk}", h.apiDetachDisk)
+
+				// Quota
+				r.Get("/quota", h.apiGetQuota)
+
+				// Spec, OS and cluster type catalog
+				r.Get("/specs", h.apiListSpecs)
+				r.Get("/os", h.apiListOS)
+				r.Get("/cluster-types", h.apiListClusterTypes)
+
+				// IP block and leases
+				r.Get("/ipam/block", h.apiGetIPBlock)
+				r.Get("/ipam/leases", h.apiListIPLeases)
+
+				// Background jobs (VM and cluster creation)
+				r.Get("/jobs", h.apiListJobs)
+				r.Get("/jobs/{id}", h.apiGetJob)
+
+				// Cluster management (Stage 2)
+				r.Post("/clusters", h.apiCreateCluster)
+				r.Get("/clusters", h.apiListClusters)
+				r.Get("/clusters/quota", h.apiGetClusterQuota)
+				r.Get("/clusters/{name}", h.apiGetCluster)
+				r.Patch("/clusters/{name}", h.apiUpdateCluster)
+				r.Delete("/clusters/{name}", h.apiDeleteCluster)
+				r.Post("/clusters/{name}/renew", h.apiRenewCluster)
+				r.Get("/clusters/{name}/kubeconfig", h.apiGetKubeconfig)
+				r.Get("/clusters/{name}/status", h.apiGetClusterStatus)
+			})
+		})
+	})
 
 	return r
 }
@@ -159,7 +459,15 @@ func (h *Handler) indexPage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) registerPage(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.requireSession(w, r)
+	if !ok {
+		return
+	}
+
 	data := map[string]interface{}{}
+	if sess != nil {
+		data["Identity"] = sess
+	}
 	if h.config.Recaptcha.Enabled && h.config.Recaptcha.SiteKey != "" {
 		data["RecaptchaSiteKey"] = h.config.Recaptcha.SiteKey
 	}
@@ -186,6 +494,11 @@ func (h *Handler) sshGuidePage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) registerFormSubmit(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.requireSession(w, r)
+	if !ok {
+		return
+	}
+
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
@@ -198,12 +511,21 @@ func (h *Handler) registerFormSubmit(w http.ResponseWriter, r *http.Request) {
 		PublicKey: r.FormValue("public_key"),
 	}
 
+	// Verified identity from the OIDC login overrides whatever the form posted
+	if sess != nil {
+		input.Email = sess.Email
+		input.FullName = sess.Name
+	}
+
 	// Helper function to render form with errors
 	renderWithErrors := func(errors []string) {
 		data := map[string]interface{}{
 			"Errors": errors,
 			"Input":  input,
 		}
+		if sess != nil {
+			data["Identity"] = sess
+		}
 		if h.config.Recaptcha.Enabled && h.config.Recaptcha.SiteKey != "" {
 			data["RecaptchaSiteKey"] = h.config.Recaptcha.SiteKey
 		}
@@ -237,7 +559,7 @@ func (h *Handler) registerFormSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create registration request
-	req, err := h.createRegistrationRequest(input)
+	req, err := h.createRegistrationRequest(r.Context(), input)
 	if err != nil {
 		renderWithErrors([]string{err.Error()})
 		return
@@ -306,12 +628,23 @@ func (h *Handler) validateEmailDomain(email string) error {
 // API handlers
 
 func (h *Handler) apiRegister(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.requireAPISession(w, r)
+	if !ok {
+		return
+	}
+
 	var input model.RegisterInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
 		return
 	}
 
+	// Same as the web form: the verified identity overrides whatever was posted
+	if sess != nil {
+		input.Email = sess.Email
+		input.FullName = sess.Name
+	}
+
 	if errors := input.Validate(); len(errors) > 0 {
 		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
 		return
@@ -323,7 +656,7 @@ func (h *Handler) apiRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req, err := h.createRegistrationRequest(&input)
+	req, err := h.createRegistrationRequest(r.Context(), &input)
 	if err != nil {
 		h.jsonError(w, http.StatusConflict, err.Error())
 		return
@@ -358,12 +691,6 @@ func (h *Handler) apiGetPending(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) apiApprove(w http.ResponseWriter, r *http.Request) {
 	username := chi.URLParam(r, "username")
 
-	var input model.ApproveInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		// Allow empty body, default to "admin"
-		input.ProcessedBy = "admin"
-	}
-
 	req, err := h.store.GetByUsername(username)
 	if err != nil {
 		h.jsonError(w, http.StatusNotFound, "Request not found", err.Error())
@@ -376,7 +703,7 @@ func (h *Handler) apiApprove(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if system user already exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -387,14 +714,14 @@ func (h *Handler) apiApprove(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Provision the user
-	if err := h.provisioner.CreateUser(req); err != nil {
+	if err := h.provisioner.CreateUser(r.Context(), req, h.bastionAuthorizedKey(req.Username, req.PublicKey)); err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to create user", err.Error())
 		return
 	}
 
 	// Update request status
 	req.Status = model.StatusApproved
-	req.ProcessedBy = input.ProcessedBy
+	req.ProcessedBy = currentUser(r)
 	req.ProcessedAt = time.Now().Format(time.RFC3339)
 	req.UpdatedAt = time.Now()
 
@@ -409,10 +736,9 @@ func (h *Handler) apiApprove(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) apiReject(w http.ResponseWriter, r *http.Request) {
 	username := chi.URLParam(r, "username")
 
+	// Body is optional (reason only)
 	var input model.RejectInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		input.ProcessedBy = "admin"
-	}
+	json.NewDecoder(r.Body).Decode(&input)
 
 	req, err := h.store.GetByUsername(username)
 	if err != nil {
@@ -427,7 +753,7 @@ func (h *Handler) apiReject(w http.ResponseWriter, r *http.Request) {
 
 	// Update request status
 	req.Status = model.StatusRejected
-	req.ProcessedBy = input.ProcessedBy
+	req.ProcessedBy = currentUser(r)
 	req.ProcessedAt = time.Now().Format(time.RFC3339)
 	req.RejectReason = input.Reason
 	req.UpdatedAt = time.Now()
@@ -442,7 +768,7 @@ func (h *Handler) apiReject(w http.ResponseWriter, r *http.Request) {
 
 // Helper methods
 
-func (h *Handler) createRegistrationRequest(input *model.RegisterInput) (*model.RegistrationRequest, error) {
+func (h *Handler) createRegistrationRequest(ctx context.Context, input *model.RegisterInput) (*model.RegistrationRequest, error) {
 	// Check if username already has pending request
 	exists, err := h.store.ExistsUsername(input.Username)
 	if err != nil {
@@ -462,7 +788,7 @@ func (h *Handler) createRegistrationRequest(input *model.RegisterInput) (*model.
 	}
 
 	// Check if system user already exists
-	userExists, err := h.provisioner.UserExists(input.Username)
+	userExists, err := h.provisioner.UserExists(ctx, input.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -475,6 +801,7 @@ func (h *Handler) createRegistrationRequest(input *model.RegisterInput) (*model.
 		ID:        generateID(),
 		Username:  input.Username,
 		Email:     input.Email,
+		FullName:  input.FullName,
 		Team:      input.Team,
 		PublicKey: input.PublicKey,
 		Status:    model.StatusPending,
@@ -500,7 +827,15 @@ func generateKeyChangeID() string {
 // Key change web pages
 
 func (h *Handler) keyChangePage(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.requireSession(w, r)
+	if !ok {
+		return
+	}
+
 	data := map[string]interface{}{}
+	if sess != nil {
+		data["Identity"] = sess
+	}
 	if h.config.Recaptcha.Enabled && h.config.Recaptcha.SiteKey != "" {
 		data["RecaptchaSiteKey"] = h.config.Recaptcha.SiteKey
 	}
@@ -519,6 +854,11 @@ func (h *Handler) keyChangeSuccessPage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) keyChangeFormSubmit(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.requireSession(w, r)
+	if !ok {
+		return
+	}
+
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
@@ -531,11 +871,19 @@ func (h *Handler) keyChangeFormSubmit(w http.ResponseWriter, r *http.Request) {
 		Reason:       r.FormValue("reason"),
 	}
 
+	// Verified email from the OIDC login overrides whatever the form posted
+	if sess != nil {
+		input.Email = sess.Email
+	}
+
 	renderWithErrors := func(errors []string) {
 		data := map[string]interface{}{
 			"Errors": errors,
 			"Input":  input,
 		}
+		if sess != nil {
+			data["Identity"] = sess
+		}
 		if h.config.Recaptcha.Enabled && h.config.Recaptcha.SiteKey != "" {
 			data["RecaptchaSiteKey"] = h.config.Recaptcha.SiteKey
 		}
@@ -563,7 +911,7 @@ func (h *Handler) keyChangeFormSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create key change request
-	req, err := h.createKeyChangeRequest(input)
+	req, err := h.createKeyChangeRequest(r.Context(), input)
 	if err != nil {
 		renderWithErrors([]string{err.Error()})
 		return
@@ -573,13 +921,13 @@ func (h *Handler) keyChangeFormSubmit(w http.ResponseWriter, r *http.Request) {
 }
 
 // createKeyChangeRequest creates a key change request after validation
-func (h *Handler) createKeyChangeRequest(input *model.KeyChangeInput) (*model.KeyChangeRequest, error) {
+func (h *Handler) createKeyChangeRequest(ctx context.Context, input *model.KeyChangeInput) (*model.KeyChangeRequest, error) {
 	if h.keyChangeStore == nil {
 		return nil, fmt.Errorf("키 변경 기능이 비활성화되어 있습니다")
 	}
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(input.Username)
+	exists, err := h.provisioner.UserExists(ctx, input.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -588,7 +936,7 @@ func (h *Handler) createKeyChangeRequest(input *model.KeyChangeInput) (*model.Ke
 	}
 
 	// Verify email matches the registered email
-	registeredEmail, err := h.provisioner.GetUserEmail(input.Username)
+	registeredEmail, err := h.provisioner.GetUserEmail(ctx, input.Username)
 	if err != nil {
 		// If we can't get the email, allow the request but note in logs
 		log.Printf("Warning: could not verify email for user %s: %v", input.Username, err)
@@ -624,12 +972,30 @@ func (h *Handler) apiKeyChangeRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// With OIDC login the email must come from a verified identity: the web session,
+	// or the registered email when the user authenticates with their own API token
+	if h.oidc != nil {
+		if sess := h.currentSession(r); sess != nil {
+			input.Email = sess.Email
+		} else if username := h.tokenUsername(r); username != "" && username == input.Username {
+			email, err := h.provisioner.GetUserEmail(r.Context(), username)
+			if err != nil {
+				h.jsonError(w, http.StatusInternalServerError, "Failed to look up user email", err.Error())
+				return
+			}
+			input.Email = email
+		} else {
+			h.jsonError(w, http.StatusForbidden, "Login required", "log in at /login or use an API token of the user")
+			return
+		}
+	}
+
 	if errors := input.Validate(); len(errors) > 0 {
 		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
 		return
 	}
 
-	req, err := h.createKeyChangeRequest(&input)
+	req, err := h.createKeyChangeRequest(r.Context(), &input)
 	if err != nil {
 		h.jsonError(w, http.StatusConflict, err.Error())
 		return
@@ -678,11 +1044,6 @@ func (h *Handler) apiApproveKeyChange(w http.ResponseWriter, r *http.Request) {
 
 	username := chi.URLParam(r, "username")
 
-	var input model.ApproveInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		input.ProcessedBy = "admin"
-	}
-
 	req, err := h.keyChangeStore.GetByUsername(username)
 	if err != nil {
 		h.jsonError(w, http.StatusNotFound, "Request not found", err.Error())
@@ -695,14 +1056,15 @@ func (h *Handler) apiApproveKeyChange(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update the user's SSH key
-	if err := h.provisioner.UpdateUserKey(username, req.NewPublicKey); err != nil {
+	bastionKey := h.bastionAuthorizedKey(username, req.NewPublicKey)
+	if err := h.provisioner.UpdateUserKey(r.Context(), username, req.NewPublicKey, bastionKey); err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to update SSH key", err.Error())
 		return
 	}
 
 	// Update request status
 	req.Status = model.StatusApproved
-	req.ProcessedBy = input.ProcessedBy
+	req.ProcessedBy = currentUser(r)
 	req.ProcessedAt = time.Now().Format(time.RFC3339)
 	req.UpdatedAt = time.Now()
 
@@ -722,10 +1084,9 @@ func (h *Handler) apiRejectKeyChange(w http.ResponseWriter, r *http.Request) {
 
 	username := chi.URLParam(r, "username")
 
+	// Body is optional (reason only)
 	var input model.RejectInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		input.ProcessedBy = "admin"
-	}
+	json.NewDecoder(r.Body).Decode(&input)
 
 	req, err := h.keyChangeStore.GetByUsername(username)
 	if err != nil {
@@ -740,7 +1101,7 @@ func (h *Handler) apiRejectKeyChange(w http.ResponseWriter, r *http.Request) {
 
 	// Update request status
 	req.Status = model.StatusRejected
-	req.ProcessedBy = input.ProcessedBy
+	req.ProcessedBy = currentUser(r)
 	req.ProcessedAt = time.Now().Format(time.RFC3339)
 	req.RejectReason = input.Reason
 	req.UpdatedAt = time.Now()
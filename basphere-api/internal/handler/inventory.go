@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/config"
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/vsphere"
+)
+
+// openInventory creates the vCenter inventory reader (nil when disabled)
+// It connects lazily, so a vCenter outage at startup only disables live state until it is back.
+func openInventory(cfg *config.Config) (*vsphere.Inventory, error) {
+	if !cfg.Inventory.Enabled {
+		return nil, nil
+	}
+
+	settings, err := vsphere.LoadSettings(cfg.Provisioner.VSphere)
+	if err != nil {
+		return nil, err
+	}
+	return vsphere.NewInventory(vsphere.NewSession(settings.Login), settings, cfg.Inventory.CacheTTL), nil
+}
+
+// applyLiveState enriches VM records with their state in vCenter
+// Without an inventory, or when vCenter cannot be read, the records are returned as stored.
+func (h *Handler) applyLiveState(ctx context.Context, vms []model.VM) {
+	if h.inventory == nil || len(vms) == 0 {
+		return
+	}
+
+	snapshot, err := h.inventory.Snapshot(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to read vSphere inventory: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range vms {
+		vms[i].ApplyLiveState(snapshot.LiveState(&vms[i], now))
+	}
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/basphere/basphere-api/internal/config"
+	"github.com/basphere/basphere-api/internal/ipam"
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// openIPAM opens the IP allocation data shared with the CLI scripts
+func openIPAM(cfg config.IPAMConfig) (*ipam.IPAM, error) {
+	if _, err := os.Stat(cfg.Dir); err != nil {
+		return nil, fmt.Errorf("IPAM directory not available: %w", err)
+	}
+
+	networkCfg, err := ipam.LoadConfig(cfg.NetworkConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return ipam.New(cfg.Dir, networkCfg)
+}
+
+// toIPLeases converts IPAM leases to their API representation
+func toIPLeases(leases []ipam.Lease) []model.IPLease {
+	result := make([]model.IPLease, 0, len(leases))
+	for _, l := range leases {
+		result = append(result, model.IPLease{
+			IP:           l.IP.String(),
+			User:         l.User,
+			ResourceName: l.ResourceName,
+			ResourceType: l.ResourceType,
+			AllocatedAt:  l.AllocatedAt,
+		})
+	}
+	return result
+}
+
+// buildIPBlock describes a block with the leases that fall inside it
+func (h *Handler) buildIPBlock(alloc ipam.Allocation, leases []ipam.Lease) model.IPBlock {
+	end := h.ipam.BlockEnd(alloc.BlockStart)
+
+	var inBlock []ipam.Lease
+	for _, l := range leases {
+		if h.ipam.BlockContains(alloc.BlockStart, l.IP) {
+			inBlock = append(inBlock, l)
+		}
+	}
+
+	return model.IPBlock{
+		User:        alloc.User,
+		BlockStart:  alloc.BlockStart.String(),
+		BlockEnd:    end.String(),
+		BlockSize:   h.ipam.BlockSize(),
+		Used:        len(inBlock),
+		Free:        h.ipam.FreeInBlock(alloc.BlockStart, inBlock),
+		AllocatedAt: alloc.AllocatedAt,
+		Leases:      toIPLeases(inBlock),
+	}
+}
+
+// IPAM API handlers
+
+// apiGetIPBlock handles GET /api/v1/ipam/block
+func (h *Handler) apiGetIPBlock(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	if h.ipam == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "IPAM not available")
+		return
+	}
+
+	alloc, err := h.ipam.UserBlock(username)
+	if errors.Is(err, ipam.ErrNoBlock) {
+		h.jsonError(w, http.StatusNotFound, "No IP block allocated", username)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get IP block", err.Error())
+		return
+	}
+
+	leases, err := h.ipam.Leases(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list IP leases", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "", h.buildIPBlock(*alloc, leases))
+}
+
+// apiListIPLeases handles GET /api/v1/ipam/leases
+func (h *Handler) apiListIPLeases(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	if h.ipam == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "IPAM not available")
+		return
+	}
+
+	leases, err := h.ipam.Leases(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list IP leases", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "", model.IPLeaseListResponse{
+		Leases: toIPLeases(leases),
+		Total:  len(leases),
+	})
+}
+
+// apiAdminIPAM handles GET /api/v1/admin/ipam
+func (h *Handler) apiAdminIPAM(w http.ResponseWriter, r *http.Request) {
+	if h.ipam == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "IPAM not available")
+		return
+	}
+
+	allocations, leases, err := h.ipam.Snapshot()
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to read IPAM data", err.Error())
+		return
+	}
+
+	resp := model.IPAMSummaryResponse{
+		Network:         h.ipam.Network().String(),
+		Gateway:         h.ipam.Gateway().String(),
+		BlockSize:       h.ipam.BlockSize(),
+		TotalBlocks:     h.ipam.BlockCount(),
+		AllocatedBlocks: len(allocations),
+		FreeBlocks:      h.ipam.BlockCount() - len(allocations),
+		TotalLeases:     len(leases),
+		Blocks:          make([]model.IPBlock, 0, len(allocations)),
+	}
+
+	for _, alloc := range allocations {
+		resp.Blocks = append(resp.Blocks, h.buildIPBlock(alloc, leases))
+	}
+
+	var unassigned []ipam.Lease
+	for _, l := range leases {
+		if !h.inAnyBlock(l, allocations) {
+			unassigned = append(unassigned, l)
+		}
+	}
+	if len(unassigned) > 0 {
+		resp.Unassigned = toIPLeases(unassigned)
+	}
+
+	h.jsonSuccess(w, "", resp)
+}
+
+// inAnyBlock reports whether a lease falls inside one of the allocated blocks
+func (h *Handler) inAnyBlock(l ipam.Lease, allocations []ipam.Allocation) bool {
+	for _, alloc := range allocations {
+		if h.ipam.BlockContains(alloc.BlockStart, l.IP) {
+			return true
+		}
+	}
+	return false
+}
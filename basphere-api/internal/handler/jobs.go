@@ -0,0 +1,431 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/basphere/basphere-api/internal/logstream"
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// Job runners
+
+// registerJobRunners wires the provisioning operations into the job manager
+func (h *Handler) registerJobRunners() {
+	h.jobs.Register(model.JobTypeCreateVM, h.runCreateVM)
+	h.jobs.Register(model.JobTypeResizeVM, h.runResizeVM)
+	h.jobs.Register(model.JobTypeVMDisk, h.runVMDisk)
+	h.jobs.Register(model.JobTypeDeleteVMs, h.runDeleteVMs)
+	h.jobs.Register(model.JobTypeCreateCluster, h.runCreateCluster)
+	h.jobs.Register(model.JobTypeExpire, h.runExpire)
+}
+
+// runCreateVM creates the VMs requested by a create-vm job
+func (h *Handler) runCreateVM(ctx context.Context, job *model.Job) (interface{}, error) {
+	var input model.CreateVMInput
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, fmt.Errorf("invalid job input: %w", err)
+	}
+
+	// allocate-ip enforces the owner's resolved IP quota, not only the default
+	limits, _, err := h.quotaLimits(job.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := model.CreateVMResponse{}
+	leaseFailed := 0
+
+	for i, vmName := range vmNames(&input) {
+		// Stop between VMs on shutdown; the job is resumed after restart
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// A resumed job may have created this VM before the restart; only a VM carrying
+		// this job's ID is its own, a VM with the same name from elsewhere is left alone
+		if job.Attempts > 1 {
+			if vm, err := h.provisioner.GetVM(ctx, job.Owner, vmName); err == nil {
+				switch {
+				case vm.JobID != job.ID:
+					resp.Failed++
+					resp.Errors = append(resp.Errors, fmt.Sprintf("Failed to create %s: VM already exists", vmName))
+				case vm.Status == model.VMStatusRunning:
+					if err := h.saveLease(ctx, job.Owner, model.LeaseKindVM, vmName, input.Spec, input.ExpiresAt); err != nil {
+						leaseFailed++
+						resp.Errors = append(resp.Errors, "Created "+vmName+" without its lease: "+err.Error())
+					}
+					vm.ExpiresAt = input.ExpiresAt
+					resp.VMs = append(resp.VMs, *vm)
+					resp.Created++
+				default:
+					resp.Failed++
+					resp.Errors = append(resp.Errors, fmt.Sprintf("Failed to create %s: interrupted by server restart (status: %s)", vmName, vm.Status))
+				}
+				continue
+			}
+		}
+
+		vmCtx, finishLog := h.startLog(ctx, job, logstream.KindVM, vmName)
+		vm, err := h.provisioner.CreateVM(vmCtx, job.Owner, &model.CreateVMInput{
+			Name:              vmName,
+			OS:                input.OS,
+			Spec:              input.Spec,
+			Hostname:          vmHostname(&input, i),
+			SSHAuthorizedKeys: h.terminalAuthorizedKeys(job.Owner, input.SSHAuthorizedKeys),
+			Packages:          input.Packages,
+			UserData:          input.UserData,
+			Labels:            input.Labels,
+			Description:       input.Description,
+			MaxIPs:            limits.MaxIPs,
+			JobID:             job.ID,
+		})
+		finishLog(err)
+		if err != nil {
+			resp.Failed++
+			resp.Errors = append(resp.Errors, "Failed to create "+vmName+": "+err.Error())
+			continue
+		}
+
+		if err := h.saveLease(ctx, job.Owner, model.LeaseKindVM, vmName, input.Spec, input.ExpiresAt); err != nil {
+			leaseFailed++
+			resp.Errors = append(resp.Errors, "Created "+vmName+" without its lease: "+err.Error())
+		}
+		vm.ExpiresAt = input.ExpiresAt
+
+		resp.VMs = append(resp.VMs, *vm)
+		resp.Created++
+	}
+
+	if resp.Created == 0 {
+		return resp, fmt.Errorf("failed to create VMs")
+	}
+	// A VM without its lease would never expire; fail the job so the owner and admins see it
+	if leaseFailed > 0 {
+		return resp, fmt.Errorf("failed to save the lease of %d VMs", leaseFailed)
+	}
+
+	return resp, nil
+}
+
+// runResizeVM resizes the VM of a resize-vm job
+// Re-running after a restart is safe: Terraform only applies what is still different.
+func (h *Handler) runResizeVM(ctx context.Context, job *model.Job) (interface{}, error) {
+	var input model.ResizeVMJobInput
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, fmt.Errorf("invalid job input: %w", err)
+	}
+
+	ctx, finishLog := h.startLog(ctx, job, logstream.KindVM, input.Name)
+	vm, err := h.provisioner.ResizeVM(ctx, job.Owner, input.Name, input.Spec, input.DiskGB)
+	finishLog(err)
+	if err != nil {
+		return vm, err
+	}
+
+	// The new spec may allow a shorter lease than the VM has
+	expiresAt, err := h.capLease(job.Owner, model.LeaseKindVM, input.Name, input.Spec)
+	if err != nil {
+		return vm, fmt.Errorf("VM resized, but its lease was not updated: %w", err)
+	}
+	vm.ExpiresAt = expiresAt
+
+	return vm, nil
+}
+
+// runVMDisk attaches, grows or detaches the data disk of a vm-disk job
+func (h *Handler) runVMDisk(ctx context.Context, job *model.Job) (interface{}, error) {
+	var input model.DiskJobInput
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, fmt.Errorf("invalid job input: %w", err)
+	}
+
+	// A resumed job may have attached or detached the disk before the restart
+	if job.Attempts > 1 {
+		if vm, err := h.provisioner.GetVM(ctx, job.Owner, input.VM); err == nil {
+			exists := vm.FindDataDisk(input.Disk) != nil
+			if (input.Action == model.DiskActionAttach && exists) || (input.Action == model.DiskActionDetach && !exists) {
+				return vm, nil
+			}
+		}
+	}
+
+	ctx, finishLog := h.startLog(ctx, job, logstream.KindVM, input.VM)
+	var vm *model.VM
+	var err error
+	switch input.Action {
+	case model.DiskActionAttach:
+		vm, err = h.provisioner.AttachDisk(ctx, job.Owner, input.VM, input.Disk, input.SizeGB)
+	case model.DiskActionGrow:
+		vm, err = h.provisioner.ResizeDisk(ctx, job.Owner, input.VM, input.Disk, input.SizeGB)
+	case model.DiskActionDetach:
+		vm, err = h.provisioner.DetachDisk(ctx, job.Owner, input.VM, input.Disk)
+	default:
+		err = fmt.Errorf("unknown disk action: %s", input.Action)
+	}
+	finishLog(err)
+
+	return vm, err
+}
+
+// runDeleteVMs deletes the VMs of a delete-vms job
+// VMs already gone (deleted by hand or before a restart) count as deleted.
+func (h *Handler) runDeleteVMs(ctx context.Context, job *model.Job) (interface{}, error) {
+	var input model.DeleteVMsJobInput
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, fmt.Errorf("invalid job input: %w", err)
+	}
+
+	resp := model.DeleteVMsResponse{Deleted: []string{}}
+
+	for _, vmName := range input.Names {
+		// Stop between VMs on shutdown; the job is resumed after restart
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if exists, err := h.provisioner.VMExists(ctx, job.Owner, vmName); err == nil && !exists {
+			resp.Deleted = append(resp.Deleted, vmName)
+			continue
+		}
+
+		// A resize or disk change may have been queued after this job
+		inProgress, err := h.updateInProgress(job.Owner, vmName)
+		if err == nil && inProgress {
+			err = fmt.Errorf("VM update in progress")
+		}
+		if err != nil {
+			resp.Failed++
+			resp.Errors = append(resp.Errors, "Failed to delete "+vmName+": "+err.Error())
+			continue
+		}
+
+		if err := h.provisioner.DeleteVM(ctx, job.Owner, vmName); err != nil {
+			resp.Failed++
+			resp.Errors = append(resp.Errors, "Failed to delete "+vmName+": "+err.Error())
+			continue
+		}
+		h.removeLog(job.Owner, logstream.KindVM, vmName)
+		h.dropLease(job.Owner, model.LeaseKindVM, vmName)
+
+		resp.Deleted = append(resp.Deleted, vmName)
+	}
+
+	if resp.Failed > 0 {
+		return resp, fmt.Errorf("failed to delete %d of %d VMs", resp.Failed, len(input.Names))
+	}
+
+	return resp, nil
+}
+
+// runCreateCluster creates the cluster requested by a create-cluster job
+func (h *Handler) runCreateCluster(ctx context.Context, job *model.Job) (interface{}, error) {
+	var input model.CreateClusterInput
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, fmt.Errorf("invalid job input: %w", err)
+	}
+
+	// A resumed job may have started the cluster before the restart
+	if job.Attempts > 1 {
+		if cluster, err := h.provisioner.GetCluster(ctx, job.Owner, input.Name); err == nil {
+			cluster.ExpiresAt = input.ExpiresAt
+			return cluster, h.saveLease(ctx, job.Owner, model.LeaseKindCluster, input.Name, input.Type, input.ExpiresAt)
+		}
+	}
+
+	limits, _, err := h.quotaLimits(job.Owner)
+	if err != nil {
+		return nil, err
+	}
+	input.MaxIPs = limits.MaxIPs
+
+	ctx, finishLog := h.startLog(ctx, job, logstream.KindCluster, input.Name)
+	cluster, err := h.provisioner.CreateCluster(ctx, job.Owner, &input)
+	finishLog(err)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster.ExpiresAt = input.ExpiresAt
+
+	// A cluster without its lease would never expire; fail the job so the owner and admins see it
+	return cluster, h.saveLease(ctx, job.Owner, model.LeaseKindCluster, input.Name, input.Type, input.ExpiresAt)
+}
+
+// vmNames expands a create request into individual VM names (name-0, name-1, ... when count > 1)
+func vmNames(input *model.CreateVMInput) []string {
+	if input.Count <= 1 {
+		return []string{input.Name}
+	}
+
+	names := make([]string, 0, input.Count)
+	for i := 0; i < input.Count; i++ {
+		names = append(names, fmt.Sprintf("%s-%d", input.Name, i))
+	}
+	return names
+}
+
+// vmHostname returns the hostname of the i-th VM of a create request, suffixed like its name when count > 1
+// An empty hostname lets the VM use its name.
+func vmHostname(input *model.CreateVMInput, i int) string {
+	if input.Hostname == "" || input.Count <= 1 {
+		return input.Hostname
+	}
+	return fmt.Sprintf("%s-%d", input.Hostname, i)
+}
+
+// pendingUsage is what unfinished create, resize and disk jobs will add to a user's usage
+type pendingUsage struct {
+	VMs       int
+	Clusters  int
+	IPs       int
+	Resources model.Resources
+	// Names taken by VMs and clusters that are still being created
+	VMNames      map[string]bool
+	ClusterNames map[string]bool
+}
+
+// pendingUsage sums the VMs, clusters, IPs and resources of the unfinished jobs owned by username
+func (h *Handler) pendingUsage(username string) (*pendingUsage, error) {
+	jobs, err := h.jobs.List(username)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := h.specs()
+	pending := &pendingUsage{
+		VMNames:      make(map[string]bool),
+		ClusterNames: make(map[string]bool),
+	}
+	for _, job := range jobs {
+		if job.IsFinished() {
+			continue
+		}
+
+		switch job.Type {
+		case model.JobTypeCreateVM:
+			var input model.CreateVMInput
+			if json.Unmarshal(job.Input, &input) != nil {
+				continue
+			}
+			names := vmNames(&input)
+			for _, name := range names {
+				pending.VMNames[name] = true
+			}
+			n := len(names)
+			pending.VMs += n
+			pending.IPs += n
+			pending.Resources = pending.Resources.Add(specs.VMResources(input.Spec).Times(n))
+
+		case model.JobTypeResizeVM:
+			var input model.ResizeVMJobInput
+			if json.Unmarshal(job.Input, &input) != nil {
+				continue
+			}
+			// Shrinking frees resources only once the job succeeds
+			pending.Resources = pending.Resources.Add(model.Resources{
+				CPU:      max(input.Added.CPU, 0),
+				MemoryMB: max(input.Added.MemoryMB, 0),
+				DiskGB:   max(input.Added.DiskGB, 0),
+			})
+
+		case model.JobTypeVMDisk:
+			var input model.DiskJobInput
+			if json.Unmarshal(job.Input, &input) != nil {
+				continue
+			}
+			// Detaching frees disk space only once the job succeeds
+			pending.Resources = pending.Resources.Add(model.Resources{DiskGB: max(input.Added.DiskGB, 0)})
+
+		case model.JobTypeCreateCluster:
+			var input model.CreateClusterInput
+			if json.Unmarshal(job.Input, &input) != nil {
+				continue
+			}
+			layout := specs.ClusterLayout(input.Type, input.WorkerSpec)
+			pending.ClusterNames[input.Name] = true
+			pending.Clusters++
+			pending.IPs += layout.Nodes()
+			pending.Resources = pending.Resources.Add(specs.LayoutResources(layout))
+		}
+	}
+	return pending, nil
+}
+
+// lockOwner serializes create requests of an owner, so concurrent requests cannot all pass
+// the quota and name checks before any of their jobs is queued. Call the returned func to unlock.
+func (h *Handler) lockOwner(owner string) func() {
+	h.ownerMu.Lock()
+	if h.ownerLocks == nil {
+		h.ownerLocks = make(map[string]*sync.Mutex)
+	}
+	mu, ok := h.ownerLocks[owner]
+	if !ok {
+		mu = &sync.Mutex{}
+		h.ownerLocks[owner] = mu
+	}
+	h.ownerMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// acceptJob writes a 202 response pointing at the job status URL
+func (h *Handler) acceptJob(w http.ResponseWriter, message string, job *model.Job) {
+	h.jsonResponse(w, http.StatusAccepted, apiResponse{
+		Success: true,
+		Message: message,
+		Data: model.JobAcceptedResponse{
+			JobID:  job.ID,
+			Status: job.Status,
+			URL:    "/api/v1/jobs/" + job.ID,
+		},
+	})
+}
+
+// Job API handlers
+
+// apiListJobs handles GET /api/v1/jobs
+func (h *Handler) apiListJobs(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	jobs, err := h.jobs.List(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list jobs", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "", model.JobListResponse{
+		Jobs:  jobs,
+		Total: len(jobs),
+	})
+}
+
+// apiGetJob handles GET /api/v1/jobs/{id}
+func (h *Handler) apiGetJob(w http.ResponseWriter, r *http.Request) {
+	identity := currentIdentity(r)
+
+	// Job IDs are UUIDs; anything else must not reach the file store
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.jsonError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	job, err := h.jobs.Get(id)
+
+	// Other users' jobs are reported as missing rather than forbidden
+	if err != nil || (job.Owner != identity.Username && !identity.IsAdmin()) {
+		h.jsonError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	h.jsonSuccess(w, "", job)
+}
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/basphere/basphere-api/internal/labels"
+)
+
+// labelSelector parses the labelSelector query parameter (empty matches everything)
+// It writes the error response and returns false if the selector is invalid.
+func (h *Handler) labelSelector(w http.ResponseWriter, r *http.Request) (labels.Selector, bool) {
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid label selector", err.Error())
+		return nil, false
+	}
+	return selector, true
+}
@@ -0,0 +1,499 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/basphere/basphere-api/internal/logstream"
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// How long the lease notify command may run
+const notifyTimeout = 30 * time.Second
+
+// How often saving the lease of a new resource is tried before its job fails
+const (
+	leaseSaveAttempts   = 3
+	leaseSaveRetryDelay = time.Second
+)
+
+// Lease events passed to the notify command
+const (
+	leaseEventWarn    = "warn"
+	leaseEventExpired = "expired"
+)
+
+// maxLease returns the longest lease allowed for a VM spec or cluster type (0 = unlimited)
+func (h *Handler) maxLease(kind model.LeaseKind, spec string) time.Duration {
+	cfg := h.config.Leases
+
+	limits := cfg.SpecMaxTTL
+	if kind == model.LeaseKindCluster {
+		limits = cfg.ClusterTypeMaxTTL
+	}
+	if max, ok := limits[spec]; ok {
+		return max
+	}
+	return cfg.MaxTTL
+}
+
+// resolveLease returns when a resource created or renewed now expires, or nil if it never does
+// Without a requested lifetime the default lease applies, capped by the maximum for the spec.
+// It writes the error response and returns false if the request cannot be honoured.
+func (h *Handler) resolveLease(w http.ResponseWriter, kind model.LeaseKind, spec string, input *model.LeaseInput) (*time.Time, bool) {
+	now := time.Now()
+	max := h.maxLease(kind, spec)
+
+	expiresAt := input.Expiry(now)
+	if expiresAt == nil {
+		expiresAt = h.defaultLease(kind, spec, now)
+	} else if max > 0 && expiresAt.Sub(now) > max {
+		h.jsonError(w, http.StatusBadRequest, "Lease too long",
+			fmt.Sprintf("max lease for %s %s: %s", kind, spec, max))
+		return nil, false
+	}
+
+	if expiresAt != nil && h.leases == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "Leases not available")
+		return nil, false
+	}
+
+	return expiresAt, true
+}
+
+// defaultLease returns when a resource created now without a requested lifetime expires, or nil if it never does
+// The default lease is capped by the maximum for the spec, and a spec with a maximum never gets an unlimited one.
+func (h *Handler) defaultLease(kind model.LeaseKind, spec string, now time.Time) *time.Time {
+	max := h.maxLease(kind, spec)
+
+	var expiresAt *time.Time
+	if ttl := h.config.Leases.DefaultTTL; ttl > 0 {
+		t := now.Add(ttl)
+		expiresAt = &t
+	}
+	if max > 0 && (expiresAt == nil || expiresAt.Sub(now) > max) {
+		t := now.Add(max)
+		expiresAt = &t
+	}
+	return expiresAt
+}
+
+// saveLease records the lease of a resource created by a job, retrying a failed save
+// The caller fails the job on error: a resource whose lease is lost would never expire.
+func (h *Handler) saveLease(ctx context.Context, owner string, kind model.LeaseKind, name, spec string, expiresAt *time.Time) error {
+	if expiresAt == nil || h.leases == nil {
+		return nil
+	}
+
+	lease := &model.Lease{
+		Kind:      kind,
+		Name:      name,
+		Owner:     owner,
+		ExpiresAt: *expiresAt,
+		Spec:      spec,
+		CreatedAt: time.Now(),
+	}
+
+	var err error
+	for attempt := 1; attempt <= leaseSaveAttempts; attempt++ {
+		if err = h.leases.Save(lease); err == nil {
+			return nil
+		}
+		log.Printf("Warning: failed to save lease of %s %s/%s (attempt %d/%d): %v", kind, owner, name, attempt, leaseSaveAttempts, err)
+
+		if attempt < leaseSaveAttempts {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("failed to save lease of %s: %w", name, err)
+			case <-time.After(leaseSaveRetryDelay):
+			}
+		}
+	}
+	return fmt.Errorf("failed to save lease of %s: %w", name, err)
+}
+
+// capLease shortens the lease of a resource whose spec changed to the maximum for the new spec
+// A resource without a lease gets the default one if the new spec has a maximum, as if created with it.
+// It returns the resulting expiry, or nil if the resource never expires.
+func (h *Handler) capLease(owner string, kind model.LeaseKind, name, spec string) (*time.Time, error) {
+	if h.leases == nil {
+		return nil, nil
+	}
+
+	// The reaper and renewals must not overwrite the capped lease
+	h.leaseMu.Lock()
+	defer h.leaseMu.Unlock()
+
+	lease, err := h.leases.Get(owner, kind, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lease of %s: %w", name, err)
+	}
+
+	now := time.Now()
+	max := h.maxLease(kind, spec)
+	switch {
+	case lease == nil && max == 0:
+		return nil, nil
+	case lease == nil:
+		lease = &model.Lease{Kind: kind, Name: name, Owner: owner, ExpiresAt: *h.defaultLease(kind, spec, now), CreatedAt: now}
+	case max > 0 && lease.ExpiresAt.Sub(now) > max:
+		lease.ExpiresAt = now.Add(max)
+		lease.WarnedAt = nil
+	}
+	lease.Spec = spec
+
+	if err := h.leases.Save(lease); err != nil {
+		return nil, fmt.Errorf("failed to save lease of %s: %w", name, err)
+	}
+
+	expiresAt := lease.ExpiresAt
+	return &expiresAt, nil
+}
+
+// dropLease removes the lease of a deleted resource
+func (h *Handler) dropLease(owner string, kind model.LeaseKind, name string) {
+	if h.leases == nil {
+		return
+	}
+	if err := h.leases.Delete(owner, kind, name); err != nil {
+		log.Printf("Warning: failed to delete lease of %s %s/%s: %v", kind, owner, name, err)
+	}
+}
+
+// leaseExpiries returns the expiry of each of the owner's leased resources of a kind, by name
+func (h *Handler) leaseExpiries(owner string, kind model.LeaseKind) map[string]*time.Time {
+	expiries := map[string]*time.Time{}
+	if h.leases == nil {
+		return expiries
+	}
+
+	leases, err := h.leases.List(owner)
+	if err != nil {
+		log.Printf("Warning: failed to list leases of %s: %v", owner, err)
+		return expiries
+	}
+	for _, lease := range leases {
+		if lease.Kind == kind {
+			expiresAt := lease.ExpiresAt
+			expiries[lease.Name] = &expiresAt
+		}
+	}
+	return expiries
+}
+
+// Lease API handlers
+
+// apiRenewVM handles POST /api/v1/vms/{name}/renew
+func (h *Handler) apiRenewVM(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	vm, err := h.provisioner.GetVM(r.Context(), username, chi.URLParam(r, "name"))
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return
+	}
+
+	h.renewLease(w, r, username, model.LeaseKindVM, vm.Name, vm.Spec)
+}
+
+// apiRenewCluster handles POST /api/v1/clusters/{name}/renew
+func (h *Handler) apiRenewCluster(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	cluster, err := h.provisioner.GetCluster(r.Context(), username, chi.URLParam(r, "name"))
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "Cluster not found", err.Error())
+		return
+	}
+
+	h.renewLease(w, r, username, model.LeaseKindCluster, cluster.Name, cluster.Type)
+}
+
+// renewLease sets a new expiry for a resource, counted from now
+func (h *Handler) renewLease(w http.ResponseWriter, r *http.Request, username string, kind model.LeaseKind, name, spec string) {
+	var input model.LeaseInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	if h.leases == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "Leases not available")
+		return
+	}
+
+	// The reaper must not queue a deletion between reading and saving the lease
+	h.leaseMu.Lock()
+	defer h.leaseMu.Unlock()
+
+	lease, err := h.leases.Get(username, kind, name)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get lease", err.Error())
+		return
+	}
+	// Too late once the reaper is deleting the resource, unless the deletion failed
+	if lease != nil && lease.ExpireJobID != "" {
+		if job, err := h.jobs.Get(lease.ExpireJobID); err != nil || job.Status != model.JobStatusFailed {
+			h.jsonError(w, http.StatusConflict, "Lease expired", fmt.Sprintf("deletion queued (job: %s)", lease.ExpireJobID))
+			return
+		}
+		lease.ExpireJobID = ""
+	}
+
+	expiresAt, ok := h.resolveLease(w, kind, spec, &input)
+	if !ok {
+		return
+	}
+	if expiresAt == nil {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", "ttl or expires_at is required")
+		return
+	}
+
+	now := time.Now()
+	if lease == nil {
+		lease = &model.Lease{Kind: kind, Name: name, Owner: username, CreatedAt: now}
+	}
+	lease.ExpiresAt = *expiresAt
+	lease.Spec = spec
+	lease.RenewedAt = &now
+	lease.WarnedAt = nil
+
+	if err := h.leases.Save(lease); err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to save lease", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "Lease renewed", lease)
+}
+
+// Reaper
+
+// runReaper checks the leases every interval until the server shuts down
+func (h *Handler) runReaper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.reapLeases(h.lifetime, time.Now())
+
+		select {
+		case <-h.lifetime.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapLeases warns the owners of resources about to expire and queues the deletion of expired ones
+func (h *Handler) reapLeases(ctx context.Context, now time.Time) {
+	leases, err := h.leases.List("")
+	if err != nil {
+		log.Printf("Warning: failed to list leases: %v", err)
+		return
+	}
+
+	for _, lease := range leases {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if warned := h.reapLease(lease, now); warned != nil {
+			h.notifyLease(ctx, warned, leaseEventWarn)
+		}
+	}
+}
+
+// reapLease queues the deletion of an expired lease or marks it warned, and returns it if the owner is to be warned
+// The lease is read again under leaseMu, so a renewal since the listing is never overwritten.
+func (h *Handler) reapLease(listed *model.Lease, now time.Time) *model.Lease {
+	h.leaseMu.Lock()
+	defer h.leaseMu.Unlock()
+
+	lease, err := h.leases.Get(listed.Owner, listed.Kind, listed.Name)
+	if err != nil {
+		log.Printf("Warning: failed to get lease of %s %s/%s: %v", listed.Kind, listed.Owner, listed.Name, err)
+		return nil
+	}
+	if lease == nil {
+		return nil
+	}
+
+	warnBefore := h.config.Leases.WarnBefore
+	switch {
+	case !now.Before(lease.ExpiresAt):
+		h.expireLease(lease)
+
+	case warnBefore > 0 && lease.WarnedAt == nil && !now.Before(lease.ExpiresAt.Add(-warnBefore)):
+		lease.WarnedAt = &now
+		if err := h.leases.Save(lease); err != nil {
+			log.Printf("Warning: failed to save lease of %s %s/%s: %v", lease.Kind, lease.Owner, lease.Name, err)
+		}
+		return lease
+	}
+	return nil
+}
+
+// expireLease queues an expire job for an expired lease unless one is already running
+// A failed expire job is retried on the next check. The caller holds leaseMu.
+func (h *Handler) expireLease(lease *model.Lease) {
+	if lease.ExpireJobID != "" {
+		if job, err := h.jobs.Get(lease.ExpireJobID); err == nil {
+			if !job.IsFinished() {
+				return
+			}
+			// Already deleted; the lease was saved again after the job removed it
+			if job.Status == model.JobStatusSucceeded {
+				h.dropLease(lease.Owner, lease.Kind, lease.Name)
+				return
+			}
+		}
+	}
+
+	job, err := h.jobs.Submit(model.JobTypeExpire, lease.Owner, model.ExpireJobInput{
+		Kind:      lease.Kind,
+		Name:      lease.Name,
+		ExpiresAt: lease.ExpiresAt,
+		Reason:    "lease expired at " + lease.ExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		log.Printf("Warning: failed to queue expiry of %s %s/%s: %v", lease.Kind, lease.Owner, lease.Name, err)
+		return
+	}
+
+	lease.ExpireJobID = job.ID
+	if err := h.leases.Save(lease); err != nil {
+		log.Printf("Warning: failed to save lease of %s %s/%s: %v", lease.Kind, lease.Owner, lease.Name, err)
+	}
+	log.Printf("Lease of %s %s/%s expired, deletion queued (job: %s)", lease.Kind, lease.Owner, lease.Name, job.ID)
+}
+
+// runExpire deletes a resource whose lease expired
+func (h *Handler) runExpire(ctx context.Context, job *model.Job) (interface{}, error) {
+	var input model.ExpireJobInput
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, fmt.Errorf("invalid job input: %w", err)
+	}
+
+	result := model.ExpireResult{Kind: input.Kind, Name: input.Name, Reason: input.Reason}
+
+	// A renewal may have saved the lease after the reaper queued this job
+	renewed, err := h.leaseRenewed(job, &input)
+	if err != nil {
+		return nil, err
+	}
+	if renewed {
+		log.Printf("Lease of %s %s/%s renewed, expiry skipped (job: %s)", input.Kind, job.Owner, input.Name, job.ID)
+		result.Renewed = true
+		return result, nil
+	}
+
+	var exists bool
+	switch input.Kind {
+	case model.LeaseKindVM:
+		exists, err = h.provisioner.VMExists(ctx, job.Owner, input.Name)
+	case model.LeaseKindCluster:
+		exists, err = h.provisioner.ClusterExists(ctx, job.Owner, input.Name)
+	default:
+		return nil, fmt.Errorf("unknown lease kind: %s", input.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		if err := h.deleteExpired(ctx, job.Owner, input.Kind, input.Name); err != nil {
+			return nil, err
+		}
+		result.Deleted = true
+	}
+
+	lease := &model.Lease{Kind: input.Kind, Name: input.Name, Owner: job.Owner, ExpiresAt: input.ExpiresAt}
+	h.dropLease(job.Owner, input.Kind, input.Name)
+	if result.Deleted {
+		h.notifyLease(ctx, lease, leaseEventExpired)
+	}
+
+	return result, nil
+}
+
+// leaseRenewed reports whether the lease an expire job was queued for is gone, was extended
+// or was handed to another job. While the job runs renewLease refuses the lease, as its
+// ExpireJobID points at an unfinished job.
+func (h *Handler) leaseRenewed(job *model.Job, input *model.ExpireJobInput) (bool, error) {
+	if h.leases == nil {
+		return false, nil
+	}
+
+	h.leaseMu.Lock()
+	defer h.leaseMu.Unlock()
+
+	lease, err := h.leases.Get(job.Owner, input.Kind, input.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get lease: %w", err)
+	}
+	return lease == nil || lease.ExpiresAt.After(input.ExpiresAt) || lease.ExpireJobID != job.ID, nil
+}
+
+// deleteExpired deletes an expired VM or cluster through the provisioner
+func (h *Handler) deleteExpired(ctx context.Context, owner string, kind model.LeaseKind, name string) error {
+	if kind == model.LeaseKindCluster {
+		if err := h.provisioner.DeleteCluster(ctx, owner, name); err != nil {
+			return err
+		}
+		h.removeLog(owner, logstream.KindCluster, name)
+		return nil
+	}
+
+	// Terraform owns the VM while it applies a resize or disk change; retried on the next check
+	inProgress, err := h.updateInProgress(owner, name)
+	if err != nil {
+		return err
+	}
+	if inProgress {
+		return fmt.Errorf("VM update in progress: %s", name)
+	}
+
+	if err := h.provisioner.DeleteVM(ctx, owner, name); err != nil {
+		return err
+	}
+	h.removeLog(owner, logstream.KindVM, name)
+	return nil
+}
+
+// notifyLease tells the owner about a lease event through the configured notify command
+func (h *Handler) notifyLease(ctx context.Context, lease *model.Lease, event string) {
+	log.Printf("Lease of %s %s/%s: %s (expires %s)", lease.Kind, lease.Owner, lease.Name, event, lease.ExpiresAt.Format(time.RFC3339))
+
+	command := h.config.Leases.NotifyCommand
+	if command == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, command,
+		"--user", lease.Owner,
+		"--kind", string(lease.Kind),
+		"--name", lease.Name,
+		"--expires-at", lease.ExpiresAt.Format(time.RFC3339),
+		"--event", event,
+	).CombinedOutput()
+	if err != nil {
+		log.Printf("Warning: lease notify command failed: %v: %s", err, out)
+	}
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/basphere/basphere-api/internal/logstream"
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/provisioner"
+)
+
+// keepaliveInterval is how often an idle log stream sends a comment so proxies keep it open
+const keepaliveInterval = 15 * time.Second
+
+// Provisioning logs
+
+// startLog starts recording the provisioning log of a resource and routes the script output
+// of the returned context into it. finish records the outcome and closes the log.
+// Without a log store the context is returned unchanged and finish does nothing.
+func (h *Handler) startLog(ctx context.Context, job *model.Job, kind, name string) (context.Context, func(err error)) {
+	if h.logs == nil {
+		return ctx, func(error) {}
+	}
+
+	l, err := h.logs.Create(job.Owner, kind, name)
+	if err != nil {
+		log.Printf("Warning: failed to create %s log for %s/%s: %v", kind, job.Owner, name, err)
+		return ctx, func(error) {}
+	}
+
+	l.Printf("==> %s %s (job %s, attempt %d)", job.Type, name, job.ID, job.Attempts)
+
+	return provisioner.WithOutput(ctx, l), func(err error) {
+		if err != nil {
+			l.Printf("==> failed: %v", err)
+		} else {
+			l.Printf("==> done")
+		}
+		l.Close()
+	}
+}
+
+// removeLog deletes the provisioning log of a deleted resource
+func (h *Handler) removeLog(username, kind, name string) {
+	if h.logs == nil {
+		return
+	}
+	if err := h.logs.Remove(username, kind, name); err != nil {
+		log.Printf("Warning: failed to remove %s log for %s/%s: %v", kind, username, name, err)
+	}
+}
+
+// Log API handlers
+
+// apiGetVMLogs handles GET /api/v1/vms/{name}/logs
+func (h *Handler) apiGetVMLogs(w http.ResponseWriter, r *http.Request) {
+	h.streamLogs(w, r, logstream.KindVM)
+}
+
+// apiGetClusterLogs handles GET /api/v1/clusters/{name}/logs
+func (h *Handler) apiGetClusterLogs(w http.ResponseWriter, r *http.Request) {
+	h.streamLogs(w, r, logstream.KindCluster)
+}
+
+// streamLogs sends the provisioning log of a resource as Server-Sent Events
+// Each line is one "data" event whose id is the line number; a client reconnecting with
+// Last-Event-ID continues after that line. A final "end" event follows once provisioning finished.
+func (h *Handler) streamLogs(w http.ResponseWriter, r *http.Request, kind string) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	name := chi.URLParam(r, "name")
+
+	if h.logs == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "Provisioning logs not available")
+		return
+	}
+
+	skip := 0
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		n, err := strconv.Atoi(lastID)
+		if err != nil || n < 0 {
+			h.jsonError(w, http.StatusBadRequest, "Invalid Last-Event-ID", lastID)
+			return
+		}
+		skip = n
+	}
+
+	lines, err := h.logs.Follow(r.Context(), username, kind, name)
+	if errors.Is(err, logstream.ErrNotFound) {
+		h.jsonError(w, http.StatusNotFound, "Log not found", name)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to open log", err.Error())
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: do not buffer the stream
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	id := 0
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// Stopped early (client gone or server shutting down): no end event
+				if r.Context().Err() != nil || h.logs.Closed() {
+					return
+				}
+				fmt.Fprint(w, "event: end\ndata: finished\n\n")
+				rc.Flush()
+				return
+			}
+
+			id++
+			if id <= skip {
+				continue
+			}
+			// A carriage return would end the SSE field early
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", id, strings.ReplaceAll(line, "\r", ""))
+			rc.Flush()
+
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			rc.Flush()
+		}
+	}
+}
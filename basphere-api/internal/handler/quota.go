@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/quota"
+)
+
+// userTeam returns the team a user registered with, or "" if unknown
+func (h *Handler) userTeam(username string) string {
+	if h.store == nil {
+		return ""
+	}
+	req, err := h.store.GetByUsername(username)
+	if err != nil || req.Status != model.StatusApproved {
+		return ""
+	}
+	return req.Team
+}
+
+// quotaLimits resolves the effective limits of a user along with the admin override, if any
+func (h *Handler) quotaLimits(username string) (quota.Limits, *model.QuotaOverride, error) {
+	var override *model.QuotaOverride
+	if h.quotaStore != nil {
+		var err error
+		override, err = h.quotaStore.Get(username)
+		if err != nil {
+			return quota.Limits{}, nil, fmt.Errorf("failed to read quota override: %w", err)
+		}
+	}
+
+	cfg := h.quotas
+	if cfg == nil {
+		cfg = &quota.Config{}
+	}
+
+	return cfg.Resolve(username, h.userTeam(username), override), override, nil
+}
+
+// resourceUsage returns the vCPU, memory and disk of the user's VMs and cluster nodes
+func (h *Handler) resourceUsage(ctx context.Context, username string) (model.Resources, error) {
+	var usage model.Resources
+
+	vms, err := h.provisioner.ListVMs(ctx, username)
+	if err != nil {
+		return usage, err
+	}
+	clusters, err := h.provisioner.ListClusters(ctx, username)
+	if err != nil {
+		return usage, err
+	}
+
+	specs := h.specs()
+	for i := range vms {
+		usage = usage.Add(specs.VMSize(&vms[i]))
+	}
+	for i := range clusters {
+		usage = usage.Add(specs.ClusterResources(&clusters[i]))
+	}
+	return usage, nil
+}
+
+// getQuota returns the user's VM, IP, snapshot and resource quota
+// Usage comes from the provisioner (IP usage from IPAM when available) and the spec catalog,
+// limits from the quota configuration.
+func (h *Handler) getQuota(ctx context.Context, username string) (*model.Quota, error) {
+	q, err := h.provisioner.GetQuota(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, _, err := h.quotaLimits(username)
+	if err != nil {
+		return nil, err
+	}
+	q.MaxVMs = limits.MaxVMs
+	q.MaxIPs = limits.MaxIPs
+	q.MaxSnapshots = limits.MaxSnapshots
+	q.MaxCPU = limits.MaxCPU
+	q.MaxMemoryMB = limits.MaxMemoryMB
+	q.MaxDiskGB = limits.MaxDiskGB
+
+	usage, err := h.resourceUsage(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	q.UsedCPU = usage.CPU
+	q.UsedMemoryMB = usage.MemoryMB
+	q.UsedDiskGB = usage.DiskGB
+
+	q.UsedSnapshots, err = h.snapshotUsage(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.ipam != nil {
+		used, err := h.ipam.Usage(username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IP usage: %w", err)
+		}
+		q.UsedIPs = used
+	}
+
+	return q, nil
+}
+
+// getClusterQuota returns the user's cluster quota
+func (h *Handler) getClusterQuota(ctx context.Context, username string) (*model.ClusterQuota, error) {
+	q, err := h.provisioner.GetClusterQuota(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, _, err := h.quotaLimits(username)
+	if err != nil {
+		return nil, err
+	}
+	q.MaxClusters = limits.MaxClusters
+	q.MaxNodesPerCluster = limits.MaxNodesPerCluster
+
+	return q, nil
+}
+
+// Quota admin API handlers
+
+// apiListQuotaOverrides handles GET /api/v1/admin/quotas
+func (h *Handler) apiListQuotaOverrides(w http.ResponseWriter, r *http.Request) {
+	if h.quotaStore == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "Quota store not available")
+		return
+	}
+
+	overrides, err := h.quotaStore.List()
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list quota overrides", err.Error())
+		return
+	}
+	if overrides == nil {
+		overrides = []*model.QuotaOverride{}
+	}
+
+	h.jsonSuccess(w, "", overrides)
+}
+
+// apiGetUserQuota handles GET /api/v1/admin/quotas/{username}
+func (h *Handler) apiGetUserQuota(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	exists, err := h.provisioner.UserExists(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
+		return
+	}
+	if !exists {
+		h.jsonError(w, http.StatusNotFound, "User not found", username)
+		return
+	}
+
+	vmQuota, err := h.getQuota(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
+		return
+	}
+
+	clusterQuota, err := h.getClusterQuota(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get cluster quota", err.Error())
+		return
+	}
+
+	_, override, err := h.quotaLimits(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "", model.UserQuotaResponse{
+		Username:     username,
+		Team:         h.userTeam(username),
+		Quota:        *vmQuota,
+		ClusterQuota: *clusterQuota,
+		Override:     override,
+	})
+}
+
+// apiSetUserQuota handles PUT /api/v1/admin/quotas/{username}
+// Only the limits present in the body are overridden; the rest keep following the configuration.
+func (h *Handler) apiSetUserQuota(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	if h.quotaStore == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "Quota store not available")
+		return
+	}
+
+	var input model.QuotaLimits
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	// A user never has more addresses than their IP block
+	if h.ipam != nil && input.MaxIPs != nil && *input.MaxIPs > h.ipam.BlockSize() {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed",
+			fmt.Sprintf("max_ips must not exceed the IP block size (%d)", h.ipam.BlockSize()))
+		return
+	}
+
+	override := &model.QuotaOverride{
+		Username:    username,
+		QuotaLimits: input,
+		UpdatedBy:   currentUser(r),
+		UpdatedAt:   time.Now(),
+	}
+	if err := h.quotaStore.Save(override); err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to save quota override", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "Quota updated", override)
+}
+
+// apiDeleteUserQuota handles DELETE /api/v1/admin/quotas/{username}
+func (h *Handler) apiDeleteUserQuota(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	if h.quotaStore == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "Quota store not available")
+		return
+	}
+
+	if err := h.quotaStore.Delete(username); err != nil {
+		h.jsonError(w, http.StatusNotFound, "Quota override not found", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "Quota override removed", nil)
+}
@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/oidc"
+)
+
+const (
+	sessionCookieName = "basphere_session"
+	loginCookieName   = "basphere_login"
+
+	// How long a browser may take to complete the provider login
+	loginTimeout = 10 * time.Minute
+
+	// How often expired sessions and abandoned logins are dropped
+	sessionPruneInterval = 10 * time.Minute
+)
+
+// webSession represents a browser session established through OIDC login
+type webSession struct {
+	Subject   string
+	Email     string
+	Name      string
+	ExpiresAt time.Time
+}
+
+// pendingLogin holds the PKCE/nonce secrets between /login and /auth/callback
+type pendingLogin struct {
+	login     *oidc.LoginRequest
+	returnTo  string
+	expiresAt time.Time
+}
+
+// sessionStore keeps web sessions and in-flight logins in memory
+// Sessions do not survive a restart; users simply log in again
+type sessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*webSession
+	logins   map[string]*pendingLogin
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*webSession),
+		logins:   make(map[string]*pendingLogin),
+	}
+}
+
+func (s *sessionStore) create(sess *webSession) (string, error) {
+	id, err := randomSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess.ExpiresAt = time.Now().Add(s.ttl)
+	s.sessions[id] = sess
+	return id, nil
+}
+
+func (s *sessionStore) get(id string) *webSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil
+	}
+	return sess
+}
+
+func (s *sessionStore) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+func (s *sessionStore) putLogin(p *pendingLogin) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop abandoned logins so the map cannot grow without bound
+	now := time.Now()
+	for state, pl := range s.logins {
+		if now.After(pl.expiresAt) {
+			delete(s.logins, state)
+		}
+	}
+
+	s.logins[p.login.State] = p
+}
+
+// prune drops expired sessions and abandoned logins
+// get only removes the session it is asked for, so sessions that are never used again
+// would otherwise stay in memory until the next restart
+func (s *sessionStore) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sess := range s.sessions {
+		if now.After(sess.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	for state, pl := range s.logins {
+		if now.After(pl.expiresAt) {
+			delete(s.logins, state)
+		}
+	}
+}
+
+// takeLogin returns and removes the pending login for state (single use)
+func (s *sessionStore) takeLogin(state string) *pendingLogin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.logins[state]
+	if !ok {
+		return nil
+	}
+	delete(s.logins, state)
+	if time.Now().After(p.expiresAt) {
+		return nil
+	}
+	return p
+}
+
+// runSessionPruning periodically drops expired sessions until the handler shuts down
+func (h *Handler) runSessionPruning(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.lifetime.Done():
+			return
+		case now := <-ticker.C:
+			h.sessions.prune(now)
+		}
+	}
+}
+
+func randomSessionID() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// currentSession returns the web session of the request, or nil if not logged in
+func (h *Handler) currentSession(r *http.Request) *webSession {
+	if h.sessions == nil {
+		return nil
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+	return h.sessions.get(cookie.Value)
+}
+
+// requireSession returns the web session when OIDC login is enabled, redirecting to /login if there is none
+// ok is false when a redirect has been written and the caller must stop
+func (h *Handler) requireSession(w http.ResponseWriter, r *http.Request) (sess *webSession, ok bool) {
+	if h.oidc == nil {
+		return nil, true
+	}
+
+	if current := h.currentSession(r); current != nil {
+		return current, true
+	}
+
+	http.Redirect(w, r, "/login?return_to="+url.QueryEscape(r.URL.Path), http.StatusFound)
+	return nil, false
+}
+
+// requireAPISession is the JSON API counterpart of requireSession
+// Without OIDC login the API stays anonymous; with it, a missing session is answered with 403
+func (h *Handler) requireAPISession(w http.ResponseWriter, r *http.Request) (sess *webSession, ok bool) {
+	if h.oidc == nil {
+		return nil, true
+	}
+
+	if current := h.currentSession(r); current != nil {
+		return current, true
+	}
+
+	h.jsonError(w, http.StatusForbidden, "Login required", "log in at /login before submitting this request")
+	return nil, false
+}
+
+// Web login handlers (OIDC authorization code flow with PKCE)
+
+// loginPage handles GET /login
+func (h *Handler) loginPage(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	login, err := oidc.NewLoginRequest()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	h.sessions.putLogin(&pendingLogin{
+		login:     login,
+		returnTo:  safeReturnTo(r.URL.Query().Get("return_to")),
+		expiresAt: time.Now().Add(loginTimeout),
+	})
+
+	// Bind the login to this browser so a callback URL cannot be replayed elsewhere
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookieName,
+		Value:    login.State,
+		Path:     "/auth/callback",
+		MaxAge:   int(loginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, h.oidc.AuthCodeURL(login), http.StatusFound)
+}
+
+// authCallback handles GET /auth/callback
+func (h *Handler) authCallback(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		http.Error(w, "Login failed: "+errParam, http.StatusUnauthorized)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	cookie, err := r.Cookie(loginCookieName)
+	if err != nil || state == "" || cookie.Value != state {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	// Clear the login cookie regardless of the outcome
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookieName,
+		Path:     "/auth/callback",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	pending := h.sessions.takeLogin(state)
+	if pending == nil {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.oidc.Exchange(r.Context(), pending.login, r.URL.Query().Get("code"))
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		http.Error(w, "Your identity provider did not return a verified email", http.StatusForbidden)
+		return
+	}
+
+	id, err := h.sessions.create(&webSession{
+		Subject: claims.Subject,
+		Email:   claims.Email,
+		Name:    claims.Name,
+	})
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(h.sessions.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, pending.returnTo, http.StatusFound)
+}
+
+// logoutPage handles GET /logout
+func (h *Handler) logoutPage(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && h.sessions != nil {
+		h.sessions.delete(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	http.Redirect(w, r, "/register", http.StatusFound)
+}
+
+// safeReturnTo only allows local absolute paths to prevent open redirects
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, "\\") {
+		return "/register"
+	}
+	return returnTo
+}
+
+// isSecureRequest checks if the request arrived over HTTPS (directly or via nginx)
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
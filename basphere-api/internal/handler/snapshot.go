@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// snapshotUsage returns the number of snapshots across the user's VMs
+func (h *Handler) snapshotUsage(ctx context.Context, username string) (int, error) {
+	vms, err := h.provisioner.ListVMs(ctx, username)
+	if err != nil {
+		return 0, err
+	}
+
+	used := 0
+	for _, vm := range vms {
+		snapshots, err := h.provisioner.ListSnapshots(ctx, username, vm.Name)
+		if err != nil {
+			return 0, err
+		}
+		used += len(snapshots)
+	}
+	return used, nil
+}
+
+// snapshotTarget returns the VM named in the URL if snapshots can be taken or reverted right now
+// It writes the error response and returns nil otherwise.
+func (h *Handler) snapshotTarget(w http.ResponseWriter, r *http.Request, username string) *model.VM {
+	vmName := chi.URLParam(r, "name")
+
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return nil
+	}
+
+	if vm.Status != model.VMStatusRunning && vm.Status != model.VMStatusStopped {
+		h.jsonError(w, http.StatusConflict, "VM is not ready", fmt.Sprintf("VM is %s", vm.Status))
+		return nil
+	}
+
+	// Terraform owns the VM while it applies a resize or disk change
+	inProgress, err := h.updateInProgress(username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return nil
+	}
+	if inProgress {
+		h.jsonError(w, http.StatusConflict, "VM update in progress", vmName)
+		return nil
+	}
+
+	return vm
+}
+
+// findSnapshot returns the named snapshot of a VM, or nil if it does not exist
+func findSnapshot(snapshots []model.Snapshot, name string) *model.Snapshot {
+	for i := range snapshots {
+		if snapshots[i].Name == name {
+			return &snapshots[i]
+		}
+	}
+	return nil
+}
+
+// Snapshot API handlers
+
+// apiListSnapshots handles GET /api/v1/vms/{name}/snapshots
+func (h *Handler) apiListSnapshots(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+	vmName := chi.URLParam(r, "name")
+
+	if _, err := h.provisioner.GetVM(r.Context(), username, vmName); err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return
+	}
+
+	snapshots, err := h.provisioner.ListSnapshots(r.Context(), username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list snapshots", err.Error())
+		return
+	}
+	if snapshots == nil {
+		snapshots = []model.Snapshot{}
+	}
+
+	h.jsonSuccess(w, "", model.SnapshotListResponse{
+		Snapshots: snapshots,
+		Total:     len(snapshots),
+	})
+}
+
+// apiCreateSnapshot handles POST /api/v1/vms/{name}/snapshots
+func (h *Handler) apiCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	var input model.CreateSnapshotInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	vm := h.snapshotTarget(w, r, username)
+	if vm == nil {
+		return
+	}
+
+	snapshots, err := h.provisioner.ListSnapshots(r.Context(), username, vm.Name)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list snapshots", err.Error())
+		return
+	}
+	if findSnapshot(snapshots, input.Name) != nil {
+		h.jsonError(w, http.StatusConflict, "Snapshot already exists", input.Name)
+		return
+	}
+
+	// Check quota
+	quota, err := h.getQuota(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
+		return
+	}
+	if quota.UsedSnapshots >= quota.MaxSnapshots {
+		h.jsonError(w, http.StatusForbidden, "Snapshot quota exceeded",
+			fmt.Sprintf("current: %d, max: %d", quota.UsedSnapshots, quota.MaxSnapshots))
+		return
+	}
+
+	// Not interrupted by a client disconnect, see detachedContext
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	snapshot, err := h.provisioner.CreateSnapshot(ctx, username, vm.Name, &input)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to create snapshot", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "Snapshot created", snapshot)
+}
+
+// apiRevertSnapshot handles POST /api/v1/vms/{name}/snapshots/{snapshot}/revert
+func (h *Handler) apiRevertSnapshot(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+	snapshotName := chi.URLParam(r, "snapshot")
+
+	vm := h.snapshotTarget(w, r, username)
+	if vm == nil {
+		return
+	}
+
+	snapshots, err := h.provisioner.ListSnapshots(r.Context(), username, vm.Name)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list snapshots", err.Error())
+		return
+	}
+	snapshot := findSnapshot(snapshots, snapshotName)
+	if snapshot == nil {
+		h.jsonError(w, http.StatusNotFound, "Snapshot not found", snapshotName)
+		return
+	}
+
+	// vSphere would roll back the spec and disks, but the VM record, quota usage and Terraform state would not
+	if changes := snapshot.ConfigChanges(vm); len(changes) > 0 {
+		h.jsonError(w, http.StatusConflict, "VM changed since the snapshot", changes...)
+		return
+	}
+
+	// Not interrupted by a client disconnect, see detachedContext
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	vm, err = h.provisioner.RevertSnapshot(ctx, username, vm.Name, snapshotName)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to revert snapshot", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "VM reverted to snapshot "+snapshotName, vm)
+}
+
+// apiDeleteSnapshot handles DELETE /api/v1/vms/{name}/snapshots/{snapshot}
+func (h *Handler) apiDeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+	snapshotName := chi.URLParam(r, "snapshot")
+
+	vm := h.snapshotTarget(w, r, username)
+	if vm == nil {
+		return
+	}
+
+	snapshots, err := h.provisioner.ListSnapshots(r.Context(), username, vm.Name)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list snapshots", err.Error())
+		return
+	}
+	snapshot := findSnapshot(snapshots, snapshotName)
+	if snapshot == nil {
+		h.jsonError(w, http.StatusNotFound, "Snapshot not found", snapshotName)
+		return
+	}
+
+	// Not interrupted by a client disconnect, see detachedContext
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	if err := h.provisioner.DeleteSnapshot(ctx, username, vm.Name, snapshotName); err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to delete snapshot", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "Snapshot deleted", snapshot)
+}
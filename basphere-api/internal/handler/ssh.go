@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/sshca"
+	"github.com/basphere/basphere-api/internal/sshconfig"
+)
+
+// SSH certificate authority handlers
+
+// apiGetSSHCAPublicKey handles GET /api/v1/ssh/ca.pub
+// The output is meant to be installed as the bastion's TrustedUserCAKeys file
+func (h *Handler) apiGetSSHCAPublicKey(w http.ResponseWriter, r *http.Request) {
+	if h.sshCA == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "SSH certificate authority is disabled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(h.sshCA.PublicKey() + "\n"))
+}
+
+// bastionAuthorizedKey returns the bastion authorized_keys entry for a user's registered key
+// With the CA enabled the raw key is not installed: the bastion only accepts certificates
+// issued for the user, so expired certificates and replaced keys stop working there.
+func (h *Handler) bastionAuthorizedKey(username, publicKey string) string {
+	if h.sshCA == nil {
+		return strings.TrimSpace(publicKey)
+	}
+	return certAuthorityLine(username, h.sshCA.PublicKey())
+}
+
+// certAuthorityLine returns the authorized_keys line trusting caKey for certificates of owner
+func certAuthorityLine(owner, caKey string) string {
+	return `cert-authority,principals="` + owner + `" ` + strings.TrimSpace(caKey)
+}
+
+// apiIssueSSHCert handles POST /api/v1/ssh/cert
+func (h *Handler) apiIssueSSHCert(w http.ResponseWriter, r *http.Request) {
+	if h.sshCA == nil {
+		h.jsonError(w, http.StatusServiceUnavailable, "SSH certificate authority is disabled")
+		return
+	}
+
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	var input model.SSHCertInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+			return
+		}
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	// Only the key registered through approval (or an approved key change) is signed
+	publicKey, err := h.provisioner.GetUserKey(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "No registered SSH key", err.Error())
+		return
+	}
+
+	ttl := h.config.SSHCA.CertTTL
+	if input.TTLMinutes > 0 {
+		ttl = time.Duration(input.TTLMinutes) * time.Minute
+	}
+	if max := h.config.SSHCA.MaxCertTTL; max > 0 && ttl > max {
+		ttl = max
+	}
+
+	cert, err := h.sshCA.SignUserKey(username, publicKey, ttl)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to sign certificate", err.Error())
+		return
+	}
+
+	log.Printf("SSH CA: issued certificate serial=%d key_id=%s valid_before=%s",
+		cert.Serial, cert.KeyId, time.Unix(int64(cert.ValidBefore), 0).Format(time.RFC3339))
+
+	h.jsonSuccess(w, "Certificate issued", model.SSHCertResponse{
+		Certificate: sshca.MarshalCertificate(cert),
+		Serial:      cert.Serial,
+		KeyID:       cert.KeyId,
+		Principals:  cert.ValidPrincipals,
+		ValidAfter:  time.Unix(int64(cert.ValidAfter), 0).UTC(),
+		ValidBefore: time.Unix(int64(cert.ValidBefore), 0).UTC(),
+	})
+}
+
+// apiGetSSHConfig handles GET /api/v1/ssh-config
+// Renders an OpenSSH client config with a ProxyJump entry for each of the user's VMs and cluster nodes.
+// Query: os (macos, linux or windows), windows_user and identity_file fill in the key path.
+func (h *Handler) apiGetSSHConfig(w http.ResponseWriter, r *http.Request) {
+	username := currentUser(r)
+	query := r.URL.Query()
+
+	variant, err := sshconfig.ParseVariant(query.Get("os"))
+	if err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid os", err.Error())
+		return
+	}
+
+	identityFile := query.Get("identity_file")
+	windowsUser := query.Get("windows_user")
+	for _, v := range []string{identityFile, windowsUser} {
+		if strings.ContainsAny(v, "\"\r\n") {
+			h.jsonError(w, http.StatusBadRequest, "Invalid identity file", "must not contain quotes or line breaks")
+			return
+		}
+	}
+	if identityFile == "" {
+		identityFile = sshconfig.DefaultIdentityFile(variant, windowsUser)
+	}
+
+	vms, err := h.provisioner.ListVMs(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list VMs", err.Error())
+		return
+	}
+
+	clusters, err := h.provisioner.ListClusters(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list clusters", err.Error())
+		return
+	}
+
+	config := sshconfig.Render(sshconfig.Options{
+		Variant:        variant,
+		Username:       username,
+		BastionAddress: h.config.Bastion.Address,
+		BastionPort:    h.config.Bastion.Port,
+		IdentityFile:   identityFile,
+		Hosts:          append(sshconfig.VMHosts(vms, username), sshconfig.ClusterHosts(clusters)...),
+	})
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(config)
+}
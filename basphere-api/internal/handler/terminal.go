@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/terminal"
+)
+
+const (
+	// WebSocket subprotocol of the web terminal
+	terminalSubprotocol = "basphere.terminal"
+	// Browsers cannot set headers on WebSocket requests, so the API token is offered
+	// as a second subprotocol "bearer.<token>" (never echoed back)
+	terminalTokenPrefix = "bearer."
+
+	// Lifetime of the certificate signing in to the bastion and the VM; sshd only checks it while connecting
+	terminalCertTTL = 2 * time.Minute
+	// Limits on browser messages and on writing to a stalled browser
+	terminalMaxMessage   = 64 * 1024
+	terminalWriteTimeout = 10 * time.Second
+)
+
+var terminalUpgrader = websocket.Upgrader{
+	Subprotocols:    []string{terminalSubprotocol},
+	ReadBufferSize:  4096,
+	WriteBufferSize: 32 * 1024,
+}
+
+// Web terminal handlers
+
+// terminalPage handles GET /terminal
+func (h *Handler) terminalPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"VM":      r.URL.Query().Get("vm"),
+		"Enabled": h.terminalEnabled(),
+	}
+	if err := h.templates.ExecuteTemplate(w, "terminal.html", data); err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// apiVMTerminal handles GET /api/v1/vms/{name}/terminal (WebSocket)
+// Opens a shell on the caller's VM through the bastion. Output is sent as binary messages;
+// the browser sends model.TerminalMessage input and resize messages.
+// Query: cols and rows set the initial terminal size.
+func (h *Handler) apiVMTerminal(w http.ResponseWriter, r *http.Request) {
+	if !h.terminalEnabled() {
+		h.jsonError(w, http.StatusServiceUnavailable, "Web terminal is disabled")
+		return
+	}
+
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+	vmName := chi.URLParam(r, "name")
+
+	// VMs are looked up under the caller's account, so only owned VMs can be opened
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return
+	}
+	if vm.Status != model.VMStatusRunning || vm.IPAddress == "" {
+		h.jsonError(w, http.StatusConflict, "VM is not running", "status: "+string(vm.Status))
+		return
+	}
+
+	if !websocket.IsWebSocketUpgrade(r) {
+		h.jsonError(w, http.StatusBadRequest, "WebSocket upgrade required")
+		return
+	}
+
+	hostKey, err := h.bastionHostKeyCallback()
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to load bastion host key", err.Error())
+		return
+	}
+
+	signer, err := h.sshCA.SessionSigner(username, terminalCertTTL)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to sign session certificate", err.Error())
+		return
+	}
+
+	cols, rows := terminalSize(r)
+
+	// Upgrade writes its own error response
+	conn, err := terminalUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(terminalMaxMessage)
+
+	// The session outlives request timeouts but not the server
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	loginUser := vm.LoginUser
+	if loginUser == "" {
+		loginUser = username
+	}
+
+	session, err := terminal.Open(ctx, terminal.Target{
+		BastionAddr:    h.terminalBastionAddr(),
+		BastionUser:    username,
+		BastionHostKey: hostKey,
+		Addr:           net.JoinHostPort(vm.IPAddress, "22"),
+		User:           loginUser,
+		Signer:         signer,
+	}, cols, rows)
+	if err != nil {
+		log.Printf("Terminal: %s failed to connect to %s (%s): %v", username, vm.Name, vm.IPAddress, err)
+		closeTerminal(conn, model.TerminalMessage{Type: model.TerminalError, Message: "Failed to connect: " + err.Error()})
+		return
+	}
+	defer session.Close()
+
+	log.Printf("Terminal: %s connected to %s (%s)", username, vm.Name, vm.IPAddress)
+	result := h.bridgeTerminal(conn, session, ctx.Done())
+	log.Printf("Terminal: %s disconnected from %s: %s", username, vm.Name, result.Message)
+
+	closeTerminal(conn, result)
+}
+
+// bridgeTerminal copies shell output to the browser and browser input to the shell
+// until the shell exits, the browser goes away or idles out, or done is closed.
+// It returns the message to send before closing.
+func (h *Handler) bridgeTerminal(conn *websocket.Conn, session *terminal.Session, done <-chan struct{}) model.TerminalMessage {
+	// Only this goroutine writes to conn until outputDone is closed
+	var writeErr error
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := session.Read(buf)
+			if n > 0 {
+				conn.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+				if writeErr = conn.WriteMessage(websocket.BinaryMessage, buf[:n]); writeErr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	inputDone := make(chan error, 1)
+	go func() {
+		idle := h.config.Terminal.IdleTimeout
+		for {
+			if idle > 0 {
+				conn.SetReadDeadline(time.Now().Add(idle))
+			}
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				inputDone <- err
+				return
+			}
+
+			var msg model.TerminalMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				continue
+			}
+			switch msg.Type {
+			case model.TerminalInput:
+				session.Write([]byte(msg.Data))
+			case model.TerminalResize:
+				session.Resize(msg.Cols, msg.Rows)
+			}
+		}
+	}()
+
+	// Ends the output copy so the caller is the only writer left
+	stopOutput := func() {
+		session.Close()
+		<-outputDone
+	}
+
+	select {
+	case <-outputDone:
+		if writeErr != nil {
+			return model.TerminalMessage{Type: model.TerminalError, Message: "Browser disconnected"}
+		}
+		code, err := session.Wait()
+		if err != nil {
+			return model.TerminalMessage{Type: model.TerminalError, Message: "Connection lost: " + err.Error()}
+		}
+		return model.TerminalMessage{Type: model.TerminalExit, Message: "Session ended", ExitCode: &code}
+	case err := <-inputDone:
+		stopOutput()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return model.TerminalMessage{Type: model.TerminalError, Message: "Idle timeout"}
+		}
+		return model.TerminalMessage{Type: model.TerminalError, Message: "Browser disconnected"}
+	case <-done:
+		stopOutput()
+		return model.TerminalMessage{Type: model.TerminalError, Message: "Server is shutting down"}
+	}
+}
+
+// closeTerminal sends a final message and a close frame
+// Writes fail harmlessly if the browser already went away.
+func closeTerminal(conn *websocket.Conn, msg model.TerminalMessage) {
+	deadline := time.Now().Add(terminalWriteTimeout)
+	conn.SetWriteDeadline(deadline)
+	if data, err := json.Marshal(msg); err == nil {
+		conn.WriteMessage(websocket.TextMessage, data)
+	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+}
+
+// terminalSize returns the initial terminal size from the query (default 80x24)
+func terminalSize(r *http.Request) (cols, rows int) {
+	cols, rows = 80, 24
+	if v, err := strconv.Atoi(r.URL.Query().Get("cols")); err == nil && v > 0 && v <= 1000 {
+		cols = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("rows")); err == nil && v > 0 && v <= 1000 {
+		rows = v
+	}
+	return cols, rows
+}
+
+// terminalBastionAddr returns the bastion sshd address the terminal connects to
+func (h *Handler) terminalBastionAddr() string {
+	if addr := h.config.Terminal.BastionAddr; addr != "" {
+		return addr
+	}
+	port := h.config.Bastion.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(h.config.Bastion.Address, strconv.Itoa(port))
+}
+
+// terminalEnabled reports whether the web terminal is configured and usable
+// The bastion must be pinned in terminal.known_hosts_file: the API server hands it a session
+// certificate, so an unverified bastion could be impersonated.
+func (h *Handler) terminalEnabled() bool {
+	return h.config.Terminal.Enabled && h.sshCA != nil && h.config.Terminal.KnownHostsFile != ""
+}
+
+// bastionHostKeyCallback verifies the bastion against terminal.known_hosts_file
+func (h *Handler) bastionHostKeyCallback() (ssh.HostKeyCallback, error) {
+	path := h.config.Terminal.KnownHostsFile
+	if path == "" {
+		return nil, errors.New("terminal.known_hosts_file is not set")
+	}
+	return knownhosts.New(path)
+}
+
+// terminalAuthorizedKeys returns keys plus the SSH CA as a cert-authority for owner when the
+// web terminal is enabled, so new VMs accept the terminal's session certificates
+func (h *Handler) terminalAuthorizedKeys(owner string, keys []string) []string {
+	if !h.config.Terminal.Enabled || h.sshCA == nil {
+		return keys
+	}
+	out := make([]string, 0, len(keys)+1)
+	out = append(out, keys...)
+	return append(out, certAuthorityLine(owner, h.sshCA.PublicKey()))
+}
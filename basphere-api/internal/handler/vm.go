@@ -2,10 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/basphere/basphere-api/internal/labels"
+	"github.com/basphere/basphere-api/internal/logstream"
 	"github.com/basphere/basphere-api/internal/model"
 )
 
@@ -13,15 +16,11 @@ import (
 
 // apiCreateVM handles POST /api/v1/vms
 func (h *Handler) apiCreateVM(w http.ResponseWriter, r *http.Request) {
-	// Get username from header (set by CLI)
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "X-Basphere-User header required")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -38,8 +37,9 @@ func (h *Handler) apiCreateVM(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
-	if errors := input.Validate(); len(errors) > 0 {
+	// Validate input (OS and spec must exist in the catalog)
+	errors := append(input.Validate(), h.specs().ValidateVM(&input)...)
+	if len(errors) > 0 {
 		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
 		return
 	}
@@ -49,92 +49,83 @@ func (h *Handler) apiCreateVM(w http.ResponseWriter, r *http.Request) {
 		input.Count = 1
 	}
 
-	// Check quota
-	quota, err := h.provisioner.GetQuota(username)
+	// Resolve the lease now so a queued job does not get extra lifetime
+	expiresAt, ok := h.resolveLease(w, model.LeaseKindVM, input.Spec, &input.LeaseInput)
+	if !ok {
+		return
+	}
+	input.LeaseInput = model.LeaseInput{ExpiresAt: expiresAt}
+
+	// Hold the owner's lock until the job is queued so its VMs count as pending for the next request
+	defer h.lockOwner(username)()
+
+	// Check quota (VMs and clusters still being created by queued jobs count as used)
+	quota, err := h.getQuota(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
 		return
 	}
 
-	if quota.UsedVMs+input.Count > quota.MaxVMs {
+	pending, err := h.pendingUsage(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return
+	}
+
+	if quota.UsedVMs+pending.VMs+input.Count > quota.MaxVMs {
 		h.jsonError(w, http.StatusForbidden, "VM quota exceeded",
-			"current: "+string(rune(quota.UsedVMs+'0'))+", requested: "+string(rune(input.Count+'0'))+", max: "+string(rune(quota.MaxVMs+'0')))
+			fmt.Sprintf("current: %d, in progress: %d, requested: %d, max: %d", quota.UsedVMs, pending.VMs, input.Count, quota.MaxVMs))
+		return
+	}
+
+	// Every VM takes one IP from the user's block
+	if quota.UsedIPs+pending.IPs+input.Count > quota.MaxIPs {
+		h.jsonError(w, http.StatusForbidden, "IP quota exceeded",
+			fmt.Sprintf("current: %d, in progress: %d, requested: %d, max: %d", quota.UsedIPs, pending.IPs, input.Count, quota.MaxIPs))
+		return
+	}
+
+	// A large spec uses more of the vCPU, memory and disk ceilings than a small one
+	requested := h.specs().VMResources(input.Spec).Times(input.Count)
+	if exceeded := quota.ExceededResources(pending.Resources.Add(requested)); len(exceeded) > 0 {
+		h.jsonError(w, http.StatusForbidden, "Resource quota exceeded", exceeded...)
 		return
 	}
 
-	// Check if VM already exists (for single VM)
-	if input.Count == 1 {
-		vmExists, err := h.provisioner.VMExists(username, input.Name)
+	// Every generated name must be free of existing VMs and VMs still being created
+	for _, name := range vmNames(&input) {
+		if pending.VMNames[name] {
+			h.jsonError(w, http.StatusConflict, "VM is already being created", name)
+			return
+		}
+		vmExists, err := h.provisioner.VMExists(r.Context(), username, name)
 		if err != nil {
 			h.jsonError(w, http.StatusInternalServerError, "Failed to check VM", err.Error())
 			return
 		}
 		if vmExists {
-			h.jsonError(w, http.StatusConflict, "VM already exists", input.Name)
+			h.jsonError(w, http.StatusConflict, "VM already exists", name)
 			return
 		}
 	}
 
-	// Create VMs
-	var createdVMs []model.VM
-	var errors []string
-	created := 0
-	failed := 0
-
-	for i := 1; i <= input.Count; i++ {
-		vmName := input.Name
-		if input.Count > 1 {
-			vmName = input.Name + "-" + string(rune(i+'0'-1))
-		}
-
-		vmInput := &model.CreateVMInput{
-			Name: vmName,
-			OS:   input.OS,
-			Spec: input.Spec,
-		}
-
-		vm, err := h.provisioner.CreateVM(username, vmInput)
-		if err != nil {
-			failed++
-			errors = append(errors, "Failed to create "+vmName+": "+err.Error())
-			continue
-		}
-
-		createdVMs = append(createdVMs, *vm)
-		created++
-	}
-
-	// Return response
-	resp := model.CreateVMResponse{
-		VMs:     createdVMs,
-		Created: created,
-		Failed:  failed,
-		Errors:  errors,
-	}
-
-	if created == 0 {
-		h.jsonResponse(w, http.StatusInternalServerError, apiResponse{
-			Success: false,
-			Message: "Failed to create VMs",
-			Data:    resp,
-		})
+	// Create VMs in the background; progress is reported through the job
+	job, err := h.jobs.Submit(model.JobTypeCreateVM, username, input)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to queue VM creation", err.Error())
 		return
 	}
 
-	h.jsonSuccess(w, "VMs created", resp)
+	h.acceptJob(w, "VM creation queued", job)
 }
 
 // apiListVMs handles GET /api/v1/vms
 func (h *Handler) apiListVMs(w http.ResponseWriter, r *http.Request) {
-	// Get username from header
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "X-Basphere-User header required")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -144,15 +135,27 @@ func (h *Handler) apiListVMs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	selector, ok := h.labelSelector(w, r)
+	if !ok {
+		return
+	}
+
 	// List VMs
-	vms, err := h.provisioner.ListVMs(username)
+	vms, err := h.provisioner.ListVMs(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to list VMs", err.Error())
 		return
 	}
+	vms = filterVMs(vms, selector)
+
+	expiries := h.leaseExpiries(username, model.LeaseKindVM)
+	for i := range vms {
+		vms[i].ExpiresAt = expiries[vms[i].Name]
+	}
+	h.applyLiveState(r.Context(), vms)
 
 	// Get quota
-	quota, err := h.provisioner.GetQuota(username)
+	quota, err := h.getQuota(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
 		return
@@ -167,13 +170,25 @@ func (h *Handler) apiListVMs(w http.ResponseWriter, r *http.Request) {
 	h.jsonSuccess(w, "", resp)
 }
 
+// filterVMs returns the VMs whose labels match the selector
+func filterVMs(vms []model.VM, selector labels.Selector) []model.VM {
+	if selector.Empty() {
+		return vms
+	}
+
+	matched := []model.VM{}
+	for _, vm := range vms {
+		if selector.Matches(vm.Labels) {
+			matched = append(matched, vm)
+		}
+	}
+	return matched
+}
+
 // apiGetVM handles GET /api/v1/vms/{name}
 func (h *Handler) apiGetVM(w http.ResponseWriter, r *http.Request) {
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "X-Basphere-User header required")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	vmName := chi.URLParam(r, "name")
 	if vmName == "" {
@@ -181,22 +196,23 @@ func (h *Handler) apiGetVM(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vm, err := h.provisioner.GetVM(username, vmName)
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
 	if err != nil {
 		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
 		return
 	}
+	vm.ExpiresAt = h.leaseExpiries(username, model.LeaseKindVM)[vm.Name]
+
+	vms := []model.VM{*vm}
+	h.applyLiveState(r.Context(), vms)
 
-	h.jsonSuccess(w, "", vm)
+	h.jsonSuccess(w, "", vms[0])
 }
 
 // apiDeleteVM handles DELETE /api/v1/vms/{name}
 func (h *Handler) apiDeleteVM(w http.ResponseWriter, r *http.Request) {
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "X-Basphere-User header required")
-		return
-	}
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
 
 	vmName := chi.URLParam(r, "name")
 	if vmName == "" {
@@ -205,31 +221,329 @@ func (h *Handler) apiDeleteVM(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if VM exists
-	vm, err := h.provisioner.GetVM(username, vmName)
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
 	if err != nil {
 		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
 		return
 	}
 
-	// Delete VM
-	if err := h.provisioner.DeleteVM(username, vmName); err != nil {
+	// Terraform owns the VM while it applies a resize or disk change
+	inProgress, err := h.updateInProgress(username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return
+	}
+	if inProgress {
+		h.jsonError(w, http.StatusConflict, "VM update in progress", vmName)
+		return
+	}
+
+	// Delete VM (not interrupted by a client disconnect, see detachedContext)
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	if err := h.provisioner.DeleteVM(ctx, username, vmName); err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to delete VM", err.Error())
 		return
 	}
+	h.removeLog(username, logstream.KindVM, vmName)
+	h.dropLease(username, model.LeaseKindVM, vmName)
 
 	h.jsonSuccess(w, "VM deleted", vm)
 }
 
-// apiGetQuota handles GET /api/v1/quota
-func (h *Handler) apiGetQuota(w http.ResponseWriter, r *http.Request) {
-	username := r.Header.Get("X-Basphere-User")
-	if username == "" {
-		h.jsonError(w, http.StatusUnauthorized, "X-Basphere-User header required")
+// apiDeleteVMs handles DELETE /api/v1/vms?labelSelector=...
+// The VMs matching the selector now are deleted one by one in a delete-vms job.
+func (h *Handler) apiDeleteVMs(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	selector, ok := h.labelSelector(w, r)
+	if !ok {
+		return
+	}
+	// Never delete everything by accident
+	if selector.Empty() {
+		h.jsonError(w, http.StatusBadRequest, "Label selector required", "use labelSelector to choose the VMs to delete")
+		return
+	}
+
+	vms, err := h.provisioner.ListVMs(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to list VMs", err.Error())
 		return
 	}
 
+	var names, busy []string
+	for _, vm := range filterVMs(vms, selector) {
+		names = append(names, vm.Name)
+
+		// Terraform owns the VM while it applies a resize or disk change
+		inProgress, err := h.updateInProgress(username, vm.Name)
+		if err != nil {
+			h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+			return
+		}
+		if inProgress {
+			busy = append(busy, vm.Name)
+		}
+	}
+	if len(names) == 0 {
+		h.jsonError(w, http.StatusNotFound, "No VMs match the selector", r.URL.Query().Get("labelSelector"))
+		return
+	}
+	if len(busy) > 0 {
+		h.jsonError(w, http.StatusConflict, "VM update in progress", busy...)
+		return
+	}
+
+	job, err := h.jobs.Submit(model.JobTypeDeleteVMs, username, model.DeleteVMsJobInput{
+		Selector: r.URL.Query().Get("labelSelector"),
+		Names:    names,
+	})
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to queue VM deletion", err.Error())
+		return
+	}
+
+	h.acceptJob(w, fmt.Sprintf("Deletion of %d VM(s) queued", len(names)), job)
+}
+
+// apiUpdateVM handles PATCH /api/v1/vms/{name}
+// Labels and description are saved right away; a spec or disk change is queued as a resize job.
+func (h *Handler) apiUpdateVM(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	vmName := chi.URLParam(r, "name")
+	if vmName == "" {
+		h.jsonError(w, http.StatusBadRequest, "VM name required")
+		return
+	}
+
+	var input model.UpdateVMInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	// Validate input (spec must exist in the catalog)
+	errors := input.Validate()
+	if input.Spec != "" {
+		errors = append(errors, h.specs().ValidateVMSpec(input.Spec)...)
+	}
+	if len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return
+	}
+
+	newLabels, description := input.Apply(vm.Labels, vm.Description)
+	if errors := labels.Validate(newLabels); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	var resize *model.ResizeVMJobInput
+	if input.Resizes() {
+		if resize = h.planResize(w, r, username, vm, &input.ResizeVMInput); resize == nil {
+			return
+		}
+	}
+
+	if !input.MetadataUpdate.IsEmpty() {
+		if vm, err = h.provisioner.UpdateVMMetadata(r.Context(), username, vmName, newLabels, description); err != nil {
+			h.jsonError(w, http.StatusInternalServerError, "Failed to update VM", err.Error())
+			return
+		}
+	}
+
+	if resize == nil {
+		h.jsonSuccess(w, "VM updated", vm)
+		return
+	}
+
+	// Terraform may power cycle the VM, so the resize runs in the background
+	job, err := h.jobs.Submit(model.JobTypeResizeVM, username, resize)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to queue VM resize", err.Error())
+		return
+	}
+
+	h.acceptJob(w, "VM resize queued", job)
+}
+
+// planResize checks that the VM can be resized as requested and resolves the target size
+// It writes the error response and returns nil otherwise.
+func (h *Handler) planResize(w http.ResponseWriter, r *http.Request, username string, vm *model.VM, input *model.ResizeVMInput) *model.ResizeVMJobInput {
+	vmName := vm.Name
+	specs := h.specs()
+
+	if vm.Status != model.VMStatusRunning && vm.Status != model.VMStatusStopped {
+		h.jsonError(w, http.StatusConflict, "VM cannot be resized", fmt.Sprintf("cannot resize a VM that is %s", vm.Status))
+		return nil
+	}
+
+	inProgress, err := h.updateInProgress(username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return nil
+	}
+	if inProgress {
+		h.jsonError(w, http.StatusConflict, "VM update already in progress", vmName)
+		return nil
+	}
+
+	// Resolve the target size; the disk keeps its current size unless it grows (data disks are not touched)
+	current := specs.VMRootSize(vm)
+	spec := input.Spec
+	if spec == "" {
+		spec = vm.Spec
+	}
+	target := specs.VMResources(spec)
+	if input.DiskGB > 0 && input.DiskGB < current.DiskGB {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed",
+			fmt.Sprintf("disk_gb must not be smaller than the current disk (%d GB)", current.DiskGB))
+		return nil
+	}
+	target.DiskGB = max(target.DiskGB, current.DiskGB, input.DiskGB)
+
+	if spec == vm.Spec && target == current {
+		h.jsonError(w, http.StatusBadRequest, "Nothing to change", "VM already has this size")
+		return nil
+	}
+
+	// vSphere cannot extend a disk that has snapshots, and reverting one would roll back the spec
+	if !h.checkNoSnapshots(w, r, username, vm, "resizing it") {
+		return nil
+	}
+
+	// Only the growth counts against quota (resizes still running count as used)
+	added := target.Sub(current)
+
+	quota, err := h.getQuota(r.Context(), username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
+		return nil
+	}
+
+	pending, err := h.pendingUsage(username)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return nil
+	}
+
+	if exceeded := quota.ExceededResources(pending.Resources.Add(added)); len(exceeded) > 0 {
+		h.jsonError(w, http.StatusForbidden, "Resource quota exceeded", exceeded...)
+		return nil
+	}
+
+	return &model.ResizeVMJobInput{
+		Name:   vmName,
+		Spec:   spec,
+		DiskGB: target.DiskGB,
+		Added:  added,
+	}
+}
+
+// updateInProgress reports whether an unfinished resize-vm or vm-disk job targets the VM
+// Both re-apply the VM's Terraform configuration, so only one may run at a time.
+func (h *Handler) updateInProgress(username, vmName string) (bool, error) {
+	jobs, err := h.jobs.List(username)
+	if err != nil {
+		return false, err
+	}
+
+	for _, job := range jobs {
+		if job.IsFinished() {
+			continue
+		}
+		switch job.Type {
+		case model.JobTypeResizeVM:
+			var input model.ResizeVMJobInput
+			if json.Unmarshal(job.Input, &input) == nil && input.Name == vmName {
+				return true, nil
+			}
+		case model.JobTypeVMDisk:
+			var input model.DiskJobInput
+			if json.Unmarshal(job.Input, &input) == nil && input.VM == vmName {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// apiVMAction handles POST /api/v1/vms/{name}/actions
+func (h *Handler) apiVMAction(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
+	vmName := chi.URLParam(r, "name")
+	if vmName == "" {
+		h.jsonError(w, http.StatusBadRequest, "VM name required")
+		return
+	}
+
+	var input model.VMActionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if errors := input.Validate(); len(errors) > 0 {
+		h.jsonError(w, http.StatusBadRequest, "Validation failed", errors...)
+		return
+	}
+
+	vm, err := h.provisioner.GetVM(r.Context(), username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusNotFound, "VM not found", err.Error())
+		return
+	}
+
+	if !input.Action.AllowedFrom(vm.Status) {
+		h.jsonError(w, http.StatusConflict, "Action not allowed",
+			fmt.Sprintf("cannot %s a VM that is %s", input.Action, vm.Status))
+		return
+	}
+
+	// Terraform owns the power state while it applies a resize or disk change
+	inProgress, err := h.updateInProgress(username, vmName)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to check jobs", err.Error())
+		return
+	}
+	if inProgress {
+		h.jsonError(w, http.StatusConflict, "VM update in progress", vmName)
+		return
+	}
+
+	// Not interrupted by a client disconnect, see detachedContext
+	ctx, cancel := h.detachedContext(r)
+	defer cancel()
+
+	vm, err = h.provisioner.PowerVM(ctx, username, vmName, input.Action)
+	if err != nil {
+		h.jsonError(w, http.StatusInternalServerError, "Failed to "+string(input.Action)+" VM", err.Error())
+		return
+	}
+
+	h.jsonSuccess(w, "VM "+string(input.Action)+" completed", vm)
+}
+
+// apiGetQuota handles GET /api/v1/quota
+func (h *Handler) apiGetQuota(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated username (set by authenticate middleware)
+	username := currentUser(r)
+
 	// Check if user exists
-	exists, err := h.provisioner.UserExists(username)
+	exists, err := h.provisioner.UserExists(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to check user", err.Error())
 		return
@@ -239,7 +553,7 @@ func (h *Handler) apiGetQuota(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	quota, err := h.provisioner.GetQuota(username)
+	quota, err := h.getQuota(r.Context(), username)
 	if err != nil {
 		h.jsonError(w, http.StatusInternalServerError, "Failed to get quota", err.Error())
 		return
@@ -0,0 +1,145 @@
+package ipam
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigPath is the CLI configuration file holding the network section
+const DefaultConfigPath = "/etc/basphere/config.yaml"
+
+// DefaultDir is where allocations.tsv and leases.tsv are kept
+const DefaultDir = "/var/lib/basphere/ipam"
+
+// Config describes the managed network
+// Defaults match load_network_config in ipam-common.sh.
+type Config struct {
+	// Whole network range (e.g., "10.254.0.0/21")
+	CIDR string `yaml:"cidr"`
+	// Gateway address, always reserved
+	Gateway string `yaml:"gateway"`
+	// Number of addresses in a user block (32 = /27)
+	BlockSize int `yaml:"block_size"`
+	// Addresses that are never leased
+	Reserved []string `yaml:"reserved"`
+	// Maximum number of leases per user (0 = block size)
+	MaxIPs int `yaml:"-"`
+}
+
+// DefaultConfig returns the network defaults used by the CLI scripts
+func DefaultConfig() Config {
+	return Config{
+		CIDR:      "10.254.0.0/21",
+		Gateway:   "10.254.0.1",
+		BlockSize: 32,
+	}
+}
+
+// LoadConfig reads the network section and the default IP quota from the CLI config.yaml
+// A missing file yields the defaults, like get_config in common.sh.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var file struct {
+		Network Config `yaml:"network"`
+		Quotas  struct {
+			Default struct {
+				MaxIPs int `yaml:"max_ips"`
+			} `yaml:"default"`
+		} `yaml:"quotas"`
+	}
+	file.Network = cfg
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return cfg, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	cfg = file.Network
+	cfg.MaxIPs = file.Quotas.Default.MaxIPs
+	return cfg, nil
+}
+
+// network is the parsed form of Config
+type network struct {
+	prefix    netip.Prefix
+	first     uint32
+	last      uint32
+	broadcast netip.Addr
+	gateway   netip.Addr
+	blockSize uint32
+	reserved  map[netip.Addr]bool
+	maxIPs    int
+}
+
+func (c Config) parse() (*network, error) {
+	prefix, err := netip.ParsePrefix(c.CIDR)
+	if err != nil || !prefix.Addr().Is4() {
+		return nil, fmt.Errorf("invalid network cidr: %q", c.CIDR)
+	}
+	prefix = prefix.Masked()
+
+	if c.BlockSize <= 0 || c.BlockSize&(c.BlockSize-1) != 0 {
+		return nil, fmt.Errorf("block size must be a power of two: %d", c.BlockSize)
+	}
+
+	size := uint64(1) << (32 - prefix.Bits())
+	if uint64(c.BlockSize)*2 > size {
+		return nil, fmt.Errorf("network %s is too small for blocks of %d", prefix, c.BlockSize)
+	}
+
+	n := &network{
+		prefix:    prefix,
+		first:     toUint32(prefix.Addr()),
+		blockSize: uint32(c.BlockSize),
+		reserved:  make(map[netip.Addr]bool),
+		maxIPs:    c.MaxIPs,
+	}
+	n.last = n.first + uint32(size-1)
+	n.broadcast = fromUint32(n.last)
+
+	if n.maxIPs <= 0 {
+		n.maxIPs = c.BlockSize
+	}
+
+	gateway, err := netip.ParseAddr(c.Gateway)
+	if err != nil || !prefix.Contains(gateway) {
+		return nil, fmt.Errorf("invalid gateway: %q", c.Gateway)
+	}
+	n.gateway = gateway
+	n.reserved[gateway] = true
+
+	for _, r := range c.Reserved {
+		ip, err := netip.ParseAddr(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reserved address: %q", r)
+		}
+		n.reserved[ip] = true
+	}
+
+	return n, nil
+}
+
+// available reports whether ip may be leased at all
+// The network and broadcast addresses and reserved addresses (including the gateway) never are.
+func (n *network) available(ip netip.Addr) bool {
+	return ip != n.prefix.Addr() && ip != n.broadcast && !n.reserved[ip]
+}
+
+func toUint32(ip netip.Addr) uint32 {
+	b := ip.As4()
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func fromUint32(v uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
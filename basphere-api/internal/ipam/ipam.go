@@ -0,0 +1,477 @@
+// Package ipam allocates per-user IP blocks and per-resource leases.
+//
+// It reads and writes the same files as the CLI scripts (allocate-block, allocate-ip,
+// release-ip) and takes the same flock on <dir>/.lock, so both can be used side by side:
+//
+//	allocations.tsv  user, block_start, allocated_at
+//	leases.tsv       ip, user, resource_name, resource_type, allocated_at
+package ipam
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned by IPAM operations
+var (
+	ErrNoBlock         = errors.New("no IP block allocated")
+	ErrNoFreeBlock     = errors.New("no free IP block")
+	ErrNoFreeIP        = errors.New("no free IP in block")
+	ErrQuotaExceeded   = errors.New("IP quota exceeded")
+	ErrLeaseNotFound   = errors.New("IP is not leased")
+	ErrNotLeaseOwner   = errors.New("IP is leased to another user")
+	ErrIPInUse         = errors.New("IP is already leased")
+	ErrIPReserved      = errors.New("IP is reserved")
+	ErrOutsideNetwork  = errors.New("IP is outside the managed network")
+	ErrLockUnavailable = errors.New("failed to acquire IPAM lock")
+)
+
+// lockTimeout matches acquire_lock's default in common.sh
+const lockTimeout = 30 * time.Second
+
+// Allocation is a block of addresses assigned to a user
+type Allocation struct {
+	User        string
+	BlockStart  netip.Addr
+	AllocatedAt time.Time
+}
+
+// Lease is an address assigned to a VM or cluster node
+type Lease struct {
+	IP           netip.Addr
+	User         string
+	ResourceName string
+	ResourceType string
+	AllocatedAt  time.Time
+}
+
+// IPAM manages the allocation files in one directory
+type IPAM struct {
+	dir     string
+	network *network
+
+	// Serializes goroutines; the file lock serializes processes
+	mu sync.Mutex
+}
+
+// New creates an IPAM for the allocation files in dir
+func New(dir string, cfg Config) (*IPAM, error) {
+	n, err := cfg.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &IPAM{dir: dir, network: n}, nil
+}
+
+// Network returns the managed network range
+func (m *IPAM) Network() netip.Prefix {
+	return m.network.prefix
+}
+
+// Gateway returns the network gateway
+func (m *IPAM) Gateway() netip.Addr {
+	return m.network.gateway
+}
+
+// BlockCount returns the number of blocks that can be assigned to users (all but the first)
+func (m *IPAM) BlockCount() int {
+	return int((uint64(m.network.last)-uint64(m.network.first)+1)/uint64(m.network.blockSize)) - 1
+}
+
+// BlockSize returns the number of addresses in a user block
+func (m *IPAM) BlockSize() int {
+	return int(m.network.blockSize)
+}
+
+// MaxIPs returns the maximum number of leases per user
+func (m *IPAM) MaxIPs() int {
+	return m.network.maxIPs
+}
+
+// BlockEnd returns the last address of the block starting at start
+func (m *IPAM) BlockEnd(start netip.Addr) netip.Addr {
+	return fromUint32(toUint32(start) + m.network.blockSize - 1)
+}
+
+// BlockContains reports whether ip lies in the block starting at start
+func (m *IPAM) BlockContains(start, ip netip.Addr) bool {
+	return !ip.Less(start) && !m.BlockEnd(start).Less(ip)
+}
+
+// Available reports whether ip could be leased (not reserved, gateway, network or broadcast)
+func (m *IPAM) Available(ip netip.Addr) bool {
+	return m.network.available(ip)
+}
+
+// AllocateBlock assigns the next free block to user
+// If user already has a block it is returned with created set to false.
+// The first block of the network is never assigned; it holds the gateway and infrastructure addresses.
+func (m *IPAM) AllocateBlock(user string) (alloc *Allocation, created bool, err error) {
+	err = m.locked(func() error {
+		allocations, err := m.readAllocations()
+		if err != nil {
+			return err
+		}
+
+		used := make(map[netip.Addr]bool)
+		for i := range allocations {
+			if allocations[i].User == user {
+				alloc = &allocations[i]
+				return nil
+			}
+			used[allocations[i].BlockStart] = true
+		}
+
+		n := m.network
+		for start := uint64(n.first) + uint64(n.blockSize); start+uint64(n.blockSize)-1 <= uint64(n.last); start += uint64(n.blockSize) {
+			addr := fromUint32(uint32(start))
+			if used[addr] {
+				continue
+			}
+
+			alloc = &Allocation{User: user, BlockStart: addr, AllocatedAt: now()}
+			created = true
+			return appendTSV(m.allocationsFile(), allocationsHeader,
+				user, addr.String(), alloc.AllocatedAt.Format(timestampFormat))
+		}
+
+		return ErrNoFreeBlock
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return alloc, created, nil
+}
+
+// AllocateIP leases the next free address in user's block to a resource
+// If the resource already holds a lease it is returned with created set to false.
+// maxIPs is the user's resolved IP quota; 0 applies the configured default (MaxIPs).
+func (m *IPAM) AllocateIP(user, resourceName, resourceType string, maxIPs int) (lease *Lease, created bool, err error) {
+	if resourceType == "" {
+		resourceType = "vm"
+	}
+	if maxIPs <= 0 {
+		maxIPs = m.network.maxIPs
+	}
+
+	err = m.locked(func() error {
+		alloc, err := m.userBlock(user)
+		if err != nil {
+			return err
+		}
+
+		leases, err := m.readLeases()
+		if err != nil {
+			return err
+		}
+
+		usage := 0
+		leased := make(map[netip.Addr]bool)
+		for i := range leases {
+			if leases[i].User == user {
+				if leases[i].ResourceName == resourceName {
+					lease = &leases[i]
+					return nil
+				}
+				usage++
+			}
+			leased[leases[i].IP] = true
+		}
+
+		if usage >= maxIPs {
+			return fmt.Errorf("%w (used: %d, max: %d)", ErrQuotaExceeded, usage, maxIPs)
+		}
+
+		start := toUint32(alloc.BlockStart)
+		for i := uint32(0); i < m.network.blockSize; i++ {
+			ip := fromUint32(start + i)
+			if leased[ip] || !m.network.available(ip) {
+				continue
+			}
+
+			lease = &Lease{
+				IP:           ip,
+				User:         user,
+				ResourceName: resourceName,
+				ResourceType: resourceType,
+				AllocatedAt:  now(),
+			}
+			created = true
+			return appendTSV(m.leasesFile(), leasesHeader,
+				ip.String(), user, resourceName, resourceType, lease.AllocatedAt.Format(timestampFormat))
+		}
+
+		return ErrNoFreeIP
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return lease, created, nil
+}
+
+// LeaseIP leases a given address to a resource, for VMs that already use it (adopted VMs)
+// Unlike AllocateIP the address need not be in user's block and the IP quota is not checked.
+// If the resource already holds this address its lease is returned with created set to false.
+func (m *IPAM) LeaseIP(ip netip.Addr, user, resourceName, resourceType string) (lease *Lease, created bool, err error) {
+	if !m.network.prefix.Contains(ip) {
+		return nil, false, ErrOutsideNetwork
+	}
+	if !m.network.available(ip) {
+		return nil, false, ErrIPReserved
+	}
+	if resourceType == "" {
+		resourceType = "vm"
+	}
+
+	err = m.locked(func() error {
+		leases, err := m.readLeases()
+		if err != nil {
+			return err
+		}
+
+		for i := range leases {
+			l := &leases[i]
+			switch {
+			case l.IP == ip && l.User == user && l.ResourceName == resourceName:
+				lease = l
+				return nil
+			case l.IP == ip:
+				return fmt.Errorf("%w (%s/%s)", ErrIPInUse, l.User, l.ResourceName)
+			case l.User == user && l.ResourceName == resourceName:
+				return fmt.Errorf("%s already holds %s", resourceName, l.IP)
+			}
+		}
+
+		lease = &Lease{
+			IP:           ip,
+			User:         user,
+			ResourceName: resourceName,
+			ResourceType: resourceType,
+			AllocatedAt:  now(),
+		}
+		created = true
+		return appendTSV(m.leasesFile(), leasesHeader,
+			ip.String(), user, resourceName, resourceType, lease.AllocatedAt.Format(timestampFormat))
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return lease, created, nil
+}
+
+// ReleaseIP removes the lease for ip and returns it
+// When user is not empty the lease must belong to user.
+func (m *IPAM) ReleaseIP(ip netip.Addr, user string) (*Lease, error) {
+	if !m.network.prefix.Contains(ip) {
+		return nil, ErrOutsideNetwork
+	}
+
+	var released *Lease
+	err := m.locked(func() error {
+		leases, err := m.readLeases()
+		if err != nil {
+			return err
+		}
+
+		for i := range leases {
+			if leases[i].IP == ip {
+				released = &leases[i]
+				break
+			}
+		}
+		if released == nil {
+			return ErrLeaseNotFound
+		}
+		if user != "" && released.User != user {
+			return fmt.Errorf("%w (owner: %s)", ErrNotLeaseOwner, released.User)
+		}
+
+		return removeTSV(m.leasesFile(), func(fields []string) bool {
+			return len(fields) > 0 && fields[0] == ip.String()
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// UserBlock returns the block assigned to user, or ErrNoBlock
+func (m *IPAM) UserBlock(user string) (*Allocation, error) {
+	var alloc *Allocation
+	err := m.locked(func() (err error) {
+		alloc, err = m.userBlock(user)
+		return err
+	})
+	return alloc, err
+}
+
+// Allocations returns all assigned blocks ordered by address
+func (m *IPAM) Allocations() ([]Allocation, error) {
+	var allocations []Allocation
+	err := m.locked(func() (err error) {
+		allocations, err = m.readAllocations()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].BlockStart.Less(allocations[j].BlockStart)
+	})
+	return allocations, nil
+}
+
+// Leases returns the leases of user (all leases when user is empty) ordered by address
+func (m *IPAM) Leases(user string) ([]Lease, error) {
+	var leases []Lease
+	err := m.locked(func() error {
+		all, err := m.readLeases()
+		if err != nil {
+			return err
+		}
+		for _, l := range all {
+			if user == "" || l.User == user {
+				leases = append(leases, l)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].IP.Less(leases[j].IP)
+	})
+	return leases, nil
+}
+
+// Snapshot returns all blocks and leases read under one lock, ordered by address
+func (m *IPAM) Snapshot() ([]Allocation, []Lease, error) {
+	var allocations []Allocation
+	var leases []Lease
+	err := m.locked(func() (err error) {
+		if allocations, err = m.readAllocations(); err != nil {
+			return err
+		}
+		leases, err = m.readLeases()
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].BlockStart.Less(allocations[j].BlockStart)
+	})
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].IP.Less(leases[j].IP)
+	})
+	return allocations, leases, nil
+}
+
+// Usage returns the number of addresses leased to user
+func (m *IPAM) Usage(user string) (int, error) {
+	leases, err := m.Leases(user)
+	return len(leases), err
+}
+
+// FreeInBlock returns how many addresses of the block starting at start can still be leased
+func (m *IPAM) FreeInBlock(start netip.Addr, leases []Lease) int {
+	leased := make(map[netip.Addr]bool, len(leases))
+	for _, l := range leases {
+		leased[l.IP] = true
+	}
+
+	free := 0
+	base := toUint32(start)
+	for i := uint32(0); i < m.network.blockSize; i++ {
+		ip := fromUint32(base + i)
+		if !leased[ip] && m.network.available(ip) {
+			free++
+		}
+	}
+	return free
+}
+
+// userBlock finds user's block; the lock must be held
+func (m *IPAM) userBlock(user string) (*Allocation, error) {
+	allocations, err := m.readAllocations()
+	if err != nil {
+		return nil, err
+	}
+	for i := range allocations {
+		if allocations[i].User == user {
+			return &allocations[i], nil
+		}
+	}
+	return nil, ErrNoBlock
+}
+
+func (m *IPAM) readAllocations() ([]Allocation, error) {
+	records, err := readTSV(m.allocationsFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allocations: %w", err)
+	}
+
+	allocations := make([]Allocation, 0, len(records))
+	for i, fields := range records {
+		a, err := parseAllocation(fields)
+		if err != nil {
+			return nil, fmt.Errorf("allocations.tsv record %d: %w", i+1, err)
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, nil
+}
+
+func (m *IPAM) readLeases() ([]Lease, error) {
+	records, err := readTSV(m.leasesFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leases: %w", err)
+	}
+
+	leases := make([]Lease, 0, len(records))
+	for i, fields := range records {
+		l, err := parseLease(fields)
+		if err != nil {
+			return nil, fmt.Errorf("leases.tsv record %d: %w", i+1, err)
+		}
+		leases = append(leases, l)
+	}
+	return leases, nil
+}
+
+// locked runs fn holding both the in-process mutex and the file lock shared with the scripts
+func (m *IPAM) locked(fn func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	unlock, err := lockFile(filepath.Join(m.dir, ".lock"), lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return fn()
+}
+
+func (m *IPAM) allocationsFile() string {
+	return filepath.Join(m.dir, "allocations.tsv")
+}
+
+func (m *IPAM) leasesFile() string {
+	return filepath.Join(m.dir, "leases.tsv")
+}
+
+// now is the allocation timestamp (second precision, as written to the files)
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
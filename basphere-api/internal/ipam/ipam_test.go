@@ -0,0 +1,541 @@
+package ipam
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+// setupTestIPAM creates an IPAM over a small /24 network with 32-address blocks
+func setupTestIPAM(t *testing.T, modify ...func(*Config)) (*IPAM, string) {
+	t.Helper()
+
+	cfg := Config{
+		CIDR:      "10.0.0.0/24",
+		Gateway:   "10.0.0.1",
+		BlockSize: 32,
+		Reserved:  []string{"10.0.0.2"},
+	}
+	for _, fn := range modify {
+		fn(&cfg)
+	}
+
+	dir := t.TempDir()
+	m, err := New(dir, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create IPAM: %v", err)
+	}
+	return m, dir
+}
+
+// writeFile writes a data file the way install.sh and the scripts do
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func mustAddr(s string) netip.Addr {
+	return netip.MustParseAddr(s)
+}
+
+// =============================================================================
+// Config Tests
+// =============================================================================
+
+func TestLoadConfig_MissingFileUsesDefaults(t *testing.T) {
+	cfg, err := LoadConfig(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.CIDR != "10.254.0.0/21" || cfg.Gateway != "10.254.0.1" || cfg.BlockSize != 32 {
+		t.Errorf("Expected script defaults, got %+v", cfg)
+	}
+}
+
+func TestLoadConfig_ReadsNetworkAndQuota(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+network:
+  cidr: "10.10.0.0/22"
+  gateway: "10.10.0.254"
+  block_size: 16
+  reserved:
+    - "10.10.0.253"
+quotas:
+  default:
+    max_vms: 10
+    max_ips: 8
+`), 0644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.CIDR != "10.10.0.0/22" || cfg.Gateway != "10.10.0.254" || cfg.BlockSize != 16 {
+		t.Errorf("Unexpected network config: %+v", cfg)
+	}
+	if len(cfg.Reserved) != 1 || cfg.Reserved[0] != "10.10.0.253" {
+		t.Errorf("Unexpected reserved addresses: %v", cfg.Reserved)
+	}
+	if cfg.MaxIPs != 8 {
+		t.Errorf("Expected MaxIPs 8, got %d", cfg.MaxIPs)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"invalid cidr", Config{CIDR: "10.0.0.0", Gateway: "10.0.0.1", BlockSize: 32}},
+		{"ipv6 cidr", Config{CIDR: "fd00::/64", Gateway: "fd00::1", BlockSize: 32}},
+		{"block size not power of two", Config{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", BlockSize: 30}},
+		{"network too small", Config{CIDR: "10.0.0.0/27", Gateway: "10.0.0.1", BlockSize: 32}},
+		{"gateway outside network", Config{CIDR: "10.0.0.0/24", Gateway: "10.0.1.1", BlockSize: 32}},
+		{"invalid reserved", Config{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", BlockSize: 32, Reserved: []string{"x"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(t.TempDir(), tt.cfg); err == nil {
+				t.Error("Expected error for invalid config")
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Block Allocation Tests
+// =============================================================================
+
+func TestAllocateBlock_SkipsFirstBlock(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+
+	alloc, created, err := m.AllocateBlock("alice")
+	if err != nil {
+		t.Fatalf("AllocateBlock failed: %v", err)
+	}
+	if !created {
+		t.Error("Expected a new block")
+	}
+	if alloc.BlockStart != mustAddr("10.0.0.32") {
+		t.Errorf("Expected first user block 10.0.0.32, got %s", alloc.BlockStart)
+	}
+	if m.BlockEnd(alloc.BlockStart) != mustAddr("10.0.0.63") {
+		t.Errorf("Expected block end 10.0.0.63, got %s", m.BlockEnd(alloc.BlockStart))
+	}
+
+	content := readFile(t, dir, "allocations.tsv")
+	if !strings.HasPrefix(content, allocationsHeader+"\n") {
+		t.Errorf("Expected header in new file, got %q", content)
+	}
+	if !strings.Contains(content, "alice\t10.0.0.32\t") {
+		t.Errorf("Expected allocation record, got %q", content)
+	}
+}
+
+func TestAllocateBlock_Idempotent(t *testing.T) {
+	m, _ := setupTestIPAM(t)
+
+	first, _, _ := m.AllocateBlock("alice")
+	again, created, err := m.AllocateBlock("alice")
+	if err != nil {
+		t.Fatalf("AllocateBlock failed: %v", err)
+	}
+	if created {
+		t.Error("Expected existing block to be returned")
+	}
+	if again.BlockStart != first.BlockStart {
+		t.Errorf("Expected %s, got %s", first.BlockStart, again.BlockStart)
+	}
+}
+
+func TestAllocateBlock_FillsGapsAndExhausts(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+
+	// Blocks written by the scripts, with a gap at 10.0.0.64
+	writeFile(t, dir, "allocations.tsv", allocationsHeader+"\n"+
+		"alice\t10.0.0.32\t2025-01-01T00:00:00Z\n"+
+		"bob\t10.0.0.96\t2025-01-02T00:00:00Z\n")
+
+	alloc, _, err := m.AllocateBlock("carol")
+	if err != nil {
+		t.Fatalf("AllocateBlock failed: %v", err)
+	}
+	if alloc.BlockStart != mustAddr("10.0.0.64") {
+		t.Errorf("Expected gap 10.0.0.64 to be reused, got %s", alloc.BlockStart)
+	}
+
+	// A /24 has 8 blocks; the first is never assigned
+	for i := 0; i < 4; i++ {
+		if _, _, err := m.AllocateBlock(fmt.Sprintf("user%d", i)); err != nil {
+			t.Fatalf("AllocateBlock %d failed: %v", i, err)
+		}
+	}
+	if _, _, err := m.AllocateBlock("late"); !errors.Is(err, ErrNoFreeBlock) {
+		t.Errorf("Expected ErrNoFreeBlock, got %v", err)
+	}
+
+	allocations, _ := m.Allocations()
+	if len(allocations) != 7 {
+		t.Errorf("Expected 7 allocations, got %d", len(allocations))
+	}
+	if allocations[0].User != "alice" || allocations[0].AllocatedAt.Year() != 2025 {
+		t.Errorf("Expected script-written allocation to be parsed, got %+v", allocations[0])
+	}
+}
+
+// =============================================================================
+// IP Lease Tests
+// =============================================================================
+
+func TestAllocateIP_RequiresBlock(t *testing.T) {
+	m, _ := setupTestIPAM(t)
+
+	if _, _, err := m.AllocateIP("alice", "web", "vm", 0); !errors.Is(err, ErrNoBlock) {
+		t.Errorf("Expected ErrNoBlock, got %v", err)
+	}
+}
+
+func TestAllocateIP_SequentialAndIdempotent(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+	m.AllocateBlock("alice")
+
+	tests := []struct {
+		resource string
+		wantIP   string
+		created  bool
+	}{
+		{"web", "10.0.0.32", true},
+		{"db", "10.0.0.33", true},
+		{"web", "10.0.0.32", false},
+	}
+
+	for _, tt := range tests {
+		lease, created, err := m.AllocateIP("alice", tt.resource, "", 0)
+		if err != nil {
+			t.Fatalf("AllocateIP(%s) failed: %v", tt.resource, err)
+		}
+		if lease.IP != mustAddr(tt.wantIP) || created != tt.created {
+			t.Errorf("AllocateIP(%s) = %s (created %v), expected %s (created %v)",
+				tt.resource, lease.IP, created, tt.wantIP, tt.created)
+		}
+		if lease.ResourceType != "vm" {
+			t.Errorf("Expected default type 'vm', got '%s'", lease.ResourceType)
+		}
+	}
+
+	if !strings.Contains(readFile(t, dir, "leases.tsv"), "10.0.0.33\talice\tdb\tvm\t") {
+		t.Error("Expected lease record in script format")
+	}
+}
+
+func TestAllocateIP_SkipsReservedGatewayAndBroadcast(t *testing.T) {
+	// Single user block in the last block: reserved, gateway and broadcast all inside it
+	m, _ := setupTestIPAM(t, func(c *Config) {
+		c.CIDR = "10.0.0.0/26"
+		c.Gateway = "10.0.0.33"
+		c.Reserved = []string{"10.0.0.34"}
+	})
+	m.AllocateBlock("alice")
+
+	lease, _, err := m.AllocateIP("alice", "first", "vm", 0)
+	if err != nil {
+		t.Fatalf("AllocateIP failed: %v", err)
+	}
+	if lease.IP != mustAddr("10.0.0.32") {
+		t.Errorf("Expected 10.0.0.32, got %s", lease.IP)
+	}
+
+	lease, _, _ = m.AllocateIP("alice", "second", "vm", 0)
+	if lease.IP != mustAddr("10.0.0.35") {
+		t.Errorf("Expected gateway and reserved address to be skipped, got %s", lease.IP)
+	}
+
+	for _, ip := range []string{"10.0.0.0", "10.0.0.33", "10.0.0.34", "10.0.0.63"} {
+		if m.Available(mustAddr(ip)) {
+			t.Errorf("Expected %s to be unavailable", ip)
+		}
+	}
+
+	alloc, _ := m.UserBlock("alice")
+	leases, _ := m.Leases("alice")
+	// 32 addresses - gateway - reserved - broadcast - 2 leases
+	if free := m.FreeInBlock(alloc.BlockStart, leases); free != 27 {
+		t.Errorf("Expected 27 free addresses, got %d", free)
+	}
+}
+
+func TestAllocateIP_Quota(t *testing.T) {
+	m, _ := setupTestIPAM(t, func(c *Config) { c.MaxIPs = 2 })
+	m.AllocateBlock("alice")
+
+	m.AllocateIP("alice", "a", "vm", 0)
+	m.AllocateIP("alice", "b", "vm", 0)
+
+	if _, _, err := m.AllocateIP("alice", "c", "vm", 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// Existing leases are still returned at the limit
+	if _, created, err := m.AllocateIP("alice", "a", "vm", 0); err != nil || created {
+		t.Errorf("Expected existing lease at quota, got created=%v err=%v", created, err)
+	}
+}
+
+func TestAllocateIP_PerCallLimit(t *testing.T) {
+	m, _ := setupTestIPAM(t, func(c *Config) { c.MaxIPs = 2 })
+	m.AllocateBlock("alice")
+
+	// A user override above the default is passed by the caller
+	for _, name := range []string{"a", "b", "c"} {
+		if _, _, err := m.AllocateIP("alice", name, "vm", 3); err != nil {
+			t.Fatalf("AllocateIP(%s) with limit 3 failed: %v", name, err)
+		}
+	}
+	if _, _, err := m.AllocateIP("alice", "d", "vm", 3); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded at the per-call limit, got %v", err)
+	}
+
+	// A limit below the default applies as well
+	m.AllocateBlock("bob")
+	m.AllocateIP("bob", "a", "vm", 1)
+	if _, _, err := m.AllocateIP("bob", "b", "vm", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded at limit 1, got %v", err)
+	}
+}
+
+func TestAllocateIP_BlockFull(t *testing.T) {
+	m, _ := setupTestIPAM(t, func(c *Config) { c.BlockSize = 4; c.MaxIPs = 100 })
+	m.AllocateBlock("alice") // 10.0.0.4 - 10.0.0.7
+
+	for i := 0; i < 4; i++ {
+		if _, _, err := m.AllocateIP("alice", fmt.Sprintf("vm%d", i), "vm", 0); err != nil {
+			t.Fatalf("AllocateIP %d failed: %v", i, err)
+		}
+	}
+	if _, _, err := m.AllocateIP("alice", "extra", "vm", 0); !errors.Is(err, ErrNoFreeIP) {
+		t.Errorf("Expected ErrNoFreeIP, got %v", err)
+	}
+}
+
+// =============================================================================
+// Release Tests
+// =============================================================================
+
+func TestReleaseIP(t *testing.T) {
+	m, _ := setupTestIPAM(t)
+	m.AllocateBlock("alice")
+	m.AllocateBlock("bob")
+	web, _, _ := m.AllocateIP("alice", "web", "vm", 0)
+	m.AllocateIP("bob", "api", "vm", 0)
+
+	tests := []struct {
+		name    string
+		ip      string
+		user    string
+		wantErr error
+	}{
+		{"other owner", web.IP.String(), "bob", ErrNotLeaseOwner},
+		{"outside network", "192.168.0.10", "", ErrOutsideNetwork},
+		{"not leased", "10.0.0.50", "", ErrLeaseNotFound},
+		{"owner", web.IP.String(), "alice", nil},
+		{"already released", web.IP.String(), "alice", ErrLeaseNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lease, err := m.ReleaseIP(mustAddr(tt.ip), tt.user)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && lease.ResourceName != "web" {
+				t.Errorf("Expected released lease for 'web', got %+v", lease)
+			}
+		})
+	}
+
+	if n, _ := m.Usage("alice"); n != 0 {
+		t.Errorf("Expected alice to have no leases, got %d", n)
+	}
+	if n, _ := m.Usage("bob"); n != 1 {
+		t.Errorf("Expected bob's lease to be kept, got %d", n)
+	}
+
+	// The released address is leased again
+	again, _, _ := m.AllocateIP("alice", "web2", "vm", 0)
+	if again.IP != web.IP {
+		t.Errorf("Expected released IP %s to be reused, got %s", web.IP, again.IP)
+	}
+}
+
+func TestLeaseIP(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+	m.AllocateBlock("alice")
+	web, _, _ := m.AllocateIP("alice", "web", "vm", 0)
+
+	tests := []struct {
+		name     string
+		ip       string
+		resource string
+		created  bool
+		wantErr  error
+	}{
+		{"outside network", "192.168.0.10", "db", false, ErrOutsideNetwork},
+		{"reserved", "10.0.0.2", "db", false, ErrIPReserved},
+		{"gateway", "10.0.0.1", "db", false, ErrIPReserved},
+		{"leased to another resource", web.IP.String(), "db", false, ErrIPInUse},
+		{"outside the user's block", "10.0.0.100", "db", true, nil},
+		{"same lease again", "10.0.0.100", "db", false, nil},
+		{"same lease as allocated", web.IP.String(), "web", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lease, created, err := m.LeaseIP(mustAddr(tt.ip), "alice", tt.resource, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if lease.IP != mustAddr(tt.ip) || created != tt.created {
+				t.Errorf("LeaseIP(%s) = %s (created %v), expected created %v", tt.ip, lease.IP, created, tt.created)
+			}
+		})
+	}
+
+	if _, _, err := m.LeaseIP(mustAddr("10.0.0.101"), "alice", "web", "vm"); err == nil {
+		t.Error("Expected an error for a resource that holds another address")
+	}
+	if !strings.Contains(readFile(t, dir, "leases.tsv"), "10.0.0.100\talice\tdb\tvm\t") {
+		t.Error("Expected lease record in script format")
+	}
+}
+
+func TestReleaseIP_PreservesFile(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+	writeFile(t, dir, "allocations.tsv", "alice\t10.0.0.32\t2025-01-01T00:00:00Z\n")
+	writeFile(t, dir, "leases.tsv", leasesHeader+"\n"+
+		"10.0.0.32\talice\tweb\tvm\t2025-01-01T00:00:00Z\n"+
+		"10.0.0.33\talice\tk8s-cp\tcluster-node\t2025-01-01T00:00:00Z\n")
+	os.Chmod(filepath.Join(dir, "leases.tsv"), 0666)
+
+	if _, err := m.ReleaseIP(mustAddr("10.0.0.32"), ""); err != nil {
+		t.Fatalf("ReleaseIP failed: %v", err)
+	}
+
+	want := leasesHeader + "\n10.0.0.33\talice\tk8s-cp\tcluster-node\t2025-01-01T00:00:00Z\n"
+	if got := readFile(t, dir, "leases.tsv"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	info, _ := os.Stat(filepath.Join(dir, "leases.tsv"))
+	if info.Mode().Perm() != 0666 {
+		t.Errorf("Expected file mode 0666 to be kept, got %o", info.Mode().Perm())
+	}
+}
+
+// =============================================================================
+// Data File Tests
+// =============================================================================
+
+func TestLeases_CorruptRecord(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+	writeFile(t, dir, "leases.tsv", leasesHeader+"\nnot-an-ip\talice\tweb\tvm\n")
+
+	if _, err := m.Leases(""); err == nil || !strings.Contains(err.Error(), "record 1") {
+		t.Errorf("Expected error naming the corrupt record, got %v", err)
+	}
+}
+
+func TestLeases_FilterAndOrder(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+	writeFile(t, dir, "leases.tsv", leasesHeader+"\n"+
+		"10.0.0.40\talice\tb\tvm\t2025-01-01T00:00:00Z\n"+
+		"\n"+
+		"10.0.0.64\tbob\tc\tvm\t2025-01-01T00:00:00Z\n"+
+		"10.0.0.35\talice\ta\tvm\t2025-01-01T00:00:00Z\n")
+
+	leases, err := m.Leases("alice")
+	if err != nil {
+		t.Fatalf("Leases failed: %v", err)
+	}
+	if len(leases) != 2 || leases[0].ResourceName != "a" || leases[1].ResourceName != "b" {
+		t.Errorf("Expected alice's leases ordered by IP, got %+v", leases)
+	}
+
+	all, _ := m.Leases("")
+	if len(all) != 3 {
+		t.Errorf("Expected 3 leases, got %d", len(all))
+	}
+}
+
+// =============================================================================
+// Concurrency Tests
+// =============================================================================
+
+func TestAllocateIP_ConcurrentInstances(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+	m.AllocateBlock("alice")
+
+	// Separate instances only share the file lock, like the API and a script would
+	other, err := New(dir, Config{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", BlockSize: 32})
+	if err != nil {
+		t.Fatalf("Failed to create IPAM: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			instance := m
+			if i%2 == 1 {
+				instance = other
+			}
+			if _, _, err := instance.AllocateIP("alice", fmt.Sprintf("vm%d", i), "vm", 0); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("AllocateIP failed: %v", err)
+	}
+
+	leases, _ := m.Leases("alice")
+	seen := make(map[netip.Addr]bool)
+	for _, l := range leases {
+		if seen[l.IP] {
+			t.Errorf("IP %s leased twice", l.IP)
+		}
+		seen[l.IP] = true
+	}
+	if len(leases) != 20 {
+		t.Errorf("Expected 20 leases, got %d", len(leases))
+	}
+}
@@ -0,0 +1,52 @@
+//go:build unix
+
+package ipam
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// File Lock Tests
+// =============================================================================
+
+func TestAllocateBlock_WaitsForScriptLock(t *testing.T) {
+	m, dir := setupTestIPAM(t)
+
+	// Hold the lock the way acquire_lock in common.sh does (flock on .lock)
+	f, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("Failed to open lock file: %v", err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := m.AllocateBlock("alice")
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Expected AllocateBlock to wait for the lock")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("AllocateBlock failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected AllocateBlock to continue after the lock was released")
+	}
+}
@@ -0,0 +1,150 @@
+package ipam
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File headers written by install.sh (init_ipam)
+const (
+	allocationsHeader = "# user\tblock_start\tallocated_at"
+	leasesHeader      = "# ip\tuser\tresource_name\tresource_type\tallocated_at"
+)
+
+// timestampFormat matches get_timestamp in common.sh
+const timestampFormat = "2006-01-02T15:04:05Z"
+
+// readTSV returns the fields of every record in path, skipping comments and blank lines
+// A missing file has no records.
+func readTSV(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		records = append(records, strings.Split(line, "\t"))
+	}
+	return records, scanner.Err()
+}
+
+// appendTSV appends one record to path, creating it with header when missing
+func appendTSV(path, header string, fields ...string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if os.IsNotExist(err) {
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintln(f, header)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintln(f, strings.Join(fields, "\t")); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// removeTSV rewrites path without the records for which drop returns true
+// Comments and every other line are kept as they are, and the file keeps its permissions.
+func removeTSV(path string, drop func(fields []string) bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" && !strings.HasPrefix(line, "#") && drop(strings.Split(strings.TrimRight(line, "\r"), "\t")) {
+			continue
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// parseAllocation parses an allocations.tsv record: user, block_start, allocated_at
+func parseAllocation(fields []string) (Allocation, error) {
+	if len(fields) < 2 {
+		return Allocation{}, fmt.Errorf("expected at least 2 fields, got %d", len(fields))
+	}
+
+	start, err := netip.ParseAddr(fields[1])
+	if err != nil || !start.Is4() {
+		return Allocation{}, fmt.Errorf("invalid block start: %q", fields[1])
+	}
+
+	a := Allocation{User: fields[0], BlockStart: start}
+	if len(fields) > 2 {
+		a.AllocatedAt, _ = time.Parse(timestampFormat, fields[2])
+	}
+	return a, nil
+}
+
+// parseLease parses a leases.tsv record: ip, user, resource_name, resource_type, allocated_at
+func parseLease(fields []string) (Lease, error) {
+	if len(fields) < 3 {
+		return Lease{}, fmt.Errorf("expected at least 3 fields, got %d", len(fields))
+	}
+
+	ip, err := netip.ParseAddr(fields[0])
+	if err != nil || !ip.Is4() {
+		return Lease{}, fmt.Errorf("invalid IP: %q", fields[0])
+	}
+
+	l := Lease{IP: ip, User: fields[1], ResourceName: fields[2]}
+	if len(fields) > 3 {
+		l.ResourceType = fields[3]
+	}
+	if len(fields) > 4 {
+		l.AllocatedAt, _ = time.Parse(timestampFormat, fields[4])
+	}
+	return l, nil
+}
@@ -0,0 +1,291 @@
+// Package jobs runs long provisioning operations in the background.
+// Jobs are persisted through store.JobStore on every state change; on startup, jobs that were
+// queued or running when the server stopped are put back on the queue.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/store"
+)
+
+// MaxAttempts bounds how often a job is resumed after being interrupted by a restart
+const MaxAttempts = 3
+
+// Runner executes a job and returns its result
+// job.Attempts is greater than 1 when the job is resumed after an interrupted run
+type Runner func(ctx context.Context, job *model.Job) (interface{}, error)
+
+// Manager queues jobs and executes them on a fixed number of workers
+type Manager struct {
+	store   *store.JobStore
+	workers int
+	runners map[model.JobType]Runner
+
+	mu    sync.Mutex
+	queue []string
+	wake  chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a job manager; call Register for each job type, then Start
+func NewManager(s *store.JobStore, workers int) *Manager {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Manager{
+		store:   s,
+		workers: workers,
+		runners: make(map[model.JobType]Runner),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Register sets the runner for a job type
+func (m *Manager) Register(jobType model.JobType, runner Runner) {
+	m.runners[jobType] = runner
+}
+
+// Start recovers unfinished jobs and starts the workers
+func (m *Manager) Start() error {
+	jobs, err := m.store.List()
+	if err != nil {
+		return fmt.Errorf("failed to recover jobs: %w", err)
+	}
+
+	// List is newest first; resume in submission order
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := jobs[i]
+		if job.IsFinished() {
+			continue
+		}
+
+		if job.Status == model.JobStatusRunning {
+			log.Printf("Resuming job %s (%s) interrupted by restart", job.ID, job.Type)
+			job.Status = model.JobStatusQueued
+			if err := m.store.Save(job); err != nil {
+				return err
+			}
+		}
+		m.enqueue(job.ID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	for i := 0; i < m.workers; i++ {
+		m.wg.Add(1)
+		go m.worker(ctx)
+	}
+
+	return nil
+}
+
+// Stop signals running jobs to stop and waits for the workers to exit
+// Jobs still running are left in the running state and resumed on the next Start
+func (m *Manager) Stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Submit persists a new job and puts it on the queue
+func (m *Manager) Submit(jobType model.JobType, owner string, input interface{}) (*model.Job, error) {
+	if _, ok := m.runners[jobType]; !ok {
+		return nil, fmt.Errorf("unknown job type: %s", jobType)
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job input: %w", err)
+	}
+
+	job := &model.Job{
+		ID:        uuid.New().String(),
+		Type:      jobType,
+		Owner:     owner,
+		Status:    model.JobStatusQueued,
+		Input:     data,
+		CreatedAt: time.Now(),
+	}
+
+	if err := m.store.Save(job); err != nil {
+		return nil, err
+	}
+
+	m.enqueue(job.ID)
+	return job, nil
+}
+
+// Get retrieves a job by ID
+func (m *Manager) Get(id string) (*model.Job, error) {
+	return m.store.Get(id)
+}
+
+// List returns the jobs owned by a user, newest first
+func (m *Manager) List(owner string) ([]*model.Job, error) {
+	jobs, err := m.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []*model.Job
+	for _, job := range jobs {
+		if job.Owner == owner {
+			filtered = append(filtered, job)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Prune removes finished jobs older than the retention period
+func (m *Manager) Prune(retention time.Duration) (int, error) {
+	return m.store.DeleteFinishedBefore(time.Now().Add(-retention))
+}
+
+// Internal methods
+
+func (m *Manager) enqueue(id string) {
+	m.mu.Lock()
+	m.queue = append(m.queue, id)
+	m.mu.Unlock()
+
+	m.signal()
+}
+
+// signal wakes one idle worker without blocking
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) dequeue() (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.queue) == 0 {
+		return "", false
+	}
+	id := m.queue[0]
+	m.queue = m.queue[1:]
+
+	// More work left: make sure another worker picks it up
+	if len(m.queue) > 0 {
+		m.signal()
+	}
+	return id, true
+}
+
+func (m *Manager) worker(ctx context.Context) {
+	defer m.wg.Done()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		id, ok := m.dequeue()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.wake:
+			}
+			continue
+		}
+
+		m.run(ctx, id)
+	}
+}
+
+func (m *Manager) run(ctx context.Context, id string) {
+	job, err := m.store.Get(id)
+	if err != nil {
+		log.Printf("Warning: failed to load job %s: %v", id, err)
+		return
+	}
+	if job.Status != model.JobStatusQueued {
+		return
+	}
+
+	now := time.Now()
+	job.Status = model.JobStatusRunning
+	job.StartedAt = &now
+	job.Attempts++
+
+	if job.Attempts > MaxAttempts {
+		m.finish(job, nil, fmt.Errorf("job interrupted %d times, giving up", MaxAttempts))
+		return
+	}
+
+	if err := m.store.Save(job); err != nil {
+		log.Printf("Warning: failed to save job %s: %v", id, err)
+		return
+	}
+
+	result, err := m.execute(ctx, job)
+
+	// Shutting down: keep the job running so it is resumed on the next start
+	if ctx.Err() != nil && err != nil {
+		return
+	}
+
+	m.finish(job, result, err)
+}
+
+// execute calls the runner, converting a panic into a job failure
+func (m *Manager) execute(ctx context.Context, job *model.Job) (result interface{}, err error) {
+	runner, ok := m.runners[job.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown job type: %s", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return runner(ctx, job)
+}
+
+func (m *Manager) finish(job *model.Job, result interface{}, err error) {
+	now := time.Now()
+	job.FinishedAt = &now
+
+	if result != nil {
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil && err == nil {
+			err = fmt.Errorf("failed to marshal job result: %w", marshalErr)
+		}
+		job.Result = data
+	}
+
+	if err != nil {
+		job.Status = model.JobStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = model.JobStatusSucceeded
+		job.Error = ""
+	}
+
+	if err := m.store.Save(job); err != nil {
+		log.Printf("Warning: failed to save job %s: %v", job.ID, err)
+	}
+}
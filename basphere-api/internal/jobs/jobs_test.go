@@ -0,0 +1,224 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/store"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+const testJobType model.JobType = "test"
+
+func setupStore(t *testing.T) *store.JobStore {
+	t.Helper()
+
+	s, err := store.NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create job store: %v", err)
+	}
+	return s
+}
+
+func startManager(t *testing.T, s *store.JobStore, runner Runner) *Manager {
+	t.Helper()
+
+	m := NewManager(s, 2)
+	m.Register(testJobType, runner)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+
+	return m
+}
+
+// waitFinished polls until the job reaches a terminal state
+func waitFinished(t *testing.T, m *Manager, id string) *model.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Failed to get job: %v", err)
+		}
+		if job.IsFinished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Job %s did not finish in time", id)
+	return nil
+}
+
+// =============================================================================
+// Execution Tests
+// =============================================================================
+
+func TestSubmit_Succeeded(t *testing.T) {
+	m := startManager(t, setupStore(t), func(ctx context.Context, job *model.Job) (interface{}, error) {
+		var input map[string]string
+		json.Unmarshal(job.Input, &input)
+		return map[string]string{"echo": input["name"]}, nil
+	})
+
+	job, err := m.Submit(testJobType, "testuser", map[string]string{"name": "myvm"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if job.Status != model.JobStatusQueued {
+		t.Errorf("Expected status queued, got %s", job.Status)
+	}
+
+	done := waitFinished(t, m, job.ID)
+	if done.Status != model.JobStatusSucceeded {
+		t.Fatalf("Expected status succeeded, got %s (%s)", done.Status, done.Error)
+	}
+	if done.StartedAt == nil || done.FinishedAt == nil {
+		t.Error("Expected started_at and finished_at to be set")
+	}
+	var result map[string]string
+	if err := json.Unmarshal(done.Result, &result); err != nil || result["echo"] != "myvm" {
+		t.Errorf("Unexpected result: %s", done.Result)
+	}
+}
+
+func TestSubmit_Failed(t *testing.T) {
+	m := startManager(t, setupStore(t), func(ctx context.Context, job *model.Job) (interface{}, error) {
+		return nil, errors.New("terraform apply failed")
+	})
+
+	job, _ := m.Submit(testJobType, "testuser", nil)
+	done := waitFinished(t, m, job.ID)
+
+	if done.Status != model.JobStatusFailed {
+		t.Errorf("Expected status failed, got %s", done.Status)
+	}
+	if done.Error != "terraform apply failed" {
+		t.Errorf("Expected error message, got %q", done.Error)
+	}
+}
+
+func TestSubmit_PanicFailsJob(t *testing.T) {
+	m := startManager(t, setupStore(t), func(ctx context.Context, job *model.Job) (interface{}, error) {
+		panic("boom")
+	})
+
+	job, _ := m.Submit(testJobType, "testuser", nil)
+	if done := waitFinished(t, m, job.ID); done.Status != model.JobStatusFailed {
+		t.Errorf("Expected status failed, got %s", done.Status)
+	}
+}
+
+func TestSubmit_UnknownType(t *testing.T) {
+	m := NewManager(setupStore(t), 1)
+	if _, err := m.Submit("unknown", "testuser", nil); err == nil {
+		t.Error("Expected error for unknown job type")
+	}
+}
+
+func TestList_FiltersByOwner(t *testing.T) {
+	m := startManager(t, setupStore(t), func(ctx context.Context, job *model.Job) (interface{}, error) {
+		return nil, nil
+	})
+
+	m.Submit(testJobType, "alice", nil)
+	m.Submit(testJobType, "alice", nil)
+	m.Submit(testJobType, "bob", nil)
+
+	jobs, err := m.List("alice")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("Expected 2 jobs for alice, got %d", len(jobs))
+	}
+}
+
+// =============================================================================
+// Recovery Tests
+// =============================================================================
+
+func TestStart_ResumesInterruptedJobs(t *testing.T) {
+	s := setupStore(t)
+
+	// Simulate jobs left behind by a server that stopped mid-run
+	started := time.Now().Add(-time.Minute)
+	interrupted := &model.Job{
+		ID: "job-running", Type: testJobType, Owner: "testuser",
+		Status: model.JobStatusRunning, Attempts: 1,
+		CreatedAt: started, StartedAt: &started,
+	}
+	queued := &model.Job{
+		ID: "job-queued", Type: testJobType, Owner: "testuser",
+		Status: model.JobStatusQueued, CreatedAt: started.Add(time.Second),
+	}
+	s.Save(interrupted)
+	s.Save(queued)
+
+	m := startManager(t, s, func(ctx context.Context, job *model.Job) (interface{}, error) {
+		return nil, nil
+	})
+
+	for _, id := range []string{"job-running", "job-queued"} {
+		if done := waitFinished(t, m, id); done.Status != model.JobStatusSucceeded {
+			t.Errorf("Expected %s to succeed after restart, got %s", id, done.Status)
+		}
+	}
+
+	resumed, _ := m.Get("job-running")
+	if resumed.Attempts != 2 {
+		t.Errorf("Expected resumed job to be on attempt 2, got %d", resumed.Attempts)
+	}
+}
+
+func TestStart_GivesUpAfterMaxAttempts(t *testing.T) {
+	s := setupStore(t)
+
+	started := time.Now()
+	s.Save(&model.Job{
+		ID: "job-crashloop", Type: testJobType, Owner: "testuser",
+		Status: model.JobStatusRunning, Attempts: MaxAttempts,
+		CreatedAt: started, StartedAt: &started,
+	})
+
+	m := startManager(t, s, func(ctx context.Context, job *model.Job) (interface{}, error) {
+		t.Error("Runner must not be called after max attempts")
+		return nil, nil
+	})
+
+	if done := waitFinished(t, m, "job-crashloop"); done.Status != model.JobStatusFailed {
+		t.Errorf("Expected status failed, got %s", done.Status)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := setupStore(t)
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	recent := time.Now()
+	s.Save(&model.Job{ID: "old", Status: model.JobStatusSucceeded, CreatedAt: old, FinishedAt: &old})
+	s.Save(&model.Job{ID: "recent", Status: model.JobStatusFailed, CreatedAt: recent, FinishedAt: &recent})
+	s.Save(&model.Job{ID: "queued", Status: model.JobStatusQueued, CreatedAt: old})
+
+	m := NewManager(s, 1)
+	pruned, err := m.Prune(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Expected 1 pruned job, got %d", pruned)
+	}
+	if _, err := s.Get("queued"); err != nil {
+		t.Error("Expected unfinished job to be kept")
+	}
+}
@@ -0,0 +1,245 @@
+// Package labels validates resource labels and matches them against selectors.
+//
+// Keys, values and selectors follow the Kubernetes label syntax:
+//
+//	env=dev,tier!=db            equality (= or ==) and inequality
+//	tier in (web,api),!legacy   set membership (in, notin), existence (key, !key)
+//
+// Requirements are separated by commas and must all match.
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on labels
+const (
+	MaxLabels      = 32
+	MaxNameLength  = 63
+	MaxValueLength = 63
+	// Prefix of a key such as example.com/owner
+	MaxPrefixLength = 253
+)
+
+var (
+	namePattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	prefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	setPattern    = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// ValidateKey checks a label key: an optional DNS subdomain prefix and slash, then a name
+func ValidateKey(key string) error {
+	name := key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if prefix == "" || len(prefix) > MaxPrefixLength || !prefixPattern.MatchString(prefix) {
+			return fmt.Errorf("label key %q: prefix must be a lowercase DNS subdomain", key)
+		}
+	}
+	if name == "" || len(name) > MaxNameLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("label key %q: name must be 1-%d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric", key, MaxNameLength)
+	}
+	return nil
+}
+
+// ValidateValue checks a label value, which may be empty
+func ValidateValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > MaxValueLength || !namePattern.MatchString(value) {
+		return fmt.Errorf("label value %q: must be at most %d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric", value, MaxValueLength)
+	}
+	return nil
+}
+
+// Validate checks every key and value of a label set and its size
+func Validate(labels map[string]string) []string {
+	var errors []string
+
+	if len(labels) > MaxLabels {
+		errors = append(errors, fmt.Sprintf("at most %d labels are allowed", MaxLabels))
+	}
+
+	// Sorted so the errors are stable
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := ValidateKey(k); err != nil {
+			errors = append(errors, err.Error())
+		}
+		if err := ValidateValue(labels[k]); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	return errors
+}
+
+// Operator is the comparison of a selector requirement
+type Operator string
+
+const (
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+)
+
+// Requirement is a single condition of a selector
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Matches reports whether the labels satisfy the requirement
+// As in Kubernetes, != and notin also match resources without the key.
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && r.hasValue(value)
+	case NotEquals, NotIn:
+		return !ok || !r.hasValue(value)
+	}
+	return false
+}
+
+func (r Requirement) hasValue(value string) bool {
+	for _, v := range r.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector is a set of requirements that must all match
+// The empty selector matches everything.
+type Selector []Requirement
+
+// Matches reports whether the labels satisfy every requirement of the selector
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty reports whether the selector has no requirements
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// Parse parses a selector such as "env=dev,tier in (web,api),!legacy"
+func Parse(selector string) (Selector, error) {
+	var s Selector
+
+	parts, err := splitRequirements(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range parts {
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, r)
+	}
+
+	return s, nil
+}
+
+// splitRequirements splits a selector at the commas outside of parentheses
+func splitRequirements(selector string) ([]string, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in selector %q", selector)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in selector %q", selector)
+	}
+
+	return append(parts, selector[start:]), nil
+}
+
+// parseRequirement parses one comma-separated requirement
+func parseRequirement(s string) (Requirement, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Requirement{}, fmt.Errorf("empty requirement in selector")
+	}
+
+	var r Requirement
+	switch {
+	case strings.HasPrefix(s, "!"):
+		r = Requirement{Key: strings.TrimSpace(s[1:]), Operator: DoesNotExist}
+
+	case setPattern.MatchString(s):
+		m := setPattern.FindStringSubmatch(s)
+		r = Requirement{Key: m[1], Operator: Operator(m[2])}
+		for _, v := range strings.Split(m[3], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(v))
+		}
+
+	case strings.Contains(s, "!="):
+		key, value, _ := strings.Cut(s, "!=")
+		r = Requirement{Key: strings.TrimSpace(key), Operator: NotEquals, Values: []string{strings.TrimSpace(value)}}
+
+	case strings.Contains(s, "="):
+		key, value, _ := strings.Cut(s, "=")
+		value = strings.TrimPrefix(value, "=")
+		r = Requirement{Key: strings.TrimSpace(key), Operator: Equals, Values: []string{strings.TrimSpace(value)}}
+
+	default:
+		r = Requirement{Key: s, Operator: Exists}
+	}
+
+	if err := ValidateKey(r.Key); err != nil {
+		return Requirement{}, err
+	}
+	for _, v := range r.Values {
+		if err := ValidateValue(v); err != nil {
+			return Requirement{}, err
+		}
+	}
+
+	return r, nil
+}
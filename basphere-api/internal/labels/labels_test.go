@@ -0,0 +1,124 @@
+package labels
+
+import (
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Validation Tests
+// =============================================================================
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"env", true},
+		{"app.kubernetes.io/name", true},
+		{"team_a.tier-1", true},
+		{"", false},
+		{"-env", false},
+		{"env-", false},
+		{"Example.com/env", false},
+		{"/env", false},
+		{"example.com/", false},
+		{strings.Repeat("a", 63), true},
+		{strings.Repeat("a", 64), false},
+		{"has space", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := ValidateKey(tt.key)
+			if tt.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got %v", tt.key, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected %q to be invalid", tt.key)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if errors := Validate(map[string]string{"env": "dev", "owner": ""}); len(errors) != 0 {
+		t.Errorf("Expected no errors, got %v", errors)
+	}
+
+	errors := Validate(map[string]string{"env": "dev server", "-bad": "x"})
+	if len(errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errors)
+	}
+	if !strings.Contains(errors[0], `"-bad"`) || !strings.Contains(errors[1], `"dev server"`) {
+		t.Errorf("Expected errors sorted by key, got %v", errors)
+	}
+
+	many := map[string]string{}
+	for i := 0; i <= MaxLabels; i++ {
+		many[strings.Repeat("k", i+1)] = "v"
+	}
+	if errors := Validate(many); len(errors) != 1 {
+		t.Errorf("Expected label count error, got %v", errors)
+	}
+}
+
+// =============================================================================
+// Selector Tests
+// =============================================================================
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []string{
+		"env=dev,",
+		"tier in (web,api",
+		"tier in web)",
+		"env=dev server",
+		"=dev",
+		"!",
+		"env=a=b",
+	}
+
+	for _, selector := range tests {
+		t.Run(selector, func(t *testing.T) {
+			if _, err := Parse(selector); err == nil {
+				t.Errorf("Expected error for %q", selector)
+			}
+		})
+	}
+}
+
+func TestSelector_Matches(t *testing.T) {
+	web := map[string]string{"env": "dev", "tier": "web", "experiment": "a1"}
+	db := map[string]string{"env": "prod", "tier": "db"}
+	bare := map[string]string{}
+
+	tests := []struct {
+		selector string
+		matches  []bool // web, db, bare
+	}{
+		{"", []bool{true, true, true}},
+		{"env=dev", []bool{true, false, false}},
+		{"env==dev", []bool{true, false, false}},
+		{"env!=dev", []bool{false, true, true}},
+		{"tier in (web, db)", []bool{true, true, false}},
+		{"tier notin (web)", []bool{false, true, true}},
+		{"experiment", []bool{true, false, false}},
+		{"!experiment", []bool{false, true, true}},
+		{"env=dev,tier in (web,api),experiment", []bool{true, false, false}},
+		{" env = prod , !experiment ", []bool{false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			for i, labels := range []map[string]string{web, db, bare} {
+				if got := s.Matches(labels); got != tt.matches[i] {
+					t.Errorf("Expected %v for %v, got %v", tt.matches[i], labels, got)
+				}
+			}
+		})
+	}
+}
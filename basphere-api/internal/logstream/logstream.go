@@ -0,0 +1,293 @@
+// Package logstream records the output of provisioning scripts per resource
+// and lets clients follow it live or replay it after the run finished.
+package logstream
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Resource kinds that have provisioning logs
+const (
+	KindVM      = "vm"
+	KindCluster = "cluster"
+)
+
+// ErrNotFound is returned when no log exists for a resource
+var ErrNotFound = errors.New("log not found")
+
+// Store keeps one log file per resource under <dir>/<owner>/<kind>/<name>.log
+// Only the most recent run of a resource is kept; a new run replaces the previous log.
+type Store struct {
+	dir string
+
+	mu     sync.Mutex
+	active map[string]*Log
+	closed chan struct{}
+}
+
+// NewStore creates a log store in dir
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	return &Store{
+		dir:    dir,
+		active: make(map[string]*Log),
+		closed: make(chan struct{}),
+	}, nil
+}
+
+// Close ends all followers (used on server shutdown); running logs keep recording
+func (s *Store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
+// Closed reports whether Close was called
+func (s *Store) Closed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Create starts a new log for a resource, replacing the previous one
+// Followers of the previous log keep reading it until it finishes.
+func (s *Store) Create(owner, kind, name string) (*Log, error) {
+	path, err := s.path(owner, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Unlink instead of truncating so open readers of the old run are not affected
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove previous log: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log: %w", err)
+	}
+
+	l := &Log{
+		store:     s,
+		key:       path,
+		file:      file,
+		done:      make(chan struct{}),
+		followers: make(map[chan struct{}]struct{}),
+	}
+	s.active[path] = l
+	return l, nil
+}
+
+// Remove deletes the log of a resource (e.g., after the resource was deleted)
+func (s *Store) Remove(owner, kind, name string) error {
+	path, err := s.path(owner, kind, name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove log: %w", err)
+	}
+	return nil
+}
+
+// Follow streams the lines of a resource's log, starting from the beginning
+// While the run is in progress new lines are delivered as they are written; the channel
+// is closed once the run finished and every line was sent, or when ctx is done.
+func (s *Store) Follow(ctx context.Context, owner, kind, name string) (<-chan string, error) {
+	path, err := s.path(owner, kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open and subscribe atomically so no write falls between the two
+	s.mu.Lock()
+	file, err := os.Open(path)
+	if err != nil {
+		s.mu.Unlock()
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+
+	var done <-chan struct{}
+	wake := make(chan struct{}, 1)
+	l := s.active[path]
+	if l != nil {
+		done = l.subscribe(wake)
+	}
+	s.mu.Unlock()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		defer file.Close()
+		if l != nil {
+			defer l.unsubscribe(wake)
+		}
+
+		reader := bufio.NewReader(file)
+		var partial string
+
+		send := func(line string) bool {
+			select {
+			case lines <- line:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			// Send everything written so far; an unterminated line waits for the rest
+			for {
+				chunk, err := reader.ReadString('\n')
+				if err != nil {
+					partial += chunk
+					break
+				}
+				if !send(partial + strings.TrimSuffix(chunk, "\n")) {
+					return
+				}
+				partial = ""
+			}
+
+			if done == nil {
+				if partial != "" {
+					send(partial)
+				}
+				return
+			}
+
+			select {
+			case <-wake:
+			case <-done:
+				// Drain what was written before the run finished
+				done = nil
+			case <-s.closed:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return lines, nil
+}
+
+// path returns the log file of a resource, rejecting names that would escape the store
+func (s *Store) path(owner, kind, name string) (string, error) {
+	for _, part := range []string{owner, kind, name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", ErrNotFound
+		}
+	}
+	return filepath.Join(s.dir, owner, kind, name+".log"), nil
+}
+
+// Log is the output of one provisioning run while it is being recorded
+type Log struct {
+	store *Store
+	key   string
+
+	mu        sync.Mutex
+	file      *os.File
+	done      chan struct{}
+	followers map[chan struct{}]struct{}
+}
+
+var _ io.WriteCloser = (*Log)(nil)
+
+// Write appends output to the log and wakes its followers
+// Callers writing from several sources should write whole lines so they do not interleave.
+func (l *Log) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	n, err := l.file.Write(p)
+	for wake := range l.followers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+	return n, err
+}
+
+// Printf appends a formatted line to the log
+func (l *Log) Printf(format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	l.Write([]byte(line))
+}
+
+// Close finishes the run; followers receive the remaining lines and stop
+func (l *Log) Close() error {
+	l.store.mu.Lock()
+	if l.store.active[l.key] == l {
+		delete(l.store.active, l.key)
+	}
+	l.store.mu.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	close(l.done)
+	return err
+}
+
+// subscribe registers a follower woken on every write; the returned channel closes when the run finishes
+func (l *Log) subscribe(wake chan struct{}) <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.followers[wake] = struct{}{}
+	return l.done
+}
+
+func (l *Log) unsubscribe(wake chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.followers, wake)
+}
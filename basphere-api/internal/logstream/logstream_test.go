@@ -0,0 +1,187 @@
+package logstream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+func setupTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return s
+}
+
+// receive returns the next line, failing the test if none arrives in time
+func receive(t *testing.T, lines <-chan string) (string, bool) {
+	t.Helper()
+
+	select {
+	case line, ok := <-lines:
+		return line, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for log line")
+		return "", false
+	}
+}
+
+// =============================================================================
+// Follow Tests
+// =============================================================================
+
+func TestFollow_LiveThenFinished(t *testing.T) {
+	s := setupTestStore(t)
+
+	l, err := s.Create("testuser", KindVM, "web")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	l.Printf("first")
+
+	lines, err := s.Follow(context.Background(), "testuser", KindVM, "web")
+	if err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	if line, _ := receive(t, lines); line != "first" {
+		t.Errorf("Expected 'first', got '%s'", line)
+	}
+
+	// A line written in two parts is delivered once complete
+	l.Write([]byte("sec"))
+	l.Write([]byte("ond\nthird"))
+	if line, _ := receive(t, lines); line != "second" {
+		t.Errorf("Expected 'second', got '%s'", line)
+	}
+
+	l.Close()
+	if line, _ := receive(t, lines); line != "third" {
+		t.Errorf("Expected unterminated last line 'third', got '%s'", line)
+	}
+	if _, ok := receive(t, lines); ok {
+		t.Error("Expected channel to close after the run finished")
+	}
+}
+
+func TestFollow_Replay(t *testing.T) {
+	s := setupTestStore(t)
+
+	l, _ := s.Create("testuser", KindCluster, "dev1")
+	l.Printf("one")
+	l.Printf("two")
+	l.Close()
+
+	lines, err := s.Follow(context.Background(), "testuser", KindCluster, "dev1")
+	if err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	var got []string
+	for line := range lines {
+		got = append(got, line)
+	}
+	if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("Expected [one two], got %v", got)
+	}
+}
+
+func TestFollow_NewRunReplacesLog(t *testing.T) {
+	s := setupTestStore(t)
+
+	old, _ := s.Create("testuser", KindVM, "web")
+	old.Printf("old run")
+
+	lines, _ := s.Follow(context.Background(), "testuser", KindVM, "web")
+	if line, _ := receive(t, lines); line != "old run" {
+		t.Fatalf("Expected 'old run', got '%s'", line)
+	}
+
+	// The old follower keeps reading the old run
+	current, _ := s.Create("testuser", KindVM, "web")
+	current.Printf("new run")
+	current.Close()
+
+	old.Printf("old run finishing")
+	old.Close()
+	if line, _ := receive(t, lines); line != "old run finishing" {
+		t.Errorf("Expected old follower to stay on its run, got '%s'", line)
+	}
+
+	replay, _ := s.Follow(context.Background(), "testuser", KindVM, "web")
+	if line, _ := receive(t, replay); line != "new run" {
+		t.Errorf("Expected replay of the new run, got '%s'", line)
+	}
+}
+
+func TestFollow_StopsOnCancelAndClose(t *testing.T) {
+	s := setupTestStore(t)
+	l, _ := s.Create("testuser", KindVM, "web")
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, _ := s.Follow(ctx, "testuser", KindVM, "web")
+	cancel()
+	if _, ok := receive(t, lines); ok {
+		t.Error("Expected channel to close on cancel")
+	}
+
+	lines, _ = s.Follow(context.Background(), "testuser", KindVM, "web")
+	s.Close()
+	if _, ok := receive(t, lines); ok {
+		t.Error("Expected channel to close when the store is closed")
+	}
+	if !s.Closed() {
+		t.Error("Expected store to report closed")
+	}
+}
+
+func TestFollow_NotFound(t *testing.T) {
+	s := setupTestStore(t)
+
+	tests := []struct {
+		name  string
+		owner string
+		res   string
+	}{
+		{"missing log", "testuser", "nothere"},
+		{"path traversal", "testuser", ".."},
+		{"nested path", "testuser", "../otheruser/vm/web"},
+		{"empty owner", "", "web"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Follow(context.Background(), tt.owner, KindVM, tt.res); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s := setupTestStore(t)
+
+	l, _ := s.Create("testuser", KindVM, "web")
+	l.Close()
+
+	if err := s.Remove("testuser", KindVM, "web"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := s.Follow(context.Background(), "testuser", KindVM, "web"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after remove, got %v", err)
+	}
+
+	// Removing a missing log is not an error
+	if err := s.Remove("testuser", KindVM, "web"); err != nil {
+		t.Errorf("Expected no error for missing log, got %v", err)
+	}
+}
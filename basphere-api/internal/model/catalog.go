@@ -0,0 +1,31 @@
+package model
+
+// OSInfo represents an OS template VMs can be created from
+type OSInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DefaultUser string `json:"default_user"`
+}
+
+// SpecInfo represents a VM or cluster node spec
+type SpecInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Resources
+}
+
+// SpecListResponse represents the response for listing specs
+type SpecListResponse struct {
+	VMSpecs   []SpecInfo `json:"vm_specs"`
+	NodeSpecs []SpecInfo `json:"cluster_node_specs"`
+}
+
+// ClusterTypeInfo represents a cluster type and the nodes it is created with
+type ClusterTypeInfo struct {
+	Name              string `json:"name"`
+	Description       string `json:"description,omitempty"`
+	ControlPlaneCount int    `json:"control_plane_count"`
+	ControlPlaneSpec  string `json:"control_plane_spec"`
+	WorkerCount       int    `json:"worker_count"`
+	DefaultWorkerSpec string `json:"default_worker_spec,omitempty"`
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Cloud-init customization limits
+// user_data travels base64 encoded in a guestinfo property next to the platform's own cloud-config,
+// so it is kept well below the size vSphere accepts for VM extra config.
+const (
+	MaxUserDataBytes     = 32 * 1024
+	MaxSSHAuthorizedKeys = 10
+	MaxPackages          = 50
+)
+
+var (
+	// hostnamePattern matches a single DNS label (RFC 1123)
+	hostnamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	// packagePattern matches apt/dnf package names, optionally pinned (nginx, python3.12, nginx=1.24.*)
+	packagePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_:~*=-]*$`)
+)
+
+// validateCloudInit validates the optional cloud-init fields of a VM creation request
+// Keys are sanitized in place like registration keys.
+func (v *CreateVMInput) validateCloudInit() []string {
+	var errors []string
+
+	if v.Hostname != "" {
+		// Leaves room for the "-N" suffix added when count > 1
+		if len(v.Hostname) > 60 || !hostnamePattern.MatchString(v.Hostname) {
+			errors = append(errors, "hostname must be 1-60 characters, lowercase letters, numbers, and hyphens only")
+		}
+	}
+
+	if len(v.SSHAuthorizedKeys) > MaxSSHAuthorizedKeys {
+		errors = append(errors, fmt.Sprintf("ssh_authorized_keys must have at most %d keys", MaxSSHAuthorizedKeys))
+	}
+	for i, key := range v.SSHAuthorizedKeys {
+		key = sanitizeSSHKey(key)
+		v.SSHAuthorizedKeys[i] = key
+		if strings.Contains(key, "\n") || !isValidSSHPublicKey(key) {
+			errors = append(errors, fmt.Sprintf("ssh_authorized_keys[%d] must be a single SSH public key", i))
+		}
+	}
+
+	if len(v.Packages) > MaxPackages {
+		errors = append(errors, fmt.Sprintf("packages must have at most %d entries", MaxPackages))
+	}
+	for _, pkg := range v.Packages {
+		if len(pkg) > 100 || !packagePattern.MatchString(pkg) {
+			errors = append(errors, fmt.Sprintf("invalid package name: %q", pkg))
+		}
+	}
+
+	if v.UserData != "" {
+		errors = append(errors, validateUserData(v.UserData)...)
+	}
+
+	return errors
+}
+
+// validateUserData checks that user data is a cloud-config document cloud-init can merge
+func validateUserData(data string) []string {
+	if len(data) > MaxUserDataBytes {
+		return []string{fmt.Sprintf("user_data must be at most %d bytes", MaxUserDataBytes)}
+	}
+
+	// Scripts and MIME archives cannot be merged with the platform's cloud-config
+	if !strings.HasPrefix(data, "#cloud-config") {
+		return []string{"user_data must be a cloud-config document starting with #cloud-config"}
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return []string{fmt.Sprintf("user_data is not valid YAML: %v", err)}
+	}
+
+	return nil
+}
@@ -28,6 +28,11 @@ type Cluster struct {
 	CreatedAt         time.Time     `json:"created_at"`
 	ReadyAt           *time.Time    `json:"ready_at,omitempty"`
 	KubeconfigPath    string        `json:"kubeconfig_path,omitempty"`
+	// Free-form metadata set by the owner
+	Labels      map[string]string `json:"labels,omitempty"`
+	Description string            `json:"description,omitempty"`
+	// When the cluster is deleted by the reaper (nil = never)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // CreateClusterInput represents the input for creating a cluster
@@ -35,6 +40,13 @@ type CreateClusterInput struct {
 	Name       string `json:"name"`
 	Type       string `json:"type"`        // dev, standard
 	WorkerSpec string `json:"worker_spec"` // small, medium, large
+	// Optional free-form metadata
+	Labels      map[string]string `json:"labels,omitempty"`
+	Description string            `json:"description,omitempty"`
+	// Optional lifetime; the API resolves it to expires_at before queuing the job
+	LeaseInput
+	// IP quota resolved by the API for the owner when the job runs; not settable by users
+	MaxIPs int `json:"-"`
 }
 
 // Validate validates the cluster creation input
@@ -49,16 +61,15 @@ func (c *CreateClusterInput) Validate() []string {
 
 	if c.Type == "" {
 		errors = append(errors, "type is required")
-	} else if !isValidClusterType(c.Type) {
-		errors = append(errors, "type must be one of: dev, standard")
 	}
 
 	if c.WorkerSpec == "" {
 		errors = append(errors, "worker_spec is required")
-	} else if !isValidWorkerSpec(c.WorkerSpec) {
-		errors = append(errors, "worker_spec must be one of: small, medium, large")
 	}
 
+	errors = append(errors, validateMetadata(c.Labels, c.Description)...)
+	errors = append(errors, c.LeaseInput.Validate()...)
+
 	return errors
 }
 
@@ -82,26 +93,17 @@ func isValidClusterName(name string) bool {
 	return true
 }
 
-// isValidClusterType checks if cluster type is valid
-func isValidClusterType(t string) bool {
-	validTypes := []string{"dev", "standard"}
-	for _, v := range validTypes {
-		if t == v {
-			return true
-		}
-	}
-	return false
+// UpdateClusterInput represents the input for PATCH /clusters/{name}
+type UpdateClusterInput struct {
+	MetadataUpdate
 }
 
-// isValidWorkerSpec checks if worker spec is valid
-func isValidWorkerSpec(spec string) bool {
-	validSpecs := []string{"small", "medium", "large"}
-	for _, v := range validSpecs {
-		if spec == v {
-			return true
-		}
+// Validate validates the cluster update input
+func (u *UpdateClusterInput) Validate() []string {
+	if u.IsEmpty() {
+		return []string{"labels or description is required"}
 	}
-	return false
+	return u.MetadataUpdate.Validate()
 }
 
 // DeleteClusterInput represents the input for deleting a cluster
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Data disk limits
+// Data disks share the VM's first SCSI controller with the root disk (unit 7 is reserved for the controller).
+const (
+	MaxDataDisks  = 8
+	MaxDiskSizeGB = 4096
+)
+
+// Disk represents an additional data disk attached to a VM
+type Disk struct {
+	Name       string    `json:"name"`
+	SizeGB     int       `json:"size_gb"`
+	UnitNumber int       `json:"unit_number"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DiskAction represents a change to a VM's data disks
+type DiskAction string
+
+const (
+	DiskActionAttach DiskAction = "attach"
+	DiskActionGrow   DiskAction = "grow"
+	DiskActionDetach DiskAction = "detach"
+)
+
+// AttachDiskInput represents the input for attaching a data disk
+type AttachDiskInput struct {
+	Name   string `json:"name"`
+	SizeGB int    `json:"size_gb"`
+}
+
+// Validate validates the disk attach input
+func (d *AttachDiskInput) Validate() []string {
+	var errors []string
+
+	if d.Name == "" {
+		errors = append(errors, "name is required")
+	} else if !isValidVMName(d.Name) {
+		errors = append(errors, "name must be 1-30 characters, lowercase letters, numbers, and hyphens only")
+	}
+
+	errors = append(errors, validateDiskSize(d.SizeGB)...)
+
+	return errors
+}
+
+// ResizeDiskInput represents the input for growing a data disk
+type ResizeDiskInput struct {
+	SizeGB int `json:"size_gb"`
+}
+
+// Validate validates the disk resize input
+func (d *ResizeDiskInput) Validate() []string {
+	return validateDiskSize(d.SizeGB)
+}
+
+// validateDiskSize checks a requested disk size in GB
+func validateDiskSize(sizeGB int) []string {
+	if sizeGB < 1 || sizeGB > MaxDiskSizeGB {
+		return []string{fmt.Sprintf("size_gb must be between 1 and %d", MaxDiskSizeGB)}
+	}
+	return nil
+}
+
+// DiskJobInput is the input of a vm-disk job
+type DiskJobInput struct {
+	VM     string     `json:"vm"`
+	Action DiskAction `json:"action"`
+	Disk   string     `json:"disk"`
+	SizeGB int        `json:"size_gb,omitempty"`
+	// Disk space the change adds to the user's usage (counted against quota while the job runs)
+	Added Resources `json:"added"`
+}
+
+// DiskListResponse represents the response for listing the data disks of a VM
+type DiskListResponse struct {
+	Disks   []Disk `json:"disks"`
+	Total   int    `json:"total"`
+	TotalGB int    `json:"total_gb"`
+}
+
+// FindDataDisk returns the named data disk of the VM, or nil if it does not exist
+func (v *VM) FindDataDisk(name string) *Disk {
+	for i := range v.DataDisks {
+		if v.DataDisks[i].Name == name {
+			return &v.DataDisks[i]
+		}
+	}
+	return nil
+}
+
+// DataDiskGB returns the total size of the VM's data disks
+func (v *VM) DataDiskGB() int {
+	total := 0
+	for _, d := range v.DataDisks {
+		total += d.SizeGB
+	}
+	return total
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// DriftKind is a way the VM records, vCenter and IPAM disagree
+type DriftKind string
+
+const (
+	// A VM record without a VM in the owner's vSphere folder
+	DriftKindMissingVM DriftKind = "missing_vm"
+	// A VM in a user's vSphere folder without a record
+	DriftKindUnmanagedVM DriftKind = "unmanaged_vm"
+	// An IP lease without the VM or cluster it was allocated to
+	DriftKindOrphanIP DriftKind = "orphan_ip"
+)
+
+// DriftAction resolves a drift item
+type DriftAction string
+
+const (
+	// Write a record for an unmanaged VM
+	DriftActionAdopt DriftAction = "adopt"
+	// Delete the record (and its Terraform state), destroy the unmanaged VM or release the IP
+	DriftActionCleanup DriftAction = "cleanup"
+	// Keep the record or VM but mark it failed, so its owner sees it and can delete it
+	DriftActionMarkFailed DriftAction = "mark_failed"
+)
+
+// driftActions lists the actions available for each kind of drift
+var driftActions = map[DriftKind][]DriftAction{
+	DriftKindMissingVM:   {DriftActionCleanup, DriftActionMarkFailed},
+	DriftKindUnmanagedVM: {DriftActionAdopt, DriftActionCleanup, DriftActionMarkFailed},
+	DriftKindOrphanIP:    {DriftActionCleanup},
+}
+
+// Actions returns the actions that resolve this kind of drift
+func (k DriftKind) Actions() []DriftAction {
+	return driftActions[k]
+}
+
+// Allows reports whether the action resolves this kind of drift
+func (k DriftKind) Allows(action DriftAction) bool {
+	for _, a := range driftActions[k] {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// DriftItem is one disagreement found by the drift check
+type DriftItem struct {
+	// Stable across checks while the drift persists
+	ID    string    `json:"id"`
+	Kind  DriftKind `json:"kind"`
+	Owner string    `json:"owner"`
+	// VM name of the record, or resource name of the IP lease
+	Name          string `json:"name,omitempty"`
+	VsphereVMName string `json:"vsphere_vm_name,omitempty"`
+	IPAddress     string `json:"ip_address,omitempty"`
+	// IP lease resource type: "vm", "cluster-cp" or "cluster-worker"
+	ResourceType string `json:"resource_type,omitempty"`
+	// Recorded status of a missing VM
+	Status VMStatus `json:"status,omitempty"`
+	// vCenter power state of an unmanaged VM
+	PowerState  string        `json:"power_state,omitempty"`
+	Actions     []DriftAction `json:"actions"`
+	FirstSeenAt time.Time     `json:"first_seen_at"`
+}
+
+// DriftReport is the result of a drift check
+type DriftReport struct {
+	Items     []DriftItem `json:"items"`
+	Total     int         `json:"total"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// DriftActionInput represents the input for resolving a drift item
+// The record fields apply to adopt and mark_failed of an unmanaged VM; vCenter does not know them.
+type DriftActionInput struct {
+	Action DriftAction `json:"action"`
+	// VM name of the new record (default: vSphere name without the "<owner>-" prefix)
+	Name      string `json:"name,omitempty"`
+	OS        string `json:"os,omitempty"`
+	Spec      string `json:"spec,omitempty"`
+	LoginUser string `json:"login_user,omitempty"`
+}
+
+// Validate validates the drift action input
+func (d *DriftActionInput) Validate() []string {
+	var errors []string
+
+	switch d.Action {
+	case "":
+		errors = append(errors, "action is required")
+	case DriftActionAdopt, DriftActionCleanup, DriftActionMarkFailed:
+	default:
+		errors = append(errors, "action must be one of: adopt, cleanup, mark_failed")
+	}
+
+	if d.Name != "" && !isValidVMName(d.Name) {
+		errors = append(errors, "name must be 1-30 characters, lowercase letters, numbers, and hyphens only")
+	}
+
+	return errors
+}
+
+// VMName returns the name of the record adopting an unmanaged VM and whether it is a valid VM name
+func (d *DriftActionInput) VMName(item *DriftItem) (string, bool) {
+	name := d.Name
+	if name == "" {
+		name = strings.TrimPrefix(item.VsphereVMName, item.Owner+"-")
+	}
+	return name, isValidVMName(name)
+}
+
+// DriftActionResult describes how a drift item was resolved
+type DriftActionResult struct {
+	Item   DriftItem   `json:"item"`
+	Action DriftAction `json:"action"`
+	// Record written by adopt or mark_failed
+	VM *VM `json:"vm,omitempty"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Role represents the authorization role of an authenticated caller
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Identity represents the authenticated caller of an API request
+type Identity struct {
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+	// Expiry of the API token the request was made with (nil = never expires)
+	TokenExpiresAt *time.Time `json:"-"`
+}
+
+// IsAdmin checks if the identity has the admin role
+func (i *Identity) IsAdmin() bool {
+	return i != nil && i.Role == RoleAdmin
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// IPLease represents an IP address leased to a VM or cluster node
+type IPLease struct {
+	IP           string `json:"ip"`
+	User         string `json:"user"`
+	ResourceName string `json:"resource_name"`
+	// "vm", "cluster-cp" or "cluster-worker"
+	ResourceType string    `json:"resource_type"`
+	AllocatedAt  time.Time `json:"allocated_at"`
+}
+
+// IPBlock represents the IP block assigned to a user
+type IPBlock struct {
+	User        string    `json:"user"`
+	BlockStart  string    `json:"block_start"`
+	BlockEnd    string    `json:"block_end"`
+	BlockSize   int       `json:"block_size"`
+	Used        int       `json:"used"`
+	Free        int       `json:"free"`
+	AllocatedAt time.Time `json:"allocated_at"`
+	Leases      []IPLease `json:"leases"`
+}
+
+// IPLeaseListResponse represents the response for listing a user's leases
+type IPLeaseListResponse struct {
+	Leases []IPLease `json:"leases"`
+	Total  int       `json:"total"`
+}
+
+// IPAMSummaryResponse represents the admin view of the whole network
+type IPAMSummaryResponse struct {
+	Network         string    `json:"network"`
+	Gateway         string    `json:"gateway"`
+	BlockSize       int       `json:"block_size"`
+	TotalBlocks     int       `json:"total_blocks"`
+	AllocatedBlocks int       `json:"allocated_blocks"`
+	FreeBlocks      int       `json:"free_blocks"`
+	TotalLeases     int       `json:"total_leases"`
+	Blocks          []IPBlock `json:"blocks"`
+	// Leases outside every user block (e.g., written by hand)
+	Unassigned []IPLease `json:"unassigned,omitempty"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobType represents the kind of work a job performs
+type JobType string
+
+const (
+	JobTypeCreateVM      JobType = "create-vm"
+	JobTypeResizeVM      JobType = "resize-vm"
+	JobTypeVMDisk        JobType = "vm-disk"
+	JobTypeDeleteVMs     JobType = "delete-vms"
+	JobTypeCreateCluster JobType = "create-cluster"
+	JobTypeExpire        JobType = "expire"
+)
+
+// JobStatus represents the state of an asynchronous job
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Job represents a long-running provisioning operation executed in the background
+type Job struct {
+	ID         string          `json:"id"`
+	Type       JobType         `json:"type"`
+	Owner      string          `json:"owner"`
+	Status     JobStatus       `json:"status"`
+	Input      json.RawMessage `json:"input"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Attempts   int             `json:"attempts"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// IsFinished checks if the job reached a terminal state
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed
+}
+
+// JobAcceptedResponse represents the response for an enqueued job
+type JobAcceptedResponse struct {
+	JobID  string    `json:"job_id"`
+	Status JobStatus `json:"status"`
+	URL    string    `json:"url"`
+}
+
+// JobListResponse represents the response for listing jobs
+type JobListResponse struct {
+	Jobs  []*Job `json:"jobs"`
+	Total int    `json:"total"`
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LeaseKind is the kind of resource a lease belongs to
+type LeaseKind string
+
+const (
+	LeaseKindVM      LeaseKind = "vm"
+	LeaseKindCluster LeaseKind = "cluster"
+)
+
+// Lease records when a VM or cluster expires and is deleted by the reaper
+type Lease struct {
+	Kind      LeaseKind `json:"kind"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// VM spec or cluster type the lease was granted for
+	Spec      string     `json:"spec"`
+	CreatedAt time.Time  `json:"created_at"`
+	RenewedAt *time.Time `json:"renewed_at,omitempty"`
+	// Set once the owner has been warned about the coming expiry; cleared on renew
+	WarnedAt *time.Time `json:"warned_at,omitempty"`
+	// Expire job deleting the resource
+	ExpireJobID string `json:"expire_job_id,omitempty"`
+}
+
+// LeaseInput is the requested lifetime of a VM or cluster
+// At most one field may be set; without either the configured default lease applies.
+type LeaseInput struct {
+	// Absolute expiry time (RFC 3339)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Lifetime from now, e.g. "72h" or "7d"
+	TTL string `json:"ttl,omitempty"`
+}
+
+// IsEmpty reports whether no lifetime was requested
+func (l *LeaseInput) IsEmpty() bool {
+	return l.ExpiresAt == nil && l.TTL == ""
+}
+
+// Validate validates the requested lifetime
+func (l *LeaseInput) Validate() []string {
+	var errors []string
+
+	if l.ExpiresAt != nil && l.TTL != "" {
+		errors = append(errors, "only one of expires_at and ttl may be set")
+	}
+
+	if l.TTL != "" {
+		if ttl, err := ParseTTL(l.TTL); err != nil {
+			errors = append(errors, err.Error())
+		} else if ttl <= 0 {
+			errors = append(errors, "ttl must be positive")
+		}
+	}
+
+	if l.ExpiresAt != nil && !l.ExpiresAt.After(time.Now()) {
+		errors = append(errors, "expires_at must be in the future")
+	}
+
+	return errors
+}
+
+// Expiry returns the requested expiry time, or nil if no lifetime was requested
+// The input must be valid.
+func (l *LeaseInput) Expiry(now time.Time) *time.Time {
+	if l.ExpiresAt != nil {
+		t := *l.ExpiresAt
+		return &t
+	}
+	if l.TTL != "" {
+		ttl, _ := ParseTTL(l.TTL)
+		t := now.Add(ttl)
+		return &t
+	}
+	return nil
+}
+
+// ParseTTL parses a lifetime: a Go duration such as "36h" or a number of days such as "7d"
+func ParseTTL(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl %q: days must be a whole number", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q: use a duration such as 72h or 7d", s)
+	}
+	return ttl, nil
+}
+
+// ExpireJobInput is the input of an expire job deleting a resource whose lease ran out
+type ExpireJobInput struct {
+	Kind      LeaseKind `json:"kind"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Why the resource is deleted, recorded in the job result
+	Reason string `json:"reason"`
+}
+
+// ExpireResult is the result of an expire job
+type ExpireResult struct {
+	Kind   LeaseKind `json:"kind"`
+	Name   string    `json:"name"`
+	Reason string    `json:"reason"`
+	// False if the resource was already gone
+	Deleted bool `json:"deleted"`
+	// True if the lease was renewed or removed after the job was queued, so nothing was deleted
+	Renewed bool `json:"renewed,omitempty"`
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/basphere/basphere-api/internal/labels"
+)
+
+// MaxDescriptionLength is the longest description of a VM or cluster in characters
+const MaxDescriptionLength = 200
+
+// validateMetadata checks the labels and description given on create
+func validateMetadata(l map[string]string, description string) []string {
+	errors := labels.Validate(l)
+	errors = append(errors, validateDescription(description)...)
+	return errors
+}
+
+func validateDescription(description string) []string {
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return []string{fmt.Sprintf("description must be at most %d characters", MaxDescriptionLength)}
+	}
+	return nil
+}
+
+// MetadataUpdate changes the labels and description of a VM or cluster
+// Labels are merged like a JSON merge patch: a key set to null is removed, other keys are set.
+// Omitted fields are left unchanged.
+type MetadataUpdate struct {
+	Labels      map[string]*string `json:"labels,omitempty"`
+	Description *string            `json:"description,omitempty"`
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u *MetadataUpdate) IsEmpty() bool {
+	return len(u.Labels) == 0 && u.Description == nil
+}
+
+// Validate validates the keys and values being set
+// The size of the merged label set is checked after Apply.
+func (u *MetadataUpdate) Validate() []string {
+	var errors []string
+
+	// Sorted so the errors are stable
+	keys := make([]string, 0, len(u.Labels))
+	for k := range u.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := labels.ValidateKey(k); err != nil {
+			errors = append(errors, err.Error())
+		}
+		if v := u.Labels[k]; v != nil {
+			if err := labels.ValidateValue(*v); err != nil {
+				errors = append(errors, err.Error())
+			}
+		}
+	}
+
+	if u.Description != nil {
+		errors = append(errors, validateDescription(*u.Description)...)
+	}
+
+	return errors
+}
+
+// Apply returns the labels and description after the update, leaving current unchanged
+func (u *MetadataUpdate) Apply(current map[string]string, description string) (map[string]string, string) {
+	merged := make(map[string]string, len(current)+len(u.Labels))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range u.Labels {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = *v
+		}
+	}
+
+	if u.Description != nil {
+		description = *u.Description
+	}
+
+	return merged, description
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuotaLimits holds quota limits where nil fields are inherited from the next level
+// (user override, then per-user config, then team config, then the default)
+type QuotaLimits struct {
+	MaxVMs             *int `json:"max_vms,omitempty" yaml:"max_vms"`
+	MaxClusters        *int `json:"max_clusters,omitempty" yaml:"max_clusters"`
+	MaxNodesPerCluster *int `json:"max_nodes_per_cluster,omitempty" yaml:"max_nodes_per_cluster"`
+	MaxIPs             *int `json:"max_ips,omitempty" yaml:"max_ips"`
+	MaxSnapshots       *int `json:"max_snapshots,omitempty" yaml:"max_snapshots"`
+	// Ceilings across VMs and cluster nodes (0 = unlimited)
+	MaxCPU      *int `json:"max_cpu,omitempty" yaml:"max_cpu"`
+	MaxMemoryMB *int `json:"max_memory_mb,omitempty" yaml:"max_memory_mb"`
+	MaxDiskGB   *int `json:"max_disk_gb,omitempty" yaml:"max_disk_gb"`
+}
+
+// Validate validates the limits set by an admin
+func (l *QuotaLimits) Validate() []string {
+	var errors []string
+
+	fields := []struct {
+		name  string
+		value *int
+	}{
+		{"max_vms", l.MaxVMs},
+		{"max_clusters", l.MaxClusters},
+		{"max_nodes_per_cluster", l.MaxNodesPerCluster},
+		{"max_ips", l.MaxIPs},
+		{"max_snapshots", l.MaxSnapshots},
+		{"max_cpu", l.MaxCPU},
+		{"max_memory_mb", l.MaxMemoryMB},
+		{"max_disk_gb", l.MaxDiskGB},
+	}
+	for _, f := range fields {
+		if f.value != nil && *f.value < 0 {
+			errors = append(errors, f.name+" must not be negative")
+		}
+	}
+
+	if l.IsEmpty() {
+		errors = append(errors, "at least one limit is required")
+	}
+
+	return errors
+}
+
+// IsEmpty reports whether no limit is set
+func (l *QuotaLimits) IsEmpty() bool {
+	return l.MaxVMs == nil && l.MaxClusters == nil && l.MaxNodesPerCluster == nil && l.MaxIPs == nil &&
+		l.MaxSnapshots == nil && l.MaxCPU == nil && l.MaxMemoryMB == nil && l.MaxDiskGB == nil
+}
+
+// Resources represents the vCPU, memory and disk of VMs or cluster nodes
+type Resources struct {
+	CPU      int `json:"cpu"`
+	MemoryMB int `json:"memory_mb"`
+	DiskGB   int `json:"disk_gb"`
+}
+
+// Add returns the sum of r and o
+func (r Resources) Add(o Resources) Resources {
+	return Resources{CPU: r.CPU + o.CPU, MemoryMB: r.MemoryMB + o.MemoryMB, DiskGB: r.DiskGB + o.DiskGB}
+}
+
+// Sub returns the difference of r and o
+func (r Resources) Sub(o Resources) Resources {
+	return Resources{CPU: r.CPU - o.CPU, MemoryMB: r.MemoryMB - o.MemoryMB, DiskGB: r.DiskGB - o.DiskGB}
+}
+
+// Times returns r multiplied by n
+func (r Resources) Times(n int) Resources {
+	return Resources{CPU: r.CPU * n, MemoryMB: r.MemoryMB * n, DiskGB: r.DiskGB * n}
+}
+
+// ExceededResources describes each ceiling that adding extra to the current usage would exceed
+// Fields of extra that do not grow (such as a resize to a smaller spec) are never reported.
+func (q *Quota) ExceededResources(extra Resources) []string {
+	var exceeded []string
+
+	checks := []struct {
+		name      string
+		used, max int
+		requested int
+	}{
+		{"cpu", q.UsedCPU, q.MaxCPU, extra.CPU},
+		{"memory_mb", q.UsedMemoryMB, q.MaxMemoryMB, extra.MemoryMB},
+		{"disk_gb", q.UsedDiskGB, q.MaxDiskGB, extra.DiskGB},
+	}
+	for _, c := range checks {
+		if c.max > 0 && c.requested > 0 && c.used+c.requested > c.max {
+			exceeded = append(exceeded, fmt.Sprintf("%s: current: %d, requested: %d, max: %d", c.name, c.used, c.requested, c.max))
+		}
+	}
+
+	return exceeded
+}
+
+// QuotaOverride represents quota limits an admin set for a single user
+type QuotaOverride struct {
+	Username string `json:"username"`
+	QuotaLimits
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserQuotaResponse represents the admin view of a user's effective quota
+type UserQuotaResponse struct {
+	Username     string         `json:"username"`
+	Team         string         `json:"team,omitempty"`
+	Quota        Quota          `json:"quota"`
+	ClusterQuota ClusterQuota   `json:"cluster_quota"`
+	Override     *QuotaOverride `json:"override,omitempty"`
+}
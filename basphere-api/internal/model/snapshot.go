@@ -0,0 +1,88 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot represents a point-in-time snapshot of a VM
+type Snapshot struct {
+	Name        string `json:"name"`
+	VMName      string `json:"vm_name"`
+	Description string `json:"description,omitempty"`
+	// Whether the memory of a running VM was included (reverting resumes the VM instead of leaving it off)
+	Memory    bool      `json:"memory"`
+	CreatedAt time.Time `json:"created_at"`
+	// Size and data disks of the VM when the snapshot was taken (empty in older snapshots)
+	Spec      string `json:"spec,omitempty"`
+	DiskGB    int    `json:"disk_gb,omitempty"`
+	DataDisks []Disk `json:"data_disks,omitempty"`
+}
+
+// ConfigChanges lists how the VM's spec, disk and data disks differ from the snapshot
+// Reverting rolls them back in vSphere but not in the VM record or Terraform state, so a
+// snapshot with changes must not be reverted. Older snapshots without a recorded spec report none.
+func (s *Snapshot) ConfigChanges(vm *VM) []string {
+	if s.Spec == "" {
+		return nil
+	}
+
+	var changes []string
+	if s.Spec != vm.Spec {
+		changes = append(changes, fmt.Sprintf("spec changed from %s to %s", s.Spec, vm.Spec))
+	}
+	if s.DiskGB != vm.DiskGB {
+		changes = append(changes, fmt.Sprintf("disk_gb changed from %d to %d", s.DiskGB, vm.DiskGB))
+	}
+
+	sizes := make(map[string]int, len(s.DataDisks))
+	for _, d := range s.DataDisks {
+		sizes[d.Name] = d.SizeGB
+	}
+	for _, d := range vm.DataDisks {
+		size, ok := sizes[d.Name]
+		switch {
+		case !ok:
+			changes = append(changes, "disk "+d.Name+" attached")
+		case size != d.SizeGB:
+			changes = append(changes, fmt.Sprintf("disk %s resized from %d to %d GB", d.Name, size, d.SizeGB))
+		}
+		delete(sizes, d.Name)
+	}
+	for _, d := range s.DataDisks {
+		if _, ok := sizes[d.Name]; ok {
+			changes = append(changes, "disk "+d.Name+" detached")
+		}
+	}
+	return changes
+}
+
+// CreateSnapshotInput represents the input for creating a snapshot
+type CreateSnapshotInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Memory      bool   `json:"memory,omitempty"`
+}
+
+// Validate validates the snapshot creation input
+func (s *CreateSnapshotInput) Validate() []string {
+	var errors []string
+
+	if s.Name == "" {
+		errors = append(errors, "name is required")
+	} else if !isValidVMName(s.Name) {
+		errors = append(errors, "name must be 1-30 characters, lowercase letters, numbers, and hyphens only")
+	}
+
+	if len(s.Description) > 200 {
+		errors = append(errors, "description must be at most 200 characters")
+	}
+
+	return errors
+}
+
+// SnapshotListResponse represents the response for listing the snapshots of a VM
+type SnapshotListResponse struct {
+	Snapshots []Snapshot `json:"snapshots"`
+	Total     int        `json:"total"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// SSHCertInput represents the input for requesting an SSH user certificate
+type SSHCertInput struct {
+	// Requested lifetime in minutes (0 = server default, capped at the server maximum)
+	TTLMinutes int `json:"ttl_minutes,omitempty"`
+}
+
+// Validate validates the certificate request input
+func (s *SSHCertInput) Validate() []string {
+	var errors []string
+
+	if s.TTLMinutes < 0 {
+		errors = append(errors, "ttl_minutes must not be negative")
+	}
+
+	return errors
+}
+
+// SSHCertResponse represents a signed SSH user certificate
+type SSHCertResponse struct {
+	// Certificate in authorized_keys format; save as ~/.ssh/id_<type>-cert.pub
+	Certificate string    `json:"certificate"`
+	Serial      uint64    `json:"serial"`
+	KeyID       string    `json:"key_id"`
+	Principals  []string  `json:"principals"`
+	ValidAfter  time.Time `json:"valid_after"`
+	ValidBefore time.Time `json:"valid_before"`
+}
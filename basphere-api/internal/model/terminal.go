@@ -0,0 +1,25 @@
+package model
+
+// Web terminal message types
+const (
+	// Browser to server
+	TerminalInput  = "input"
+	TerminalResize = "resize"
+	// Server to browser (terminal output itself is sent as binary messages)
+	TerminalError = "error"
+	TerminalExit  = "exit"
+)
+
+// TerminalMessage is a JSON text message on the web terminal WebSocket
+type TerminalMessage struct {
+	Type string `json:"type"`
+	// Keyboard input (input)
+	Data string `json:"data,omitempty"`
+	// Terminal size (resize)
+	Cols int `json:"cols,omitempty"`
+	Rows int `json:"rows,omitempty"`
+	// Why the session ended (error, exit)
+	Message string `json:"message,omitempty"`
+	// Shell exit status (exit); -1 if the VM did not report one
+	ExitCode *int `json:"exit_code,omitempty"`
+}
@@ -0,0 +1,69 @@
+package model
+
+import "time"
+
+// APIToken represents a per-user API token
+// Only the SHA-256 hash of the token is stored; the raw value is shown once at issue time
+type APIToken struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	TokenHash   string     `json:"token_hash"`
+	Description string     `json:"description,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsExpired checks if the token has passed its expiry time
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
+
+// CreateTokenInput represents the input for issuing an API token
+type CreateTokenInput struct {
+	Description string `json:"description"`
+	// Lifetime in days (0 = never expires)
+	// Capped at the expiry of the token used to make the request
+	ExpiresInDays int `json:"expires_in_days,omitempty"`
+}
+
+// Validate validates the token creation input
+func (c *CreateTokenInput) Validate() []string {
+	var errors []string
+
+	if len(c.Description) > 100 {
+		errors = append(errors, "description must be 100 characters or less")
+	}
+
+	if c.ExpiresInDays < 0 || c.ExpiresInDays > 365 {
+		errors = append(errors, "expires_in_days must be between 0 and 365")
+	}
+
+	return errors
+}
+
+// TokenInfo represents an API token without its hash, safe to return to clients
+type TokenInfo struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	Description string     `json:"description,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Info returns the client-safe view of the token
+func (t *APIToken) Info() TokenInfo {
+	return TokenInfo{
+		ID:          t.ID,
+		Username:    t.Username,
+		Description: t.Description,
+		CreatedAt:   t.CreatedAt,
+		ExpiresAt:   t.ExpiresAt,
+	}
+}
+
+// CreateTokenResponse represents the response for issuing a token
+// Token holds the raw value and is only returned once
+type CreateTokenResponse struct {
+	Token string    `json:"token"`
+	Info  TokenInfo `json:"info"`
+}
@@ -19,6 +19,7 @@ type RegistrationRequest struct {
 	ID        string        `json:"id"`
 	Username  string        `json:"username"`
 	Email     string        `json:"email"`
+	FullName  string        `json:"full_name,omitempty"` // From the OIDC login, when enabled
 	Team      string        `json:"team,omitempty"`
 	PublicKey string        `json:"public_key"`
 	Status    RequestStatus `json:"status"`
@@ -34,6 +35,7 @@ type RegistrationRequest struct {
 type RegisterInput struct {
 	Username  string `json:"username"`
 	Email     string `json:"email"`
+	FullName  string `json:"full_name,omitempty"`
 	Team      string `json:"team"`
 	PublicKey string `json:"public_key"`
 }
@@ -54,6 +56,10 @@ func (r *RegisterInput) Validate() []string {
 		errors = append(errors, "invalid email format")
 	}
 
+	if len(r.FullName) > 100 {
+		errors = append(errors, "full_name must be 100 characters or less")
+	}
+
 	// Sanitize SSH key (remove Windows line endings)
 	r.PublicKey = sanitizeSSHKey(r.PublicKey)
 
@@ -134,15 +140,10 @@ func isValidSSHPublicKey(key string) bool {
 	return false
 }
 
-// ApproveInput represents the input for approving a request
-type ApproveInput struct {
-	ProcessedBy string `json:"processed_by"`
-}
-
 // RejectInput represents the input for rejecting a request
+// The processing admin is taken from the authenticated identity, not the body
 type RejectInput struct {
-	ProcessedBy string `json:"processed_by"`
-	Reason      string `json:"reason"`
+	Reason string `json:"reason"`
 }
 
 // KeyChangeRequest represents a request to change SSH public key
@@ -6,12 +6,119 @@ import "time"
 type VMStatus string
 
 const (
-	VMStatusCreating VMStatus = "creating"
-	VMStatusRunning  VMStatus = "running"
-	VMStatusDeleting VMStatus = "deleting"
-	VMStatusFailed   VMStatus = "failed"
+	VMStatusCreating  VMStatus = "creating"
+	VMStatusRunning   VMStatus = "running"
+	VMStatusStopped   VMStatus = "stopped"
+	VMStatusSuspended VMStatus = "suspended"
+	VMStatusDeleting  VMStatus = "deleting"
+	VMStatusFailed    VMStatus = "failed"
+	// The record has no VM in vCenter (reported from live state, never stored)
+	VMStatusMissing VMStatus = "missing"
 )
 
+// VMAction represents a power operation on a VM
+type VMAction string
+
+const (
+	VMActionStart    VMAction = "start"    // power on
+	VMActionStop     VMAction = "stop"     // hard power off
+	VMActionReboot   VMAction = "reboot"   // guest OS reboot (requires VMware Tools)
+	VMActionShutdown VMAction = "shutdown" // guest OS shutdown (requires VMware Tools)
+)
+
+// vmActionAllowedFrom lists the statuses each action may be performed from
+var vmActionAllowedFrom = map[VMAction][]VMStatus{
+	VMActionStart:    {VMStatusStopped, VMStatusSuspended},
+	VMActionStop:     {VMStatusRunning, VMStatusSuspended},
+	VMActionReboot:   {VMStatusRunning},
+	VMActionShutdown: {VMStatusRunning},
+}
+
+// AllowedFrom reports whether the action can be performed on a VM in the given status
+func (a VMAction) AllowedFrom(status VMStatus) bool {
+	for _, s := range vmActionAllowedFrom[a] {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ResizeVMInput represents the input for resizing a VM
+// Either field may be omitted; the disk is never shrunk.
+type ResizeVMInput struct {
+	Spec   string `json:"spec,omitempty"`
+	DiskGB int    `json:"disk_gb,omitempty"`
+}
+
+// Resizes reports whether the input changes the spec or disk
+func (r *ResizeVMInput) Resizes() bool {
+	return r.Spec != "" || r.DiskGB != 0
+}
+
+// Validate validates the VM resize input
+func (r *ResizeVMInput) Validate() []string {
+	var errors []string
+
+	if r.Spec == "" && r.DiskGB == 0 {
+		errors = append(errors, "spec or disk_gb is required")
+	}
+
+	if r.DiskGB < 0 {
+		errors = append(errors, "disk_gb must not be negative")
+	}
+
+	return errors
+}
+
+// UpdateVMInput represents the input for PATCH /vms/{name}
+// Labels and description are updated right away; a spec or disk change is queued as a resize.
+type UpdateVMInput struct {
+	ResizeVMInput
+	MetadataUpdate
+}
+
+// Validate validates the VM update input
+func (u *UpdateVMInput) Validate() []string {
+	if !u.Resizes() && u.MetadataUpdate.IsEmpty() {
+		return []string{"spec, disk_gb, labels or description is required"}
+	}
+
+	var errors []string
+	if u.Resizes() {
+		errors = append(errors, u.ResizeVMInput.Validate()...)
+	}
+	errors = append(errors, u.MetadataUpdate.Validate()...)
+	return errors
+}
+
+// ResizeVMJobInput is the input of a resize-vm job with the target size resolved by the API
+type ResizeVMJobInput struct {
+	Name   string `json:"name"`
+	Spec   string `json:"spec"`
+	DiskGB int    `json:"disk_gb"`
+	// Resources the resize adds to the user's usage (counted against quota while the job runs)
+	Added Resources `json:"added"`
+}
+
+// VMActionInput represents the input for a VM power operation
+type VMActionInput struct {
+	Action VMAction `json:"action"`
+}
+
+// Validate validates the VM action input
+func (a *VMActionInput) Validate() []string {
+	var errors []string
+
+	if a.Action == "" {
+		errors = append(errors, "action is required")
+	} else if _, ok := vmActionAllowedFrom[a.Action]; !ok {
+		errors = append(errors, "action must be one of: start, stop, reboot, shutdown")
+	}
+
+	return errors
+}
+
 // VM represents a virtual machine
 type VM struct {
 	Name          string    `json:"name"`
@@ -23,6 +130,68 @@ type VM struct {
 	IPAddress     string    `json:"ip_address"`
 	Status        VMStatus  `json:"status"`
 	CreatedAt     time.Time `json:"created_at"`
+	// Disk size after a resize grew it beyond the spec (0 = spec size)
+	DiskGB int `json:"disk_gb,omitempty"`
+	// Guest hostname when it differs from the VM name
+	Hostname string `json:"hostname,omitempty"`
+	// Additional data disks beside the root disk
+	DataDisks []Disk `json:"data_disks,omitempty"`
+	// Free-form metadata set by the owner
+	Labels      map[string]string `json:"labels,omitempty"`
+	Description string            `json:"description,omitempty"`
+	// When the VM is deleted by the reaper (nil = never)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Create job that made the VM, so a resumed job only adopts its own VMs
+	JobID string `json:"job_id,omitempty"`
+	// State reported by vCenter (only when the inventory is enabled)
+	Live *VMLiveState `json:"live,omitempty"`
+}
+
+// VMLiveState is the VM as vCenter reports it, read through a short-lived cache
+type VMLiveState struct {
+	// False when vCenter has no VM for the record
+	Found bool `json:"found"`
+	// poweredOn, poweredOff or suspended
+	PowerState string `json:"power_state,omitempty"`
+	GuestIP    string `json:"guest_ip,omitempty"`
+	// VMware Tools: guestToolsRunning, guestToolsNotRunning or guestToolsExecutingScripts
+	ToolsStatus   string     `json:"tools_status,omitempty"`
+	Host          string     `json:"host,omitempty"`
+	BootTime      *time.Time `json:"boot_time,omitempty"`
+	UptimeSeconds int64      `json:"uptime_seconds,omitempty"`
+	// When vCenter was read
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// vmPowerStateStatus maps vCenter power states to VM statuses
+var vmPowerStateStatus = map[string]VMStatus{
+	"poweredOn":  VMStatusRunning,
+	"poweredOff": VMStatusStopped,
+	"suspended":  VMStatusSuspended,
+}
+
+// ApplyLiveState attaches the live state and derives the status from it
+// VMs being created or deleted, or left failed, keep their recorded status.
+func (vm *VM) ApplyLiveState(live *VMLiveState) {
+	vm.Live = live
+
+	switch vm.Status {
+	case VMStatusCreating, VMStatusDeleting, VMStatusFailed:
+		return
+	}
+	if !live.Found {
+		vm.Status = VMStatusMissing
+		return
+	}
+	if status, ok := PowerStateStatus(live.PowerState); ok {
+		vm.Status = status
+	}
+}
+
+// PowerStateStatus returns the VM status for a vCenter power state
+func PowerStateStatus(powerState string) (VMStatus, bool) {
+	status, ok := vmPowerStateStatus[powerState]
+	return status, ok
 }
 
 // CreateVMInput represents the input for creating a VM
@@ -31,6 +200,20 @@ type CreateVMInput struct {
 	OS    string `json:"os"`
 	Spec  string `json:"spec"`
 	Count int    `json:"count,omitempty"`
+	// Optional cloud-init customization, merged into the platform's default cloud-config
+	Hostname          string   `json:"hostname,omitempty"`
+	SSHAuthorizedKeys []string `json:"ssh_authorized_keys,omitempty"`
+	Packages          []string `json:"packages,omitempty"`
+	UserData          string   `json:"user_data,omitempty"`
+	// Labels and description copied to every VM of the request
+	Labels      map[string]string `json:"labels,omitempty"`
+	Description string            `json:"description,omitempty"`
+	// Optional lifetime; the API resolves it to expires_at before queuing the job
+	LeaseInput
+	// IP quota resolved by the API for the owner when the job runs; not settable by users
+	MaxIPs int `json:"-"`
+	// Create job recorded in the VM's metadata; set by the API when the job runs
+	JobID string `json:"-"`
 }
 
 // Validate validates the VM creation input
@@ -55,6 +238,10 @@ func (v *CreateVMInput) Validate() []string {
 		errors = append(errors, "count must be between 1 and 10")
 	}
 
+	errors = append(errors, v.validateCloudInit()...)
+	errors = append(errors, validateMetadata(v.Labels, v.Description)...)
+	errors = append(errors, v.LeaseInput.Validate()...)
+
 	return errors
 }
 
@@ -91,11 +278,33 @@ type VMListResponse struct {
 }
 
 // Quota represents user's resource quota
+// The vCPU, memory and disk usage covers VMs and cluster nodes; a Max of 0 means unlimited.
 type Quota struct {
-	MaxVMs     int `json:"max_vms"`
-	UsedVMs    int `json:"used_vms"`
-	MaxIPs     int `json:"max_ips"`
-	UsedIPs    int `json:"used_ips"`
+	MaxVMs        int `json:"max_vms"`
+	UsedVMs       int `json:"used_vms"`
+	MaxIPs        int `json:"max_ips"`
+	UsedIPs       int `json:"used_ips"`
+	MaxSnapshots  int `json:"max_snapshots"`
+	UsedSnapshots int `json:"used_snapshots"`
+	MaxCPU        int `json:"max_cpu"`
+	UsedCPU       int `json:"used_cpu"`
+	MaxMemoryMB   int `json:"max_memory_mb"`
+	UsedMemoryMB  int `json:"used_memory_mb"`
+	MaxDiskGB     int `json:"max_disk_gb"`
+	UsedDiskGB    int `json:"used_disk_gb"`
+}
+
+// DeleteVMsJobInput is the input of a delete-vms job with the VMs matching the selector resolved by the API
+type DeleteVMsJobInput struct {
+	Selector string   `json:"selector"`
+	Names    []string `json:"names"`
+}
+
+// DeleteVMsResponse represents the result of a bulk delete
+type DeleteVMsResponse struct {
+	Deleted []string `json:"deleted"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
 }
 
 // CreateVMResponse represents the response for creating VMs
@@ -3,6 +3,7 @@ package model
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 // =============================================================================
@@ -218,6 +219,107 @@ func TestCreateVMInput_Validate(t *testing.T) {
 	}
 }
 
+func TestCreateVMInput_ValidateCloudInit(t *testing.T) {
+	base := func(modify func(*CreateVMInput)) CreateVMInput {
+		input := CreateVMInput{Name: "web", OS: "ubuntu-24.04", Spec: "small"}
+		modify(&input)
+		return input
+	}
+
+	tests := []struct {
+		name       string
+		input      CreateVMInput
+		wantErrors []string
+	}{
+		{
+			"all fields",
+			base(func(v *CreateVMInput) {
+				v.Hostname = "web-frontend"
+				v.SSHAuthorizedKeys = []string{"ssh-ed25519 AAAAC3... ci@runner"}
+				v.Packages = []string{"nginx", "python3.12", "docker-ce=5:27.*"}
+				v.UserData = "#cloud-config\nruncmd:\n  - systemctl enable --now nginx\n"
+			}),
+			nil,
+		},
+		{
+			"invalid hostname",
+			base(func(v *CreateVMInput) { v.Hostname = "Web_Frontend" }),
+			[]string{"hostname must be"},
+		},
+		{
+			"hostname ending with hyphen",
+			base(func(v *CreateVMInput) { v.Hostname = "web-" }),
+			[]string{"hostname must be"},
+		},
+		{
+			"invalid ssh key",
+			base(func(v *CreateVMInput) { v.SSHAuthorizedKeys = []string{"not-a-key"} }),
+			[]string{"ssh_authorized_keys[0]"},
+		},
+		{
+			"multi-line ssh key",
+			base(func(v *CreateVMInput) { v.SSHAuthorizedKeys = []string{"ssh-ed25519 AAAA\nruncmd: [reboot]"} }),
+			[]string{"ssh_authorized_keys[0]"},
+		},
+		{
+			"invalid package",
+			base(func(v *CreateVMInput) { v.Packages = []string{"nginx; reboot"} }),
+			[]string{"invalid package name"},
+		},
+		{
+			"too many packages",
+			base(func(v *CreateVMInput) { v.Packages = strings.Fields(strings.Repeat("nginx ", 51)) }),
+			[]string{"packages must have at most 50 entries"},
+		},
+		{
+			"shell script user data",
+			base(func(v *CreateVMInput) { v.UserData = "#!/bin/bash\necho hi\n" }),
+			[]string{"must be a cloud-config document"},
+		},
+		{
+			"invalid yaml user data",
+			base(func(v *CreateVMInput) { v.UserData = "#cloud-config\nruncmd: [unclosed\n" }),
+			[]string{"not valid YAML"},
+		},
+		{
+			"user data is a list",
+			base(func(v *CreateVMInput) { v.UserData = "#cloud-config\n- a\n- b\n" }),
+			[]string{"not valid YAML"},
+		},
+		{
+			"user data too large",
+			base(func(v *CreateVMInput) { v.UserData = "#cloud-config\n#" + strings.Repeat("x", MaxUserDataBytes) }),
+			[]string{"user_data must be at most"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := tt.input.Validate()
+			if len(errors) != len(tt.wantErrors) {
+				t.Fatalf("Expected %d errors, got %d: %v", len(tt.wantErrors), len(errors), errors)
+			}
+			for i, want := range tt.wantErrors {
+				if !strings.Contains(errors[i], want) {
+					t.Errorf("Expected error containing %q, got %q", want, errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCreateVMInput_SanitizesSSHKeys(t *testing.T) {
+	input := CreateVMInput{Name: "web", OS: "ubuntu-24.04", Spec: "small",
+		SSHAuthorizedKeys: []string{"  ssh-ed25519 AAAAC3... ci@runner\r\n"}}
+
+	if errors := input.Validate(); len(errors) != 0 {
+		t.Fatalf("Expected no errors, got %v", errors)
+	}
+	if input.SSHAuthorizedKeys[0] != "ssh-ed25519 AAAAC3... ci@runner" {
+		t.Errorf("Expected sanitized key, got %q", input.SSHAuthorizedKeys[0])
+	}
+}
+
 // =============================================================================
 // VM Status Tests
 // =============================================================================
@@ -230,8 +332,11 @@ func TestVMStatus_Constants(t *testing.T) {
 	}{
 		{VMStatusCreating, "creating"},
 		{VMStatusRunning, "running"},
+		{VMStatusStopped, "stopped"},
+		{VMStatusSuspended, "suspended"},
 		{VMStatusDeleting, "deleting"},
 		{VMStatusFailed, "failed"},
+		{VMStatusMissing, "missing"},
 	}
 
 	for _, tt := range tests {
@@ -243,6 +348,343 @@ func TestVMStatus_Constants(t *testing.T) {
 	}
 }
 
+func TestVM_ApplyLiveState(t *testing.T) {
+	tests := []struct {
+		name   string
+		status VMStatus
+		live   VMLiveState
+		want   VMStatus
+	}{
+		{"powered off in vCenter", VMStatusRunning, VMLiveState{Found: true, PowerState: "poweredOff"}, VMStatusStopped},
+		{"powered on in vCenter", VMStatusStopped, VMLiveState{Found: true, PowerState: "poweredOn"}, VMStatusRunning},
+		{"suspended", VMStatusRunning, VMLiveState{Found: true, PowerState: "suspended"}, VMStatusSuspended},
+		{"deleted in vCenter", VMStatusRunning, VMLiveState{}, VMStatusMissing},
+		{"creating keeps status", VMStatusCreating, VMLiveState{}, VMStatusCreating},
+		{"deleting keeps status", VMStatusDeleting, VMLiveState{Found: true, PowerState: "poweredOff"}, VMStatusDeleting},
+		{"failed keeps status", VMStatusFailed, VMLiveState{Found: true, PowerState: "poweredOn"}, VMStatusFailed},
+		{"unknown power state", VMStatusRunning, VMLiveState{Found: true}, VMStatusRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := &VM{Status: tt.status}
+			live := tt.live
+			vm.ApplyLiveState(&live)
+
+			if vm.Status != tt.want {
+				t.Errorf("Expected status %s, got %s", tt.want, vm.Status)
+			}
+			if vm.Live != &live {
+				t.Error("Expected live state to be attached")
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Resize Tests
+// =============================================================================
+
+func TestResizeVMInput_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      ResizeVMInput
+		wantErrors int
+	}{
+		{"spec only", ResizeVMInput{Spec: "large"}, 0},
+		{"disk only", ResizeVMInput{DiskGB: 100}, 0},
+		{"both", ResizeVMInput{Spec: "large", DiskGB: 100}, 0},
+		{"empty", ResizeVMInput{}, 1},
+		{"negative disk", ResizeVMInput{Spec: "large", DiskGB: -10}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errors := tt.input.Validate(); len(errors) != tt.wantErrors {
+				t.Errorf("Expected %d errors, got %d: %v", tt.wantErrors, len(errors), errors)
+			}
+		})
+	}
+}
+
+func TestQuota_ExceededResources_IgnoresShrink(t *testing.T) {
+	q := &Quota{MaxCPU: 4, UsedCPU: 8, MaxDiskGB: 100, UsedDiskGB: 90}
+
+	// Over the CPU ceiling already, but a resize that frees CPU and grows the disk only reports the disk
+	exceeded := q.ExceededResources(Resources{CPU: -2, DiskGB: 20})
+	if len(exceeded) != 1 || !strings.HasPrefix(exceeded[0], "disk_gb") {
+		t.Errorf("Expected only disk_gb to be exceeded, got %v", exceeded)
+	}
+}
+
+// =============================================================================
+// Snapshot Tests
+// =============================================================================
+
+func TestCreateSnapshotInput_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      CreateSnapshotInput
+		wantErrors int
+	}{
+		{"valid", CreateSnapshotInput{Name: "before-upgrade", Description: "kernel 6.8"}, 0},
+		{"with memory", CreateSnapshotInput{Name: "warm", Memory: true}, 0},
+		{"missing name", CreateSnapshotInput{Description: "no name"}, 1},
+		{"invalid name", CreateSnapshotInput{Name: "Before Upgrade"}, 1},
+		{"long description", CreateSnapshotInput{Name: "snap", Description: strings.Repeat("x", 201)}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errors := tt.input.Validate(); len(errors) != tt.wantErrors {
+				t.Errorf("Expected %d errors, got %d: %v", tt.wantErrors, len(errors), errors)
+			}
+		})
+	}
+}
+
+func TestSnapshot_ConfigChanges(t *testing.T) {
+	data := Disk{Name: "data", SizeGB: 30, UnitNumber: 1}
+	snapshot := Snapshot{Name: "snap", Spec: "small", DiskGB: 60, DataDisks: []Disk{data}}
+
+	tests := []struct {
+		name        string
+		snapshot    Snapshot
+		vm          VM
+		wantChanges int
+	}{
+		{"unchanged", snapshot, VM{Spec: "small", DiskGB: 60, DataDisks: []Disk{data}}, 0},
+		{"spec", snapshot, VM{Spec: "large", DiskGB: 60, DataDisks: []Disk{data}}, 1},
+		{"disk grown", snapshot, VM{Spec: "small", DiskGB: 80, DataDisks: []Disk{data}}, 1},
+		{"disk attached", snapshot, VM{Spec: "small", DiskGB: 60, DataDisks: []Disk{data, {Name: "logs", SizeGB: 10}}}, 1},
+		{"disk detached", snapshot, VM{Spec: "small", DiskGB: 60}, 1},
+		{"data disk grown", snapshot, VM{Spec: "small", DiskGB: 60, DataDisks: []Disk{{Name: "data", SizeGB: 40}}}, 1},
+		{"spec and disk", snapshot, VM{Spec: "large", DiskGB: 60, DataDisks: []Disk{{Name: "logs", SizeGB: 10}}}, 3},
+		{"not recorded", Snapshot{Name: "old"}, VM{Spec: "large", DiskGB: 80}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if changes := tt.snapshot.ConfigChanges(&tt.vm); len(changes) != tt.wantChanges {
+				t.Errorf("Expected %d changes, got %d: %v", tt.wantChanges, len(changes), changes)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Metadata Tests
+// =============================================================================
+
+func strPtr(v string) *string { return &v }
+
+func TestUpdateVMInput_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      UpdateVMInput
+		wantErrors int
+	}{
+		{"resize only", UpdateVMInput{ResizeVMInput: ResizeVMInput{Spec: "large"}}, 0},
+		{"labels only", UpdateVMInput{MetadataUpdate: MetadataUpdate{Labels: map[string]*string{"env": strPtr("dev")}}}, 0},
+		{"remove label", UpdateVMInput{MetadataUpdate: MetadataUpdate{Labels: map[string]*string{"env": nil}}}, 0},
+		{"clear description", UpdateVMInput{MetadataUpdate: MetadataUpdate{Description: strPtr("")}}, 0},
+		{"empty", UpdateVMInput{}, 1},
+		{"invalid key", UpdateVMInput{MetadataUpdate: MetadataUpdate{Labels: map[string]*string{"-env": nil}}}, 1},
+		{"invalid value", UpdateVMInput{MetadataUpdate: MetadataUpdate{Labels: map[string]*string{"env": strPtr("dev server")}}}, 1},
+		{"long description", UpdateVMInput{MetadataUpdate: MetadataUpdate{Description: strPtr(strings.Repeat("설", 201))}}, 1},
+		{"negative disk with labels", UpdateVMInput{
+			ResizeVMInput:  ResizeVMInput{DiskGB: -1},
+			MetadataUpdate: MetadataUpdate{Description: strPtr("x")},
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errors := tt.input.Validate(); len(errors) != tt.wantErrors {
+				t.Errorf("Expected %d errors, got %d: %v", tt.wantErrors, len(errors), errors)
+			}
+		})
+	}
+}
+
+func TestMetadataUpdate_Apply(t *testing.T) {
+	current := map[string]string{"env": "dev", "tier": "web"}
+	u := MetadataUpdate{Labels: map[string]*string{"env": strPtr("prod"), "tier": nil, "team": strPtr("ml")}}
+
+	labels, description := u.Apply(current, "kept")
+	if len(labels) != 2 || labels["env"] != "prod" || labels["team"] != "ml" {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+	if description != "kept" {
+		t.Errorf("Expected description to be kept, got %q", description)
+	}
+	if current["env"] != "dev" || len(current) != 2 {
+		t.Errorf("Expected current labels unchanged, got %v", current)
+	}
+}
+
+// =============================================================================
+// Disk Tests
+// =============================================================================
+
+func TestAttachDiskInput_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      AttachDiskInput
+		wantErrors int
+	}{
+		{"valid", AttachDiskInput{Name: "data", SizeGB: 100}, 0},
+		{"largest", AttachDiskInput{Name: "data", SizeGB: MaxDiskSizeGB}, 0},
+		{"missing name", AttachDiskInput{SizeGB: 100}, 1},
+		{"invalid name", AttachDiskInput{Name: "Data Disk", SizeGB: 100}, 1},
+		{"missing size", AttachDiskInput{Name: "data"}, 1},
+		{"too large", AttachDiskInput{Name: "data", SizeGB: MaxDiskSizeGB + 1}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errors := tt.input.Validate(); len(errors) != tt.wantErrors {
+				t.Errorf("Expected %d errors, got %d: %v", tt.wantErrors, len(errors), errors)
+			}
+		})
+	}
+}
+
+func TestVM_DataDisks(t *testing.T) {
+	vm := VM{DataDisks: []Disk{{Name: "data", SizeGB: 100}, {Name: "logs", SizeGB: 20}}}
+
+	if got := vm.DataDiskGB(); got != 120 {
+		t.Errorf("Expected 120 GB, got %d", got)
+	}
+	if d := vm.FindDataDisk("logs"); d == nil || d.SizeGB != 20 {
+		t.Errorf("Expected logs disk, got %+v", d)
+	}
+	if d := vm.FindDataDisk("missing"); d != nil {
+		t.Errorf("Expected no disk, got %+v", d)
+	}
+}
+
+// =============================================================================
+// VM Action Tests
+// =============================================================================
+
+func TestVMActionInput_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     VMAction
+		wantErrors int
+	}{
+		{"start", VMActionStart, 0},
+		{"stop", VMActionStop, 0},
+		{"reboot", VMActionReboot, 0},
+		{"shutdown", VMActionShutdown, 0},
+		{"missing", "", 1},
+		{"unknown", "suspend", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := VMActionInput{Action: tt.action}
+			if errors := input.Validate(); len(errors) != tt.wantErrors {
+				t.Errorf("Expected %d errors, got %d: %v", tt.wantErrors, len(errors), errors)
+			}
+		})
+	}
+}
+
+func TestVMAction_AllowedFrom(t *testing.T) {
+	tests := []struct {
+		action VMAction
+		status VMStatus
+		want   bool
+	}{
+		{VMActionStart, VMStatusStopped, true},
+		{VMActionStart, VMStatusSuspended, true},
+		{VMActionStart, VMStatusRunning, false},
+		{VMActionStop, VMStatusRunning, true},
+		{VMActionStop, VMStatusStopped, false},
+		{VMActionReboot, VMStatusRunning, true},
+		{VMActionReboot, VMStatusStopped, false},
+		{VMActionShutdown, VMStatusRunning, true},
+		{VMActionShutdown, VMStatusCreating, false},
+		{VMActionStop, VMStatusDeleting, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.action)+"/"+string(tt.status), func(t *testing.T) {
+			if got := tt.action.AllowedFrom(tt.status); got != tt.want {
+				t.Errorf("AllowedFrom = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Lease Tests
+// =============================================================================
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"72h", 72 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 0, true},
+		{"d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTTL(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLeaseInput_Validate(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		input      LeaseInput
+		wantErrors int
+	}{
+		{"empty", LeaseInput{}, 0},
+		{"ttl", LeaseInput{TTL: "3d"}, 0},
+		{"expires_at", LeaseInput{ExpiresAt: &future}, 0},
+		{"both", LeaseInput{TTL: "3d", ExpiresAt: &future}, 1},
+		{"negative ttl", LeaseInput{TTL: "-1h"}, 1},
+		{"invalid ttl", LeaseInput{TTL: "tomorrow"}, 1},
+		{"past", LeaseInput{ExpiresAt: &past}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errors := tt.input.Validate(); len(errors) != tt.wantErrors {
+				t.Errorf("Expected %d errors, got %d: %v", tt.wantErrors, len(errors), errors)
+			}
+		})
+	}
+
+	now := time.Now()
+	if got := (&LeaseInput{TTL: "2h"}).Expiry(now); got == nil || !got.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("Expected expiry in 2h, got %v", got)
+	}
+	if got := (&LeaseInput{}).Expiry(now); got != nil {
+		t.Errorf("Expected no expiry, got %v", got)
+	}
+}
+
 // =============================================================================
 // Quota Tests
 // =============================================================================
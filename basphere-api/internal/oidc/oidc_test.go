@@ -0,0 +1,139 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/basphere/basphere-api/internal/oidc/oidctest"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+func setupClient(t *testing.T) (*Client, *oidctest.Provider) {
+	t.Helper()
+
+	provider := oidctest.NewProvider("basphere", "secret")
+	t.Cleanup(provider.Close)
+
+	client, err := NewClient(context.Background(), Config{
+		IssuerURL:    provider.Issuer(),
+		ClientID:     "basphere",
+		ClientSecret: "secret",
+		RedirectURL:  "http://basphere.test/auth/callback",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	return client, provider
+}
+
+// authorize follows the authorization redirect and returns the callback query
+func authorize(t *testing.T, client *Client, login *LoginRequest) url.Values {
+	t.Helper()
+
+	httpClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := httpClient.Get(client.AuthCodeURL(login))
+	if err != nil {
+		t.Fatalf("Authorization request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected redirect from provider, got %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid redirect location: %v", err)
+	}
+
+	return location.Query()
+}
+
+// =============================================================================
+// Authorization Code Flow Tests
+// =============================================================================
+
+func TestExchange_Success(t *testing.T) {
+	client, provider := setupClient(t)
+	provider.SetUser(oidctest.User{
+		Subject:       "abc123",
+		Email:         "hong@company.com",
+		EmailVerified: true,
+		Name:          "Hong Gildong",
+	})
+
+	login, err := NewLoginRequest()
+	if err != nil {
+		t.Fatalf("Failed to create login request: %v", err)
+	}
+
+	callback := authorize(t, client, login)
+	if callback.Get("state") != login.State {
+		t.Errorf("Expected state %q, got %q", login.State, callback.Get("state"))
+	}
+
+	claims, err := client.Exchange(context.Background(), login, callback.Get("code"))
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+
+	if claims.Subject != "abc123" || claims.Email != "hong@company.com" || claims.Name != "Hong Gildong" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+	if !claims.EmailVerified {
+		t.Error("Expected email_verified=true")
+	}
+}
+
+func TestExchange_WrongVerifier(t *testing.T) {
+	client, _ := setupClient(t)
+
+	login, _ := NewLoginRequest()
+	callback := authorize(t, client, login)
+
+	// A different PKCE verifier must not redeem the code
+	other, _ := NewLoginRequest()
+	login.Verifier = other.Verifier
+
+	if _, err := client.Exchange(context.Background(), login, callback.Get("code")); err == nil {
+		t.Error("Expected exchange to fail with wrong PKCE verifier")
+	}
+}
+
+func TestExchange_NonceMismatch(t *testing.T) {
+	client, _ := setupClient(t)
+
+	login, _ := NewLoginRequest()
+	callback := authorize(t, client, login)
+
+	login.Nonce = "tampered"
+
+	if _, err := client.Exchange(context.Background(), login, callback.Get("code")); err == nil {
+		t.Error("Expected exchange to fail with nonce mismatch")
+	}
+}
+
+func TestExchange_CodeReuse(t *testing.T) {
+	client, _ := setupClient(t)
+
+	login, _ := NewLoginRequest()
+	callback := authorize(t, client, login)
+
+	if _, err := client.Exchange(context.Background(), login, callback.Get("code")); err != nil {
+		t.Fatalf("First exchange failed: %v", err)
+	}
+	if _, err := client.Exchange(context.Background(), login, callback.Get("code")); err == nil {
+		t.Error("Expected second exchange of the same code to fail")
+	}
+}
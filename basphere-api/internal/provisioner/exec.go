@@ -0,0 +1,142 @@
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+type outputKey struct{}
+
+// WithOutput returns a context whose script-backed operations also copy the script's
+// stdout and stderr to w, line by line, while the script runs
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// outputFrom returns the writer set by WithOutput, or nil
+func outputFrom(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(outputKey{}).(io.Writer)
+	return w
+}
+
+// progress writes a line to the output set by WithOutput, like a script would
+func progress(ctx context.Context, format string, args ...interface{}) {
+	if out := outputFrom(ctx); out != nil {
+		fmt.Fprintf(out, format+"\n", args...)
+	}
+}
+
+// withTimeout bounds ctx by timeout (no bound when timeout is zero)
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// command creates a command bound to ctx that runs in its own process group
+// When ctx is done the whole group receives SIGTERM so the script's cleanup trap runs
+// alongside terraform/kubectl; the script is killed if it is still running after the grace period
+func (p *BashProvisioner) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return terminateProcessGroup(cmd)
+	}
+	cmd.WaitDelay = p.timeouts.CancelGrace
+	return cmd
+}
+
+// scriptCommand creates a command for a basphere script in API mode
+func (p *BashProvisioner) scriptCommand(ctx context.Context, script string, args ...string) *exec.Cmd {
+	cmd := p.command(ctx, script, args...)
+	cmd.Env = append(os.Environ(), "BASPHERE_API_MODE=1")
+	return cmd
+}
+
+// run runs cmd and reports cancellation and timeouts distinctly from script failures
+func run(ctx context.Context, cmd *exec.Cmd) error {
+	err := cmd.Run()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// Children that ignored SIGTERM must not outlive the operation
+		killProcessGroup(cmd)
+
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return fmt.Errorf("timed out: %w", ctxErr)
+		}
+		return fmt.Errorf("cancelled: %w", ctxErr)
+	}
+
+	return err
+}
+
+// captureOutput collects cmd's stdout and stderr and, when ctx carries an output writer,
+// streams both to it as they are produced. The returned func flushes unterminated lines
+// and must be called after the command finished.
+func captureOutput(ctx context.Context, cmd *exec.Cmd) (stdout, stderr *bytes.Buffer, flush func()) {
+	stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	out := outputFrom(ctx)
+	if out == nil {
+		return stdout, stderr, func() {}
+	}
+
+	// One line writer per stream so concurrent stdout/stderr writes never split a line
+	var mu sync.Mutex
+	outLines := &lineWriter{out: out, mu: &mu}
+	errLines := &lineWriter{out: out, mu: &mu}
+	cmd.Stdout = io.MultiWriter(stdout, outLines)
+	cmd.Stderr = io.MultiWriter(stderr, errLines)
+
+	return stdout, stderr, func() {
+		outLines.Flush()
+		errLines.Flush()
+	}
+}
+
+// ansiEscape matches terminal color codes emitted by the CLI log functions
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// lineWriter forwards complete lines to out without terminal color codes
+type lineWriter struct {
+	out     io.Writer
+	mu      *sync.Mutex
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+
+	if i := bytes.LastIndexByte(w.partial, '\n'); i >= 0 {
+		w.emit(w.partial[:i+1])
+		w.partial = append(w.partial[:0], w.partial[i+1:]...)
+	}
+
+	// Output errors must not fail the script
+	return len(p), nil
+}
+
+// Flush forwards an unterminated last line
+func (w *lineWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.emit(append(w.partial, '\n'))
+		w.partial = nil
+	}
+}
+
+func (w *lineWriter) emit(lines []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.out.Write(ansiEscape.ReplaceAll(lines, nil))
+}
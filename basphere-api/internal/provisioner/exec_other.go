@@ -0,0 +1,15 @@
+//go:build !unix
+
+package provisioner
+
+import "os/exec"
+
+// Process groups are unix-only; elsewhere only the script itself is stopped
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func killProcessGroup(cmd *exec.Cmd) {}
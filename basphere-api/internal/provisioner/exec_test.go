@@ -0,0 +1,407 @@
+//go:build unix
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/config"
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+// setupScriptProvisioner returns a provisioner whose create-vm script is the given shell body
+func setupScriptProvisioner(t *testing.T, body string, timeouts config.TimeoutsConfig) *BashProvisioner {
+	t.Helper()
+
+	script := filepath.Join(t.TempDir(), "create-vm")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	return &BashProvisioner{
+		createVMScript: script,
+		timeouts:       timeouts,
+	}
+}
+
+// slowScript waits for a long time and records when its cleanup trap runs
+const slowScript = `
+trap 'echo cleaned > "$MARKER"; exit 143' TERM
+sleep 30 &
+wait $!
+`
+
+var testInput = &model.CreateVMInput{Name: "myvm", OS: "ubuntu-24.04", Spec: "small"}
+
+// =============================================================================
+// Script Execution Tests
+// =============================================================================
+
+func TestCreateVM_ParsesScriptOutput(t *testing.T) {
+	p := setupScriptProvisioner(t, `echo '{"name": "myvm", "ip_address": "10.254.0.10", "status": "running"}'`,
+		config.TimeoutsConfig{CreateVM: 10 * time.Second})
+
+	vm, err := p.CreateVM(context.Background(), "testuser", testInput)
+	if err != nil {
+		t.Fatalf("CreateVM failed: %v", err)
+	}
+	if vm.Name != "myvm" || vm.IPAddress != "10.254.0.10" {
+		t.Errorf("Unexpected VM: %+v", vm)
+	}
+}
+
+func TestCreateVM_PassesCloudInit(t *testing.T) {
+	// The script records its arguments and the user data it reads from stdin
+	dir := t.TempDir()
+	t.Setenv("MARKER", filepath.Join(dir, "marker"))
+	p := setupScriptProvisioner(t, `
+echo "$*" > "$MARKER.args"
+cat > "$MARKER.stdin"
+echo '{"name": "myvm", "status": "running"}'
+`, config.TimeoutsConfig{CreateVM: 10 * time.Second})
+
+	input := &model.CreateVMInput{
+		Name: "myvm", OS: "ubuntu-24.04", Spec: "small",
+		Hostname:          "web",
+		SSHAuthorizedKeys: []string{"ssh-ed25519 AAAA ci"},
+		Packages:          []string{"nginx", "git"},
+		UserData:          "#cloud-config\npassword: secret\n",
+	}
+	if _, err := p.CreateVM(context.Background(), "testuser", input); err != nil {
+		t.Fatalf("CreateVM failed: %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "marker.args"))
+	for _, arg := range []string{"--hostname web", "--ssh-key ssh-ed25519 AAAA ci", "--package nginx --package git", "--user-data -"} {
+		if !strings.Contains(string(args), arg) {
+			t.Errorf("Expected arguments to contain %q, got %q", arg, args)
+		}
+	}
+	if strings.Contains(string(args), "secret") {
+		t.Error("Expected user data to stay out of the arguments")
+	}
+
+	stdin, _ := os.ReadFile(filepath.Join(dir, "marker.stdin"))
+	if string(stdin) != input.UserData {
+		t.Errorf("Expected user data on stdin, got %q", stdin)
+	}
+}
+
+func TestCreateVM_PassesLabels(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MARKER", filepath.Join(dir, "marker"))
+	p := setupScriptProvisioner(t, `
+echo "$*" > "$MARKER"
+echo '{"name": "myvm", "status": "running"}'
+`, config.TimeoutsConfig{CreateVM: 10 * time.Second})
+
+	input := &model.CreateVMInput{
+		Name: "myvm", OS: "ubuntu-24.04", Spec: "small",
+		Labels:      map[string]string{"tier": "web", "experiment": "a1"},
+		Description: "load test",
+	}
+	if _, err := p.CreateVM(context.Background(), "testuser", input); err != nil {
+		t.Fatalf("CreateVM failed: %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "marker"))
+	if !strings.Contains(string(args), "--label experiment=a1 --label tier=web --description load test") {
+		t.Errorf("Expected sorted labels and description, got %q", args)
+	}
+}
+
+func TestCreateVM_PassesMaxIPs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MARKER", filepath.Join(dir, "marker"))
+	p := setupScriptProvisioner(t, `
+echo "$*" > "$MARKER"
+echo '{"name": "myvm", "status": "running"}'
+`, config.TimeoutsConfig{CreateVM: 10 * time.Second})
+
+	tests := []struct {
+		maxIPs int
+		want   bool
+	}{
+		{48, true},
+		{0, false},
+	}
+
+	for _, tt := range tests {
+		input := &model.CreateVMInput{Name: "myvm", OS: "ubuntu-24.04", Spec: "small", MaxIPs: tt.maxIPs}
+		if _, err := p.CreateVM(context.Background(), "testuser", input); err != nil {
+			t.Fatalf("CreateVM failed: %v", err)
+		}
+
+		args, _ := os.ReadFile(filepath.Join(dir, "marker"))
+		if got := strings.Contains(string(args), "--max-ips"); got != tt.want {
+			t.Errorf("MaxIPs %d: expected --max-ips passed = %v, got %q", tt.maxIPs, tt.want, args)
+		}
+		if tt.want && !strings.Contains(string(args), "--max-ips 48") {
+			t.Errorf("Expected --max-ips 48, got %q", args)
+		}
+	}
+}
+
+func TestUpdateVMMetadata_SendsJSONOnStdin(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "label-resource")
+	body := `#!/bin/sh
+echo "$@" > "$MARKER.args"
+cat > "$MARKER.stdin"
+echo '{"name": "myvm", "labels": {"env": "dev"}, "description": "it'"'"'s mine"}'
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	dir := t.TempDir()
+	t.Setenv("MARKER", filepath.Join(dir, "marker"))
+	p := &BashProvisioner{labelScript: script, timeouts: config.TimeoutsConfig{Label: 10 * time.Second}}
+
+	vm, err := p.UpdateVMMetadata(context.Background(), "testuser", "myvm", map[string]string{"env": "dev"}, "it's mine")
+	if err != nil {
+		t.Fatalf("UpdateVMMetadata failed: %v", err)
+	}
+	if vm.Labels["env"] != "dev" || vm.Description != "it's mine" {
+		t.Errorf("Unexpected VM: %+v", vm)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "marker.args"))
+	if got := strings.TrimSpace(string(args)); got != "--api --user testuser vm myvm" {
+		t.Errorf("Unexpected args: %q", got)
+	}
+	stdin, _ := os.ReadFile(filepath.Join(dir, "marker.stdin"))
+	if string(stdin) != `{"description":"it's mine","labels":{"env":"dev"}}` {
+		t.Errorf("Unexpected stdin: %s", stdin)
+	}
+}
+
+func TestCreateVM_StreamsOutput(t *testing.T) {
+	p := setupScriptProvisioner(t, `
+printf '\033[0;34m[INFO]\033[0m terraform init\n' >&2
+printf 'partial line' >&2
+echo '{"name": "myvm", "status": "running"}'
+`, config.TimeoutsConfig{CreateVM: 10 * time.Second})
+
+	var out bytes.Buffer
+	if _, err := p.CreateVM(WithOutput(context.Background(), &out), "testuser", testInput); err != nil {
+		t.Fatalf("CreateVM failed: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"[INFO] terraform init\n",
+		"partial line\n",
+		`{"name": "myvm", "status": "running"}` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, got)
+		}
+	}
+	if strings.Contains(got, "\x1b") {
+		t.Errorf("Expected color codes to be stripped, got %q", got)
+	}
+}
+
+func TestCreateVM_CancelRunsCleanup(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	t.Setenv("MARKER", marker)
+
+	p := setupScriptProvisioner(t, slowScript, config.TimeoutsConfig{
+		CreateVM:    time.Minute,
+		CancelGrace: 5 * time.Second,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := p.CreateVM(ctx, "testuser", testInput)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Expected cancelled error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected script to stop promptly, took %v", elapsed)
+	}
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("Expected script cleanup trap to run on cancellation")
+	}
+}
+
+func TestCreateVM_Timeout(t *testing.T) {
+	t.Setenv("MARKER", filepath.Join(t.TempDir(), "marker"))
+
+	p := setupScriptProvisioner(t, slowScript, config.TimeoutsConfig{
+		CreateVM:    200 * time.Millisecond,
+		CancelGrace: 5 * time.Second,
+	})
+
+	_, err := p.CreateVM(context.Background(), "testuser", testInput)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected timeout error, got: %v", err)
+	}
+}
+
+func TestCreateVM_KillsScriptIgnoringTerm(t *testing.T) {
+	p := setupScriptProvisioner(t, `
+trap '' TERM
+sleep 30
+`, config.TimeoutsConfig{
+		CreateVM:    200 * time.Millisecond,
+		CancelGrace: 200 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if _, err := p.CreateVM(context.Background(), "testuser", testInput); err == nil {
+		t.Fatal("Expected error for killed script")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected script to be killed after the grace period, took %v", elapsed)
+	}
+}
+
+func TestPowerVM_PassesActionAndParsesOutput(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "power-vm")
+	body := `#!/bin/sh
+echo "{\"name\": \"$4\", \"owner\": \"$3\", \"status\": \"$5\"}"
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	p := &BashProvisioner{powerVMScript: script, timeouts: config.TimeoutsConfig{PowerVM: 10 * time.Second}}
+
+	// The script echoes its arguments (--api --user <user> <vm> <action>) back as the VM
+	vm, err := p.PowerVM(context.Background(), "testuser", "myvm", model.VMActionStop)
+	if err != nil {
+		t.Fatalf("PowerVM failed: %v", err)
+	}
+	if vm.Name != "myvm" || vm.Owner != "testuser" || vm.Status != "stop" {
+		t.Errorf("Unexpected VM: %+v", vm)
+	}
+}
+
+func TestCreateSnapshot_PassesOptionsAndParsesOutput(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "snapshot-vm")
+	body := `#!/bin/sh
+[ "$6" = "--memory" ] || exit 1
+echo "{\"name\": \"$9\", \"vm_name\": \"$7\", \"description\": \"$5\", \"memory\": true}"
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	p := &BashProvisioner{snapshotVMScript: script, timeouts: config.TimeoutsConfig{Snapshot: 10 * time.Second}}
+
+	// --api --user <user> --description <text> --memory <vm> create <name>
+	snapshot, err := p.CreateSnapshot(context.Background(), "testuser", "myvm",
+		&model.CreateSnapshotInput{Name: "before-upgrade", Description: "apt", Memory: true})
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+	if snapshot.Name != "before-upgrade" || snapshot.VMName != "myvm" || snapshot.Description != "apt" || !snapshot.Memory {
+		t.Errorf("Unexpected snapshot: %+v", snapshot)
+	}
+}
+
+func TestDiskScript_PassesActionAndParsesOutput(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "disk-vm")
+	body := `#!/bin/sh
+echo "$@" > "$MARKER"
+echo '{"name": "myvm", "data_disks": [{"name": "data", "size_gb": 100, "unit_number": 1}]}'
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	marker := filepath.Join(t.TempDir(), "args")
+	t.Setenv("MARKER", marker)
+	p := &BashProvisioner{diskVMScript: script, timeouts: config.TimeoutsConfig{Disk: 10 * time.Second}}
+
+	tests := []struct {
+		name     string
+		run      func() (*model.VM, error)
+		expected string
+	}{
+		{"attach", func() (*model.VM, error) {
+			return p.AttachDisk(context.Background(), "testuser", "myvm", "data", 100)
+		}, "--api --user testuser --size-gb 100 myvm attach data"},
+		{"grow", func() (*model.VM, error) {
+			return p.ResizeDisk(context.Background(), "testuser", "myvm", "data", 150)
+		}, "--api --user testuser --size-gb 150 myvm grow data"},
+		{"detach", func() (*model.VM, error) {
+			return p.DetachDisk(context.Background(), "testuser", "myvm", "data")
+		}, "--api --user testuser myvm detach data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm, err := tt.run()
+			if err != nil {
+				t.Fatalf("Disk script failed: %v", err)
+			}
+			if len(vm.DataDisks) != 1 || vm.DataDisks[0].SizeGB != 100 {
+				t.Errorf("Unexpected VM: %+v", vm)
+			}
+
+			args, _ := os.ReadFile(marker)
+			if got := strings.TrimSpace(string(args)); got != tt.expected {
+				t.Errorf("Expected args %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestListSnapshots_ReadsMetadataBesideVM(t *testing.T) {
+	dataDir := t.TempDir()
+	vmDir := filepath.Join(dataDir, "terraform", "testuser", "myvm")
+	if err := os.MkdirAll(vmDir, 0755); err != nil {
+		t.Fatalf("Failed to create VM dir: %v", err)
+	}
+	os.WriteFile(filepath.Join(vmDir, "metadata.json"), []byte(`{"name": "myvm"}`), 0644)
+	p := &BashProvisioner{dataDir: dataDir}
+
+	// No snapshots.json yet
+	snapshots, err := p.ListSnapshots(context.Background(), "testuser", "myvm")
+	if err != nil || len(snapshots) != 0 {
+		t.Fatalf("Expected no snapshots, got %+v (err: %v)", snapshots, err)
+	}
+
+	os.WriteFile(filepath.Join(vmDir, "snapshots.json"), []byte(`[{"name": "snap", "vm_name": "myvm"}]`), 0644)
+	snapshots, err = p.ListSnapshots(context.Background(), "testuser", "myvm")
+	if err != nil || len(snapshots) != 1 || snapshots[0].Name != "snap" {
+		t.Errorf("Expected one snapshot, got %+v (err: %v)", snapshots, err)
+	}
+
+	if _, err := p.ListSnapshots(context.Background(), "testuser", "missing"); err == nil {
+		t.Error("Expected error for unknown VM")
+	}
+}
+
+func TestGetUserKey_ReadsUserRecord(t *testing.T) {
+	dataDir := t.TempDir()
+	p := &BashProvisioner{dataDir: dataDir}
+
+	if _, err := readUserKey(dataDir, "testuser"); !os.IsNotExist(err) {
+		t.Fatalf("Expected a missing record to be reported as not exist, got %v", err)
+	}
+
+	const key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAITest test@host"
+	if err := writeUserKey(dataDir, "testuser", key); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	got, err := p.GetUserKey(context.Background(), "testuser")
+	if err != nil {
+		t.Fatalf("Failed to read key: %v", err)
+	}
+	if got != key {
+		t.Errorf("Expected %q, got %q", key, got)
+	}
+}
@@ -2,42 +2,67 @@ package provisioner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/basphere/basphere-api/internal/config"
 	"github.com/basphere/basphere-api/internal/model"
 )
 
 // Provisioner defines the interface for user and VM provisioning
+// All operations honor ctx: script-backed operations are stopped when it is cancelled
 type Provisioner interface {
 	// User management
-	CreateUser(req *model.RegistrationRequest) error
-	UserExists(username string) (bool, error)
-	UpdateUserKey(username, newPublicKey string) error
-	GetUserEmail(username string) (string, error)
+	// The registered public key is kept in the user record; bastionKey is what the bastion's
+	// authorized_keys gets (the key itself, or a cert-authority line when the SSH CA is enabled)
+	CreateUser(ctx context.Context, req *model.RegistrationRequest, bastionKey string) error
+	UserExists(ctx context.Context, username string) (bool, error)
+	UpdateUserKey(ctx context.Context, username, newPublicKey, bastionKey string) error
+	GetUserKey(ctx context.Context, username string) (string, error)
+	GetUserEmail(ctx context.Context, username string) (string, error)
 
 	// VM management
-	CreateVM(username string, input *model.CreateVMInput) (*model.VM, error)
-	DeleteVM(username, vmName string) error
-	ListVMs(username string) ([]model.VM, error)
-	GetVM(username, vmName string) (*model.VM, error)
-	VMExists(username, vmName string) (bool, error)
-
-	// Quota
-	GetQuota(username string) (*model.Quota, error)
+	CreateVM(ctx context.Context, username string, input *model.CreateVMInput) (*model.VM, error)
+	DeleteVM(ctx context.Context, username, vmName string) error
+	ListVMs(ctx context.Context, username string) ([]model.VM, error)
+	GetVM(ctx context.Context, username, vmName string) (*model.VM, error)
+	VMExists(ctx context.Context, username, vmName string) (bool, error)
+	PowerVM(ctx context.Context, username, vmName string, action model.VMAction) (*model.VM, error)
+	ResizeVM(ctx context.Context, username, vmName, spec string, diskGB int) (*model.VM, error)
+	// Replaces the labels and description kept in metadata.json
+	UpdateVMMetadata(ctx context.Context, username, vmName string, labels map[string]string, description string) (*model.VM, error)
+
+	// VM snapshots (metadata kept beside the VM's metadata.json)
+	CreateSnapshot(ctx context.Context, username, vmName string, input *model.CreateSnapshotInput) (*model.Snapshot, error)
+	ListSnapshots(ctx context.Context, username, vmName string) ([]model.Snapshot, error)
+	RevertSnapshot(ctx context.Context, username, vmName, snapshotName string) (*model.VM, error)
+	DeleteSnapshot(ctx context.Context, username, vmName, snapshotName string) error
+
+	// VM data disks (rendered into the VM's Terraform configuration)
+	AttachDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error)
+	ResizeDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error)
+	DetachDisk(ctx context.Context, username, vmName, diskName string) (*model.VM, error)
+
+	// Quota usage (the limits are resolved by the quota package and left zero here)
+	GetQuota(ctx context.Context, username string) (*model.Quota, error)
 
 	// Cluster management (Stage 2)
-	CreateCluster(username string, input *model.CreateClusterInput) (*model.Cluster, error)
-	DeleteCluster(username, clusterName string) error
-	ListClusters(username string) ([]model.Cluster, error)
-	GetCluster(username, clusterName string) (*model.Cluster, error)
-	ClusterExists(username, clusterName string) (bool, error)
-	GetKubeconfig(username, clusterName string) ([]byte, error)
-	GetClusterQuota(username string) (*model.ClusterQuota, error)
+	CreateCluster(ctx context.Context, username string, input *model.CreateClusterInput) (*model.Cluster, error)
+	DeleteCluster(ctx context.Context, username, clusterName string) error
+	ListClusters(ctx context.Context, username string) ([]model.Cluster, error)
+	GetCluster(ctx context.Context, username, clusterName string) (*model.Cluster, error)
+	ClusterExists(ctx context.Context, username, clusterName string) (bool, error)
+	GetKubeconfig(ctx context.Context, username, clusterName string) ([]byte, error)
+	UpdateClusterMetadata(ctx context.Context, username, clusterName string, labels map[string]string, description string) (*model.Cluster, error)
+	GetClusterQuota(ctx context.Context, username string) (*model.ClusterQuota, error)
 }
 
 // BashProvisioner implements Provisioner using bash scripts
@@ -46,14 +71,22 @@ type BashProvisioner struct {
 	createVMScript      string
 	deleteVMScript      string
 	listVMsScript       string
+	powerVMScript       string
+	resizeVMScript      string
+	snapshotVMScript    string
+	diskVMScript        string
+	labelScript         string
 	createClusterScript string
 	deleteClusterScript string
 	tempDir             string
 	dataDir             string
+	timeouts            config.TimeoutsConfig
 }
 
 // NewBashProvisioner creates a new bash-based provisioner
-func NewBashProvisioner(adminScript string) (*BashProvisioner, error) {
+func NewBashProvisioner(cfg config.ProvisionerConfig) (*BashProvisioner, error) {
+	adminScript := cfg.AdminScript
+
 	// Check if admin script exists
 	if _, err := os.Stat(adminScript); err != nil {
 		return nil, fmt.Errorf("admin script not found: %s", adminScript)
@@ -69,33 +102,49 @@ func NewBashProvisioner(adminScript string) (*BashProvisioner, error) {
 		createVMScript:      "/usr/local/bin/create-vm",
 		deleteVMScript:      "/usr/local/bin/delete-vm",
 		listVMsScript:       "/usr/local/bin/list-vms",
+		powerVMScript:       "/usr/local/bin/power-vm",
+		resizeVMScript:      "/usr/local/bin/resize-vm",
+		snapshotVMScript:    "/usr/local/bin/snapshot-vm",
+		diskVMScript:        "/usr/local/bin/disk-vm",
+		labelScript:         "/usr/local/bin/label-resource",
 		createClusterScript: "/usr/local/bin/create-cluster",
 		deleteClusterScript: "/usr/local/bin/delete-cluster",
 		tempDir:             tempDir,
-		dataDir:             "/var/lib/basphere",
+		dataDir:             DataDir,
+		timeouts:            cfg.Timeouts,
 	}, nil
 }
 
 // CreateUser creates a system user with the given SSH public key
-func (p *BashProvisioner) CreateUser(req *model.RegistrationRequest) error {
+func (p *BashProvisioner) CreateUser(ctx context.Context, req *model.RegistrationRequest, bastionKey string) error {
 	// Sanitize SSH key (remove Windows line endings)
 	publicKey := strings.ReplaceAll(strings.TrimSpace(req.PublicKey), "\r", "")
 
-	// Write public key to temp file
+	// Write public key and the bastion's authorized_keys entry to temp files
 	pubkeyFile := filepath.Join(p.tempDir, req.Username+".pub")
 	if err := os.WriteFile(pubkeyFile, []byte(publicKey), 0600); err != nil {
 		return fmt.Errorf("failed to write public key: %w", err)
 	}
 	defer os.Remove(pubkeyFile)
 
+	authorizedKeysFile := filepath.Join(p.tempDir, req.Username+".authorized_keys")
+	if err := os.WriteFile(authorizedKeysFile, []byte(bastionKey+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write authorized_keys: %w", err)
+	}
+	defer os.Remove(authorizedKeysFile)
+
+	ctx, cancel := withTimeout(ctx, p.timeouts.User)
+	defer cancel()
+
 	// Run basphere-admin user add command
-	cmd := exec.Command("sudo", p.adminScript, "user", "add", req.Username, "--pubkey", pubkeyFile)
+	cmd := p.command(ctx, "sudo", p.adminScript, "user", "add", req.Username,
+		"--pubkey", pubkeyFile, "--authorized-keys", authorizedKeysFile)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
 
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to create user: %s\nstdout: %s\nstderr: %s",
 			err, stdout.String(), stderr.String())
 	}
@@ -104,9 +153,15 @@ func (p *BashProvisioner) CreateUser(req *model.RegistrationRequest) error {
 }
 
 // UserExists checks if a system user already exists
-func (p *BashProvisioner) UserExists(username string) (bool, error) {
-	cmd := exec.Command("id", username)
-	err := cmd.Run()
+func (p *BashProvisioner) UserExists(ctx context.Context, username string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.User)
+	defer cancel()
+
+	cmd := p.command(ctx, "id", username)
+	err := run(ctx, cmd)
+	if ctx.Err() != nil {
+		return false, err
+	}
 	if err != nil {
 		// User does not exist
 		return false, nil
@@ -114,27 +169,23 @@ func (p *BashProvisioner) UserExists(username string) (bool, error) {
 	return true, nil
 }
 
-// UpdateUserKey updates the SSH public key for a user
-func (p *BashProvisioner) UpdateUserKey(username, newPublicKey string) error {
+// UpdateUserKey records a new SSH public key for a user and reinstalls the bastion's authorized_keys
+func (p *BashProvisioner) UpdateUserKey(ctx context.Context, username, newPublicKey, bastionKey string) error {
 	// Sanitize SSH key (remove Windows line endings)
 	newPublicKey = strings.ReplaceAll(strings.TrimSpace(newPublicKey), "\r", "")
 
-	// Get user home directory
-	cmd := exec.Command("getent", "passwd", username)
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("user not found: %s", username)
+	ctx, cancel := withTimeout(ctx, p.timeouts.User)
+	defer cancel()
+
+	homeDir, err := p.userHomeDir(ctx, username)
+	if err != nil {
+		return err
 	}
 
-	// Parse home directory from passwd entry (username:x:uid:gid:gecos:home:shell)
-	parts := strings.Split(strings.TrimSpace(stdout.String()), ":")
-	if len(parts) < 6 {
-		return fmt.Errorf("invalid passwd entry for user: %s", username)
+	if err := writeUserKey(p.dataDir, username, newPublicKey); err != nil {
+		return err
 	}
-	homeDir := parts[5]
 
-	// Write new public key to authorized_keys
 	sshDir := filepath.Join(homeDir, ".ssh")
 	authorizedKeysPath := filepath.Join(sshDir, "authorized_keys")
 
@@ -143,22 +194,112 @@ func (p *BashProvisioner) UpdateUserKey(username, newPublicKey string) error {
 		return fmt.Errorf("failed to create .ssh directory: %w", err)
 	}
 
-	// Write the new public key
-	if err := os.WriteFile(authorizedKeysPath, []byte(newPublicKey+"\n"), 0600); err != nil {
+	if err := os.WriteFile(authorizedKeysPath, []byte(bastionKey+"\n"), 0600); err != nil {
 		return fmt.Errorf("failed to write authorized_keys: %w", err)
 	}
 
 	// Fix ownership using chown command (since we're running as root)
-	chownCmd := exec.Command("chown", "-R", username+":"+username, sshDir)
-	if err := chownCmd.Run(); err != nil {
+	chownCmd := p.command(ctx, "chown", "-R", username+":"+username, sshDir)
+	if err := run(ctx, chownCmd); err != nil {
 		return fmt.Errorf("failed to set ownership: %w", err)
 	}
 
 	return nil
 }
 
+// GetUserKey returns the user's registered SSH public key from the user record
+// Users created before the key was recorded fall back to the first key of authorized_keys.
+func (p *BashProvisioner) GetUserKey(ctx context.Context, username string) (string, error) {
+	key, err := readUserKey(p.dataDir, username)
+	if err == nil || !os.IsNotExist(err) {
+		return key, err
+	}
+
+	ctx, cancel := withTimeout(ctx, p.timeouts.User)
+	defer cancel()
+
+	homeDir, err := p.userHomeDir(ctx, username)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(homeDir, ".ssh", "authorized_keys"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no registered SSH key for user: %s", username)
+		}
+		return "", fmt.Errorf("failed to read authorized_keys: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		// cert-authority lines trust the SSH CA and are not the user's key
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "cert-authority") {
+			continue
+		}
+		return line, nil
+	}
+
+	return "", fmt.Errorf("no registered SSH key for user: %s", username)
+}
+
+// userKeyPath returns where the registered public key is kept, next to the user's metadata
+func userKeyPath(dataDir, username string) string {
+	return filepath.Join(dataDir, "users", username, "public_key")
+}
+
+// readUserKey reads the registered public key; a missing record is reported as os.IsNotExist
+func readUserKey(dataDir, username string) (string, error) {
+	data, err := os.ReadFile(userKeyPath(dataDir, username))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to read registered key: %w", err)
+	}
+
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("no registered SSH key for user: %s", username)
+	}
+	return key, nil
+}
+
+// writeUserKey records the registered public key in the user record
+func writeUserKey(dataDir, username, publicKey string) error {
+	path := userKeyPath(dataDir, username)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create user directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(publicKey+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write registered key: %w", err)
+	}
+	return nil
+}
+
+// userHomeDir looks up a system user's home directory
+func (p *BashProvisioner) userHomeDir(ctx context.Context, username string) (string, error) {
+	cmd := p.command(ctx, "getent", "passwd", username)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := run(ctx, cmd); err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("user not found: %s", username)
+	}
+
+	// Parse home directory from passwd entry (username:x:uid:gid:gecos:home:shell)
+	parts := strings.Split(strings.TrimSpace(stdout.String()), ":")
+	if len(parts) < 6 {
+		return "", fmt.Errorf("invalid passwd entry for user: %s", username)
+	}
+
+	return parts[5], nil
+}
+
 // GetUserEmail retrieves the email from the user's registration record
-func (p *BashProvisioner) GetUserEmail(username string) (string, error) {
+func (p *BashProvisioner) GetUserEmail(ctx context.Context, username string) (string, error) {
 	// Try to read from user's metadata file
 	metadataPath := filepath.Join(p.dataDir, "users", username+".json")
 	data, err := os.ReadFile(metadataPath)
@@ -180,24 +321,47 @@ func (p *BashProvisioner) GetUserEmail(username string) (string, error) {
 }
 
 // CreateVM creates a new VM for the user
-func (p *BashProvisioner) CreateVM(username string, input *model.CreateVMInput) (*model.VM, error) {
+func (p *BashProvisioner) CreateVM(ctx context.Context, username string, input *model.CreateVMInput) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.CreateVM)
+	defer cancel()
+
 	// Run create-vm script with --api flag (non-interactive, JSON output)
-	cmd := exec.Command(p.createVMScript,
+	args := []string{
 		"--api",
 		"--name", input.Name,
 		"--os", input.OS,
 		"--spec", input.Spec,
 		"--user", username,
-	)
+	}
+	if input.Hostname != "" {
+		args = append(args, "--hostname", input.Hostname)
+	}
+	for _, key := range input.SSHAuthorizedKeys {
+		args = append(args, "--ssh-key", key)
+	}
+	for _, pkg := range input.Packages {
+		args = append(args, "--package", pkg)
+	}
+	args = append(args, metadataArgs(input.Labels, input.Description)...)
+	args = append(args, maxIPsArgs(input.MaxIPs)...)
+	if input.JobID != "" {
+		args = append(args, "--job-id", input.JobID)
+	}
+	// User data may hold secrets, so it goes through stdin rather than the process list
+	if input.UserData != "" {
+		args = append(args, "--user-data", "-")
+	}
 
-	// Set environment to ensure proper execution
-	cmd.Env = append(os.Environ(), "BASPHERE_API_MODE=1")
+	cmd := p.scriptCommand(ctx, p.createVMScript, args...)
+	if input.UserData != "" {
+		cmd.Stdin = strings.NewReader(input.UserData)
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
 
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("failed to create VM: %s\nstderr: %s", err, stderr.String())
 	}
 
@@ -210,23 +374,53 @@ func (p *BashProvisioner) CreateVM(username string, input *model.CreateVMInput)
 	return &vm, nil
 }
 
+// metadataArgs returns the --label and --description options of the create scripts
+func metadataArgs(labels map[string]string, description string) []string {
+	var args []string
+
+	// Sorted so the command line is stable
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		args = append(args, "--label", k+"="+labels[k])
+	}
+	if description != "" {
+		args = append(args, "--description", description)
+	}
+	return args
+}
+
+// maxIPsArgs returns the --max-ips option the create scripts pass on to allocate-ip
+// Without it allocate-ip only knows the default quota, not team, user or admin overrides.
+func maxIPsArgs(maxIPs int) []string {
+	if maxIPs <= 0 {
+		return nil
+	}
+	return []string{"--max-ips", strconv.Itoa(maxIPs)}
+}
+
 // DeleteVM deletes a VM
-func (p *BashProvisioner) DeleteVM(username, vmName string) error {
+func (p *BashProvisioner) DeleteVM(ctx context.Context, username, vmName string) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.DeleteVM)
+	defer cancel()
+
 	// Run delete-vm script with --api flag
-	cmd := exec.Command(p.deleteVMScript,
+	cmd := p.scriptCommand(ctx, p.deleteVMScript,
 		"--api",
 		"--force",
 		"--user", username,
 		vmName,
 	)
 
-	cmd.Env = append(os.Environ(), "BASPHERE_API_MODE=1")
+	_, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to delete VM: %s\nstderr: %s", err, stderr.String())
 	}
 
@@ -234,112 +428,275 @@ func (p *BashProvisioner) DeleteVM(username, vmName string) error {
 }
 
 // ListVMs lists all VMs for a user
-func (p *BashProvisioner) ListVMs(username string) ([]model.VM, error) {
+func (p *BashProvisioner) ListVMs(ctx context.Context, username string) ([]model.VM, error) {
 	// Read VM metadata directly from filesystem
-	tfDir := filepath.Join(p.dataDir, "terraform", username)
+	return listVMRecords(p.dataDir, username)
+}
 
-	entries, err := os.ReadDir(tfDir)
+// GetVM gets a specific VM
+func (p *BashProvisioner) GetVM(ctx context.Context, username, vmName string) (*model.VM, error) {
+	return readVMRecord(p.dataDir, username, vmName)
+}
+
+// VMExists checks if a VM exists
+func (p *BashProvisioner) VMExists(ctx context.Context, username, vmName string) (bool, error) {
+	return vmRecordExists(p.dataDir, username, vmName)
+}
+
+// UpdateVMMetadata replaces the labels and description of a VM
+func (p *BashProvisioner) UpdateVMMetadata(ctx context.Context, username, vmName string, labels map[string]string, description string) (*model.VM, error) {
+	out, err := p.runLabelScript(ctx, username, "vm", vmName, labels, description)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []model.VM{}, nil
-		}
-		return nil, fmt.Errorf("failed to read VM directory: %w", err)
+		return nil, err
 	}
 
-	var vms []model.VM
-	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == "_folder" {
-			continue
-		}
+	var vm model.VM
+	if err := json.Unmarshal(out, &vm); err != nil {
+		return nil, fmt.Errorf("failed to parse VM output: %w\nstdout: %s", err, out)
+	}
 
-		metadataPath := filepath.Join(tfDir, entry.Name(), "metadata.json")
-		data, err := os.ReadFile(metadataPath)
-		if err != nil {
-			continue // Skip if metadata doesn't exist
-		}
+	return &vm, nil
+}
 
-		var vm model.VM
-		if err := json.Unmarshal(data, &vm); err != nil {
-			continue // Skip if invalid JSON
-		}
+// runLabelScript runs the label-resource script, which rewrites the labels and description in metadata.json
+// The new values go through stdin as JSON so descriptions need no quoting.
+func (p *BashProvisioner) runLabelScript(ctx context.Context, username, kind, name string, labels map[string]string, description string) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Label)
+	defer cancel()
 
-		vms = append(vms, vm)
+	if labels == nil {
+		labels = map[string]string{}
 	}
+	input, err := json.Marshal(map[string]interface{}{"labels": labels, "description": description})
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := p.scriptCommand(ctx, p.labelScript, "--api", "--user", username, kind, name)
+	cmd.Stdin = bytes.NewReader(input)
+
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err = run(ctx, cmd)
+	flush()
 
-	return vms, nil
+	if err != nil {
+		return nil, fmt.Errorf("failed to update labels: %s\nstderr: %s", err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
 }
 
-// GetVM gets a specific VM
-func (p *BashProvisioner) GetVM(username, vmName string) (*model.VM, error) {
-	metadataPath := filepath.Join(p.dataDir, "terraform", username, vmName, "metadata.json")
+// PowerVM performs a power operation on a VM and returns it with the resulting status
+func (p *BashProvisioner) PowerVM(ctx context.Context, username, vmName string, action model.VMAction) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.PowerVM)
+	defer cancel()
+
+	// Run power-vm script with --api flag (prints the updated metadata)
+	cmd := p.scriptCommand(ctx, p.powerVMScript,
+		"--api",
+		"--user", username,
+		vmName,
+		string(action),
+	)
+
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
 
-	data, err := os.ReadFile(metadataPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("VM not found: %s", vmName)
-		}
-		return nil, fmt.Errorf("failed to read VM metadata: %w", err)
+		return nil, fmt.Errorf("failed to %s VM: %s\nstderr: %s", action, err, stderr.String())
 	}
 
 	var vm model.VM
-	if err := json.Unmarshal(data, &vm); err != nil {
-		return nil, fmt.Errorf("failed to parse VM metadata: %w", err)
+	if err := json.Unmarshal(stdout.Bytes(), &vm); err != nil {
+		return nil, fmt.Errorf("failed to parse VM output: %w\nstdout: %s", err, stdout.String())
 	}
 
 	return &vm, nil
 }
 
-// VMExists checks if a VM exists
-func (p *BashProvisioner) VMExists(username, vmName string) (bool, error) {
-	metadataPath := filepath.Join(p.dataDir, "terraform", username, vmName, "metadata.json")
-	_, err := os.Stat(metadataPath)
+// ResizeVM changes the spec and disk size of a VM by re-applying its Terraform configuration
+func (p *BashProvisioner) ResizeVM(ctx context.Context, username, vmName, spec string, diskGB int) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.ResizeVM)
+	defer cancel()
+
+	// Run resize-vm script with --api flag (prints the updated metadata)
+	cmd := p.scriptCommand(ctx, p.resizeVMScript,
+		"--api",
+		"--user", username,
+		"--spec", spec,
+		"--disk-gb", strconv.Itoa(diskGB),
+		vmName,
+	)
+
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
+
 	if err != nil {
-		if os.IsNotExist(err) {
-			return false, nil
-		}
-		return false, err
+		return nil, fmt.Errorf("failed to resize VM: %s\nstderr: %s", err, stderr.String())
 	}
-	return true, nil
+
+	var vm model.VM
+	if err := json.Unmarshal(stdout.Bytes(), &vm); err != nil {
+		return nil, fmt.Errorf("failed to parse VM output: %w\nstdout: %s", err, stdout.String())
+	}
+
+	return &vm, nil
+}
+
+// CreateSnapshot takes a snapshot of a VM
+func (p *BashProvisioner) CreateSnapshot(ctx context.Context, username, vmName string, input *model.CreateSnapshotInput) (*model.Snapshot, error) {
+	args := []string{"--api", "--user", username, "--description", input.Description}
+	if input.Memory {
+		args = append(args, "--memory")
+	}
+	args = append(args, vmName, "create", input.Name)
+
+	stdout, err := p.runSnapshotScript(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	var snapshot model.Snapshot
+	if err := json.Unmarshal(stdout, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot output: %w\nstdout: %s", err, stdout)
+	}
+
+	return &snapshot, nil
+}
+
+// ListSnapshots lists the snapshots of a VM
+func (p *BashProvisioner) ListSnapshots(ctx context.Context, username, vmName string) ([]model.Snapshot, error) {
+	// Read snapshot metadata directly from filesystem
+	return readSnapshotRecords(p.dataDir, username, vmName)
+}
+
+// RevertSnapshot reverts a VM to a snapshot and returns it with the resulting status
+func (p *BashProvisioner) RevertSnapshot(ctx context.Context, username, vmName, snapshotName string) (*model.VM, error) {
+	stdout, err := p.runSnapshotScript(ctx, "--api", "--user", username, vmName, "revert", snapshotName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revert snapshot: %w", err)
+	}
+
+	var vm model.VM
+	if err := json.Unmarshal(stdout, &vm); err != nil {
+		return nil, fmt.Errorf("failed to parse VM output: %w\nstdout: %s", err, stdout)
+	}
+
+	return &vm, nil
+}
+
+// DeleteSnapshot deletes a snapshot of a VM
+func (p *BashProvisioner) DeleteSnapshot(ctx context.Context, username, vmName, snapshotName string) error {
+	if _, err := p.runSnapshotScript(ctx, "--api", "--user", username, vmName, "delete", snapshotName); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// runSnapshotScript runs the snapshot-vm script and returns its output
+func (p *BashProvisioner) runSnapshotScript(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Snapshot)
+	defer cancel()
+
+	cmd := p.scriptCommand(ctx, p.snapshotVMScript, args...)
+
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s\nstderr: %s", err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// AttachDisk adds a data disk to a VM
+func (p *BashProvisioner) AttachDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error) {
+	return p.runDiskScript(ctx, username, vmName, model.DiskActionAttach, diskName, sizeGB)
+}
+
+// ResizeDisk grows a data disk of a VM
+func (p *BashProvisioner) ResizeDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error) {
+	return p.runDiskScript(ctx, username, vmName, model.DiskActionGrow, diskName, sizeGB)
 }
 
-// GetQuota gets the quota for a user
-func (p *BashProvisioner) GetQuota(username string) (*model.Quota, error) {
+// DetachDisk removes a data disk from a VM, deleting its contents
+func (p *BashProvisioner) DetachDisk(ctx context.Context, username, vmName, diskName string) (*model.VM, error) {
+	return p.runDiskScript(ctx, username, vmName, model.DiskActionDetach, diskName, 0)
+}
+
+// runDiskScript runs the disk-vm script, which re-renders the VM's data disks and applies them with Terraform
+func (p *BashProvisioner) runDiskScript(ctx context.Context, username, vmName string, action model.DiskAction, diskName string, sizeGB int) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Disk)
+	defer cancel()
+
+	args := []string{"--api", "--user", username}
+	if sizeGB > 0 {
+		args = append(args, "--size-gb", strconv.Itoa(sizeGB))
+	}
+	args = append(args, vmName, string(action), diskName)
+
+	cmd := p.scriptCommand(ctx, p.diskVMScript, args...)
+
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s disk: %s\nstderr: %s", action, err, stderr.String())
+	}
+
+	var vm model.VM
+	if err := json.Unmarshal(stdout.Bytes(), &vm); err != nil {
+		return nil, fmt.Errorf("failed to parse VM output: %w\nstdout: %s", err, stdout.String())
+	}
+
+	return &vm, nil
+}
+
+// GetQuota gets the VM and IP usage for a user
+func (p *BashProvisioner) GetQuota(ctx context.Context, username string) (*model.Quota, error) {
 	// Get current VM count
-	vms, err := p.ListVMs(username)
+	vms, err := p.ListVMs(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
-	// Count IPs (same as VMs for now)
+	// Count IPs (same as VMs; the handler uses IPAM leases when available)
 	usedIPs := len(vms)
 
-	// Default quotas (should come from config)
 	return &model.Quota{
-		MaxVMs:  10,
 		UsedVMs: len(vms),
-		MaxIPs:  32,
 		UsedIPs: usedIPs,
 	}, nil
 }
 
 // CreateCluster creates a new Kubernetes cluster for the user
-func (p *BashProvisioner) CreateCluster(username string, input *model.CreateClusterInput) (*model.Cluster, error) {
+func (p *BashProvisioner) CreateCluster(ctx context.Context, username string, input *model.CreateClusterInput) (*model.Cluster, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.CreateCluster)
+	defer cancel()
+
 	// Run create-cluster script with --api flag
-	cmd := exec.Command(p.createClusterScript,
+	args := []string{
 		"--api",
 		"--name", input.Name,
 		"--type", input.Type,
 		"--worker-spec", input.WorkerSpec,
 		"--user", username,
-	)
+	}
+	args = append(args, metadataArgs(input.Labels, input.Description)...)
+	args = append(args, maxIPsArgs(input.MaxIPs)...)
 
-	cmd.Env = append(os.Environ(), "BASPHERE_API_MODE=1")
+	cmd := p.scriptCommand(ctx, p.createClusterScript, args...)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
 
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("failed to create cluster: %s\nstderr: %s", err, stderr.String())
 	}
 
@@ -353,21 +710,22 @@ func (p *BashProvisioner) CreateCluster(username string, input *model.CreateClus
 }
 
 // DeleteCluster deletes a Kubernetes cluster
-func (p *BashProvisioner) DeleteCluster(username, clusterName string) error {
-	cmd := exec.Command(p.deleteClusterScript,
+func (p *BashProvisioner) DeleteCluster(ctx context.Context, username, clusterName string) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.DeleteCluster)
+	defer cancel()
+
+	cmd := p.scriptCommand(ctx, p.deleteClusterScript,
 		"--api",
 		"--force",
 		"--user", username,
 		clusterName,
 	)
 
-	cmd.Env = append(os.Environ(), "BASPHERE_API_MODE=1")
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	_, stderr, flush := captureOutput(ctx, cmd)
+	err := run(ctx, cmd)
+	flush()
 
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to delete cluster: %s\nstderr: %s", err, stderr.String())
 	}
 
@@ -375,7 +733,7 @@ func (p *BashProvisioner) DeleteCluster(username, clusterName string) error {
 }
 
 // ListClusters lists all clusters for a user
-func (p *BashProvisioner) ListClusters(username string) ([]model.Cluster, error) {
+func (p *BashProvisioner) ListClusters(ctx context.Context, username string) ([]model.Cluster, error) {
 	clusterDir := filepath.Join(p.dataDir, "clusters", username)
 
 	entries, err := os.ReadDir(clusterDir)
@@ -410,7 +768,7 @@ func (p *BashProvisioner) ListClusters(username string) ([]model.Cluster, error)
 }
 
 // GetCluster gets a specific cluster
-func (p *BashProvisioner) GetCluster(username, clusterName string) (*model.Cluster, error) {
+func (p *BashProvisioner) GetCluster(ctx context.Context, username, clusterName string) (*model.Cluster, error) {
 	metadataPath := filepath.Join(p.dataDir, "clusters", username, clusterName, "metadata.json")
 
 	data, err := os.ReadFile(metadataPath)
@@ -430,7 +788,7 @@ func (p *BashProvisioner) GetCluster(username, clusterName string) (*model.Clust
 }
 
 // ClusterExists checks if a cluster exists
-func (p *BashProvisioner) ClusterExists(username, clusterName string) (bool, error) {
+func (p *BashProvisioner) ClusterExists(ctx context.Context, username, clusterName string) (bool, error) {
 	metadataPath := filepath.Join(p.dataDir, "clusters", username, clusterName, "metadata.json")
 	_, err := os.Stat(metadataPath)
 	if err != nil {
@@ -443,7 +801,7 @@ func (p *BashProvisioner) ClusterExists(username, clusterName string) (bool, err
 }
 
 // GetKubeconfig gets the kubeconfig for a cluster
-func (p *BashProvisioner) GetKubeconfig(username, clusterName string) ([]byte, error) {
+func (p *BashProvisioner) GetKubeconfig(ctx context.Context, username, clusterName string) ([]byte, error) {
 	kubeconfigPath := filepath.Join(p.dataDir, "clusters", username, clusterName, "kubeconfig")
 
 	data, err := os.ReadFile(kubeconfigPath)
@@ -457,62 +815,110 @@ func (p *BashProvisioner) GetKubeconfig(username, clusterName string) ([]byte, e
 	return data, nil
 }
 
-// GetClusterQuota gets the cluster quota for a user
-func (p *BashProvisioner) GetClusterQuota(username string) (*model.ClusterQuota, error) {
-	clusters, err := p.ListClusters(username)
+// UpdateClusterMetadata replaces the labels and description of a cluster
+func (p *BashProvisioner) UpdateClusterMetadata(ctx context.Context, username, clusterName string, labels map[string]string, description string) (*model.Cluster, error) {
+	out, err := p.runLabelScript(ctx, username, "cluster", clusterName, labels, description)
+	if err != nil {
+		return nil, err
+	}
+
+	var cluster model.Cluster
+	if err := json.Unmarshal(out, &cluster); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster output: %w\nstdout: %s", err, out)
+	}
+
+	return &cluster, nil
+}
+
+// GetClusterQuota gets the cluster usage for a user
+func (p *BashProvisioner) GetClusterQuota(ctx context.Context, username string) (*model.ClusterQuota, error) {
+	clusters, err := p.ListClusters(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
-	// Default quotas (should come from config)
 	return &model.ClusterQuota{
-		MaxClusters:        3,
-		UsedClusters:       len(clusters),
-		MaxNodesPerCluster: 10,
+		UsedClusters: len(clusters),
 	}, nil
 }
 
 // MockProvisioner is a provisioner for testing
+// Methods are safe for concurrent use (jobs call them from worker goroutines)
 type MockProvisioner struct {
-	Users    map[string]bool
-	VMs      map[string][]model.VM
-	Clusters map[string][]model.Cluster
+	mu sync.Mutex
+
+	Users       map[string]bool
+	Keys        map[string]string
+	BastionKeys map[string]string // authorized_keys installed on the bastion
+	VMs         map[string][]model.VM
+	Clusters    map[string][]model.Cluster
+	Snapshots   map[string][]model.Snapshot // keyed by "username/vmName"
 }
 
 // NewMockProvisioner creates a mock provisioner for testing
 func NewMockProvisioner() *MockProvisioner {
 	return &MockProvisioner{
-		Users:    make(map[string]bool),
-		VMs:      make(map[string][]model.VM),
-		Clusters: make(map[string][]model.Cluster),
+		Users:       make(map[string]bool),
+		Keys:        make(map[string]string),
+		BastionKeys: make(map[string]string),
+		VMs:         make(map[string][]model.VM),
+		Clusters:    make(map[string][]model.Cluster),
+		Snapshots:   make(map[string][]model.Snapshot),
 	}
 }
 
 // CreateUser mock implementation
-func (p *MockProvisioner) CreateUser(req *model.RegistrationRequest) error {
+func (p *MockProvisioner) CreateUser(ctx context.Context, req *model.RegistrationRequest, bastionKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if p.Users[req.Username] {
 		return fmt.Errorf("user already exists: %s", req.Username)
 	}
 	p.Users[req.Username] = true
+	p.Keys[req.Username] = req.PublicKey
+	p.BastionKeys[req.Username] = bastionKey
 	return nil
 }
 
 // UserExists mock implementation
-func (p *MockProvisioner) UserExists(username string) (bool, error) {
+func (p *MockProvisioner) UserExists(ctx context.Context, username string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	return p.Users[username], nil
 }
 
 // UpdateUserKey mock implementation
-func (p *MockProvisioner) UpdateUserKey(username, newPublicKey string) error {
+func (p *MockProvisioner) UpdateUserKey(ctx context.Context, username, newPublicKey, bastionKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if !p.Users[username] {
 		return fmt.Errorf("user not found: %s", username)
 	}
-	// In mock, just succeed if user exists
+	p.Keys[username] = newPublicKey
+	p.BastionKeys[username] = bastionKey
 	return nil
 }
 
+// GetUserKey mock implementation
+func (p *MockProvisioner) GetUserKey(ctx context.Context, username string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.Keys[username]
+	if !p.Users[username] || !ok {
+		return "", fmt.Errorf("no registered SSH key for user: %s", username)
+	}
+	return key, nil
+}
+
 // GetUserEmail mock implementation
-func (p *MockProvisioner) GetUserEmail(username string) (string, error) {
+func (p *MockProvisioner) GetUserEmail(ctx context.Context, username string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if !p.Users[username] {
 		return "", fmt.Errorf("user not found: %s", username)
 	}
@@ -520,7 +926,10 @@ func (p *MockProvisioner) GetUserEmail(username string) (string, error) {
 }
 
 // CreateVM mock implementation
-func (p *MockProvisioner) CreateVM(username string, input *model.CreateVMInput) (*model.VM, error) {
+func (p *MockProvisioner) CreateVM(ctx context.Context, username string, input *model.CreateVMInput) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Check if VM already exists
 	for _, vm := range p.VMs[username] {
 		if vm.Name == input.Name {
@@ -537,18 +946,43 @@ func (p *MockProvisioner) CreateVM(username string, input *model.CreateVMInput)
 		Spec:          input.Spec,
 		IPAddress:     fmt.Sprintf("10.254.0.%d", len(p.VMs[username])+10),
 		Status:        model.VMStatusRunning,
+		Hostname:      input.Hostname,
+		Labels:        input.Labels,
+		Description:   input.Description,
+		JobID:         input.JobID,
 	}
 
 	p.VMs[username] = append(p.VMs[username], vm)
+	progress(ctx, "[OK] VM %s created (%s)", vm.Name, vm.IPAddress)
+	return &vm, nil
+}
+
+// UpdateVMMetadata mock implementation
+func (p *MockProvisioner) UpdateVMMetadata(ctx context.Context, username, vmName string, labels map[string]string, description string) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.findVM(username, vmName)
+	if i < 0 {
+		return nil, fmt.Errorf("VM not found: %s", vmName)
+	}
+
+	p.VMs[username][i].Labels = labels
+	p.VMs[username][i].Description = description
+	vm := p.VMs[username][i]
 	return &vm, nil
 }
 
 // DeleteVM mock implementation
-func (p *MockProvisioner) DeleteVM(username, vmName string) error {
+func (p *MockProvisioner) DeleteVM(ctx context.Context, username, vmName string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	vms := p.VMs[username]
 	for i, vm := range vms {
 		if vm.Name == vmName {
 			p.VMs[username] = append(vms[:i], vms[i+1:]...)
+			delete(p.Snapshots, username+"/"+vmName)
 			return nil
 		}
 	}
@@ -556,12 +990,19 @@ func (p *MockProvisioner) DeleteVM(username, vmName string) error {
 }
 
 // ListVMs mock implementation
-func (p *MockProvisioner) ListVMs(username string) ([]model.VM, error) {
-	return p.VMs[username], nil
+func (p *MockProvisioner) ListVMs(ctx context.Context, username string) ([]model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A copy, like the records read from disk by the real provisioners
+	return append([]model.VM(nil), p.VMs[username]...), nil
 }
 
 // GetVM mock implementation
-func (p *MockProvisioner) GetVM(username, vmName string) (*model.VM, error) {
+func (p *MockProvisioner) GetVM(ctx context.Context, username, vmName string) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	for _, vm := range p.VMs[username] {
 		if vm.Name == vmName {
 			return &vm, nil
@@ -571,7 +1012,10 @@ func (p *MockProvisioner) GetVM(username, vmName string) (*model.VM, error) {
 }
 
 // VMExists mock implementation
-func (p *MockProvisioner) VMExists(username, vmName string) (bool, error) {
+func (p *MockProvisioner) VMExists(ctx context.Context, username, vmName string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	for _, vm := range p.VMs[username] {
 		if vm.Name == vmName {
 			return true, nil
@@ -580,19 +1024,239 @@ func (p *MockProvisioner) VMExists(username, vmName string) (bool, error) {
 	return false, nil
 }
 
+// PowerVM mock implementation
+func (p *MockProvisioner) PowerVM(ctx context.Context, username, vmName string, action model.VMAction) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, vm := range p.VMs[username] {
+		if vm.Name != vmName {
+			continue
+		}
+		switch action {
+		case model.VMActionStart, model.VMActionReboot:
+			vm.Status = model.VMStatusRunning
+		case model.VMActionStop, model.VMActionShutdown:
+			vm.Status = model.VMStatusStopped
+		}
+		p.VMs[username][i] = vm
+		return &vm, nil
+	}
+	return nil, fmt.Errorf("VM not found: %s", vmName)
+}
+
+// ResizeVM mock implementation
+func (p *MockProvisioner) ResizeVM(ctx context.Context, username, vmName, spec string, diskGB int) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, vm := range p.VMs[username] {
+		if vm.Name != vmName {
+			continue
+		}
+		vm.Spec = spec
+		vm.DiskGB = diskGB
+		p.VMs[username][i] = vm
+		return &vm, nil
+	}
+	return nil, fmt.Errorf("VM not found: %s", vmName)
+}
+
+// findVM returns the index of a user's VM, or -1 (callers hold p.mu)
+func (p *MockProvisioner) findVM(username, vmName string) int {
+	for i, vm := range p.VMs[username] {
+		if vm.Name == vmName {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateSnapshot mock implementation
+func (p *MockProvisioner) CreateSnapshot(ctx context.Context, username, vmName string, input *model.CreateSnapshotInput) (*model.Snapshot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.findVM(username, vmName) < 0 {
+		return nil, fmt.Errorf("VM not found: %s", vmName)
+	}
+
+	key := username + "/" + vmName
+	for _, s := range p.Snapshots[key] {
+		if s.Name == input.Name {
+			return nil, fmt.Errorf("snapshot already exists: %s", input.Name)
+		}
+	}
+
+	vm := p.VMs[username][p.findVM(username, vmName)]
+	snapshot := model.Snapshot{
+		Name:        input.Name,
+		VMName:      vmName,
+		Description: input.Description,
+		Memory:      input.Memory,
+		CreatedAt:   time.Now(),
+		Spec:        vm.Spec,
+		DiskGB:      vm.DiskGB,
+		DataDisks:   vm.DataDisks,
+	}
+	p.Snapshots[key] = append(p.Snapshots[key], snapshot)
+	return &snapshot, nil
+}
+
+// ListSnapshots mock implementation
+func (p *MockProvisioner) ListSnapshots(ctx context.Context, username, vmName string) ([]model.Snapshot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.findVM(username, vmName) < 0 {
+		return nil, fmt.Errorf("VM not found: %s", vmName)
+	}
+	return append([]model.Snapshot{}, p.Snapshots[username+"/"+vmName]...), nil
+}
+
+// RevertSnapshot mock implementation
+// Like vSphere, a snapshot without memory comes back powered off.
+func (p *MockProvisioner) RevertSnapshot(ctx context.Context, username, vmName, snapshotName string) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.findVM(username, vmName)
+	if i < 0 {
+		return nil, fmt.Errorf("VM not found: %s", vmName)
+	}
+
+	for _, s := range p.Snapshots[username+"/"+vmName] {
+		if s.Name != snapshotName {
+			continue
+		}
+		vm := p.VMs[username][i]
+		if s.Memory {
+			vm.Status = model.VMStatusRunning
+		} else {
+			vm.Status = model.VMStatusStopped
+		}
+		p.VMs[username][i] = vm
+		return &vm, nil
+	}
+	return nil, fmt.Errorf("snapshot not found: %s", snapshotName)
+}
+
+// DeleteSnapshot mock implementation
+func (p *MockProvisioner) DeleteSnapshot(ctx context.Context, username, vmName, snapshotName string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := username + "/" + vmName
+	snapshots := p.Snapshots[key]
+	for i, s := range snapshots {
+		if s.Name == snapshotName {
+			p.Snapshots[key] = append(snapshots[:i], snapshots[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("snapshot not found: %s", snapshotName)
+}
+
+// AttachDisk mock implementation
+func (p *MockProvisioner) AttachDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.findVM(username, vmName)
+	if i < 0 {
+		return nil, fmt.Errorf("VM not found: %s", vmName)
+	}
+
+	vm := p.VMs[username][i]
+	if vm.FindDataDisk(diskName) != nil {
+		return nil, fmt.Errorf("disk already exists: %s", diskName)
+	}
+
+	// Same unit numbers as the script: 1 upwards, skipping the controller's 7
+	unit := 1
+	for used := true; used; {
+		used = unit == 7
+		for _, d := range vm.DataDisks {
+			used = used || d.UnitNumber == unit
+		}
+		if used {
+			unit++
+		}
+	}
+
+	vm.DataDisks = append(append([]model.Disk{}, vm.DataDisks...), model.Disk{
+		Name:       diskName,
+		SizeGB:     sizeGB,
+		UnitNumber: unit,
+		CreatedAt:  time.Now(),
+	})
+	p.VMs[username][i] = vm
+	return &vm, nil
+}
+
+// ResizeDisk mock implementation
+func (p *MockProvisioner) ResizeDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.findVM(username, vmName)
+	if i < 0 {
+		return nil, fmt.Errorf("VM not found: %s", vmName)
+	}
+
+	vm := p.VMs[username][i]
+	vm.DataDisks = append([]model.Disk{}, vm.DataDisks...)
+	disk := vm.FindDataDisk(diskName)
+	if disk == nil {
+		return nil, fmt.Errorf("disk not found: %s", diskName)
+	}
+	disk.SizeGB = sizeGB
+	p.VMs[username][i] = vm
+	return &vm, nil
+}
+
+// DetachDisk mock implementation
+func (p *MockProvisioner) DetachDisk(ctx context.Context, username, vmName, diskName string) (*model.VM, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.findVM(username, vmName)
+	if i < 0 {
+		return nil, fmt.Errorf("VM not found: %s", vmName)
+	}
+
+	vm := p.VMs[username][i]
+	var disks []model.Disk
+	for _, d := range vm.DataDisks {
+		if d.Name != diskName {
+			disks = append(disks, d)
+		}
+	}
+	if len(disks) == len(vm.DataDisks) {
+		return nil, fmt.Errorf("disk not found: %s", diskName)
+	}
+	vm.DataDisks = disks
+	p.VMs[username][i] = vm
+	return &vm, nil
+}
+
 // GetQuota mock implementation
-func (p *MockProvisioner) GetQuota(username string) (*model.Quota, error) {
+func (p *MockProvisioner) GetQuota(ctx context.Context, username string) (*model.Quota, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	vms := p.VMs[username]
 	return &model.Quota{
-		MaxVMs:  10,
 		UsedVMs: len(vms),
-		MaxIPs:  32,
 		UsedIPs: len(vms),
 	}, nil
 }
 
 // CreateCluster mock implementation
-func (p *MockProvisioner) CreateCluster(username string, input *model.CreateClusterInput) (*model.Cluster, error) {
+func (p *MockProvisioner) CreateCluster(ctx context.Context, username string, input *model.CreateClusterInput) (*model.Cluster, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Check if cluster already exists
 	for _, c := range p.Clusters[username] {
 		if c.Name == input.Name {
@@ -610,14 +1274,36 @@ func (p *MockProvisioner) CreateCluster(username string, input *model.CreateClus
 		WorkerSpec:        input.WorkerSpec,
 		ControlPlaneIP:    fmt.Sprintf("10.254.0.%d", len(p.Clusters[username])+100),
 		Status:            model.ClusterStatusProvisioning,
+		Labels:            input.Labels,
+		Description:       input.Description,
 	}
 
 	p.Clusters[username] = append(p.Clusters[username], cluster)
+	progress(ctx, "[OK] cluster %s provisioning started", cluster.Name)
 	return &cluster, nil
 }
 
+// UpdateClusterMetadata mock implementation
+func (p *MockProvisioner) UpdateClusterMetadata(ctx context.Context, username, clusterName string, labels map[string]string, description string) (*model.Cluster, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, c := range p.Clusters[username] {
+		if c.Name == clusterName {
+			p.Clusters[username][i].Labels = labels
+			p.Clusters[username][i].Description = description
+			cluster := p.Clusters[username][i]
+			return &cluster, nil
+		}
+	}
+	return nil, fmt.Errorf("cluster not found: %s", clusterName)
+}
+
 // DeleteCluster mock implementation
-func (p *MockProvisioner) DeleteCluster(username, clusterName string) error {
+func (p *MockProvisioner) DeleteCluster(ctx context.Context, username, clusterName string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	clusters := p.Clusters[username]
 	for i, c := range clusters {
 		if c.Name == clusterName {
@@ -629,12 +1315,18 @@ func (p *MockProvisioner) DeleteCluster(username, clusterName string) error {
 }
 
 // ListClusters mock implementation
-func (p *MockProvisioner) ListClusters(username string) ([]model.Cluster, error) {
+func (p *MockProvisioner) ListClusters(ctx context.Context, username string) ([]model.Cluster, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	return p.Clusters[username], nil
 }
 
 // GetCluster mock implementation
-func (p *MockProvisioner) GetCluster(username, clusterName string) (*model.Cluster, error) {
+func (p *MockProvisioner) GetCluster(ctx context.Context, username, clusterName string) (*model.Cluster, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	for _, c := range p.Clusters[username] {
 		if c.Name == clusterName {
 			return &c, nil
@@ -644,7 +1336,10 @@ func (p *MockProvisioner) GetCluster(username, clusterName string) (*model.Clust
 }
 
 // ClusterExists mock implementation
-func (p *MockProvisioner) ClusterExists(username, clusterName string) (bool, error) {
+func (p *MockProvisioner) ClusterExists(ctx context.Context, username, clusterName string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	for _, c := range p.Clusters[username] {
 		if c.Name == clusterName {
 			return true, nil
@@ -654,7 +1349,10 @@ func (p *MockProvisioner) ClusterExists(username, clusterName string) (bool, err
 }
 
 // GetKubeconfig mock implementation
-func (p *MockProvisioner) GetKubeconfig(username, clusterName string) ([]byte, error) {
+func (p *MockProvisioner) GetKubeconfig(ctx context.Context, username, clusterName string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	for _, c := range p.Clusters[username] {
 		if c.Name == clusterName {
 			// Return a mock kubeconfig
@@ -681,33 +1379,12 @@ users:
 }
 
 // GetClusterQuota mock implementation
-func (p *MockProvisioner) GetClusterQuota(username string) (*model.ClusterQuota, error) {
+func (p *MockProvisioner) GetClusterQuota(ctx context.Context, username string) (*model.ClusterQuota, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	clusters := p.Clusters[username]
 	return &model.ClusterQuota{
-		MaxClusters:        3,
-		UsedClusters:       len(clusters),
-		MaxNodesPerCluster: 10,
+		UsedClusters: len(clusters),
 	}, nil
 }
-
-// Helper function to check OS support
-func isValidOS(os string) bool {
-	validOS := []string{"ubuntu-24.04", "rocky-10.1", "rocky-10"}
-	for _, v := range validOS {
-		if strings.EqualFold(os, v) {
-			return true
-		}
-	}
-	return false
-}
-
-// Helper function to check spec support
-func isValidSpec(spec string) bool {
-	validSpecs := []string{"tiny", "small", "medium", "large", "huge"}
-	for _, v := range validSpecs {
-		if strings.EqualFold(spec, v) {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,202 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// VM records are the files the create-vm script keeps per VM, shared by every provisioner:
+//
+//	<dataDir>/terraform/<user>/<vm>/metadata.json   model.VM
+//	<dataDir>/terraform/<user>/<vm>/snapshots.json  []model.Snapshot
+//
+// <dataDir>/terraform/<user>/_folder holds the user's vSphere folder and is not a VM.
+
+// DataDir is where the CLI scripts keep the VM and cluster records
+const DataDir = "/var/lib/basphere"
+
+// userFolderDir is the entry of a user's record directory that is not a VM
+const userFolderDir = "_folder"
+
+// Records reads and writes VM records directly, for changes that do not go through a
+// provisioner (drift reconciliation adopts VMs and marks records failed)
+type Records struct {
+	dataDir string
+}
+
+// NewRecords returns the VM records below dataDir
+func NewRecords(dataDir string) *Records {
+	return &Records{dataDir: dataDir}
+}
+
+// Users lists the users with a record directory
+func (r *Records) Users() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(r.dataDir, "terraform"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read VM directory: %w", err)
+	}
+
+	var users []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			users = append(users, entry.Name())
+		}
+	}
+	return users, nil
+}
+
+// ListVMs reads the records of all VMs of a user
+func (r *Records) ListVMs(username string) ([]model.VM, error) {
+	return listVMRecords(r.dataDir, username)
+}
+
+// GetVM reads the record of a VM
+func (r *Records) GetVM(username, vmName string) (*model.VM, error) {
+	return readVMRecord(r.dataDir, username, vmName)
+}
+
+// SaveVM writes the record of a VM
+func (r *Records) SaveVM(vm *model.VM) error {
+	return writeVMRecord(r.dataDir, vm)
+}
+
+// vmRecordDir returns the record directory of a VM
+func vmRecordDir(dataDir, username, vmName string) string {
+	return filepath.Join(dataDir, "terraform", username, vmName)
+}
+
+// listVMRecords reads the metadata of all VMs of a user, skipping unreadable records
+func listVMRecords(dataDir, username string) ([]model.VM, error) {
+	tfDir := filepath.Join(dataDir, "terraform", username)
+
+	entries, err := os.ReadDir(tfDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []model.VM{}, nil
+		}
+		return nil, fmt.Errorf("failed to read VM directory: %w", err)
+	}
+
+	var vms []model.VM
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == userFolderDir {
+			continue
+		}
+
+		metadataPath := filepath.Join(tfDir, entry.Name(), "metadata.json")
+		data, err := os.ReadFile(metadataPath)
+		if err != nil {
+			continue // Skip if metadata doesn't exist
+		}
+
+		var vm model.VM
+		if err := json.Unmarshal(data, &vm); err != nil {
+			continue // Skip if invalid JSON
+		}
+
+		vms = append(vms, vm)
+	}
+
+	return vms, nil
+}
+
+// readVMRecord reads the metadata of a VM
+func readVMRecord(dataDir, username, vmName string) (*model.VM, error) {
+	metadataPath := filepath.Join(vmRecordDir(dataDir, username, vmName), "metadata.json")
+
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("VM not found: %s", vmName)
+		}
+		return nil, fmt.Errorf("failed to read VM metadata: %w", err)
+	}
+
+	var vm model.VM
+	if err := json.Unmarshal(data, &vm); err != nil {
+		return nil, fmt.Errorf("failed to parse VM metadata: %w", err)
+	}
+
+	return &vm, nil
+}
+
+// vmRecordExists checks if a VM has metadata
+func vmRecordExists(dataDir, username, vmName string) (bool, error) {
+	metadataPath := filepath.Join(vmRecordDir(dataDir, username, vmName), "metadata.json")
+	_, err := os.Stat(metadataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// readSnapshotRecords reads the snapshot metadata of a VM
+func readSnapshotRecords(dataDir, username, vmName string) ([]model.Snapshot, error) {
+	vmDir := vmRecordDir(dataDir, username, vmName)
+	if _, err := os.Stat(filepath.Join(vmDir, "metadata.json")); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("VM not found: %s", vmName)
+		}
+		return nil, fmt.Errorf("failed to read VM metadata: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(vmDir, "snapshots.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []model.Snapshot{}, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	var snapshots []model.Snapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// writeVMRecord replaces the metadata of a VM, creating its record directory if needed
+func writeVMRecord(dataDir string, vm *model.VM) error {
+	vmDir := vmRecordDir(dataDir, vm.Owner, vm.Name)
+	if err := os.MkdirAll(vmDir, 0755); err != nil {
+		return fmt.Errorf("failed to create VM directory: %w", err)
+	}
+	return writeJSONFile(filepath.Join(vmDir, "metadata.json"), vm)
+}
+
+// writeSnapshotRecords replaces the snapshot metadata of a VM
+func writeSnapshotRecords(dataDir, username, vmName string, snapshots []model.Snapshot) error {
+	if snapshots == nil {
+		snapshots = []model.Snapshot{}
+	}
+	return writeJSONFile(filepath.Join(vmRecordDir(dataDir, username, vmName), "snapshots.json"), snapshots)
+}
+
+// writeJSONFile writes v to path through a temporary file, like the scripts' "jq ... > tmp && mv"
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,1244 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/basphere/basphere-api/internal/catalog"
+	"github.com/basphere/basphere-api/internal/config"
+	"github.com/basphere/basphere-api/internal/ipam"
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/vsphere"
+)
+
+// Guest network configuration modes of the vsphere driver
+const (
+	// cloud-init reads the network from guestinfo.metadata (same as the Terraform template)
+	CustomizationGuestInfo = "guestinfo"
+	// vSphere guest customization (LinuxPrep) sets the hostname and IP before first boot
+	CustomizationLinuxPrep = "linuxprep"
+)
+
+const (
+	// How long a new VM may take to report its IP (Terraform's wait_for_guest_net_timeout)
+	guestIPTimeout = 5 * time.Minute
+	// How long a guest shutdown is waited for (SHUTDOWN_WAIT in power-vm)
+	guestShutdownTimeout = 40 * time.Second
+	// Domain of the LinuxPrep hostname (required by vSphere)
+	customizationDomain = "localdomain"
+)
+
+// errNotInVSphere is returned when a VM record has no VM at its inventory path
+var errNotInVSphere = errors.New("VM not found in vSphere")
+
+// VSphereProvisioner implements the VM operations of Provisioner with govmomi
+// It keeps the same metadata.json and snapshots.json records as the scripts, so the CLI
+// keeps working on its VMs. Users and clusters go to the embedded provisioner.
+type VSphereProvisioner struct {
+	// User and cluster management (the scripts)
+	Provisioner
+
+	settings      vsphere.Settings
+	customization string
+	catalog       *catalog.Catalog
+	ipam          *ipam.IPAM
+	dataDir       string
+	timeouts      config.TimeoutsConfig
+
+	session *vsphere.Session
+}
+
+// NewVSphereProvisioner creates a provisioner managing VMs through vCenter
+// scripts handles users and clusters; VM sizes and templates come from cat and IPs from m.
+func NewVSphereProvisioner(ctx context.Context, cfg config.ProvisionerConfig, scripts Provisioner, cat *catalog.Catalog, m *ipam.IPAM) (*VSphereProvisioner, error) {
+	settings, err := vsphere.LoadSettings(cfg.VSphere)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newVSphereProvisioner(scripts, settings, cfg.VSphere.Customization, cfg.Timeouts, cat, m, settings.Login)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail early on a wrong server or credentials
+	if _, err := p.session.Client(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newVSphereProvisioner(scripts Provisioner, settings vsphere.Settings, customization string, timeouts config.TimeoutsConfig, cat *catalog.Catalog, m *ipam.IPAM, login func(ctx context.Context) (*vim25.Client, error)) (*VSphereProvisioner, error) {
+	switch customization {
+	case "":
+		customization = CustomizationGuestInfo
+	case CustomizationGuestInfo, CustomizationLinuxPrep:
+	default:
+		return nil, fmt.Errorf("unknown vSphere customization: %s", customization)
+	}
+
+	return &VSphereProvisioner{
+		Provisioner:   scripts,
+		settings:      settings,
+		customization: customization,
+		catalog:       cat,
+		ipam:          m,
+		dataDir:       DataDir,
+		timeouts:      timeouts,
+		session:       vsphere.NewSession(login),
+	}, nil
+}
+
+// vcenter is a connection with the configured datacenter resolved
+type vcenter struct {
+	client *vim25.Client
+	finder *find.Finder
+	dc     *object.Datacenter
+}
+
+// vcenter connects and resolves the configured datacenter
+func (p *VSphereProvisioner) vcenter(ctx context.Context) (*vcenter, error) {
+	c, err := p.session.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	finder := find.NewFinder(c, true)
+	dc, err := finder.Datacenter(ctx, p.settings.Datacenter)
+	if err != nil {
+		return nil, fmt.Errorf("datacenter not found: %w", err)
+	}
+	finder.SetDatacenter(dc)
+
+	return &vcenter{client: c, finder: finder, dc: dc}, nil
+}
+
+// vmInventoryPath returns where a VM lives: /<dc>/vm/<folder>/<user>/<user>-<vm>
+// (get_vm_inventory_path in common.sh)
+func (p *VSphereProvisioner) vmInventoryPath(vm *model.VM) string {
+	name := vm.VsphereVMName
+	if name == "" {
+		name = vm.Owner + "-" + vm.Name
+	}
+	return path.Join(p.settings.FolderPath(), vm.Owner, name)
+}
+
+// findVM returns the vSphere VM of a record, or an error wrapping errNotInVSphere
+func (p *VSphereProvisioner) findVM(ctx context.Context, vc *vcenter, vm *model.VM) (*object.VirtualMachine, error) {
+	inventoryPath := p.vmInventoryPath(vm)
+
+	ref, err := object.NewSearchIndex(vc.client).FindByInventoryPath(ctx, inventoryPath)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := ref.(*object.VirtualMachine)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errNotInVSphere, inventoryPath)
+	}
+	return obj, nil
+}
+
+// userFolder returns the user's VM folder <folder>/<user>, creating missing levels
+// (user-folder.tf in the scripts)
+func (p *VSphereProvisioner) userFolder(ctx context.Context, vc *vcenter, username string) (*object.Folder, error) {
+	folders, err := vc.dc.Folders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	index := object.NewSearchIndex(vc.client)
+	folder := folders.VmFolder
+	for _, name := range append(strings.Split(p.settings.Folder, "/"), username) {
+		if name == "" {
+			continue
+		}
+
+		ref, err := index.FindChild(ctx, folder, name)
+		if err != nil {
+			return nil, err
+		}
+		if ref == nil {
+			created, err := folder.CreateFolder(ctx, name)
+			if err == nil {
+				folder = created
+				continue
+			}
+			// Another VM of the user may have created it meanwhile
+			if ref, _ = index.FindChild(ctx, folder, name); ref == nil {
+				return nil, fmt.Errorf("failed to create folder %s: %w", name, err)
+			}
+		}
+
+		child, ok := ref.(*object.Folder)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a folder", name)
+		}
+		folder = child
+	}
+	return folder, nil
+}
+
+// CreateVM clones the OS template into the user's folder, configures the guest with the
+// IPAM-allocated IP and powers it on (create-vm)
+func (p *VSphereProvisioner) CreateVM(ctx context.Context, username string, input *model.CreateVMInput) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.CreateVM)
+	defer cancel()
+
+	osTemplate, ok := p.catalog.OS[input.OS]
+	if !ok || osTemplate.Template == "" {
+		return nil, fmt.Errorf("failed to create VM: unknown OS: %s", input.OS)
+	}
+
+	exists, err := vmRecordExists(p.dataDir, username, input.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VM: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("failed to create VM: VM already exists: %s", input.Name)
+	}
+
+	ownerKey, err := p.GetUserKey(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VM: %w", err)
+	}
+
+	lease, _, err := p.ipam.AllocateIP(username, input.Name, "vm", input.MaxIPs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VM: IP allocation failed: %w", err)
+	}
+
+	vm := &model.VM{
+		Name:          input.Name,
+		VsphereVMName: username + "-" + input.Name,
+		Owner:         username,
+		OS:            input.OS,
+		LoginUser:     osTemplate.DefaultUser,
+		Spec:          input.Spec,
+		IPAddress:     lease.IP.String(),
+		Status:        model.VMStatusCreating,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+		Hostname:      input.Hostname,
+		Description:   input.Description,
+		JobID:         input.JobID,
+	}
+	if len(input.Labels) > 0 {
+		vm.Labels = input.Labels
+	}
+
+	// Before anything exists in vSphere a failure leaves no trace
+	undo := func() {
+		p.ipam.ReleaseIP(lease.IP, username)
+		os.RemoveAll(vmRecordDir(p.dataDir, username, vm.Name))
+	}
+
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		undo()
+		return nil, fmt.Errorf("failed to create VM: %w", err)
+	}
+
+	vc, err := p.vcenter(ctx)
+	if err != nil {
+		undo()
+		return nil, fmt.Errorf("failed to create VM: %w", contextError(ctx, err))
+	}
+
+	plan, err := p.planClone(ctx, vc, vm, osTemplate)
+	if err != nil {
+		undo()
+		return nil, fmt.Errorf("failed to create VM: %w", contextError(ctx, err))
+	}
+
+	userData := p.cloudInitUserData(vm, append([]string{ownerKey}, input.SSHAuthorizedKeys...), input.Packages, input.UserData)
+	if err := p.cloneVM(ctx, vc, plan, vm, osTemplate, userData); err != nil {
+		// The clone may exist partially; delete-vm cleans up failed VMs
+		vm.Status = model.VMStatusFailed
+		writeVMRecord(p.dataDir, vm)
+		return nil, fmt.Errorf("failed to create VM: %w", contextError(ctx, err))
+	}
+
+	vm.Status = model.VMStatusRunning
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		return nil, fmt.Errorf("failed to create VM: %w", err)
+	}
+
+	return vm, nil
+}
+
+// clonePlan is where a new VM is cloned from and to
+type clonePlan struct {
+	template  *object.VirtualMachine
+	folder    *object.Folder
+	pool      types.ManagedObjectReference
+	datastore types.ManagedObjectReference
+	network   object.NetworkReference
+}
+
+// planClone resolves the template and the placement of a new VM
+func (p *VSphereProvisioner) planClone(ctx context.Context, vc *vcenter, vm *model.VM, osTemplate catalog.OSTemplate) (*clonePlan, error) {
+	template, err := vc.finder.VirtualMachine(ctx, osTemplate.Template)
+	if err != nil {
+		return nil, fmt.Errorf("template not found: %w", err)
+	}
+
+	var pool *object.ResourcePool
+	if p.settings.ResourcePool != "" {
+		pool, err = vc.finder.ResourcePool(ctx, p.settings.ResourcePool)
+	} else {
+		var cluster *object.ClusterComputeResource
+		if cluster, err = vc.finder.ClusterComputeResource(ctx, p.settings.Cluster); err == nil {
+			pool, err = cluster.ResourcePool(ctx)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resource pool not found: %w", err)
+	}
+
+	datastore, err := vc.finder.Datastore(ctx, p.settings.Datastore)
+	if err != nil {
+		return nil, fmt.Errorf("datastore not found: %w", err)
+	}
+
+	network, err := vc.finder.Network(ctx, p.settings.Network)
+	if err != nil {
+		return nil, fmt.Errorf("network not found: %w", err)
+	}
+
+	folder, err := p.userFolder(ctx, vc, vm.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare user folder: %w", err)
+	}
+
+	return &clonePlan{
+		template:  template,
+		folder:    folder,
+		pool:      pool.Reference(),
+		datastore: datastore.Reference(),
+		network:   network,
+	}, nil
+}
+
+// cloneVM clones, sizes, customizes and powers on a new VM and waits for its IP
+func (p *VSphereProvisioner) cloneVM(ctx context.Context, vc *vcenter, plan *clonePlan, vm *model.VM, osTemplate catalog.OSTemplate, userData string) error {
+	res := p.catalog.VMResources(vm.Spec)
+
+	spec := types.VirtualMachineCloneSpec{
+		Location: types.VirtualMachineRelocateSpec{
+			Pool:      &plan.pool,
+			Datastore: &plan.datastore,
+		},
+	}
+
+	progress(ctx, "Cloning %s from template %s", vm.VsphereVMName, osTemplate.Template)
+	task, err := plan.template.Clone(ctx, plan.folder, vm.VsphereVMName, spec)
+	if err != nil {
+		return err
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("clone failed: %w", err)
+	}
+	obj := object.NewVirtualMachine(vc.client, info.Result.(types.ManagedObjectReference))
+
+	progress(ctx, "Configuring %d vCPU, %dMB memory, %dGB disk on %s", res.CPU, res.MemoryMB, res.DiskGB, p.settings.Network)
+	metadata := p.cloudInitMetadata(vm, osTemplate.Interface)
+	configSpec := types.VirtualMachineConfigSpec{
+		NumCPUs:  int32(res.CPU),
+		MemoryMB: int64(res.MemoryMB),
+		// cloud-init via VMware guestinfo (as in the Terraform template)
+		ExtraConfig: []types.BaseOptionValue{
+			&types.OptionValue{Key: "guestinfo.metadata", Value: base64.StdEncoding.EncodeToString([]byte(metadata))},
+			&types.OptionValue{Key: "guestinfo.metadata.encoding", Value: "base64"},
+			&types.OptionValue{Key: "guestinfo.userdata", Value: base64.StdEncoding.EncodeToString([]byte(userData))},
+			&types.OptionValue{Key: "guestinfo.userdata.encoding", Value: "base64"},
+		},
+	}
+	if err := configureClone(ctx, obj, configSpec, plan.network, res.DiskGB); err != nil {
+		return err
+	}
+
+	if p.customization == CustomizationLinuxPrep {
+		progress(ctx, "Customizing guest: hostname %s, IP %s", guestHostname(vm), vm.IPAddress)
+		task, err := obj.Customize(ctx, p.customizationSpec(vm))
+		if err != nil {
+			return err
+		}
+		if err := task.Wait(ctx); err != nil {
+			return fmt.Errorf("guest customization failed: %w", err)
+		}
+	}
+
+	progress(ctx, "Powering on %s", vm.VsphereVMName)
+	if err := waitTask(ctx, obj.PowerOn); err != nil {
+		return fmt.Errorf("power on failed: %w", err)
+	}
+
+	progress(ctx, "Waiting for %s to report IP %s", vm.VsphereVMName, vm.IPAddress)
+	if err := waitForGuestIP(ctx, vc.client, obj, vm.IPAddress); err != nil {
+		return err
+	}
+
+	progress(ctx, "VM %s is running at %s", vm.Name, vm.IPAddress)
+	return nil
+}
+
+// configureClone applies spec (sizing and guestinfo), grows the root disk to diskGB and
+// connects the first NIC to network in a single reconfigure before first boot
+func configureClone(ctx context.Context, obj *object.VirtualMachine, spec types.VirtualMachineConfigSpec, network object.NetworkReference, diskGB int) error {
+	devices, err := obj.Device(ctx)
+	if err != nil {
+		return err
+	}
+
+	var changes []types.BaseVirtualDeviceConfigSpec
+
+	if disk := rootDisk(devices); disk != nil && disk.CapacityInKB < gbToKB(diskGB) {
+		setDiskSize(disk, diskGB)
+		changes = append(changes, editDevice(disk))
+	}
+
+	nics := devices.SelectByType((*types.VirtualEthernetCard)(nil))
+	if len(nics) == 0 {
+		return errors.New("template has no network adapter")
+	}
+	backing, err := network.EthernetCardBackingInfo(ctx)
+	if err != nil {
+		return err
+	}
+	nics[0].(types.BaseVirtualEthernetCard).GetVirtualEthernetCard().Backing = backing
+	changes = append(changes, editDevice(nics[0]))
+
+	spec.DeviceChange = changes
+	return reconfigure(ctx, obj, spec)
+}
+
+// customizationSpec sets the hostname and the IPAM-allocated IP through LinuxPrep
+func (p *VSphereProvisioner) customizationSpec(vm *model.VM) types.CustomizationSpec {
+	mask := net.IP(net.CIDRMask(p.ipam.Network().Bits(), 32)).String()
+
+	return types.CustomizationSpec{
+		Identity: &types.CustomizationLinuxPrep{
+			HostName: &types.CustomizationFixedName{Name: guestHostname(vm)},
+			Domain:   customizationDomain,
+		},
+		GlobalIPSettings: types.CustomizationGlobalIPSettings{
+			DnsServerList: p.dnsServers(),
+		},
+		NicSettingMap: []types.CustomizationAdapterMapping{{
+			Adapter: types.CustomizationIPSettings{
+				Ip:         &types.CustomizationFixedIp{IpAddress: vm.IPAddress},
+				SubnetMask: mask,
+				Gateway:    []string{p.ipam.Gateway().String()},
+			},
+		}},
+	}
+}
+
+// cloudInitMetadata renders guestinfo.metadata with the static network configuration
+// Interface names differ per OS (Ubuntu: ens192, Rocky: ens33).
+func (p *VSphereProvisioner) cloudInitMetadata(vm *model.VM, iface string) string {
+	if iface == "" {
+		iface = "ens192"
+	}
+	mtu := p.settings.MTU
+	if mtu == 0 {
+		mtu = 1500
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "instance-id: %s\n", vm.VsphereVMName)
+	fmt.Fprintf(&b, "local-hostname: %s\n", guestHostname(vm))
+	b.WriteString("network:\n  version: 2\n  ethernets:\n")
+	fmt.Fprintf(&b, "    %s:\n", iface)
+	b.WriteString("      dhcp4: false\n")
+	fmt.Fprintf(&b, "      mtu: %d\n", mtu)
+	fmt.Fprintf(&b, "      addresses:\n        - %s/%d\n", vm.IPAddress, p.ipam.Network().Bits())
+	fmt.Fprintf(&b, "      gateway4: %s\n", p.ipam.Gateway())
+	fmt.Fprintf(&b, "      nameservers:\n        addresses: %s\n", jsonList(p.dnsServers()))
+	return b.String()
+}
+
+// cloudInitUserData renders guestinfo.userdata; user data is merged as a second MIME part
+// so lists are appended and keys of the default cloud-config are kept
+func (p *VSphereProvisioner) cloudInitUserData(vm *model.VM, keys, packages []string, userData string) string {
+	var b strings.Builder
+	b.WriteString("#cloud-config\n")
+	fmt.Fprintf(&b, "hostname: %s\n", guestHostname(vm))
+	b.WriteString("manage_etc_hosts: true\n")
+	b.WriteString("users:\n")
+	fmt.Fprintf(&b, "  - name: %s\n", vm.Owner)
+	b.WriteString("    sudo: ALL=(ALL) NOPASSWD:ALL\n")
+	b.WriteString("    shell: /bin/bash\n")
+	fmt.Fprintf(&b, "    ssh_authorized_keys: %s\n", jsonList(keys))
+	fmt.Fprintf(&b, "package_update: %t\n", len(packages) > 0)
+	b.WriteString("package_upgrade: false\n")
+	fmt.Fprintf(&b, "packages: %s\n", jsonList(packages))
+	b.WriteString("growpart:\n  mode: auto\n  devices: ['/']\n  ignore_growroot_disabled: false\n")
+	b.WriteString("runcmd:\n")
+	fmt.Fprintf(&b, "  - echo \"Basphere VM %s (%s) initialized\" > /var/log/basphere-init.log\n", vm.VsphereVMName, vm.Name)
+	fmt.Fprintf(&b, "  - echo \"Owner: %s\" >> /var/log/basphere-init.log\n", vm.Owner)
+	fmt.Fprintf(&b, "  - echo \"IP: %s\" >> /var/log/basphere-init.log\n", vm.IPAddress)
+	b.WriteString("final_message: \"Basphere VM is ready after $UPTIME seconds\"\n")
+
+	if userData == "" {
+		return b.String()
+	}
+
+	return strings.Join([]string{
+		`Content-Type: multipart/mixed; boundary="BASPHERE-USERDATA"`,
+		"MIME-Version: 1.0",
+		"",
+		"--BASPHERE-USERDATA",
+		`Content-Type: text/cloud-config; charset="utf-8"`,
+		"",
+		b.String(),
+		"--BASPHERE-USERDATA",
+		`Content-Type: text/cloud-config; charset="utf-8"`,
+		"Merge-Type: list(append)+dict(no_replace,recurse_list)+str()",
+		"",
+		userData,
+		"--BASPHERE-USERDATA--",
+		"",
+	}, "\n")
+}
+
+// dnsServers returns network.dns (default 8.8.8.8)
+func (p *VSphereProvisioner) dnsServers() []string {
+	if len(p.settings.DNS) == 0 {
+		return []string{"8.8.8.8"}
+	}
+	return p.settings.DNS
+}
+
+// guestHostname returns the hostname set in the guest (default: the VM name)
+func guestHostname(vm *model.VM) string {
+	if vm.Hostname != "" {
+		return vm.Hostname
+	}
+	return vm.Name
+}
+
+// jsonList renders a YAML flow sequence like Terraform's jsonencode
+func jsonList(items []string) string {
+	if items == nil {
+		items = []string{}
+	}
+	data, _ := json.Marshal(items)
+	return string(data)
+}
+
+// waitForGuestIP waits until VMware Tools report ip as the guest's address
+func waitForGuestIP(ctx context.Context, c *vim25.Client, obj *object.VirtualMachine, ip string) error {
+	waitCtx, cancel := context.WithTimeout(ctx, guestIPTimeout)
+	defer cancel()
+
+	err := property.Wait(waitCtx, property.DefaultCollector(c), obj.Reference(), []string{"guest.ipAddress"}, func(changes []types.PropertyChange) bool {
+		for _, change := range changes {
+			if s, ok := change.Val.(string); ok && s == ip {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil && ctx.Err() == nil && waitCtx.Err() != nil {
+		return fmt.Errorf("VM did not report IP %s within %s", ip, guestIPTimeout)
+	}
+	return err
+}
+
+// DeleteVM powers off and destroys a VM, then releases its IP and record (delete-vm)
+// If vSphere refuses, the record is kept as failed so the VM is not orphaned.
+func (p *VSphereProvisioner) DeleteVM(ctx context.Context, username, vmName string) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.DeleteVM)
+	defer cancel()
+
+	vm, err := readVMRecord(p.dataDir, username, vmName)
+	if err != nil {
+		return fmt.Errorf("failed to delete VM: %w", err)
+	}
+
+	vm.Status = model.VMStatusDeleting
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		return fmt.Errorf("failed to delete VM: %w", err)
+	}
+
+	if err := p.destroyVM(ctx, vm); err != nil {
+		vm.Status = model.VMStatusFailed
+		writeVMRecord(p.dataDir, vm)
+		return fmt.Errorf("failed to delete VM: %w", contextError(ctx, err))
+	}
+
+	if ip, err := netip.ParseAddr(vm.IPAddress); err == nil {
+		if _, err := p.ipam.ReleaseIP(ip, username); err != nil && !errors.Is(err, ipam.ErrLeaseNotFound) {
+			progress(ctx, "Warning: failed to release IP %s: %v", ip, err)
+		}
+	}
+
+	if err := os.RemoveAll(vmRecordDir(p.dataDir, username, vmName)); err != nil {
+		return fmt.Errorf("failed to delete VM: %w", err)
+	}
+
+	progress(ctx, "VM %s deleted", vmName)
+	return nil
+}
+
+// destroyVM removes a record's VM from vSphere; a VM that is already gone is not an error
+func (p *VSphereProvisioner) destroyVM(ctx context.Context, vm *model.VM) error {
+	vc, err := p.vcenter(ctx)
+	if err != nil {
+		return err
+	}
+
+	obj, err := p.findVM(ctx, vc, vm)
+	if errors.Is(err, errNotInVSphere) {
+		progress(ctx, "%s is not in vSphere; removing its record", p.vmInventoryPath(vm))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	state, err := obj.PowerState(ctx)
+	if err != nil {
+		return err
+	}
+	if state != types.VirtualMachinePowerStatePoweredOff {
+		progress(ctx, "Powering off %s", vm.VsphereVMName)
+		if err := waitTask(ctx, obj.PowerOff); err != nil {
+			return fmt.Errorf("power off failed: %w", err)
+		}
+	}
+
+	progress(ctx, "Destroying %s", vm.VsphereVMName)
+	if err := waitTask(ctx, obj.Destroy); err != nil {
+		return fmt.Errorf("destroy failed: %w", err)
+	}
+	return nil
+}
+
+// ListVMs lists all VMs for a user
+func (p *VSphereProvisioner) ListVMs(ctx context.Context, username string) ([]model.VM, error) {
+	return listVMRecords(p.dataDir, username)
+}
+
+// GetVM gets a specific VM
+func (p *VSphereProvisioner) GetVM(ctx context.Context, username, vmName string) (*model.VM, error) {
+	return readVMRecord(p.dataDir, username, vmName)
+}
+
+// VMExists checks if a VM exists
+func (p *VSphereProvisioner) VMExists(ctx context.Context, username, vmName string) (bool, error) {
+	return vmRecordExists(p.dataDir, username, vmName)
+}
+
+// UpdateVMMetadata replaces the labels and description of a VM (label-resource)
+func (p *VSphereProvisioner) UpdateVMMetadata(ctx context.Context, username, vmName string, labels map[string]string, description string) (*model.VM, error) {
+	vm, err := readVMRecord(p.dataDir, username, vmName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update VM metadata: %w", err)
+	}
+
+	vm.Labels = nil
+	if len(labels) > 0 {
+		vm.Labels = labels
+	}
+	vm.Description = description
+
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		return nil, fmt.Errorf("failed to update VM metadata: %w", err)
+	}
+	return vm, nil
+}
+
+// PowerVM performs a power operation on a VM and returns it with the resulting status (power-vm)
+func (p *VSphereProvisioner) PowerVM(ctx context.Context, username, vmName string, action model.VMAction) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.PowerVM)
+	defer cancel()
+
+	vm, obj, err := p.operableVM(ctx, username, vmName, model.VMStatusRunning, model.VMStatusStopped, model.VMStatusSuspended)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s VM: %w", action, err)
+	}
+
+	switch action {
+	case model.VMActionStart:
+		err = waitTask(ctx, obj.PowerOn)
+	case model.VMActionStop:
+		err = waitTask(ctx, obj.PowerOff)
+	case model.VMActionReboot:
+		err = obj.RebootGuest(ctx)
+	case model.VMActionShutdown:
+		if err = obj.ShutdownGuest(ctx); err == nil {
+			// The guest only gets the request; report whatever state it reached
+			waitCtx, cancel := context.WithTimeout(ctx, guestShutdownTimeout)
+			obj.WaitForPowerState(waitCtx, types.VirtualMachinePowerStatePoweredOff)
+			cancel()
+		}
+	default:
+		err = fmt.Errorf("unknown action: %s", action)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s VM: %w", action, contextError(ctx, err))
+	}
+
+	if err := p.syncStatus(ctx, vm, obj); err != nil {
+		return nil, fmt.Errorf("failed to %s VM: %w", action, err)
+	}
+	return vm, nil
+}
+
+// ResizeVM changes the spec and grows the root disk of a VM (resize-vm)
+// A running VM without CPU/memory hot add is shut down for the change and powered on again.
+func (p *VSphereProvisioner) ResizeVM(ctx context.Context, username, vmName, spec string, diskGB int) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.ResizeVM)
+	defer cancel()
+
+	vm, obj, err := p.operableVM(ctx, username, vmName, model.VMStatusRunning, model.VMStatusStopped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resize VM: %w", err)
+	}
+
+	if spec == "" {
+		spec = vm.Spec
+	}
+	res := p.catalog.VMResources(spec)
+
+	// Current disk (the old spec's size unless a resize grew it)
+	currentDisk := p.catalog.VMRootSize(vm).DiskGB
+	if diskGB > 0 && diskGB < currentDisk {
+		return nil, fmt.Errorf("failed to resize VM: Disk cannot shrink: current %dGB, requested %dGB", currentDisk, diskGB)
+	}
+	newDisk := max(res.DiskGB, currentDisk, diskGB)
+
+	var mvm mo.VirtualMachine
+	if err := obj.Properties(ctx, obj.Reference(), []string{"config", "runtime.powerState"}, &mvm); err != nil {
+		return nil, fmt.Errorf("failed to resize VM: %w", contextError(ctx, err))
+	}
+
+	configSpec := types.VirtualMachineConfigSpec{
+		NumCPUs:  int32(res.CPU),
+		MemoryMB: int64(res.MemoryMB),
+	}
+	if disk := rootDisk(object.VirtualDeviceList(mvm.Config.Hardware.Device)); disk != nil && disk.CapacityInKB < gbToKB(newDisk) {
+		setDiskSize(disk, newDisk)
+		configSpec.DeviceChange = append(configSpec.DeviceChange, editDevice(disk))
+	}
+
+	hw := mvm.Config.Hardware
+	cpuChange := configSpec.NumCPUs != hw.NumCPU
+	memoryChange := configSpec.MemoryMB != int64(hw.MemoryMB)
+	needsPowerOff := mvm.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn &&
+		((cpuChange && !isTrue(mvm.Config.CpuHotAddEnabled)) || (memoryChange && !isTrue(mvm.Config.MemoryHotAddEnabled)))
+
+	if needsPowerOff {
+		progress(ctx, "Shutting down %s to change CPU/memory", vm.VsphereVMName)
+		if err := shutdown(ctx, obj); err != nil {
+			return nil, fmt.Errorf("failed to resize VM: %w", contextError(ctx, err))
+		}
+	}
+
+	progress(ctx, "Resizing %s to %d vCPU, %dMB memory, %dGB disk", vm.VsphereVMName, res.CPU, res.MemoryMB, newDisk)
+	resizeErr := reconfigure(ctx, obj, configSpec)
+
+	if needsPowerOff {
+		progress(ctx, "Powering on %s", vm.VsphereVMName)
+		if err := waitTask(ctx, obj.PowerOn); err != nil && resizeErr == nil {
+			resizeErr = fmt.Errorf("power on failed: %w", err)
+		}
+	}
+	if resizeErr != nil {
+		return nil, fmt.Errorf("failed to resize VM: %w", contextError(ctx, resizeErr))
+	}
+
+	vm.Spec = spec
+	vm.DiskGB = newDisk
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		return nil, fmt.Errorf("failed to resize VM: %w", err)
+	}
+	return vm, nil
+}
+
+// shutdown shuts the guest down, powering the VM off if it does not stop in time
+func shutdown(ctx context.Context, obj *object.VirtualMachine) error {
+	if err := obj.ShutdownGuest(ctx); err == nil {
+		waitCtx, cancel := context.WithTimeout(ctx, guestShutdownTimeout)
+		err = obj.WaitForPowerState(waitCtx, types.VirtualMachinePowerStatePoweredOff)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return waitTask(ctx, obj.PowerOff)
+}
+
+// CreateSnapshot takes a snapshot of a VM (snapshot-vm create)
+func (p *VSphereProvisioner) CreateSnapshot(ctx context.Context, username, vmName string, input *model.CreateSnapshotInput) (*model.Snapshot, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Snapshot)
+	defer cancel()
+
+	vm, snapshots, obj, err := p.snapshotVM(ctx, username, vmName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if findSnapshot(snapshots, input.Name) >= 0 {
+		return nil, fmt.Errorf("failed to create snapshot: Snapshot already exists: %s", input.Name)
+	}
+
+	task, err := obj.CreateSnapshot(ctx, input.Name, input.Description, input.Memory, false)
+	if err == nil {
+		err = task.Wait(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", contextError(ctx, err))
+	}
+
+	snapshot := model.Snapshot{
+		Name:        input.Name,
+		VMName:      vm.Name,
+		Description: input.Description,
+		Memory:      input.Memory,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		Spec:        vm.Spec,
+		DiskGB:      vm.DiskGB,
+		DataDisks:   vm.DataDisks,
+	}
+	if err := writeSnapshotRecords(p.dataDir, username, vmName, append(snapshots, snapshot)); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// ListSnapshots lists the snapshots of a VM
+func (p *VSphereProvisioner) ListSnapshots(ctx context.Context, username, vmName string) ([]model.Snapshot, error) {
+	return readSnapshotRecords(p.dataDir, username, vmName)
+}
+
+// RevertSnapshot reverts a VM to a snapshot and returns it with the resulting status (snapshot-vm revert)
+func (p *VSphereProvisioner) RevertSnapshot(ctx context.Context, username, vmName, snapshotName string) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Snapshot)
+	defer cancel()
+
+	vm, snapshots, obj, err := p.snapshotVM(ctx, username, vmName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revert snapshot: %w", err)
+	}
+	i := findSnapshot(snapshots, snapshotName)
+	if i < 0 {
+		return nil, fmt.Errorf("failed to revert snapshot: Snapshot not found: %s", snapshotName)
+	}
+	if changes := snapshots[i].ConfigChanges(vm); len(changes) > 0 {
+		return nil, fmt.Errorf("failed to revert snapshot: VM changed since the snapshot: %s", strings.Join(changes, ", "))
+	}
+
+	task, err := obj.RevertToSnapshot(ctx, snapshotName, false)
+	if err == nil {
+		err = task.Wait(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to revert snapshot: %w", contextError(ctx, err))
+	}
+
+	// Snapshots without memory come back powered off
+	if err := p.syncStatus(ctx, vm, obj); err != nil {
+		return nil, fmt.Errorf("failed to revert snapshot: %w", err)
+	}
+	return vm, nil
+}
+
+// DeleteSnapshot deletes a snapshot of a VM (snapshot-vm delete)
+func (p *VSphereProvisioner) DeleteSnapshot(ctx context.Context, username, vmName, snapshotName string) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Snapshot)
+	defer cancel()
+
+	_, snapshots, obj, err := p.snapshotVM(ctx, username, vmName)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	i := findSnapshot(snapshots, snapshotName)
+	if i < 0 {
+		return fmt.Errorf("failed to delete snapshot: Snapshot not found: %s", snapshotName)
+	}
+
+	task, err := obj.RemoveSnapshot(ctx, snapshotName, false, nil)
+	if err == nil {
+		err = task.Wait(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", contextError(ctx, err))
+	}
+
+	snapshots = append(snapshots[:i], snapshots[i+1:]...)
+	if err := writeSnapshotRecords(p.dataDir, username, vmName, snapshots); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// snapshotVM returns a running or stopped VM with its snapshot records
+func (p *VSphereProvisioner) snapshotVM(ctx context.Context, username, vmName string) (*model.VM, []model.Snapshot, *object.VirtualMachine, error) {
+	vm, obj, err := p.operableVM(ctx, username, vmName, model.VMStatusRunning, model.VMStatusStopped)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	snapshots, err := readSnapshotRecords(p.dataDir, username, vmName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return vm, snapshots, obj, nil
+}
+
+// findSnapshot returns the index of the named snapshot, or -1
+func findSnapshot(snapshots []model.Snapshot, name string) int {
+	for i, s := range snapshots {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AttachDisk adds a thin data disk to a VM at the lowest free SCSI unit (disk-vm attach)
+func (p *VSphereProvisioner) AttachDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Disk)
+	defer cancel()
+
+	vm, obj, err := p.operableVM(ctx, username, vmName, model.VMStatusRunning, model.VMStatusStopped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach disk: %w", err)
+	}
+	if vm.FindDataDisk(diskName) != nil {
+		return nil, fmt.Errorf("failed to attach disk: Disk already exists: %s", diskName)
+	}
+	if len(vm.DataDisks) >= model.MaxDataDisks {
+		return nil, fmt.Errorf("failed to attach disk: A VM can have at most %d data disks", model.MaxDataDisks)
+	}
+
+	devices, err := obj.Device(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach disk: %w", contextError(ctx, err))
+	}
+	controller, err := rootController(devices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach disk: %w", err)
+	}
+
+	// Lowest unit free in the record and on the controller (0 is the root disk, 7 the controller)
+	unit := 1
+	for unit == 7 || vmHasDiskUnit(vm, unit) || deviceAtUnit(devices, controller, unit) != nil {
+		unit++
+	}
+
+	vc, err := p.vcenter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach disk: %w", contextError(ctx, err))
+	}
+	datastore, err := vc.finder.Datastore(ctx, p.settings.Datastore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach disk: datastore not found: %w", err)
+	}
+
+	disk := devices.CreateDisk(controller, datastore.Reference(), "")
+	unitNumber := int32(unit)
+	disk.UnitNumber = &unitNumber
+	setDiskSize(disk, sizeGB)
+
+	progress(ctx, "Attaching %dGB disk %s to %s at SCSI unit %d", sizeGB, diskName, vm.VsphereVMName, unit)
+	change := &types.VirtualDeviceConfigSpec{
+		Operation:     types.VirtualDeviceConfigSpecOperationAdd,
+		FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
+		Device:        disk,
+	}
+	if err := reconfigure(ctx, obj, types.VirtualMachineConfigSpec{DeviceChange: []types.BaseVirtualDeviceConfigSpec{change}}); err != nil {
+		return nil, fmt.Errorf("failed to attach disk: %w", contextError(ctx, err))
+	}
+
+	vm.DataDisks = append(vm.DataDisks, model.Disk{
+		Name:       diskName,
+		SizeGB:     sizeGB,
+		UnitNumber: unit,
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	})
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		return nil, fmt.Errorf("failed to attach disk: %w", err)
+	}
+	return vm, nil
+}
+
+// ResizeDisk grows a data disk of a VM (disk-vm grow)
+func (p *VSphereProvisioner) ResizeDisk(ctx context.Context, username, vmName, diskName string, sizeGB int) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Disk)
+	defer cancel()
+
+	vm, obj, err := p.operableVM(ctx, username, vmName, model.VMStatusRunning, model.VMStatusStopped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grow disk: %w", err)
+	}
+	d := vm.FindDataDisk(diskName)
+	if d == nil {
+		return nil, fmt.Errorf("failed to grow disk: Disk not found: %s", diskName)
+	}
+	if sizeGB < d.SizeGB {
+		return nil, fmt.Errorf("failed to grow disk: Disk cannot shrink: current %dGB, requested %dGB", d.SizeGB, sizeGB)
+	}
+
+	disk, err := dataDisk(ctx, obj, d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grow disk: %w", contextError(ctx, err))
+	}
+	if disk.CapacityInKB < gbToKB(sizeGB) {
+		progress(ctx, "Growing disk %s of %s to %dGB", diskName, vm.VsphereVMName, sizeGB)
+		setDiskSize(disk, sizeGB)
+		if err := reconfigure(ctx, obj, types.VirtualMachineConfigSpec{DeviceChange: []types.BaseVirtualDeviceConfigSpec{editDevice(disk)}}); err != nil {
+			return nil, fmt.Errorf("failed to grow disk: %w", contextError(ctx, err))
+		}
+	}
+
+	d.SizeGB = sizeGB
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		return nil, fmt.Errorf("failed to grow disk: %w", err)
+	}
+	return vm, nil
+}
+
+// DetachDisk removes a data disk from a VM, deleting its contents (disk-vm detach)
+func (p *VSphereProvisioner) DetachDisk(ctx context.Context, username, vmName, diskName string) (*model.VM, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Disk)
+	defer cancel()
+
+	vm, obj, err := p.operableVM(ctx, username, vmName, model.VMStatusRunning, model.VMStatusStopped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detach disk: %w", err)
+	}
+	d := vm.FindDataDisk(diskName)
+	if d == nil {
+		return nil, fmt.Errorf("failed to detach disk: Disk not found: %s", diskName)
+	}
+
+	disk, err := dataDisk(ctx, obj, d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detach disk: %w", contextError(ctx, err))
+	}
+
+	progress(ctx, "Detaching disk %s from %s", diskName, vm.VsphereVMName)
+	change := &types.VirtualDeviceConfigSpec{
+		Operation:     types.VirtualDeviceConfigSpecOperationRemove,
+		FileOperation: types.VirtualDeviceConfigSpecFileOperationDestroy,
+		Device:        disk,
+	}
+	if err := reconfigure(ctx, obj, types.VirtualMachineConfigSpec{DeviceChange: []types.BaseVirtualDeviceConfigSpec{change}}); err != nil {
+		return nil, fmt.Errorf("failed to detach disk: %w", contextError(ctx, err))
+	}
+
+	disks := make([]model.Disk, 0, len(vm.DataDisks)-1)
+	for _, other := range vm.DataDisks {
+		if other.Name != diskName {
+			disks = append(disks, other)
+		}
+	}
+	vm.DataDisks = disks
+	if len(disks) == 0 {
+		vm.DataDisks = nil
+	}
+	if err := writeVMRecord(p.dataDir, vm); err != nil {
+		return nil, fmt.Errorf("failed to detach disk: %w", err)
+	}
+	return vm, nil
+}
+
+// GetQuota gets the VM and IP usage for a user
+func (p *VSphereProvisioner) GetQuota(ctx context.Context, username string) (*model.Quota, error) {
+	vms, err := p.ListVMs(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count IPs (same as VMs; the handler uses IPAM leases when available)
+	return &model.Quota{
+		UsedVMs: len(vms),
+		UsedIPs: len(vms),
+	}, nil
+}
+
+// operableVM reads a VM record in one of the allowed statuses and finds its vSphere VM
+func (p *VSphereProvisioner) operableVM(ctx context.Context, username, vmName string, allowed ...model.VMStatus) (*model.VM, *object.VirtualMachine, error) {
+	vm, err := readVMRecord(p.dataDir, username, vmName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ok := false
+	for _, status := range allowed {
+		if vm.Status == status {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("VM is %s: %s", vm.Status, vmName)
+	}
+
+	vc, err := p.vcenter(ctx)
+	if err != nil {
+		return nil, nil, contextError(ctx, err)
+	}
+	obj, err := p.findVM(ctx, vc, vm)
+	if err != nil {
+		return nil, nil, contextError(ctx, err)
+	}
+	return vm, obj, nil
+}
+
+// syncStatus sets a VM's status from its power state and saves the record
+func (p *VSphereProvisioner) syncStatus(ctx context.Context, vm *model.VM, obj *object.VirtualMachine) error {
+	state, err := obj.PowerState(ctx)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	if status := powerStateToStatus(state); status != "" {
+		vm.Status = status
+	}
+	return writeVMRecord(p.dataDir, vm)
+}
+
+// powerStateToStatus maps a vSphere power state to a VM status (power_state_to_status in power-vm)
+func powerStateToStatus(state types.VirtualMachinePowerState) model.VMStatus {
+	switch state {
+	case types.VirtualMachinePowerStatePoweredOn:
+		return model.VMStatusRunning
+	case types.VirtualMachinePowerStatePoweredOff:
+		return model.VMStatusStopped
+	case types.VirtualMachinePowerStateSuspended:
+		return model.VMStatusSuspended
+	}
+	return ""
+}
+
+// rootDisk returns the VM's first disk (Hard disk 1), or nil
+func rootDisk(devices object.VirtualDeviceList) *types.VirtualDisk {
+	var root *types.VirtualDisk
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk)
+		if root == nil || disk.Key < root.Key {
+			root = disk
+		}
+	}
+	return root
+}
+
+// rootController returns the controller of the root disk, which data disks share
+func rootController(devices object.VirtualDeviceList) (types.BaseVirtualController, error) {
+	root := rootDisk(devices)
+	if root == nil {
+		return nil, errors.New("VM has no root disk")
+	}
+	controller, ok := devices.FindByKey(root.ControllerKey).(types.BaseVirtualController)
+	if !ok {
+		return nil, errors.New("root disk controller not found")
+	}
+	return controller, nil
+}
+
+// deviceAtUnit returns the device at a unit of controller, or nil
+func deviceAtUnit(devices object.VirtualDeviceList, controller types.BaseVirtualController, unit int) types.BaseVirtualDevice {
+	key := controller.GetVirtualController().Key
+	for _, device := range devices {
+		d := device.GetVirtualDevice()
+		if d.ControllerKey == key && d.UnitNumber != nil && int(*d.UnitNumber) == unit {
+			return device
+		}
+	}
+	return nil
+}
+
+// vmHasDiskUnit reports whether a data disk of the record uses unit
+func vmHasDiskUnit(vm *model.VM, unit int) bool {
+	for _, d := range vm.DataDisks {
+		if d.UnitNumber == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// dataDisk returns the vSphere disk of a data disk record (matched by unit on the root controller)
+func dataDisk(ctx context.Context, obj *object.VirtualMachine, d *model.Disk) (*types.VirtualDisk, error) {
+	devices, err := obj.Device(ctx)
+	if err != nil {
+		return nil, err
+	}
+	controller, err := rootController(devices)
+	if err != nil {
+		return nil, err
+	}
+	disk, ok := deviceAtUnit(devices, controller, d.UnitNumber).(*types.VirtualDisk)
+	if !ok {
+		return nil, fmt.Errorf("disk %s not found at SCSI unit %d", d.Name, d.UnitNumber)
+	}
+	return disk, nil
+}
+
+// editDevice changes an existing device in place (no file operation, so disks keep their contents)
+func editDevice(device types.BaseVirtualDevice) types.BaseVirtualDeviceConfigSpec {
+	return &types.VirtualDeviceConfigSpec{
+		Operation: types.VirtualDeviceConfigSpecOperationEdit,
+		Device:    device,
+	}
+}
+
+// setDiskSize sets a disk's capacity to sizeGB
+func setDiskSize(disk *types.VirtualDisk, sizeGB int) {
+	disk.CapacityInKB = gbToKB(sizeGB)
+	disk.CapacityInBytes = disk.CapacityInKB * 1024
+}
+
+func gbToKB(gb int) int64 {
+	return int64(gb) * 1024 * 1024
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// reconfigure applies a config spec and waits for it
+func reconfigure(ctx context.Context, obj *object.VirtualMachine, spec types.VirtualMachineConfigSpec) error {
+	task, err := obj.Reconfigure(ctx, spec)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// waitTask starts a task and waits for it
+func waitTask(ctx context.Context, start func(context.Context) (*object.Task, error)) error {
+	task, err := start(ctx)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// contextError reports cancellation and timeouts distinctly from vSphere failures, like run
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return fmt.Errorf("timed out: %w", ctxErr)
+		}
+		return fmt.Errorf("cancelled: %w", ctxErr)
+	}
+	return err
+}
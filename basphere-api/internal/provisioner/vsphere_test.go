@@ -0,0 +1,668 @@
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/basphere/basphere-api/internal/catalog"
+	"github.com/basphere/basphere-api/internal/config"
+	"github.com/basphere/basphere-api/internal/ipam"
+	"github.com/basphere/basphere-api/internal/model"
+	"github.com/basphere/basphere-api/internal/vsphere"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+// vcsimTemplate is the simulator VM turned into the OS template
+const vcsimTemplate = "DC0_C0_RP0_VM0"
+
+// testCatalog sizes small and large VMs and maps ubuntu-24.04 to the simulator template
+func testCatalog() *catalog.Catalog {
+	return &catalog.Catalog{
+		VMSpecs: map[string]catalog.Spec{
+			"small": {CPU: 2, MemoryMB: 4096, DiskGB: 50},
+			"large": {CPU: 8, MemoryMB: 16384, DiskGB: 100},
+		},
+		OS: map[string]catalog.OSTemplate{
+			"ubuntu-24.04": {Template: vcsimTemplate, DefaultUser: "ubuntu", Interface: "ens192"},
+		},
+	}
+}
+
+// testVSphereSettings points at the default vcsim inventory
+func testVSphereSettings() vsphere.Settings {
+	return vsphere.Settings{
+		Datacenter: "DC0",
+		Cluster:    "DC0_C0",
+		Datastore:  "LocalDS_0",
+		Network:    "VM Network",
+		Folder:     "basphere-vms",
+		MTU:        1450,
+		DNS:        []string{"10.0.0.53"},
+	}
+}
+
+// setupVSphere runs fn against an in-process vCenter simulator with a provisioner for
+// user alice (IP block allocated, SSH key registered) using LinuxPrep customization
+func setupVSphere(t *testing.T, fn func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client)) {
+	t.Helper()
+
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		template := vcsimVM(ctx, t, c, "/DC0/vm/"+vcsimTemplate)
+		if err := waitTask(ctx, template.PowerOff); err != nil {
+			t.Fatalf("Failed to power off template: %v", err)
+		}
+		if err := template.MarkAsTemplate(ctx); err != nil {
+			t.Fatalf("Failed to mark template: %v", err)
+		}
+
+		m, err := ipam.New(t.TempDir(), ipam.DefaultConfig())
+		if err != nil {
+			t.Fatalf("Failed to create IPAM: %v", err)
+		}
+		if _, _, err := m.AllocateBlock("alice"); err != nil {
+			t.Fatalf("Failed to allocate block: %v", err)
+		}
+
+		scripts := NewMockProvisioner()
+		scripts.Users["alice"] = true
+		scripts.Keys["alice"] = "ssh-ed25519 AAAAalice alice@laptop"
+
+		login := func(context.Context) (*vim25.Client, error) { return c, nil }
+		p, err := newVSphereProvisioner(scripts, testVSphereSettings(), CustomizationLinuxPrep, config.TimeoutsConfig{}, testCatalog(), m, login)
+		if err != nil {
+			t.Fatalf("Failed to create provisioner: %v", err)
+		}
+		p.dataDir = t.TempDir()
+
+		fn(ctx, p, c)
+	})
+}
+
+// createTestVM creates alice's VM "web" from the small spec
+func createTestVM(ctx context.Context, t *testing.T, p *VSphereProvisioner) *model.VM {
+	t.Helper()
+
+	vm, err := p.CreateVM(ctx, "alice", &model.CreateVMInput{Name: "web", OS: "ubuntu-24.04", Spec: "small"})
+	if err != nil {
+		t.Fatalf("CreateVM failed: %v", err)
+	}
+	return vm
+}
+
+// vcsimVM finds a simulator VM by inventory path
+func vcsimVM(ctx context.Context, t *testing.T, c *vim25.Client, inventoryPath string) *object.VirtualMachine {
+	t.Helper()
+
+	ref, err := object.NewSearchIndex(c).FindByInventoryPath(ctx, inventoryPath)
+	if err != nil {
+		t.Fatalf("Failed to search %s: %v", inventoryPath, err)
+	}
+	vm, ok := ref.(*object.VirtualMachine)
+	if !ok {
+		t.Fatalf("Expected VM at %s", inventoryPath)
+	}
+	return vm
+}
+
+// vcsimProperties reads properties of a simulator VM
+func vcsimProperties(ctx context.Context, t *testing.T, vm *object.VirtualMachine, props ...string) mo.VirtualMachine {
+	t.Helper()
+
+	var mvm mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), props, &mvm); err != nil {
+		t.Fatalf("Failed to read VM properties: %v", err)
+	}
+	return mvm
+}
+
+// vcsimDisks returns the capacity in GB of a simulator VM's disks by unit number
+func vcsimDisks(ctx context.Context, t *testing.T, vm *object.VirtualMachine) map[int32]int64 {
+	t.Helper()
+
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read devices: %v", err)
+	}
+	disks := make(map[int32]int64)
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk)
+		disks[*disk.UnitNumber] = disk.CapacityInKB / (1024 * 1024)
+	}
+	return disks
+}
+
+const testVMPath = "/DC0/vm/basphere-vms/alice/alice-web"
+
+// =============================================================================
+// VM Creation Tests
+// =============================================================================
+
+func TestVSphereCreateVM(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		var out bytes.Buffer
+		vm, err := p.CreateVM(WithOutput(ctx, &out), "alice", &model.CreateVMInput{
+			Name:     "web",
+			OS:       "ubuntu-24.04",
+			Spec:     "small",
+			Hostname: "web-01",
+			Labels:   map[string]string{"env": "dev"},
+		})
+		if err != nil {
+			t.Fatalf("CreateVM failed: %v", err)
+		}
+
+		if vm.Status != model.VMStatusRunning {
+			t.Errorf("Expected status running, got %s", vm.Status)
+		}
+		if vm.VsphereVMName != "alice-web" || vm.LoginUser != "ubuntu" || vm.Labels["env"] != "dev" {
+			t.Errorf("Unexpected VM: %+v", vm)
+		}
+
+		// The IP comes from alice's block
+		leases, _ := p.ipam.Leases("alice")
+		if len(leases) != 1 || leases[0].IP.String() != vm.IPAddress || leases[0].ResourceName != "web" {
+			t.Errorf("Expected an IPAM lease for %s, got %+v", vm.IPAddress, leases)
+		}
+
+		// Same record the scripts keep
+		saved, err := p.GetVM(ctx, "alice", "web")
+		if err != nil {
+			t.Fatalf("GetVM failed: %v", err)
+		}
+		if saved.Status != model.VMStatusRunning || saved.IPAddress != vm.IPAddress {
+			t.Errorf("Unexpected record: %+v", saved)
+		}
+
+		// Cloned into the user's folder, customized and powered on
+		obj := vcsimVM(ctx, t, c, testVMPath)
+		mvm := vcsimProperties(ctx, t, obj, "config", "guest", "runtime.powerState")
+		if mvm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+			t.Errorf("Expected powered on, got %s", mvm.Runtime.PowerState)
+		}
+		if mvm.Guest.IpAddress != vm.IPAddress {
+			t.Errorf("Expected guest IP %s, got %s", vm.IPAddress, mvm.Guest.IpAddress)
+		}
+		if mvm.Guest.HostName != "web-01" {
+			t.Errorf("Expected guest hostname web-01, got %s", mvm.Guest.HostName)
+		}
+		if mvm.Config.Hardware.NumCPU != 2 || mvm.Config.Hardware.MemoryMB != 4096 {
+			t.Errorf("Expected 2 vCPU and 4096MB, got %d and %d", mvm.Config.Hardware.NumCPU, mvm.Config.Hardware.MemoryMB)
+		}
+		if disks := vcsimDisks(ctx, t, obj); disks[0] != 50 {
+			t.Errorf("Expected 50GB root disk, got %v", disks)
+		}
+
+		extra := make(map[string]string)
+		for _, o := range mvm.Config.ExtraConfig {
+			opt := o.GetOptionValue()
+			if s, ok := opt.Value.(string); ok {
+				extra[opt.Key] = s
+			}
+		}
+		userData, _ := base64.StdEncoding.DecodeString(extra["guestinfo.userdata"])
+		if !strings.Contains(string(userData), "ssh-ed25519 AAAAalice") {
+			t.Errorf("Expected the owner key in guestinfo.userdata, got %q", userData)
+		}
+		metadata, _ := base64.StdEncoding.DecodeString(extra["guestinfo.metadata"])
+		if !strings.Contains(string(metadata), vm.IPAddress+"/21") {
+			t.Errorf("Expected the IP in guestinfo.metadata, got %q", metadata)
+		}
+
+		for _, want := range []string{"Cloning alice-web from template " + vcsimTemplate, "Powering on alice-web"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected output to contain %q, got %q", want, out.String())
+			}
+		}
+	})
+}
+
+func TestVSphereCreateVM_FolderPerUser(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		if _, _, err := p.ipam.AllocateBlock("bob"); err != nil {
+			t.Fatalf("Failed to allocate block: %v", err)
+		}
+		scripts := p.Provisioner.(*MockProvisioner)
+		scripts.Users["bob"] = true
+		scripts.Keys["bob"] = "ssh-ed25519 AAAAbob bob@laptop"
+
+		input := &model.CreateVMInput{Name: "web", OS: "ubuntu-24.04", Spec: "small"}
+		alice1, err := p.CreateVM(ctx, "alice", input)
+		if err != nil {
+			t.Fatalf("CreateVM failed: %v", err)
+		}
+		if _, err := p.CreateVM(ctx, "alice", &model.CreateVMInput{Name: "db", OS: "ubuntu-24.04", Spec: "small"}); err != nil {
+			t.Fatalf("CreateVM failed: %v", err)
+		}
+		bob, err := p.CreateVM(ctx, "bob", input)
+		if err != nil {
+			t.Fatalf("CreateVM failed: %v", err)
+		}
+
+		vcsimVM(ctx, t, c, "/DC0/vm/basphere-vms/alice/alice-web")
+		vcsimVM(ctx, t, c, "/DC0/vm/basphere-vms/alice/alice-db")
+		vcsimVM(ctx, t, c, "/DC0/vm/basphere-vms/bob/bob-web")
+
+		if alice1.IPAddress == bob.IPAddress {
+			t.Errorf("Expected distinct IPs, got %s twice", bob.IPAddress)
+		}
+	})
+}
+
+func TestVSphereCreateVM_Errors(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		createTestVM(ctx, t, p)
+
+		tests := []struct {
+			name    string
+			input   *model.CreateVMInput
+			wantErr string
+		}{
+			{"unknown OS", &model.CreateVMInput{Name: "a", OS: "windows", Spec: "small"}, "unknown OS"},
+			{"duplicate", &model.CreateVMInput{Name: "web", OS: "ubuntu-24.04", Spec: "small"}, "VM already exists"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := p.CreateVM(ctx, "alice", tt.input)
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+			})
+		}
+
+		// No block, no IP
+		if _, err := p.CreateVM(ctx, "carol", &model.CreateVMInput{Name: "a", OS: "ubuntu-24.04", Spec: "small"}); err == nil {
+			t.Error("Expected error for a user without SSH key and IP block")
+		}
+	})
+}
+
+func TestVSphereCreateVM_UndoesBeforeClone(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		p.catalog.OS["missing"] = catalog.OSTemplate{Template: "no-such-template"}
+
+		_, err := p.CreateVM(ctx, "alice", &model.CreateVMInput{Name: "web", OS: "missing", Spec: "small"})
+		if err == nil || !strings.Contains(err.Error(), "template not found") {
+			t.Fatalf("Expected template not found, got %v", err)
+		}
+
+		if exists, _ := p.VMExists(ctx, "alice", "web"); exists {
+			t.Error("Expected the record to be removed")
+		}
+		if leases, _ := p.ipam.Leases("alice"); len(leases) != 0 {
+			t.Errorf("Expected the IP to be released, got %+v", leases)
+		}
+	})
+}
+
+func TestVSphereCreateVM_MarksFailedAfterClone(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		// A VM already holding the name makes the clone itself fail
+		createTestVM(ctx, t, p)
+		os.RemoveAll(vmRecordDir(p.dataDir, "alice", "web"))
+
+		_, err := p.CreateVM(ctx, "alice", &model.CreateVMInput{Name: "web", OS: "ubuntu-24.04", Spec: "small"})
+		if err == nil || !strings.Contains(err.Error(), "clone failed") {
+			t.Fatalf("Expected clone failure, got %v", err)
+		}
+
+		vm, err := p.GetVM(ctx, "alice", "web")
+		if err != nil {
+			t.Fatalf("Expected the record to be kept: %v", err)
+		}
+		if vm.Status != model.VMStatusFailed {
+			t.Errorf("Expected status failed, got %s", vm.Status)
+		}
+	})
+}
+
+// =============================================================================
+// VM Deletion Tests
+// =============================================================================
+
+func TestVSphereDeleteVM(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		vm := createTestVM(ctx, t, p)
+
+		if err := p.DeleteVM(ctx, "alice", "web"); err != nil {
+			t.Fatalf("DeleteVM failed: %v", err)
+		}
+
+		ref, err := object.NewSearchIndex(c).FindByInventoryPath(ctx, testVMPath)
+		if err != nil || ref != nil {
+			t.Errorf("Expected the VM to be destroyed, got %v (%v)", ref, err)
+		}
+		if exists, _ := p.VMExists(ctx, "alice", "web"); exists {
+			t.Error("Expected the record to be removed")
+		}
+		leases, _ := p.ipam.Leases("alice")
+		for _, l := range leases {
+			if l.IP.String() == vm.IPAddress {
+				t.Errorf("Expected %s to be released", vm.IPAddress)
+			}
+		}
+
+		if err := p.DeleteVM(ctx, "alice", "web"); err == nil || !strings.Contains(err.Error(), "VM not found") {
+			t.Errorf("Expected VM not found, got %v", err)
+		}
+	})
+}
+
+func TestVSphereDeleteVM_AlreadyGone(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		vm := createTestVM(ctx, t, p)
+
+		// Removed behind basphere's back
+		obj := vcsimVM(ctx, t, c, testVMPath)
+		if err := waitTask(ctx, obj.PowerOff); err != nil {
+			t.Fatalf("Failed to power off: %v", err)
+		}
+		if err := waitTask(ctx, obj.Destroy); err != nil {
+			t.Fatalf("Failed to destroy: %v", err)
+		}
+
+		if err := p.DeleteVM(ctx, "alice", "web"); err != nil {
+			t.Fatalf("DeleteVM failed: %v", err)
+		}
+		if exists, _ := p.VMExists(ctx, "alice", "web"); exists {
+			t.Error("Expected the record to be removed")
+		}
+		if _, err := p.ipam.ReleaseIP(netip.MustParseAddr(vm.IPAddress), "alice"); err == nil {
+			t.Error("Expected the IP to be released already")
+		}
+	})
+}
+
+// =============================================================================
+// Power Tests
+// =============================================================================
+
+func TestVSpherePowerVM(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		createTestVM(ctx, t, p)
+		obj := vcsimVM(ctx, t, c, testVMPath)
+
+		steps := []struct {
+			action     model.VMAction
+			wantStatus model.VMStatus
+			wantState  types.VirtualMachinePowerState
+		}{
+			{model.VMActionStop, model.VMStatusStopped, types.VirtualMachinePowerStatePoweredOff},
+			{model.VMActionStart, model.VMStatusRunning, types.VirtualMachinePowerStatePoweredOn},
+			{model.VMActionShutdown, model.VMStatusStopped, types.VirtualMachinePowerStatePoweredOff},
+		}
+
+		for _, step := range steps {
+			vm, err := p.PowerVM(ctx, "alice", "web", step.action)
+			if err != nil {
+				t.Fatalf("PowerVM %s failed: %v", step.action, err)
+			}
+			if vm.Status != step.wantStatus {
+				t.Errorf("Expected status %s after %s, got %s", step.wantStatus, step.action, vm.Status)
+			}
+			if state, _ := obj.PowerState(ctx); state != step.wantState {
+				t.Errorf("Expected %s after %s, got %s", step.wantState, step.action, state)
+			}
+			if saved, _ := p.GetVM(ctx, "alice", "web"); saved.Status != step.wantStatus {
+				t.Errorf("Expected saved status %s, got %s", step.wantStatus, saved.Status)
+			}
+		}
+	})
+}
+
+func TestVSpherePowerVM_RejectsBusyVM(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		vm := createTestVM(ctx, t, p)
+		vm.Status = model.VMStatusDeleting
+		writeVMRecord(p.dataDir, vm)
+
+		_, err := p.PowerVM(ctx, "alice", "web", model.VMActionStop)
+		if err == nil || !strings.Contains(err.Error(), "VM is deleting") {
+			t.Errorf("Expected VM is deleting, got %v", err)
+		}
+	})
+}
+
+// =============================================================================
+// Resize Tests
+// =============================================================================
+
+func TestVSphereResizeVM(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		createTestVM(ctx, t, p)
+		obj := vcsimVM(ctx, t, c, testVMPath)
+
+		vm, err := p.ResizeVM(ctx, "alice", "web", "large", 0)
+		if err != nil {
+			t.Fatalf("ResizeVM failed: %v", err)
+		}
+		if vm.Spec != "large" || vm.DiskGB != 100 {
+			t.Errorf("Expected large with 100GB, got %s with %dGB", vm.Spec, vm.DiskGB)
+		}
+
+		mvm := vcsimProperties(ctx, t, obj, "config", "runtime.powerState")
+		if mvm.Config.Hardware.NumCPU != 8 || mvm.Config.Hardware.MemoryMB != 16384 {
+			t.Errorf("Expected 8 vCPU and 16384MB, got %d and %d", mvm.Config.Hardware.NumCPU, mvm.Config.Hardware.MemoryMB)
+		}
+		if mvm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+			t.Errorf("Expected the VM to be powered on again, got %s", mvm.Runtime.PowerState)
+		}
+		if disks := vcsimDisks(ctx, t, obj); disks[0] != 100 {
+			t.Errorf("Expected 100GB root disk, got %v", disks)
+		}
+
+		// Back to small keeps the grown disk; an explicit size grows it further
+		vm, err = p.ResizeVM(ctx, "alice", "web", "small", 120)
+		if err != nil {
+			t.Fatalf("ResizeVM failed: %v", err)
+		}
+		if vm.Spec != "small" || vm.DiskGB != 120 {
+			t.Errorf("Expected small with 120GB, got %s with %dGB", vm.Spec, vm.DiskGB)
+		}
+
+		_, err = p.ResizeVM(ctx, "alice", "web", "", 60)
+		if err == nil || !strings.Contains(err.Error(), "Disk cannot shrink: current 120GB, requested 60GB") {
+			t.Errorf("Expected shrink error, got %v", err)
+		}
+	})
+}
+
+// =============================================================================
+// Snapshot Tests
+// =============================================================================
+
+func TestVSphereSnapshots(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		createTestVM(ctx, t, p)
+		obj := vcsimVM(ctx, t, c, testVMPath)
+
+		snapshot, err := p.CreateSnapshot(ctx, "alice", "web", &model.CreateSnapshotInput{Name: "before", Description: "clean"})
+		if err != nil {
+			t.Fatalf("CreateSnapshot failed: %v", err)
+		}
+		if snapshot.Name != "before" || snapshot.VMName != "web" || snapshot.Description != "clean" || snapshot.Spec != "small" {
+			t.Errorf("Unexpected snapshot: %+v", snapshot)
+		}
+		if _, err := obj.FindSnapshot(ctx, "before"); err != nil {
+			t.Errorf("Expected the snapshot in vSphere: %v", err)
+		}
+
+		_, err = p.CreateSnapshot(ctx, "alice", "web", &model.CreateSnapshotInput{Name: "before"})
+		if err == nil || !strings.Contains(err.Error(), "Snapshot already exists: before") {
+			t.Errorf("Expected duplicate error, got %v", err)
+		}
+
+		snapshots, _ := p.ListSnapshots(ctx, "alice", "web")
+		if len(snapshots) != 1 || snapshots[0].Name != "before" {
+			t.Errorf("Expected one snapshot record, got %+v", snapshots)
+		}
+
+		// A record changed after the snapshot would no longer match the VM after a revert
+		vm, _ := p.GetVM(ctx, "alice", "web")
+		resized := *vm
+		resized.Spec = "medium"
+		writeVMRecord(p.dataDir, &resized)
+		if _, err := p.RevertSnapshot(ctx, "alice", "web", "before"); err == nil || !strings.Contains(err.Error(), "spec changed from small to medium") {
+			t.Errorf("Expected changed VM error, got %v", err)
+		}
+		writeVMRecord(p.dataDir, vm)
+
+		if _, err := p.RevertSnapshot(ctx, "alice", "web", "before"); err != nil {
+			t.Fatalf("RevertSnapshot failed: %v", err)
+		}
+		if _, err := p.RevertSnapshot(ctx, "alice", "web", "other"); err == nil || !strings.Contains(err.Error(), "Snapshot not found: other") {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+
+		if err := p.DeleteSnapshot(ctx, "alice", "web", "before"); err != nil {
+			t.Fatalf("DeleteSnapshot failed: %v", err)
+		}
+		if _, err := obj.FindSnapshot(ctx, "before"); err == nil {
+			t.Error("Expected the snapshot to be removed from vSphere")
+		}
+		if snapshots, _ := p.ListSnapshots(ctx, "alice", "web"); len(snapshots) != 0 {
+			t.Errorf("Expected no snapshot records, got %+v", snapshots)
+		}
+	})
+}
+
+// =============================================================================
+// Data Disk Tests
+// =============================================================================
+
+func TestVSphereDisks(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		createTestVM(ctx, t, p)
+		obj := vcsimVM(ctx, t, c, testVMPath)
+
+		if _, err := p.AttachDisk(ctx, "alice", "web", "data", 20); err != nil {
+			t.Fatalf("AttachDisk failed: %v", err)
+		}
+		vm, err := p.AttachDisk(ctx, "alice", "web", "logs", 10)
+		if err != nil {
+			t.Fatalf("AttachDisk failed: %v", err)
+		}
+		if len(vm.DataDisks) != 2 || vm.DataDisks[0].UnitNumber != 1 || vm.DataDisks[1].UnitNumber != 2 {
+			t.Errorf("Expected units 1 and 2, got %+v", vm.DataDisks)
+		}
+		if disks := vcsimDisks(ctx, t, obj); disks[1] != 20 || disks[2] != 10 {
+			t.Errorf("Expected 20GB at unit 1 and 10GB at unit 2, got %v", disks)
+		}
+
+		if _, err := p.AttachDisk(ctx, "alice", "web", "data", 5); err == nil || !strings.Contains(err.Error(), "Disk already exists: data") {
+			t.Errorf("Expected duplicate error, got %v", err)
+		}
+
+		vm, err = p.ResizeDisk(ctx, "alice", "web", "data", 40)
+		if err != nil {
+			t.Fatalf("ResizeDisk failed: %v", err)
+		}
+		if d := vm.FindDataDisk("data"); d == nil || d.SizeGB != 40 {
+			t.Errorf("Expected data to be 40GB, got %+v", d)
+		}
+		if disks := vcsimDisks(ctx, t, obj); disks[1] != 40 {
+			t.Errorf("Expected 40GB at unit 1, got %v", disks)
+		}
+		if _, err := p.ResizeDisk(ctx, "alice", "web", "data", 30); err == nil || !strings.Contains(err.Error(), "Disk cannot shrink") {
+			t.Errorf("Expected shrink error, got %v", err)
+		}
+
+		vm, err = p.DetachDisk(ctx, "alice", "web", "data")
+		if err != nil {
+			t.Fatalf("DetachDisk failed: %v", err)
+		}
+		if len(vm.DataDisks) != 1 || vm.DataDisks[0].Name != "logs" {
+			t.Errorf("Expected only logs left, got %+v", vm.DataDisks)
+		}
+		disks := vcsimDisks(ctx, t, obj)
+		if _, ok := disks[1]; ok || disks[2] != 10 {
+			t.Errorf("Expected unit 1 removed and unit 2 kept, got %v", disks)
+		}
+
+		// The freed unit is reused
+		vm, err = p.AttachDisk(ctx, "alice", "web", "cache", 5)
+		if err != nil {
+			t.Fatalf("AttachDisk failed: %v", err)
+		}
+		if d := vm.FindDataDisk("cache"); d == nil || d.UnitNumber != 1 {
+			t.Errorf("Expected cache at unit 1, got %+v", d)
+		}
+
+		if _, err := p.DetachDisk(ctx, "alice", "web", "nope"); err == nil || !strings.Contains(err.Error(), "Disk not found: nope") {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+}
+
+// =============================================================================
+// Metadata and Settings Tests
+// =============================================================================
+
+func TestVSphereUpdateVMMetadata(t *testing.T) {
+	setupVSphere(t, func(ctx context.Context, p *VSphereProvisioner, c *vim25.Client) {
+		createTestVM(ctx, t, p)
+
+		vm, err := p.UpdateVMMetadata(ctx, "alice", "web", map[string]string{"team": "infra"}, "web server")
+		if err != nil {
+			t.Fatalf("UpdateVMMetadata failed: %v", err)
+		}
+		if vm.Labels["team"] != "infra" || vm.Description != "web server" {
+			t.Errorf("Unexpected metadata: %+v", vm)
+		}
+
+		vm, err = p.UpdateVMMetadata(ctx, "alice", "web", nil, "")
+		if err != nil {
+			t.Fatalf("UpdateVMMetadata failed: %v", err)
+		}
+		if vm.Labels != nil || vm.Description != "" {
+			t.Errorf("Expected metadata to be cleared, got %+v", vm)
+		}
+	})
+}
+
+func TestCloudInitUserData(t *testing.T) {
+	m, err := ipam.New(t.TempDir(), ipam.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create IPAM: %v", err)
+	}
+	p := &VSphereProvisioner{settings: testVSphereSettings(), ipam: m}
+	vm := &model.VM{Name: "web", VsphereVMName: "alice-web", Owner: "alice", IPAddress: "10.254.0.40"}
+
+	plain := p.cloudInitUserData(vm, []string{"ssh-ed25519 AAAA"}, nil, "")
+	for _, want := range []string{"#cloud-config\n", "hostname: web\n", "  - name: alice\n", `ssh_authorized_keys: ["ssh-ed25519 AAAA"]`, "package_update: false\n", "packages: []\n"} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("Expected user data to contain %q, got %q", want, plain)
+		}
+	}
+	if strings.Contains(plain, "BASPHERE-USERDATA") {
+		t.Error("Expected plain cloud-config without user data")
+	}
+
+	merged := p.cloudInitUserData(vm, []string{"ssh-ed25519 AAAA"}, []string{"htop"}, "#cloud-config\nruncmd: [date]\n")
+	for _, want := range []string{"Merge-Type: list(append)+dict(no_replace,recurse_list)+str()", "runcmd: [date]", "package_update: true\n", `packages: ["htop"]`} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("Expected user data to contain %q, got %q", want, merged)
+		}
+	}
+
+	metadata := p.cloudInitMetadata(vm, "")
+	for _, want := range []string{"instance-id: alice-web\n", "    ens192:\n", "mtu: 1450\n", "- 10.254.0.40/21\n", "gateway4: 10.254.0.1\n", `addresses: ["10.0.0.53"]`} {
+		if !strings.Contains(metadata, want) {
+			t.Errorf("Expected metadata to contain %q, got %q", want, metadata)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package quota resolves per-user resource limits.
+//
+// Limits are layered, each level overriding only the limits it sets:
+//
+//	quotas.default            in the CLI config.yaml (also read by the scripts)
+//	quotas.teams.<team>       for users registered with that team
+//	quotas.users.<username>   for a single user
+//	admin override            set through the API and kept in the override store
+package quota
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// DefaultConfigPath is the CLI configuration file holding the quotas section
+const DefaultConfigPath = "/etc/basphere/config.yaml"
+
+// Limits are the effective limits of a user
+type Limits struct {
+	MaxVMs             int
+	MaxClusters        int
+	MaxNodesPerCluster int
+	MaxIPs             int
+	MaxSnapshots       int
+	// 0 = unlimited
+	MaxCPU      int
+	MaxMemoryMB int
+	MaxDiskGB   int
+}
+
+// DefaultLimits returns the limits used when config.yaml does not set them
+// They match the defaults of show-quota and the previous hardcoded values; resources are unlimited.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxVMs:             10,
+		MaxClusters:        3,
+		MaxNodesPerCluster: 10,
+		MaxIPs:             32,
+		MaxSnapshots:       10,
+	}
+}
+
+// Apply returns l with the limits set in o replaced
+func (l Limits) Apply(o model.QuotaLimits) Limits {
+	if o.MaxVMs != nil {
+		l.MaxVMs = *o.MaxVMs
+	}
+	if o.MaxClusters != nil {
+		l.MaxClusters = *o.MaxClusters
+	}
+	if o.MaxNodesPerCluster != nil {
+		l.MaxNodesPerCluster = *o.MaxNodesPerCluster
+	}
+	if o.MaxIPs != nil {
+		l.MaxIPs = *o.MaxIPs
+	}
+	if o.MaxSnapshots != nil {
+		l.MaxSnapshots = *o.MaxSnapshots
+	}
+	if o.MaxCPU != nil {
+		l.MaxCPU = *o.MaxCPU
+	}
+	if o.MaxMemoryMB != nil {
+		l.MaxMemoryMB = *o.MaxMemoryMB
+	}
+	if o.MaxDiskGB != nil {
+		l.MaxDiskGB = *o.MaxDiskGB
+	}
+	return l
+}
+
+// Config is the quotas section of the CLI config.yaml
+type Config struct {
+	Default model.QuotaLimits            `yaml:"default"`
+	Teams   map[string]model.QuotaLimits `yaml:"teams"`
+	Users   map[string]model.QuotaLimits `yaml:"users"`
+}
+
+// LoadConfig reads the quotas section from the CLI config.yaml
+// A missing file yields the default limits, like get_config in common.sh.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var file struct {
+		Quotas *Config `yaml:"quotas"`
+	}
+	file.Quotas = cfg
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Resolve returns the effective limits of a user
+// team is the team the user registered with (may be empty); override is the admin override (may be nil).
+func (c *Config) Resolve(username, team string, override *model.QuotaOverride) Limits {
+	limits := DefaultLimits().Apply(c.Default)
+
+	if team != "" {
+		if t, ok := c.Teams[team]; ok {
+			limits = limits.Apply(t)
+		}
+	}
+	if u, ok := c.Users[username]; ok {
+		limits = limits.Apply(u)
+	}
+	if override != nil {
+		limits = limits.Apply(override.QuotaLimits)
+	}
+
+	return limits
+}
@@ -0,0 +1,103 @@
+package quota
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+func intPtr(v int) *int { return &v }
+
+// =============================================================================
+// Config Tests
+// =============================================================================
+
+func TestLoadConfig_MissingFileUsesDefaults(t *testing.T) {
+	cfg, err := LoadConfig(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	limits := cfg.Resolve("hong", "", nil)
+	if limits != DefaultLimits() {
+		t.Errorf("Expected default limits, got %+v", limits)
+	}
+}
+
+func TestLoadConfig_ReadsQuotas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+network:
+  cidr: "10.254.0.0/21"
+quotas:
+  default:
+    max_vms: 5
+    max_clusters: 1
+    max_ips: 16
+  teams:
+    platform:
+      max_vms: 15
+      max_clusters: 4
+  users:
+    hong:
+      max_clusters: 6
+`), 0644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		team     string
+		expected Limits
+	}{
+		{"default", "kim", "", Limits{MaxVMs: 5, MaxClusters: 1, MaxNodesPerCluster: 10, MaxIPs: 16, MaxSnapshots: 10}},
+		{"team", "kim", "platform", Limits{MaxVMs: 15, MaxClusters: 4, MaxNodesPerCluster: 10, MaxIPs: 16, MaxSnapshots: 10}},
+		{"unknown team", "kim", "sales", Limits{MaxVMs: 5, MaxClusters: 1, MaxNodesPerCluster: 10, MaxIPs: 16, MaxSnapshots: 10}},
+		{"user over team", "hong", "platform", Limits{MaxVMs: 15, MaxClusters: 6, MaxNodesPerCluster: 10, MaxIPs: 16, MaxSnapshots: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.Resolve(tt.username, tt.team, nil); got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_InvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("quotas: [unclosed"), 0644)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+// =============================================================================
+// Resolve Tests
+// =============================================================================
+
+func TestResolve_OverrideWins(t *testing.T) {
+	cfg := &Config{
+		Users: map[string]model.QuotaLimits{"hong": {MaxVMs: intPtr(20)}},
+	}
+	override := &model.QuotaOverride{
+		Username:    "hong",
+		QuotaLimits: model.QuotaLimits{MaxVMs: intPtr(0), MaxIPs: intPtr(8)},
+	}
+
+	limits := cfg.Resolve("hong", "", override)
+	if limits.MaxVMs != 0 || limits.MaxIPs != 8 {
+		t.Errorf("Expected override to set 0 VMs and 8 IPs, got %+v", limits)
+	}
+	if limits.MaxClusters != 3 {
+		t.Errorf("Expected unset limits to keep the default, got %d clusters", limits.MaxClusters)
+	}
+}
@@ -0,0 +1,159 @@
+package sshca
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// clockSkew backdates certificates so slightly-off clocks on the bastion still accept them
+const clockSkew = 5 * time.Minute
+
+// Authority is an SSH certificate authority that signs user keys
+type Authority struct {
+	signer ssh.Signer
+	now    func() time.Time
+}
+
+// LoadOrCreate loads the CA private key from keyPath, generating an ed25519 key if missing
+func LoadOrCreate(keyPath string) (*Authority, error) {
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA key: %w", err)
+		}
+		return New(signer), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "basphere-user-ca")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CA key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create CA key directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write CA key: %w", err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA signer: %w", err)
+	}
+
+	return New(signer), nil
+}
+
+// New creates an authority from an existing signer
+func New(signer ssh.Signer) *Authority {
+	return &Authority{
+		signer: signer,
+		now:    time.Now,
+	}
+}
+
+// PublicKey returns the CA public key in authorized_keys format,
+// suitable for sshd's TrustedUserCAKeys file
+func (a *Authority) PublicKey() string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(a.signer.PublicKey())))
+}
+
+// SignUserKey signs publicKey (authorized_keys format) into a user certificate
+// valid for ttl, with username as the only principal
+func (a *Authority) SignUserKey(username, publicKey string, ttl time.Duration) (*ssh.Certificate, error) {
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("ttl must be positive")
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if _, isCert := pub.(*ssh.Certificate); isCert {
+		return nil, fmt.Errorf("public key must not be a certificate")
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now()
+	cert := &ssh.Certificate{
+		Key:             pub,
+		Serial:          serial,
+		CertType:        ssh.UserCert,
+		KeyId:           fmt.Sprintf("basphere:%s:%d", username, serial),
+		ValidPrincipals: []string{username},
+		ValidAfter:      uint64(now.Add(-clockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-pty":              "",
+				"permit-port-forwarding":  "",
+				"permit-agent-forwarding": "",
+				"permit-user-rc":          "",
+			},
+		},
+	}
+
+	if err := cert.SignCert(rand.Reader, a.signer); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// SessionSigner creates a throwaway key pair and certifies it for username, so the API server can
+// open SSH connections on the user's behalf (web terminal). The private key never leaves memory.
+func (a *Authority) SessionSigner(username string, ttl time.Duration) (ssh.Signer, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session key: %w", err)
+	}
+
+	cert, err := a.SignUserKey(username, string(ssh.MarshalAuthorizedKey(signer.PublicKey())), ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// MarshalCertificate returns the certificate in authorized_keys format (the -cert.pub file contents)
+func MarshalCertificate(cert *ssh.Certificate) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert)))
+}
+
+func randomSerial() (uint64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, fmt.Errorf("failed to generate serial: %w", err)
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
@@ -0,0 +1,230 @@
+package sshca
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+func newUserKey(t *testing.T) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	return string(ssh.MarshalAuthorizedKey(sshPub))
+}
+
+func newAuthority(t *testing.T) *Authority {
+	t.Helper()
+	ca, err := LoadOrCreate(filepath.Join(t.TempDir(), "ca", "user_ca"))
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	return ca
+}
+
+// =============================================================================
+// Key Management Tests
+// =============================================================================
+
+func TestLoadOrCreate_PersistsKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "user_ca")
+
+	first, err := LoadOrCreate(keyPath)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatalf("Expected CA key file to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected CA key mode 0600, got %o", info.Mode().Perm())
+	}
+
+	second, err := LoadOrCreate(keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load CA: %v", err)
+	}
+
+	if first.PublicKey() != second.PublicKey() {
+		t.Error("Expected reloaded CA to have the same public key")
+	}
+}
+
+func TestLoadOrCreate_InvalidKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "user_ca")
+	if err := os.WriteFile(keyPath, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadOrCreate(keyPath); err == nil {
+		t.Error("Expected error for invalid CA key")
+	}
+}
+
+// =============================================================================
+// Signing Tests
+// =============================================================================
+
+func TestSignUserKey(t *testing.T) {
+	ca := newAuthority(t)
+	now := time.Unix(1700000000, 0)
+	ca.now = func() time.Time { return now }
+
+	cert, err := ca.SignUserKey("kimht", newUserKey(t), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to sign key: %v", err)
+	}
+
+	if cert.CertType != ssh.UserCert {
+		t.Errorf("Expected user certificate, got type %d", cert.CertType)
+	}
+	if len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "kimht" {
+		t.Errorf("Expected principals [kimht], got %v", cert.ValidPrincipals)
+	}
+	if cert.ValidBefore != uint64(now.Add(time.Hour).Unix()) {
+		t.Errorf("Expected ValidBefore %d, got %d", now.Add(time.Hour).Unix(), cert.ValidBefore)
+	}
+	if cert.ValidAfter >= uint64(now.Unix()) {
+		t.Error("Expected ValidAfter to be backdated for clock skew")
+	}
+	if _, ok := cert.Permissions.Extensions["permit-pty"]; !ok {
+		t.Error("Expected permit-pty extension")
+	}
+}
+
+func TestSignUserKey_AcceptedByCertChecker(t *testing.T) {
+	ca := newAuthority(t)
+
+	cert, err := ca.SignUserKey("kimht", newUserKey(t), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to sign key: %v", err)
+	}
+
+	caPub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ca.PublicKey()))
+	if err != nil {
+		t.Fatalf("Failed to parse CA public key: %v", err)
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(caPub.Marshal())
+		},
+	}
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+	meta := fakeConnMetadata{user: "kimht", addr: addr}
+	if _, err := checker.Authenticate(meta, cert); err != nil {
+		t.Errorf("Expected certificate to be accepted for kimht: %v", err)
+	}
+
+	meta.user = "other"
+	if _, err := checker.Authenticate(meta, cert); err == nil {
+		t.Error("Expected certificate to be rejected for another principal")
+	}
+}
+
+func TestSessionSigner(t *testing.T) {
+	ca := newAuthority(t)
+
+	signer, err := ca.SessionSigner("kimht", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create session signer: %v", err)
+	}
+
+	cert, ok := signer.PublicKey().(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("Expected a certificate, got %s", signer.PublicKey().Type())
+	}
+	if len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "kimht" {
+		t.Errorf("Expected principal kimht, got %v", cert.ValidPrincipals)
+	}
+
+	checker := &ssh.CertChecker{}
+	if err := checker.CheckCert("kimht", cert); err != nil {
+		t.Errorf("Expected certificate to be valid: %v", err)
+	}
+
+	other, err := ca.SessionSigner("kimht", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create session signer: %v", err)
+	}
+	if string(other.PublicKey().(*ssh.Certificate).Key.Marshal()) == string(cert.Key.Marshal()) {
+		t.Error("Expected a new key for each session")
+	}
+}
+
+func TestSignUserKey_Expired(t *testing.T) {
+	ca := newAuthority(t)
+	issued := time.Now().Add(-2 * time.Hour)
+	ca.now = func() time.Time { return issued }
+
+	cert, err := ca.SignUserKey("kimht", newUserKey(t), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to sign key: %v", err)
+	}
+
+	checker := &ssh.CertChecker{}
+	if err := checker.CheckCert("kimht", cert); err == nil {
+		t.Error("Expected expired certificate to fail validation")
+	}
+}
+
+func TestSignUserKey_InvalidInput(t *testing.T) {
+	ca := newAuthority(t)
+
+	cert, err := ca.SignUserKey("kimht", newUserKey(t), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to sign key: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		publicKey string
+		ttl       time.Duration
+	}{
+		{"empty username", "", newUserKey(t), time.Hour},
+		{"invalid key", "kimht", "ssh-ed25519 garbage", time.Hour},
+		{"zero ttl", "kimht", newUserKey(t), 0},
+		{"certificate as key", "kimht", MarshalCertificate(cert), time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ca.SignUserKey(tt.username, tt.publicKey, tt.ttl); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+// fakeConnMetadata implements ssh.ConnMetadata for CertChecker tests
+type fakeConnMetadata struct {
+	user string
+	addr net.Addr
+}
+
+func (m fakeConnMetadata) User() string          { return m.user }
+func (m fakeConnMetadata) SessionID() []byte     { return nil }
+func (m fakeConnMetadata) ClientVersion() []byte { return nil }
+func (m fakeConnMetadata) ServerVersion() []byte { return nil }
+func (m fakeConnMetadata) RemoteAddr() net.Addr  { return m.addr }
+func (m fakeConnMetadata) LocalAddr() net.Addr   { return m.addr }
@@ -0,0 +1,208 @@
+// Package sshconfig renders OpenSSH client configuration for reaching a user's
+// VMs and cluster nodes through the bastion.
+//
+// Every host gets its own entry with ProxyJump through a "bastion" entry, so
+// after saving the output as ~/.ssh/config a VM is reachable as:
+//
+//	ssh my-server
+//	ssh my-cluster-worker-1
+package sshconfig
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// Variant selects the client platform the config is written for
+type Variant string
+
+const (
+	// OpenSSH on macOS and Linux
+	VariantMacOS Variant = "macos"
+	// OpenSSH bundled with Windows 10 and later
+	VariantWindows Variant = "windows"
+)
+
+// BastionAlias is the Host name of the bastion entry, as in the SSH guide
+const BastionAlias = "bastion"
+
+// ClusterNodeUser is the account created on cluster nodes by the CAPI template
+const ClusterNodeUser = "basphere"
+
+// ParseVariant parses a client platform; empty means macOS
+func ParseVariant(s string) (Variant, error) {
+	switch strings.ToLower(s) {
+	case "", "macos", "linux":
+		return VariantMacOS, nil
+	case "windows":
+		return VariantWindows, nil
+	default:
+		return "", fmt.Errorf("unknown os %q: use macos, linux or windows", s)
+	}
+}
+
+// DefaultIdentityFile returns the key path the SSH guide uses for the variant
+// On Windows the path needs the local account name; a placeholder is used if it is empty.
+func DefaultIdentityFile(v Variant, windowsUser string) string {
+	if v == VariantWindows {
+		if windowsUser == "" {
+			windowsUser = "<windows-user>"
+		}
+		return "C:/Users/" + windowsUser + "/.ssh/id_ed25519"
+	}
+	return "~/.ssh/id_ed25519"
+}
+
+// Host is one machine reached through the bastion
+type Host struct {
+	Alias    string
+	HostName string
+	User     string
+	// Written above the entry
+	Comment string
+}
+
+// Options configures Render
+type Options struct {
+	Variant        Variant
+	Username       string
+	BastionAddress string
+	BastionPort    int
+	IdentityFile   string
+	Hosts          []Host
+}
+
+// VMHosts returns an entry for each VM that has an IP address
+func VMHosts(vms []model.VM, username string) []Host {
+	var hosts []Host
+	for _, vm := range vms {
+		if vm.IPAddress == "" {
+			continue
+		}
+		user := vm.LoginUser
+		if user == "" {
+			user = username
+		}
+		hosts = append(hosts, Host{
+			Alias:    vm.Name,
+			HostName: vm.IPAddress,
+			User:     user,
+			Comment:  fmt.Sprintf("VM %s (%s, %s)", vm.Name, vm.OS, vm.Spec),
+		})
+	}
+	return hosts
+}
+
+// ClusterHosts returns entries for the control plane and worker nodes of each cluster
+// Workers are numbered from 1 like their IP allocations (<cluster>-worker-<n>).
+func ClusterHosts(clusters []model.Cluster) []Host {
+	var hosts []Host
+	for _, c := range clusters {
+		if c.ControlPlaneIP != "" {
+			hosts = append(hosts, Host{
+				Alias:    c.Name + "-cp",
+				HostName: c.ControlPlaneIP,
+				User:     ClusterNodeUser,
+				Comment:  fmt.Sprintf("Cluster %s control plane", c.Name),
+			})
+		}
+		for i, ip := range c.WorkerIPs {
+			if ip == "" {
+				continue
+			}
+			hosts = append(hosts, Host{
+				Alias:    fmt.Sprintf("%s-worker-%d", c.Name, i+1),
+				HostName: ip,
+				User:     ClusterNodeUser,
+				Comment:  fmt.Sprintf("Cluster %s worker %d", c.Name, i+1),
+			})
+		}
+	}
+	return hosts
+}
+
+// Render writes the bastion entry followed by one entry per host
+// Hosts with an invalid address or an alias already used are left out with a comment.
+func Render(opts Options) []byte {
+	identity := opts.IdentityFile
+	if identity == "" {
+		identity = DefaultIdentityFile(opts.Variant, "")
+	}
+	port := opts.BastionPort
+	if port == 0 {
+		port = 22
+	}
+
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+
+	line("# Basphere SSH config for %s", opts.Username)
+	line("# Generated by the Basphere API; regenerate after creating or deleting VMs")
+	line("")
+	line("# Basphere Bastion")
+	line("Host %s", BastionAlias)
+	line("    HostName %s", opts.BastionAddress)
+	line("    User %s", opts.Username)
+	line("    Port %s", strconv.Itoa(port))
+	line("    ServerAliveInterval 30")
+	line("    ServerAliveCountMax 3")
+	line("    IdentityFile %s", quote(identity))
+
+	seen := map[string]bool{BastionAlias: true}
+	for _, host := range opts.Hosts {
+		line("")
+		comment := strings.Join(strings.Fields(host.Comment), " ")
+		if net.ParseIP(host.HostName) == nil {
+			line("# Skipped %s: invalid address %q", comment, host.HostName)
+			continue
+		}
+		if seen[host.Alias] || !validAlias(host.Alias) {
+			line("# Skipped %s: host name %q is already used or invalid", comment, host.Alias)
+			continue
+		}
+		seen[host.Alias] = true
+
+		if comment != "" {
+			line("# %s", comment)
+		}
+		line("Host %s", host.Alias)
+		line("    HostName %s", host.HostName)
+		line("    User %s", host.User)
+		line("    ProxyJump %s", BastionAlias)
+		line("    IdentityFile %s", quote(identity))
+		if opts.Variant == VariantWindows {
+			// IPs are reused after a VM is deleted, which IDEs such as VS Code report as a changed host key
+			line("    StrictHostKeyChecking no")
+			line("    UserKnownHostsFile /dev/null")
+		}
+	}
+
+	return []byte(b.String())
+}
+
+// validAlias reports whether s can be used as a Host pattern without matching other hosts
+func validAlias(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// quote wraps a path in double quotes if it contains spaces
+func quote(path string) string {
+	if strings.ContainsAny(path, " \t") {
+		return `"` + path + `"`
+	}
+	return path
+}
@@ -0,0 +1,109 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// =============================================================================
+// Variant Tests
+// =============================================================================
+
+func TestParseVariant(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Variant
+		wantErr bool
+	}{
+		{"", VariantMacOS, false},
+		{"macos", VariantMacOS, false},
+		{"linux", VariantMacOS, false},
+		{"Windows", VariantWindows, false},
+		{"plan9", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVariant(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVariant(%q): expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVariant(%q): expected %q, got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+// =============================================================================
+// Host Tests
+// =============================================================================
+
+func TestClusterHosts(t *testing.T) {
+	hosts := ClusterHosts([]model.Cluster{
+		{Name: "k8s", ControlPlaneIP: "10.254.0.100", WorkerIPs: []string{"10.254.0.101", "10.254.0.102"}},
+		{Name: "pending"},
+	})
+
+	want := []string{"k8s-cp", "k8s-worker-1", "k8s-worker-2"}
+	if len(hosts) != len(want) {
+		t.Fatalf("Expected %d hosts, got %d", len(want), len(hosts))
+	}
+	for i, alias := range want {
+		if hosts[i].Alias != alias {
+			t.Errorf("Expected host %d to be %s, got %s", i, alias, hosts[i].Alias)
+		}
+		if hosts[i].User != ClusterNodeUser {
+			t.Errorf("Expected user %s, got %s", ClusterNodeUser, hosts[i].User)
+		}
+	}
+}
+
+func TestVMHosts_DefaultsLoginUser(t *testing.T) {
+	hosts := VMHosts([]model.VM{
+		{Name: "web", IPAddress: "10.254.0.10"},
+		{Name: "db", LoginUser: "rocky", IPAddress: "10.254.0.11"},
+	}, "testuser")
+
+	if len(hosts) != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", len(hosts))
+	}
+	if hosts[0].User != "testuser" {
+		t.Errorf("Expected owner as login user, got %s", hosts[0].User)
+	}
+	if hosts[1].User != "rocky" {
+		t.Errorf("Expected VM login user, got %s", hosts[1].User)
+	}
+}
+
+// =============================================================================
+// Render Tests
+// =============================================================================
+
+func TestRender_SkipsConflictsAndInvalidHosts(t *testing.T) {
+	config := string(Render(Options{
+		Variant:        VariantMacOS,
+		Username:       "testuser",
+		BastionAddress: "bastion.example.com",
+		Hosts: []Host{
+			{Alias: "web", HostName: "10.254.0.10", User: "testuser"},
+			{Alias: "web", HostName: "10.254.0.11", User: "testuser", Comment: "VM duplicate"},
+			{Alias: "bastion", HostName: "10.254.0.12", User: "testuser"},
+			{Alias: "*", HostName: "10.254.0.13", User: "testuser"},
+			{Alias: "bad", HostName: "10.254.0.14\nHost *", User: "testuser"},
+		},
+	}))
+
+	if strings.Count(config, "\nHost ") != 2 {
+		t.Errorf("Expected only the bastion and one VM entry, got:\n%s", config)
+	}
+	if !strings.Contains(config, "    Port 22\n") {
+		t.Errorf("Expected default bastion port 22, got:\n%s", config)
+	}
+	for _, unwanted := range []string{"10.254.0.11\n", "\nHost *", "HostName 10.254.0.14"} {
+		if strings.Contains(config, unwanted) {
+			t.Errorf("Expected config not to contain %q, got:\n%s", unwanted, config)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"fmt"
+)
+
+// ImportResult counts the requests copied by Import
+type ImportResult struct {
+	Requests   int
+	KeyChanges int
+	Skipped    int
+}
+
+// Import copies every registration and key change request from the src stores into the dst stores
+// Requests whose ID already exists in dst are skipped, so an interrupted import can be re-run.
+func Import(dst Store, dstKeyChanges KeyChangeStore, src Store, srcKeyChanges KeyChangeStore) (*ImportResult, error) {
+	result := &ImportResult{}
+
+	requests, err := src.List(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list requests: %w", err)
+	}
+	for _, req := range requests {
+		if _, err := dst.Get(req.ID); err == nil {
+			result.Skipped++
+			continue
+		}
+		if err := dst.Create(req); err != nil {
+			return result, fmt.Errorf("failed to import request %s: %w", req.ID, err)
+		}
+		result.Requests++
+	}
+
+	keyChanges, err := srcKeyChanges.List(nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to list key change requests: %w", err)
+	}
+	for _, req := range keyChanges {
+		if _, err := dstKeyChanges.Get(req.ID); err == nil {
+			result.Skipped++
+			continue
+		}
+		if err := dstKeyChanges.Create(req); err != nil {
+			return result, fmt.Errorf("failed to import key change request %s: %w", req.ID, err)
+		}
+		result.KeyChanges++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// JobStore implements storage for asynchronous jobs
+// Jobs are written on every state change so they survive a server restart
+type JobStore struct {
+	baseDir string
+	mu      sync.RWMutex
+}
+
+// NewJobStore creates a new job store
+func NewJobStore(baseDir string) (*JobStore, error) {
+	jobDir := filepath.Join(baseDir, "jobs")
+	if err := os.MkdirAll(jobDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	return &JobStore{
+		baseDir: jobDir,
+	}, nil
+}
+
+func (s *JobStore) filePath(id string) string {
+	return filepath.Join(s.baseDir, id+".json")
+}
+
+// Save creates or replaces a job
+func (s *JobStore) Save(job *model.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated job behind
+	tmp := s.filePath(job.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	if err := os.Rename(tmp, s.filePath(job.ID)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a job by ID
+func (s *JobStore) Get(id string) (*model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readJob(id)
+}
+
+// List returns all jobs, newest first
+func (s *JobStore) List() ([]*model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job directory: %w", err)
+	}
+
+	var jobs []*model.Job
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		id := entry.Name()[:len(entry.Name())-5]
+		job, err := s.readJob(id)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+
+	return jobs, nil
+}
+
+// DeleteFinishedBefore removes finished jobs that completed before the cutoff
+func (s *JobStore) DeleteFinishedBefore(cutoff time.Time) (int, error) {
+	jobs, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, job := range jobs {
+		if !job.IsFinished() || job.FinishedAt == nil || job.FinishedAt.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(s.filePath(job.ID)); err == nil {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// Internal methods
+
+func (s *JobStore) readJob(id string) (*model.Job, error) {
+	data, err := os.ReadFile(s.filePath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("job not found: %s", id)
+		}
+		return nil, err
+	}
+
+	var job model.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job: %w", err)
+	}
+
+	return &job, nil
+}
@@ -11,30 +11,30 @@ import (
 	"github.com/basphere/basphere-api/internal/model"
 )
 
-// KeyChangeStore implements storage for key change requests
-type KeyChangeStore struct {
+// FileKeyChangeStore implements KeyChangeStore interface using JSON files
+type FileKeyChangeStore struct {
 	baseDir string
 	mu      sync.RWMutex
 }
 
-// NewKeyChangeStore creates a new key change store
-func NewKeyChangeStore(baseDir string) (*KeyChangeStore, error) {
+// NewFileKeyChangeStore creates a new file-based key change store
+func NewFileKeyChangeStore(baseDir string) (*FileKeyChangeStore, error) {
 	keyChangeDir := filepath.Join(baseDir, "key-changes")
 	if err := os.MkdirAll(keyChangeDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create key change directory: %w", err)
 	}
 
-	return &KeyChangeStore{
+	return &FileKeyChangeStore{
 		baseDir: keyChangeDir,
 	}, nil
 }
 
-func (s *KeyChangeStore) filePath(id string) string {
+func (s *FileKeyChangeStore) filePath(id string) string {
 	return filepath.Join(s.baseDir, id+".json")
 }
 
 // Create creates a new key change request
-func (s *KeyChangeStore) Create(req *model.KeyChangeRequest) error {
+func (s *FileKeyChangeStore) Create(req *model.KeyChangeRequest) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -51,7 +51,7 @@ func (s *KeyChangeStore) Create(req *model.KeyChangeRequest) error {
 }
 
 // Get retrieves a key change request by ID
-func (s *KeyChangeStore) Get(id string) (*model.KeyChangeRequest, error) {
+func (s *FileKeyChangeStore) Get(id string) (*model.KeyChangeRequest, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -59,7 +59,7 @@ func (s *KeyChangeStore) Get(id string) (*model.KeyChangeRequest, error) {
 }
 
 // GetByUsername retrieves a key change request by username
-func (s *KeyChangeStore) GetByUsername(username string) (*model.KeyChangeRequest, error) {
+func (s *FileKeyChangeStore) GetByUsername(username string) (*model.KeyChangeRequest, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -78,7 +78,7 @@ func (s *KeyChangeStore) GetByUsername(username string) (*model.KeyChangeRequest
 }
 
 // List returns all key change requests with optional status filter
-func (s *KeyChangeStore) List(status *model.RequestStatus) ([]*model.KeyChangeRequest, error) {
+func (s *FileKeyChangeStore) List(status *model.RequestStatus) ([]*model.KeyChangeRequest, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -102,7 +102,7 @@ func (s *KeyChangeStore) List(status *model.RequestStatus) ([]*model.KeyChangeRe
 }
 
 // Update updates a key change request
-func (s *KeyChangeStore) Update(req *model.KeyChangeRequest) error {
+func (s *FileKeyChangeStore) Update(req *model.KeyChangeRequest) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -114,7 +114,7 @@ func (s *KeyChangeStore) Update(req *model.KeyChangeRequest) error {
 }
 
 // Delete deletes a key change request
-func (s *KeyChangeStore) Delete(id string) error {
+func (s *FileKeyChangeStore) Delete(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -130,7 +130,7 @@ func (s *KeyChangeStore) Delete(id string) error {
 
 // Internal methods
 
-func (s *KeyChangeStore) readRequest(id string) (*model.KeyChangeRequest, error) {
+func (s *FileKeyChangeStore) readRequest(id string) (*model.KeyChangeRequest, error) {
 	path := s.filePath(id)
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -148,7 +148,7 @@ func (s *KeyChangeStore) readRequest(id string) (*model.KeyChangeRequest, error)
 	return &req, nil
 }
 
-func (s *KeyChangeStore) writeRequest(req *model.KeyChangeRequest) error {
+func (s *FileKeyChangeStore) writeRequest(req *model.KeyChangeRequest) error {
 	path := s.filePath(req.ID)
 	data, err := json.MarshalIndent(req, "", "  ")
 	if err != nil {
@@ -162,7 +162,7 @@ func (s *KeyChangeStore) writeRequest(req *model.KeyChangeRequest) error {
 	return nil
 }
 
-func (s *KeyChangeStore) listAllUnsafe() ([]*model.KeyChangeRequest, error) {
+func (s *FileKeyChangeStore) listAllUnsafe() ([]*model.KeyChangeRequest, error) {
 	entries, err := os.ReadDir(s.baseDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read storage directory: %w", err)
@@ -189,7 +189,7 @@ func (s *KeyChangeStore) listAllUnsafe() ([]*model.KeyChangeRequest, error) {
 	return requests, nil
 }
 
-func (s *KeyChangeStore) existsUsernameUnsafe(username string) (bool, error) {
+func (s *FileKeyChangeStore) existsUsernameUnsafe(username string) (bool, error) {
 	requests, err := s.listAllUnsafe()
 	if err != nil {
 		return false, err
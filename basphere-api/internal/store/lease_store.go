@@ -0,0 +1,153 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// LeaseStore implements storage for VM and cluster leases
+// Each lease is stored as <owner>/<kind>/<name>.json
+type LeaseStore struct {
+	baseDir string
+	mu      sync.RWMutex
+}
+
+// NewLeaseStore creates a new lease store
+func NewLeaseStore(baseDir string) (*LeaseStore, error) {
+	leaseDir := filepath.Join(baseDir, "leases")
+	if err := os.MkdirAll(leaseDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create lease directory: %w", err)
+	}
+
+	return &LeaseStore{
+		baseDir: leaseDir,
+	}, nil
+}
+
+func (s *LeaseStore) filePath(owner string, kind model.LeaseKind, name string) (string, error) {
+	for _, part := range []string{owner, string(kind), name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid lease path: %s/%s/%s", owner, kind, name)
+		}
+	}
+	return filepath.Join(s.baseDir, owner, string(kind), name+".json"), nil
+}
+
+// Get retrieves the lease of a resource, or nil if it has none
+func (s *LeaseStore) Get(owner string, kind model.LeaseKind, name string) (*model.Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	path, err := s.filePath(owner, kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return readLease(path)
+}
+
+// Save creates or replaces a lease
+func (s *LeaseStore) Save(lease *model.Lease) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.filePath(lease.Owner, lease.Kind, lease.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create lease directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(lease, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lease: %w", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated lease behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write lease: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write lease: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the lease of a resource; a missing lease is not an error
+func (s *LeaseStore) Delete(owner string, kind model.LeaseKind, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.filePath(owner, kind, name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// List returns the leases of one owner, or of all owners if owner is empty, soonest expiry first
+func (s *LeaseStore) List(owner string) ([]*model.Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pattern := filepath.Join(s.baseDir, "*", "*", "*.json")
+	if owner != "" {
+		if owner == "." || owner == ".." || strings.ContainsAny(owner, `/\*?[`) {
+			return nil, fmt.Errorf("invalid owner: %q", owner)
+		}
+		pattern = filepath.Join(s.baseDir, owner, "*", "*.json")
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease directory: %w", err)
+	}
+
+	var leases []*model.Lease
+	for _, path := range paths {
+		lease, err := readLease(path)
+		if err != nil || lease == nil {
+			continue
+		}
+		leases = append(leases, lease)
+	}
+
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].ExpiresAt.Before(leases[j].ExpiresAt)
+	})
+
+	return leases, nil
+}
+
+func readLease(path string) (*model.Lease, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var lease model.Lease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, fmt.Errorf("failed to parse lease: %w", err)
+	}
+
+	return &lease, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// QuotaStore implements storage for per-user quota overrides set by admins
+// Each override is stored as <username>.json
+type QuotaStore struct {
+	baseDir string
+	mu      sync.RWMutex
+}
+
+// NewQuotaStore creates a new quota override store
+func NewQuotaStore(baseDir string) (*QuotaStore, error) {
+	quotaDir := filepath.Join(baseDir, "quotas")
+	if err := os.MkdirAll(quotaDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create quota directory: %w", err)
+	}
+
+	return &QuotaStore{
+		baseDir: quotaDir,
+	}, nil
+}
+
+func (s *QuotaStore) filePath(username string) (string, error) {
+	if username == "" || username == "." || username == ".." || strings.ContainsAny(username, `/\`) {
+		return "", fmt.Errorf("invalid username: %q", username)
+	}
+	return filepath.Join(s.baseDir, username+".json"), nil
+}
+
+// Get retrieves the override for a user, or nil if the user has none
+func (s *QuotaStore) Get(username string) (*model.QuotaOverride, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	path, err := s.filePath(username)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var override model.QuotaOverride
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("failed to parse quota override: %w", err)
+	}
+
+	return &override, nil
+}
+
+// Save creates or replaces the override for a user
+func (s *QuotaStore) Save(override *model.QuotaOverride) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.filePath(override.Username)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(override, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal quota override: %w", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated override behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write quota override: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write quota override: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the override for a user
+func (s *QuotaStore) Delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.filePath(username)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("quota override not found: %s", username)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// List returns all overrides ordered by username
+func (s *QuotaStore) List() ([]*model.QuotaOverride, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota directory: %w", err)
+	}
+
+	var overrides []*model.QuotaOverride
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		override, err := s.Get(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || override == nil {
+			continue
+		}
+		overrides = append(overrides, override)
+	}
+
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Username < overrides[j].Username
+	})
+
+	return overrides, nil
+}
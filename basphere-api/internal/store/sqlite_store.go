@@ -0,0 +1,417 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// sqliteTimeFormat is fixed-width so that text comparison orders timestamps correctly
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// migrations are applied in order; never edit an entry once released, append a new one
+var migrations = []string{
+	// 1: registration and key change requests
+	`CREATE TABLE registration_requests (
+		id            TEXT PRIMARY KEY,
+		username      TEXT NOT NULL,
+		email         TEXT NOT NULL,
+		full_name     TEXT NOT NULL DEFAULT '',
+		team          TEXT NOT NULL DEFAULT '',
+		public_key    TEXT NOT NULL,
+		status        TEXT NOT NULL,
+		created_at    TEXT NOT NULL,
+		updated_at    TEXT NOT NULL,
+		processed_by  TEXT NOT NULL DEFAULT '',
+		processed_at  TEXT NOT NULL DEFAULT '',
+		reject_reason TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_registration_username ON registration_requests (username);
+	CREATE INDEX idx_registration_email ON registration_requests (email);
+	CREATE INDEX idx_registration_status ON registration_requests (status, created_at);
+	CREATE UNIQUE INDEX idx_registration_pending_username
+		ON registration_requests (username) WHERE status = 'pending';
+
+	CREATE TABLE key_change_requests (
+		id             TEXT PRIMARY KEY,
+		username       TEXT NOT NULL,
+		email          TEXT NOT NULL,
+		new_public_key TEXT NOT NULL,
+		reason         TEXT NOT NULL DEFAULT '',
+		status         TEXT NOT NULL,
+		created_at     TEXT NOT NULL,
+		updated_at     TEXT NOT NULL,
+		processed_by   TEXT NOT NULL DEFAULT '',
+		processed_at   TEXT NOT NULL DEFAULT '',
+		reject_reason  TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_key_change_username ON key_change_requests (username);
+	CREATE INDEX idx_key_change_status ON key_change_requests (status, created_at);
+	CREATE UNIQUE INDEX idx_key_change_pending_username
+		ON key_change_requests (username) WHERE status = 'pending';`,
+}
+
+// SQLiteStore implements Store interface using a SQLite database
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and applies pending migrations
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite serializes writers anyway; a single connection also keeps :memory: databases shared
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// sqliteDSN builds the file: URI for path; escaping the path keeps characters such as ? and #
+// from being read as the start of the query or fragment
+func sqliteDSN(path string) string {
+	query := url.Values{"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)", "foreign_keys(1)"}}
+	dsn := url.URL{Scheme: "file", Path: path, OmitHost: true, RawQuery: query.Encode()}
+	return dsn.String()
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// KeyChanges returns a key change store backed by the same database
+func (s *SQLiteStore) KeyChanges() *SQLiteKeyChangeStore {
+	return &SQLiteKeyChangeStore{db: s.db}
+}
+
+// migrate applies all migrations newer than the recorded schema version
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, formatTime(time.Now())); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+const registrationColumns = `id, username, email, full_name, team, public_key, status,
+	created_at, updated_at, processed_by, processed_at, reject_reason`
+
+// Create creates a new registration request
+func (s *SQLiteStore) Create(req *model.RegistrationRequest) error {
+	_, err := s.db.Exec(`INSERT INTO registration_requests (`+registrationColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.ID, req.Username, req.Email, req.FullName, req.Team, req.PublicKey, string(req.Status),
+		formatTime(req.CreatedAt), formatTime(req.UpdatedAt), req.ProcessedBy, req.ProcessedAt, req.RejectReason)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("username %s already has a pending request", req.Username)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert request: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a registration request by ID
+func (s *SQLiteStore) Get(id string) (*model.RegistrationRequest, error) {
+	row := s.db.QueryRow(`SELECT `+registrationColumns+` FROM registration_requests WHERE id = ?`, id)
+	req, err := scanRegistration(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("request not found: %s", id)
+	}
+	return req, err
+}
+
+// GetByUsername retrieves the newest registration request by username
+func (s *SQLiteStore) GetByUsername(username string) (*model.RegistrationRequest, error) {
+	row := s.db.QueryRow(`SELECT `+registrationColumns+` FROM registration_requests
+		WHERE username = ? ORDER BY created_at DESC LIMIT 1`, username)
+	req, err := scanRegistration(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("request not found for username: %s", username)
+	}
+	return req, err
+}
+
+// List returns all registration requests with optional status filter (newest first)
+func (s *SQLiteStore) List(status *model.RequestStatus) ([]*model.RegistrationRequest, error) {
+	query := `SELECT ` + registrationColumns + ` FROM registration_requests`
+	var args []interface{}
+	if status != nil {
+		query += ` WHERE status = ?`
+		args = append(args, string(*status))
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*model.RegistrationRequest
+	for rows.Next() {
+		req, err := scanRegistration(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// Update updates a registration request
+func (s *SQLiteStore) Update(req *model.RegistrationRequest) error {
+	result, err := s.db.Exec(`UPDATE registration_requests SET
+		username = ?, email = ?, full_name = ?, team = ?, public_key = ?, status = ?,
+		created_at = ?, updated_at = ?, processed_by = ?, processed_at = ?, reject_reason = ?
+		WHERE id = ?`,
+		req.Username, req.Email, req.FullName, req.Team, req.PublicKey, string(req.Status),
+		formatTime(req.CreatedAt), formatTime(req.UpdatedAt), req.ProcessedBy, req.ProcessedAt, req.RejectReason,
+		req.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update request: %w", err)
+	}
+	return requireAffected(result, req.ID)
+}
+
+// Delete deletes a registration request
+func (s *SQLiteStore) Delete(id string) error {
+	result, err := s.db.Exec(`DELETE FROM registration_requests WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete request: %w", err)
+	}
+	return requireAffected(result, id)
+}
+
+// ExistsUsername checks if a username already has a pending request
+func (s *SQLiteStore) ExistsUsername(username string) (bool, error) {
+	return s.exists(`SELECT 1 FROM registration_requests WHERE username = ? AND status = 'pending' LIMIT 1`, username)
+}
+
+// ExistsEmail checks if an email already has a pending request
+func (s *SQLiteStore) ExistsEmail(email string) (bool, error) {
+	return s.exists(`SELECT 1 FROM registration_requests WHERE email = ? AND status = 'pending' LIMIT 1`, email)
+}
+
+func (s *SQLiteStore) exists(query string, arg string) (bool, error) {
+	var one int
+	err := s.db.QueryRow(query, arg).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SQLiteKeyChangeStore implements KeyChangeStore interface using a SQLite database
+type SQLiteKeyChangeStore struct {
+	db *sql.DB
+}
+
+const keyChangeColumns = `id, username, email, new_public_key, reason, status,
+	created_at, updated_at, processed_by, processed_at, reject_reason`
+
+// Create creates a new key change request
+func (s *SQLiteKeyChangeStore) Create(req *model.KeyChangeRequest) error {
+	_, err := s.db.Exec(`INSERT INTO key_change_requests (`+keyChangeColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.ID, req.Username, req.Email, req.NewPublicKey, req.Reason, string(req.Status),
+		formatTime(req.CreatedAt), formatTime(req.UpdatedAt), req.ProcessedBy, req.ProcessedAt, req.RejectReason)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("사용자 '%s'의 키 변경 요청이 이미 진행 중입니다", req.Username)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert request: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a key change request by ID
+func (s *SQLiteKeyChangeStore) Get(id string) (*model.KeyChangeRequest, error) {
+	row := s.db.QueryRow(`SELECT `+keyChangeColumns+` FROM key_change_requests WHERE id = ?`, id)
+	req, err := scanKeyChange(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("request not found: %s", id)
+	}
+	return req, err
+}
+
+// GetByUsername retrieves the pending key change request for a username
+func (s *SQLiteKeyChangeStore) GetByUsername(username string) (*model.KeyChangeRequest, error) {
+	row := s.db.QueryRow(`SELECT `+keyChangeColumns+` FROM key_change_requests
+		WHERE username = ? AND status = 'pending'`, username)
+	req, err := scanKeyChange(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("key change request not found for username: %s", username)
+	}
+	return req, err
+}
+
+// List returns all key change requests with optional status filter (newest first)
+func (s *SQLiteKeyChangeStore) List(status *model.RequestStatus) ([]*model.KeyChangeRequest, error) {
+	query := `SELECT ` + keyChangeColumns + ` FROM key_change_requests`
+	var args []interface{}
+	if status != nil {
+		query += ` WHERE status = ?`
+		args = append(args, string(*status))
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*model.KeyChangeRequest
+	for rows.Next() {
+		req, err := scanKeyChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// Update updates a key change request
+func (s *SQLiteKeyChangeStore) Update(req *model.KeyChangeRequest) error {
+	result, err := s.db.Exec(`UPDATE key_change_requests SET
+		username = ?, email = ?, new_public_key = ?, reason = ?, status = ?,
+		created_at = ?, updated_at = ?, processed_by = ?, processed_at = ?, reject_reason = ?
+		WHERE id = ?`,
+		req.Username, req.Email, req.NewPublicKey, req.Reason, string(req.Status),
+		formatTime(req.CreatedAt), formatTime(req.UpdatedAt), req.ProcessedBy, req.ProcessedAt, req.RejectReason,
+		req.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update request: %w", err)
+	}
+	return requireAffected(result, req.ID)
+}
+
+// Delete deletes a key change request
+func (s *SQLiteKeyChangeStore) Delete(id string) error {
+	result, err := s.db.Exec(`DELETE FROM key_change_requests WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete request: %w", err)
+	}
+	return requireAffected(result, id)
+}
+
+// Internal helpers
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRegistration(row rowScanner) (*model.RegistrationRequest, error) {
+	var req model.RegistrationRequest
+	var status, createdAt, updatedAt string
+	if err := row.Scan(&req.ID, &req.Username, &req.Email, &req.FullName, &req.Team, &req.PublicKey, &status,
+		&createdAt, &updatedAt, &req.ProcessedBy, &req.ProcessedAt, &req.RejectReason); err != nil {
+		return nil, err
+	}
+
+	req.Status = model.RequestStatus(status)
+	req.CreatedAt = parseTime(createdAt)
+	req.UpdatedAt = parseTime(updatedAt)
+	return &req, nil
+}
+
+func scanKeyChange(row rowScanner) (*model.KeyChangeRequest, error) {
+	var req model.KeyChangeRequest
+	var status, createdAt, updatedAt string
+	if err := row.Scan(&req.ID, &req.Username, &req.Email, &req.NewPublicKey, &req.Reason, &status,
+		&createdAt, &updatedAt, &req.ProcessedBy, &req.ProcessedAt, &req.RejectReason); err != nil {
+		return nil, err
+	}
+
+	req.Status = model.RequestStatus(status)
+	req.CreatedAt = parseTime(createdAt)
+	req.UpdatedAt = parseTime(updatedAt)
+	return &req, nil
+}
+
+func requireAffected(result sql.Result, id string) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("request not found: %s", id)
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(sqliteTimeFormat, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,333 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+func setupSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "basphere.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func newRegistration(id, username, email string, createdAt time.Time) *model.RegistrationRequest {
+	return &model.RegistrationRequest{
+		ID:        id,
+		Username:  username,
+		Email:     email,
+		PublicKey: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl test",
+		Status:    model.StatusPending,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+}
+
+// =============================================================================
+// Registration Request Tests
+// =============================================================================
+
+func TestSQLiteStore_CreateAndGet(t *testing.T) {
+	s := setupSQLiteStore(t)
+	now := time.Now().Truncate(time.Millisecond)
+
+	req := newRegistration("req-1", "hong", "hong@company.com", now)
+	req.FullName = "Hong Gildong"
+	req.Team = "platform"
+
+	if err := s.Create(req); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	got, err := s.Get("req-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if got.Username != "hong" || got.Email != "hong@company.com" || got.FullName != "Hong Gildong" || got.Team != "platform" {
+		t.Errorf("Unexpected request: %+v", got)
+	}
+	if got.Status != model.StatusPending {
+		t.Errorf("Expected status pending, got %s", got.Status)
+	}
+	if !got.CreatedAt.Equal(now) {
+		t.Errorf("Expected created_at %v, got %v", now, got.CreatedAt)
+	}
+
+	if _, err := s.Get("missing"); err == nil {
+		t.Error("Expected error for missing request")
+	}
+}
+
+func TestSQLiteStore_DuplicatePendingUsername(t *testing.T) {
+	s := setupSQLiteStore(t)
+	now := time.Now()
+
+	if err := s.Create(newRegistration("req-1", "hong", "hong@company.com", now)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := s.Create(newRegistration("req-2", "hong", "other@company.com", now)); err == nil {
+		t.Error("Expected error for second pending request with same username")
+	}
+
+	// Once processed, the username may request again
+	req, _ := s.Get("req-1")
+	req.Status = model.StatusRejected
+	if err := s.Update(req); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := s.Create(newRegistration("req-2", "hong", "hong@company.com", now.Add(time.Minute))); err != nil {
+		t.Errorf("Expected new request after rejection to succeed, got: %v", err)
+	}
+
+	// GetByUsername returns the newest request
+	got, err := s.GetByUsername("hong")
+	if err != nil {
+		t.Fatalf("GetByUsername failed: %v", err)
+	}
+	if got.ID != "req-2" {
+		t.Errorf("Expected newest request req-2, got %s", got.ID)
+	}
+}
+
+func TestSQLiteStore_Exists(t *testing.T) {
+	s := setupSQLiteStore(t)
+
+	if err := s.Create(newRegistration("req-1", "hong", "hong@company.com", time.Now())); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		check    func() (bool, error)
+		expected bool
+	}{
+		{"pending username", func() (bool, error) { return s.ExistsUsername("hong") }, true},
+		{"unknown username", func() (bool, error) { return s.ExistsUsername("kim") }, false},
+		{"pending email", func() (bool, error) { return s.ExistsEmail("hong@company.com") }, true},
+		{"unknown email", func() (bool, error) { return s.ExistsEmail("kim@company.com") }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.check()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// Processed requests no longer count
+	req, _ := s.Get("req-1")
+	req.Status = model.StatusApproved
+	s.Update(req)
+
+	if exists, _ := s.ExistsUsername("hong"); exists {
+		t.Error("Expected approved request not to count as pending")
+	}
+}
+
+func TestSQLiteStore_ListAndDelete(t *testing.T) {
+	s := setupSQLiteStore(t)
+	base := time.Now()
+
+	s.Create(newRegistration("req-1", "alpha", "alpha@company.com", base))
+	s.Create(newRegistration("req-2", "bravo", "bravo@company.com", base.Add(time.Minute)))
+	approved := newRegistration("req-3", "charlie", "charlie@company.com", base.Add(2*time.Minute))
+	approved.Status = model.StatusApproved
+	s.Create(approved)
+
+	all, err := s.List(nil)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(all))
+	}
+	if all[0].ID != "req-3" || all[2].ID != "req-1" {
+		t.Errorf("Expected newest first, got %s..%s", all[0].ID, all[2].ID)
+	}
+
+	status := model.StatusPending
+	pending, err := s.List(&status)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Errorf("Expected 2 pending requests, got %d", len(pending))
+	}
+
+	if err := s.Delete("req-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Delete("req-1"); err == nil {
+		t.Error("Expected error deleting missing request")
+	}
+}
+
+func TestSQLiteStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "basphere.db")
+
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	s.Create(newRegistration("req-1", "hong", "hong@company.com", time.Now()))
+	s.Close()
+
+	// Reopening must not re-apply migrations or lose data
+	s, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.Get("req-1"); err != nil {
+		t.Errorf("Expected request to persist, got: %v", err)
+	}
+}
+
+func TestSQLiteStore_PathWithURIChars(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data?x=1#y")
+	path := filepath.Join(dir, "basphere 100%.db")
+
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Create(newRegistration("req-1", "hong", "hong@company.com", time.Now())); err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	// The database must be created at the literal path, not a path cut at ? or #
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected database at %s: %v", path, err)
+	}
+}
+
+// =============================================================================
+// Key Change Request Tests
+// =============================================================================
+
+func TestSQLiteKeyChangeStore(t *testing.T) {
+	kc := setupSQLiteStore(t).KeyChanges()
+	now := time.Now()
+
+	req := &model.KeyChangeRequest{
+		ID:           "kc-1",
+		Username:     "hong",
+		Email:        "hong@company.com",
+		NewPublicKey: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl new",
+		Reason:       "lost laptop",
+		Status:       model.StatusPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := kc.Create(req); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	dup := *req
+	dup.ID = "kc-2"
+	if err := kc.Create(&dup); err == nil {
+		t.Error("Expected error for second pending key change")
+	}
+
+	got, err := kc.GetByUsername("hong")
+	if err != nil {
+		t.Fatalf("GetByUsername failed: %v", err)
+	}
+	if got.Reason != "lost laptop" {
+		t.Errorf("Expected reason 'lost laptop', got %q", got.Reason)
+	}
+
+	got.Status = model.StatusApproved
+	got.ProcessedBy = "opsadmin"
+	if err := kc.Update(got); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// Only pending requests are returned by username
+	if _, err := kc.GetByUsername("hong"); err == nil {
+		t.Error("Expected no pending key change after approval")
+	}
+
+	stored, err := kc.Get("kc-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if stored.ProcessedBy != "opsadmin" {
+		t.Errorf("Expected processed_by opsadmin, got %q", stored.ProcessedBy)
+	}
+}
+
+// =============================================================================
+// Import Tests
+// =============================================================================
+
+func TestImport_FileToSQLite(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file store: %v", err)
+	}
+	fileKeyChanges, err := NewFileKeyChangeStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file key change store: %v", err)
+	}
+
+	now := time.Now()
+	approved := newRegistration("req-1", "hong", "hong@company.com", now.Add(-time.Hour))
+	approved.Status = model.StatusApproved
+	files.Create(approved)
+	files.Create(newRegistration("req-2", "kim", "kim@company.com", now))
+	fileKeyChanges.Create(&model.KeyChangeRequest{
+		ID: "kc-1", Username: "hong", Email: "hong@company.com", NewPublicKey: approved.PublicKey,
+		Status: model.StatusPending, CreatedAt: now, UpdatedAt: now,
+	})
+
+	db := setupSQLiteStore(t)
+	result, err := Import(db, db.KeyChanges(), files, fileKeyChanges)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Requests != 2 || result.KeyChanges != 1 || result.Skipped != 0 {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+
+	got, err := db.GetByUsername("kim")
+	if err != nil || got.Status != model.StatusPending {
+		t.Errorf("Expected pending request for kim, got %+v (%v)", got, err)
+	}
+	if _, err := db.KeyChanges().GetByUsername("hong"); err != nil {
+		t.Errorf("Expected pending key change for hong: %v", err)
+	}
+
+	// Re-running skips requests that were already imported
+	result, err = Import(db, db.KeyChanges(), files, fileKeyChanges)
+	if err != nil {
+		t.Fatalf("Second import failed: %v", err)
+	}
+	if result.Requests != 0 || result.KeyChanges != 0 || result.Skipped != 3 {
+		t.Errorf("Expected all requests to be skipped, got %+v", result)
+	}
+}
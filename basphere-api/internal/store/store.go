@@ -31,3 +31,24 @@ type Store interface {
 	// ExistsEmail checks if an email already has a pending request
 	ExistsEmail(email string) (bool, error)
 }
+
+// KeyChangeStore defines the interface for storing SSH key change requests
+type KeyChangeStore interface {
+	// Create creates a new key change request
+	Create(req *model.KeyChangeRequest) error
+
+	// Get retrieves a key change request by ID
+	Get(id string) (*model.KeyChangeRequest, error)
+
+	// GetByUsername retrieves the pending key change request for a username
+	GetByUsername(username string) (*model.KeyChangeRequest, error)
+
+	// List returns all key change requests with optional status filter
+	List(status *model.RequestStatus) ([]*model.KeyChangeRequest, error)
+
+	// Update updates a key change request
+	Update(req *model.KeyChangeRequest) error
+
+	// Delete deletes a key change request
+	Delete(id string) error
+}
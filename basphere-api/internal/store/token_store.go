@@ -0,0 +1,161 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// TokenStore implements storage for API tokens
+// Each token is stored as <sha256(token)>.json so lookups by raw token are a single file read
+type TokenStore struct {
+	baseDir string
+	mu      sync.RWMutex
+}
+
+// NewTokenStore creates a new token store
+func NewTokenStore(baseDir string) (*TokenStore, error) {
+	tokenDir := filepath.Join(baseDir, "tokens")
+	if err := os.MkdirAll(tokenDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create token directory: %w", err)
+	}
+
+	return &TokenStore{
+		baseDir: tokenDir,
+	}, nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of a raw token
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *TokenStore) filePath(hash string) string {
+	return filepath.Join(s.baseDir, hash+".json")
+}
+
+// Create stores a new API token
+func (s *TokenStore) Create(token *model.APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.filePath(token.TokenHash)); err == nil {
+		return fmt.Errorf("token already exists")
+	}
+
+	return s.writeToken(token)
+}
+
+// GetByToken retrieves a token by its raw value
+func (s *TokenStore) GetByToken(raw string) (*model.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readToken(HashToken(raw))
+}
+
+// ListByUsername returns all tokens issued to a user
+func (s *TokenStore) ListByUsername(username string) ([]*model.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens, err := s.listAllUnsafe()
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []*model.APIToken
+	for _, token := range tokens {
+		if token.Username == username {
+			filtered = append(filtered, token)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Delete revokes a token by ID, restricted to the given owner
+func (s *TokenStore) Delete(username, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.listAllUnsafe()
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if token.ID == id && token.Username == username {
+			return os.Remove(s.filePath(token.TokenHash))
+		}
+	}
+
+	return fmt.Errorf("token not found: %s", id)
+}
+
+// Internal methods
+
+func (s *TokenStore) readToken(hash string) (*model.APIToken, error) {
+	data, err := os.ReadFile(s.filePath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("token not found")
+		}
+		return nil, err
+	}
+
+	var token model.APIToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	return &token, nil
+}
+
+func (s *TokenStore) writeToken(token *model.APIToken) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+
+	if err := os.WriteFile(s.filePath(token.TokenHash), data, 0600); err != nil {
+		return fmt.Errorf("failed to write token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TokenStore) listAllUnsafe() ([]*model.APIToken, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token directory: %w", err)
+	}
+
+	var tokens []*model.APIToken
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		hash := entry.Name()[:len(entry.Name())-5]
+		token, err := s.readToken(hash)
+		if err != nil {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+
+	return tokens, nil
+}
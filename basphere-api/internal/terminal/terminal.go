@@ -0,0 +1,196 @@
+// Package terminal opens interactive SSH sessions on VMs through the bastion for the web terminal.
+//
+// A session is the equivalent of "ssh -J user@bastion login@vm": an SSH connection to the
+// bastion, a direct-tcpip channel from there to the VM and a second SSH connection over
+// that channel running a login shell on a PTY.
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DialTimeout bounds connecting and signing in to the bastion and the VM
+const DialTimeout = 15 * time.Second
+
+// TermType is the terminal type announced to the VM (xterm.js is xterm compatible)
+const TermType = "xterm-256color"
+
+// Target describes where a session goes and as whom
+type Target struct {
+	// Bastion sshd, host:port
+	BastionAddr string
+	BastionUser string
+	// Verifies the bastion host key
+	BastionHostKey ssh.HostKeyCallback
+	// VM sshd as seen from the bastion, host:port
+	Addr string
+	User string
+	// Signs in to both the bastion and the VM
+	Signer ssh.Signer
+}
+
+// Session is a shell running on a VM
+type Session struct {
+	bastion *ssh.Client
+	client  *ssh.Client
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  io.Reader
+
+	closeOnce sync.Once
+}
+
+// Open connects to the VM through the bastion and starts a login shell on a cols x rows PTY
+// Cancelling ctx while connecting aborts the attempt; once open, the session outlives ctx.
+func Open(ctx context.Context, target Target, cols, rows int) (*Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, DialTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", target.BastionAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bastion: %w", err)
+	}
+
+	// Closing the bastion connection unblocks every handshake below
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	s, err := open(conn, target, cols, rows)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w (%v)", err, ctx.Err())
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+func open(conn net.Conn, target Target, cols, rows int) (*Session, error) {
+	auth := []ssh.AuthMethod{ssh.PublicKeys(target.Signer)}
+
+	bastionConn, chans, reqs, err := ssh.NewClientConn(conn, target.BastionAddr, &ssh.ClientConfig{
+		User:            target.BastionUser,
+		Auth:            auth,
+		HostKeyCallback: target.BastionHostKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign in to bastion: %w", err)
+	}
+	bastion := ssh.NewClient(bastionConn, chans, reqs)
+
+	vmConn, err := bastion.Dial("tcp", target.Addr)
+	if err != nil {
+		bastion.Close()
+		return nil, fmt.Errorf("failed to reach VM through bastion: %w", err)
+	}
+
+	clientConn, chans, reqs, err := ssh.NewClientConn(vmConn, target.Addr, &ssh.ClientConfig{
+		User: target.User,
+		Auth: auth,
+		// VM IPs are reused after deletion and the hop is inside the authenticated bastion
+		// connection, so VM host keys are not pinned (as in the generated SSH config for Windows)
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		vmConn.Close()
+		bastion.Close()
+		return nil, fmt.Errorf("failed to sign in to VM: %w", err)
+	}
+	client := ssh.NewClient(clientConn, chans, reqs)
+
+	s := &Session{bastion: bastion, client: client}
+	if err := s.start(cols, rows); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Session) start(cols, rows int) error {
+	session, err := s.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	s.session = session
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(TermType, rows, cols, modes); err != nil {
+		return fmt.Errorf("failed to allocate terminal: %w", err)
+	}
+
+	if s.stdin, err = session.StdinPipe(); err != nil {
+		return err
+	}
+	// With a PTY the shell's stderr arrives on stdout
+	if s.stdout, err = session.StdoutPipe(); err != nil {
+		return err
+	}
+
+	if err := session.Shell(); err != nil {
+		return fmt.Errorf("failed to start shell: %w", err)
+	}
+	return nil
+}
+
+// Read reads terminal output; it returns io.EOF once the shell exited
+func (s *Session) Read(p []byte) (int, error) {
+	return s.stdout.Read(p)
+}
+
+// Write sends keyboard input to the shell
+func (s *Session) Write(p []byte) (int, error) {
+	return s.stdin.Write(p)
+}
+
+// Resize changes the PTY size
+func (s *Session) Resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", cols, rows)
+	}
+	return s.session.WindowChange(rows, cols)
+}
+
+// Wait waits for the shell to exit and returns its exit status
+// The status is -1 if the VM closed the session without reporting one.
+func (s *Session) Wait() (int, error) {
+	err := s.session.Wait()
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+	var missing *ssh.ExitMissingError
+	if errors.As(err, &missing) {
+		return -1, nil
+	}
+	return -1, err
+}
+
+// Close ends the session and both connections
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		if s.session != nil {
+			s.session.Close()
+		}
+		s.client.Close()
+		s.bastion.Close()
+	})
+	return nil
+}
@@ -0,0 +1,174 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/basphere/basphere-api/internal/sshca"
+	"github.com/basphere/basphere-api/internal/terminal/terminaltest"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+// setupServers starts a bastion routing 10.254.0.10:22 to a VM, both trusting a new CA
+func setupServers(t *testing.T) (*sshca.Authority, *terminaltest.Server) {
+	t.Helper()
+	ca, err := sshca.LoadOrCreate(filepath.Join(t.TempDir(), "user_ca"))
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	bastion := terminaltest.NewServer(ca.PublicKey())
+	t.Cleanup(bastion.Close)
+	vm := terminaltest.NewServer(ca.PublicKey())
+	t.Cleanup(vm.Close)
+	bastion.Route("10.254.0.10:22", vm.Addr)
+
+	return ca, bastion
+}
+
+func newTarget(t *testing.T, ca *sshca.Authority, bastion *terminaltest.Server, username string) Target {
+	t.Helper()
+	signer, err := ca.SessionSigner(username, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return Target{
+		BastionAddr:    bastion.Addr,
+		BastionUser:    username,
+		BastionHostKey: ssh.FixedHostKey(bastion.HostKey),
+		Addr:           "10.254.0.10:22",
+		User:           username,
+		Signer:         signer,
+	}
+}
+
+// readUntil reads session output until it contains want
+func readUntil(t *testing.T, s *Session, want string) {
+	t.Helper()
+	var output bytes.Buffer
+	buf := make([]byte, 1024)
+	for !strings.Contains(output.String(), want) {
+		n, err := s.Read(buf)
+		output.Write(buf[:n])
+		if err != nil {
+			t.Fatalf("Expected output %q, got %q (%v)", want, output.String(), err)
+		}
+	}
+}
+
+// =============================================================================
+// Session Tests
+// =============================================================================
+
+func TestOpen(t *testing.T) {
+	ca, bastion := setupServers(t)
+
+	s, err := Open(context.Background(), newTarget(t, ca, bastion, "alice"), 80, 24)
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+	defer s.Close()
+
+	readUntil(t, s, "Welcome alice")
+
+	if err := s.Resize(100, 30); err != nil {
+		t.Fatalf("Failed to resize: %v", err)
+	}
+	readUntil(t, s, "[size 100x30]")
+
+	if err := s.Resize(0, 30); err == nil {
+		t.Error("Expected error for invalid size")
+	}
+
+	if _, err := s.Write([]byte("exit 7\r")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	readUntil(t, s, "exit 7")
+
+	code, err := s.Wait()
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if code != 7 {
+		t.Errorf("Expected exit status 7, got %d", code)
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	ca, bastion := setupServers(t)
+	other, err := sshca.LoadOrCreate(filepath.Join(t.TempDir(), "other_ca"))
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	otherSigner, err := other.SessionSigner("alice", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Target)
+		wantErr string
+	}{
+		{
+			name:    "untrusted CA",
+			modify:  func(target *Target) { target.Signer = otherSigner },
+			wantErr: "failed to sign in to bastion",
+		},
+		{
+			name: "bastion host key mismatch",
+			modify: func(target *Target) {
+				target.BastionHostKey = ssh.FixedHostKey(otherSigner.PublicKey())
+			},
+			wantErr: "failed to sign in to bastion",
+		},
+		{
+			name:    "unknown VM",
+			modify:  func(target *Target) { target.Addr = "10.254.0.99:22" },
+			wantErr: "failed to reach VM",
+		},
+		{
+			name:    "bastion unreachable",
+			modify:  func(target *Target) { target.BastionAddr = "127.0.0.1:1" },
+			wantErr: "failed to connect to bastion",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newTarget(t, ca, bastion, "alice")
+			tt.modify(&target)
+
+			s, err := Open(context.Background(), target, 80, 24)
+			if err == nil {
+				s.Close()
+				t.Fatal("Expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOpen_Cancelled(t *testing.T) {
+	ca, bastion := setupServers(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s, err := Open(ctx, newTarget(t, ca, bastion, "alice"), 80, 24)
+	if err == nil {
+		s.Close()
+		t.Fatal("Expected error for cancelled context")
+	}
+}
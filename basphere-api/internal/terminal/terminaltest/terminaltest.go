@@ -0,0 +1,250 @@
+// Package terminaltest provides an in-process SSH server standing in for the bastion and VMs in tests.
+// It accepts user certificates signed by a given CA, forwards direct-tcpip channels to routed
+// addresses and runs a small line-echo shell on PTY sessions:
+//
+//	exit [n]   ends the session with exit status n (default 0)
+//
+// Window size changes are printed as "[size COLSxROWS]".
+package terminaltest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Server is an SSH server listening on a random local port
+type Server struct {
+	// Address the server listens on (host:port)
+	Addr string
+	// Host key presented to clients
+	HostKey ssh.PublicKey
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu     sync.Mutex
+	routes map[string]string
+	logins []string
+	wg     sync.WaitGroup
+}
+
+// NewServer starts a server trusting user certificates signed by caPublicKey (authorized_keys format)
+func NewServer(caPublicKey string) *Server {
+	ca, _, _, _, err := ssh.ParseAuthorizedKey([]byte(caPublicKey))
+	if err != nil {
+		panic("terminaltest: invalid CA key: " + err.Error())
+	}
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic("terminaltest: failed to generate host key: " + err.Error())
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		panic("terminaltest: failed to load host key: " + err.Error())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("terminaltest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		HostKey:  hostSigner.PublicKey(),
+		listener: listener,
+		routes:   make(map[string]string),
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.Marshal())
+		},
+	}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			perms, err := checker.Authenticate(conn, key)
+			if err != nil {
+				return nil, err
+			}
+			s.mu.Lock()
+			s.logins = append(s.logins, conn.User())
+			s.mu.Unlock()
+			return perms, nil
+		},
+	}
+	s.config.AddHostKey(hostSigner)
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Route forwards direct-tcpip channels for target (host:port) to addr
+func (s *Server) Route(target, addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[target] = addr
+}
+
+// Logins returns the users that signed in, in order
+func (s *Server) Logins() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logins...)
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.handleSession(sconn.User(), newChannel)
+		case "direct-tcpip":
+			go s.handleForward(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// handleForward connects a direct-tcpip channel to its routed address
+func (s *Server) handleForward(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+		return
+	}
+
+	target := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	s.mu.Lock()
+	addr, ok := s.routes[target]
+	s.mu.Unlock()
+	if !ok {
+		newChannel.Reject(ssh.ConnectionFailed, "no route to "+target)
+		return
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		io.Copy(conn, channel)
+		conn.Close()
+	}()
+	io.Copy(channel, conn)
+	channel.Close()
+}
+
+// handleSession runs the line-echo shell
+func (s *Server) handleSession(user string, newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	// Closed when the shell starts or the channel goes away without one
+	started := make(chan struct{})
+	var startOnce sync.Once
+	start := func() { startOnce.Do(func() { close(started) }) }
+	go func() {
+		defer start()
+		for req := range reqs {
+			switch req.Type {
+			case "pty-req":
+				req.Reply(true, nil)
+			case "shell":
+				req.Reply(true, nil)
+				start()
+			case "window-change":
+				if len(req.Payload) >= 8 {
+					cols := binary.BigEndian.Uint32(req.Payload[0:4])
+					rows := binary.BigEndian.Uint32(req.Payload[4:8])
+					fmt.Fprintf(channel, "[size %dx%d]\r\n", cols, rows)
+				}
+			default:
+				req.Reply(false, nil)
+			}
+		}
+	}()
+	<-started
+
+	fmt.Fprintf(channel, "Welcome %s\r\n$ ", user)
+
+	var line strings.Builder
+	buf := make([]byte, 256)
+	for {
+		n, err := channel.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, c := range buf[:n] {
+			if c != '\r' && c != '\n' {
+				line.WriteByte(c)
+				channel.Write([]byte{c})
+				continue
+			}
+
+			channel.Write([]byte("\r\n"))
+			fields := strings.Fields(line.String())
+			line.Reset()
+			if len(fields) > 0 && fields[0] == "exit" {
+				status := 0
+				if len(fields) > 1 {
+					status, _ = strconv.Atoi(fields[1])
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+				return
+			}
+			channel.Write([]byte("$ "))
+		}
+	}
+}
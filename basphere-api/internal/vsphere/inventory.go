@@ -0,0 +1,292 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// DefaultCacheTTL is how long an inventory read is reused when no TTL is configured
+const DefaultCacheTTL = 15 * time.Second
+
+// VMState is what vCenter reports about a VM in the basphere folder
+type VMState struct {
+	// User folder the VM is in (empty when directly in the basphere folder)
+	Owner string
+	// vSphere VM name (<user>-<vm> for VMs created by basphere)
+	Name        string
+	PowerState  types.VirtualMachinePowerState
+	GuestIP     string
+	ToolsStatus string
+	Host        string
+	BootTime    *time.Time
+	Template    bool
+	// Managed object of the VM in vCenter
+	Ref types.ManagedObjectReference
+}
+
+// Snapshot is the basphere folder as read from vCenter at one point in time
+type Snapshot struct {
+	VMs    []VMState
+	ReadAt time.Time
+
+	byName map[string]int
+}
+
+// NewSnapshot indexes VMs read at readAt by owner and vSphere name
+func NewSnapshot(vms []VMState, readAt time.Time) *Snapshot {
+	s := &Snapshot{VMs: vms, ReadAt: readAt, byName: make(map[string]int, len(vms))}
+	for i, vm := range vms {
+		s.byName[vm.Owner+"/"+vm.Name] = i
+	}
+	return s
+}
+
+// Lookup returns the VM named name in owner's folder
+func (s *Snapshot) Lookup(owner, name string) (VMState, bool) {
+	i, ok := s.byName[owner+"/"+name]
+	if !ok {
+		return VMState{}, false
+	}
+	return s.VMs[i], true
+}
+
+// VMName returns the vSphere name of a VM record
+// VMs created before vsphere_vm_name was recorded are named <owner>-<name>.
+func VMName(vm *model.VM) string {
+	if vm.VsphereVMName != "" {
+		return vm.VsphereVMName
+	}
+	return vm.Owner + "-" + vm.Name
+}
+
+// LiveState returns the live state of a VM record as of now
+// A record without a VM in its user folder is reported with Found false.
+func (s *Snapshot) LiveState(vm *model.VM, now time.Time) *model.VMLiveState {
+	live := &model.VMLiveState{ObservedAt: s.ReadAt}
+	state, ok := s.Lookup(vm.Owner, VMName(vm))
+	if !ok || state.Template {
+		return live
+	}
+
+	live.Found = true
+	live.PowerState = string(state.PowerState)
+	live.GuestIP = state.GuestIP
+	live.ToolsStatus = state.ToolsStatus
+	live.Host = state.Host
+	if state.PowerState == types.VirtualMachinePowerStatePoweredOn && state.BootTime != nil {
+		live.BootTime = state.BootTime
+		if uptime := now.Sub(*state.BootTime); uptime > 0 {
+			live.UptimeSeconds = int64(uptime / time.Second)
+		}
+	}
+	return live
+}
+
+// Inventory reads the VMs in the basphere folder and caches the result for a short TTL
+// so that VM list and detail responses do not query vCenter on every request.
+type Inventory struct {
+	session  *Session
+	settings Settings
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	snapshot *Snapshot
+	err      error
+	readAt   time.Time
+}
+
+// NewInventory creates an inventory reader for the folder in settings (ttl 0 = DefaultCacheTTL)
+func NewInventory(session *Session, settings Settings, ttl time.Duration) *Inventory {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Inventory{
+		session:  session,
+		settings: settings,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// Snapshot returns the cached inventory, reading vCenter again once it is older than the TTL
+// Failed reads are cached as well, so an unreachable vCenter does not slow down every request.
+func (i *Inventory) Snapshot(ctx context.Context) (*Snapshot, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !i.readAt.IsZero() && i.now().Sub(i.readAt) < i.ttl {
+		return i.snapshot, i.err
+	}
+	return i.refreshLocked(ctx)
+}
+
+// Refresh reads vCenter regardless of the cache
+func (i *Inventory) Refresh(ctx context.Context) (*Snapshot, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.refreshLocked(ctx)
+}
+
+func (i *Inventory) refreshLocked(ctx context.Context) (*Snapshot, error) {
+	i.snapshot, i.err = i.read(ctx)
+	i.readAt = i.now()
+	return i.snapshot, i.err
+}
+
+// read lists the VMs below the basphere folder with their user folder and host
+func (i *Inventory) read(ctx context.Context) (*Snapshot, error) {
+	c, err := i.session.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	readAt := i.now()
+
+	ref, err := object.NewSearchIndex(c).FindByInventoryPath(ctx, i.settings.FolderPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find VM folder: %w", err)
+	}
+	if ref == nil {
+		// No VM was created yet
+		return NewSnapshot([]VMState{}, readAt), nil
+	}
+	root := ref.Reference()
+
+	v, err := view.NewManager(c).CreateContainerView(ctx, root, []string{"Folder", "VirtualMachine"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(context.Background())
+
+	var folders []mo.Folder
+	if err := v.Retrieve(ctx, []string{"Folder"}, []string{"name", "parent"}, &folders); err != nil {
+		return nil, fmt.Errorf("failed to read folders: %w", err)
+	}
+	var vms []mo.VirtualMachine
+	props := []string{"name", "parent", "config.template", "runtime.powerState", "runtime.host", "runtime.bootTime", "guest.ipAddress", "guest.toolsRunningStatus"}
+	if err := v.Retrieve(ctx, []string{"VirtualMachine"}, props, &vms); err != nil {
+		return nil, fmt.Errorf("failed to read VMs: %w", err)
+	}
+
+	// The user folder of a VM is its ancestor directly below the basphere folder
+	parents := make(map[types.ManagedObjectReference]mo.Folder, len(folders))
+	for _, f := range folders {
+		parents[f.Self] = f
+	}
+	owner := func(parent *types.ManagedObjectReference) string {
+		for parent != nil && *parent != root {
+			f, ok := parents[*parent]
+			if !ok {
+				return ""
+			}
+			if f.Parent != nil && *f.Parent == root {
+				return f.Name
+			}
+			parent = f.Parent
+		}
+		return ""
+	}
+
+	hosts, err := hostNames(ctx, c, vms)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]VMState, 0, len(vms))
+	for _, vm := range vms {
+		state := VMState{
+			Owner:      owner(vm.Parent),
+			Name:       vm.Name,
+			PowerState: vm.Runtime.PowerState,
+			BootTime:   vm.Runtime.BootTime,
+			Ref:        vm.Self,
+		}
+		if vm.Config != nil {
+			state.Template = vm.Config.Template
+		}
+		if vm.Runtime.Host != nil {
+			state.Host = hosts[*vm.Runtime.Host]
+		}
+		if vm.Guest != nil {
+			state.GuestIP = vm.Guest.IpAddress
+			state.ToolsStatus = vm.Guest.ToolsRunningStatus
+		}
+		states = append(states, state)
+	}
+	return NewSnapshot(states, readAt), nil
+}
+
+// Destroy powers off and deletes a VM read by the inventory and drops the cached snapshot
+// It is meant for VMs basphere has no record of; recorded VMs are deleted through the provisioner.
+func (i *Inventory) Destroy(ctx context.Context, vm VMState) error {
+	c, err := i.session.Client(ctx)
+	if err != nil {
+		return err
+	}
+	obj := object.NewVirtualMachine(c, vm.Ref)
+
+	state, err := obj.PowerState(ctx)
+	if err != nil {
+		return err
+	}
+	if state != types.VirtualMachinePowerStatePoweredOff {
+		if err := waitTask(ctx, obj.PowerOff); err != nil {
+			return fmt.Errorf("power off failed: %w", err)
+		}
+	}
+	if err := waitTask(ctx, obj.Destroy); err != nil {
+		return fmt.Errorf("destroy failed: %w", err)
+	}
+
+	i.mu.Lock()
+	i.readAt = time.Time{}
+	i.mu.Unlock()
+	return nil
+}
+
+// waitTask starts a vCenter task and waits for it to finish
+func waitTask(ctx context.Context, start func(context.Context) (*object.Task, error)) error {
+	task, err := start(ctx)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// hostNames resolves the ESXi hosts the VMs run on
+func hostNames(ctx context.Context, c *vim25.Client, vms []mo.VirtualMachine) (map[types.ManagedObjectReference]string, error) {
+	seen := make(map[types.ManagedObjectReference]bool)
+	var refs []types.ManagedObjectReference
+	for _, vm := range vms {
+		if ref := vm.Runtime.Host; ref != nil && !seen[*ref] {
+			seen[*ref] = true
+			refs = append(refs, *ref)
+		}
+	}
+
+	names := make(map[types.ManagedObjectReference]string, len(refs))
+	if len(refs) == 0 {
+		return names, nil
+	}
+
+	var hosts []mo.HostSystem
+	if err := property.DefaultCollector(c).Retrieve(ctx, refs, []string{"name"}, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to read hosts: %w", err)
+	}
+	for _, h := range hosts {
+		names[h.Self] = h.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,297 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/basphere/basphere-api/internal/model"
+)
+
+// =============================================================================
+// Test Helper Functions
+// =============================================================================
+
+// testSettings points at the default vcsim inventory
+func testSettings() Settings {
+	return Settings{Datacenter: "DC0", Folder: "basphere-vms"}
+}
+
+// setupInventory runs fn against an in-process vCenter simulator with this layout:
+//
+//	basphere-vms/alice/alice-web    (powered on)
+//	basphere-vms/alice/old/alice-db (powered off, nested folder)
+//	basphere-vms/bob/bob-api        (powered on)
+//	basphere-vms/stray              (powered on, not in a user folder)
+func setupInventory(t *testing.T, fn func(ctx context.Context, inv *Inventory, c *vim25.Client)) {
+	t.Helper()
+
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		finder := find.NewFinder(c, true)
+		dc, err := finder.Datacenter(ctx, "DC0")
+		if err != nil {
+			t.Fatalf("Failed to find datacenter: %v", err)
+		}
+		finder.SetDatacenter(dc)
+		folders, err := dc.Folders(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read folders: %v", err)
+		}
+
+		root := createFolder(ctx, t, folders.VmFolder, "basphere-vms")
+		alice := createFolder(ctx, t, root, "alice")
+		aliceOld := createFolder(ctx, t, alice, "old")
+		bob := createFolder(ctx, t, root, "bob")
+
+		moveVM(ctx, t, finder, "DC0_C0_RP0_VM0", alice, "alice-web")
+		db := moveVM(ctx, t, finder, "DC0_C0_RP0_VM1", aliceOld, "alice-db")
+		moveVM(ctx, t, finder, "DC0_H0_VM0", bob, "bob-api")
+		moveVM(ctx, t, finder, "DC0_H0_VM1", root, "stray")
+
+		if err := waitTask(ctx, db.PowerOff); err != nil {
+			t.Fatalf("Failed to power off: %v", err)
+		}
+
+		login := func(context.Context) (*vim25.Client, error) { return c, nil }
+		fn(ctx, NewInventory(NewSession(login), testSettings(), time.Minute), c)
+	})
+}
+
+func createFolder(ctx context.Context, t *testing.T, parent *object.Folder, name string) *object.Folder {
+	t.Helper()
+
+	folder, err := parent.CreateFolder(ctx, name)
+	if err != nil {
+		t.Fatalf("Failed to create folder %s: %v", name, err)
+	}
+	return folder
+}
+
+// moveVM moves a simulator VM into folder and renames it
+func moveVM(ctx context.Context, t *testing.T, finder *find.Finder, name string, folder *object.Folder, newName string) *object.VirtualMachine {
+	t.Helper()
+
+	vm, err := finder.VirtualMachine(ctx, name)
+	if err != nil {
+		t.Fatalf("Failed to find %s: %v", name, err)
+	}
+	if err := waitTask(ctx, func(ctx context.Context) (*object.Task, error) {
+		return folder.MoveInto(ctx, []types.ManagedObjectReference{vm.Reference()})
+	}); err != nil {
+		t.Fatalf("Failed to move %s: %v", name, err)
+	}
+	if err := waitTask(ctx, func(ctx context.Context) (*object.Task, error) {
+		return vm.Rename(ctx, newName)
+	}); err != nil {
+		t.Fatalf("Failed to rename %s: %v", name, err)
+	}
+	return vm
+}
+
+// =============================================================================
+// Inventory Tests
+// =============================================================================
+
+func TestInventory_Snapshot(t *testing.T) {
+	setupInventory(t, func(ctx context.Context, inv *Inventory, c *vim25.Client) {
+		snapshot, err := inv.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		if len(snapshot.VMs) != 4 {
+			t.Fatalf("Expected 4 VMs, got %+v", snapshot.VMs)
+		}
+
+		tests := []struct {
+			owner string
+			name  string
+			power types.VirtualMachinePowerState
+		}{
+			{"alice", "alice-web", types.VirtualMachinePowerStatePoweredOn},
+			{"alice", "alice-db", types.VirtualMachinePowerStatePoweredOff},
+			{"bob", "bob-api", types.VirtualMachinePowerStatePoweredOn},
+			{"", "stray", types.VirtualMachinePowerStatePoweredOn},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				state, ok := snapshot.Lookup(tt.owner, tt.name)
+				if !ok {
+					t.Fatalf("Expected %s/%s in the snapshot", tt.owner, tt.name)
+				}
+				if state.PowerState != tt.power {
+					t.Errorf("Expected %s, got %s", tt.power, state.PowerState)
+				}
+				if state.Host == "" {
+					t.Error("Expected the host name to be resolved")
+				}
+			})
+		}
+
+		if _, ok := snapshot.Lookup("bob", "alice-web"); ok {
+			t.Error("Expected VMs to be looked up in their owner's folder only")
+		}
+	})
+}
+
+func TestInventory_CachesForTTL(t *testing.T) {
+	setupInventory(t, func(ctx context.Context, inv *Inventory, c *vim25.Client) {
+		now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		inv.now = func() time.Time { return now }
+
+		if _, err := inv.Snapshot(ctx); err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+
+		vm, err := find.NewFinder(c, true).VirtualMachine(ctx, "/DC0/vm/basphere-vms/alice/alice-web")
+		if err != nil {
+			t.Fatalf("Failed to find VM: %v", err)
+		}
+		if err := waitTask(ctx, vm.PowerOff); err != nil {
+			t.Fatalf("Failed to power off: %v", err)
+		}
+
+		powerState := func(s *Snapshot) types.VirtualMachinePowerState {
+			state, _ := s.Lookup("alice", "alice-web")
+			return state.PowerState
+		}
+
+		now = now.Add(30 * time.Second)
+		snapshot, _ := inv.Snapshot(ctx)
+		if got := powerState(snapshot); got != types.VirtualMachinePowerStatePoweredOn {
+			t.Errorf("Expected the cached poweredOn within the TTL, got %s", got)
+		}
+
+		now = now.Add(time.Minute)
+		snapshot, _ = inv.Snapshot(ctx)
+		if got := powerState(snapshot); got != types.VirtualMachinePowerStatePoweredOff {
+			t.Errorf("Expected poweredOff after the TTL, got %s", got)
+		}
+		if !snapshot.ReadAt.Equal(now) {
+			t.Errorf("Expected ReadAt %v, got %v", now, snapshot.ReadAt)
+		}
+
+		if err := waitTask(ctx, vm.PowerOn); err != nil {
+			t.Fatalf("Failed to power on: %v", err)
+		}
+		snapshot, _ = inv.Refresh(ctx)
+		if got := powerState(snapshot); got != types.VirtualMachinePowerStatePoweredOn {
+			t.Errorf("Expected Refresh to bypass the cache, got %s", got)
+		}
+	})
+}
+
+func TestInventory_CachesErrors(t *testing.T) {
+	logins := 0
+	login := func(context.Context) (*vim25.Client, error) {
+		logins++
+		return nil, errors.New("connection refused")
+	}
+	inv := NewInventory(NewSession(login), testSettings(), 0)
+
+	for i := 0; i < 3; i++ {
+		if _, err := inv.Snapshot(context.Background()); err == nil {
+			t.Fatal("Expected an error")
+		}
+	}
+	if logins != 1 {
+		t.Errorf("Expected one login attempt within the TTL, got %d", logins)
+	}
+}
+
+func TestInventory_NoFolder(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		login := func(context.Context) (*vim25.Client, error) { return c, nil }
+		inv := NewInventory(NewSession(login), testSettings(), 0)
+
+		snapshot, err := inv.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		if len(snapshot.VMs) != 0 {
+			t.Errorf("Expected no VMs, got %+v", snapshot.VMs)
+		}
+	})
+}
+
+func TestInventory_Destroy(t *testing.T) {
+	setupInventory(t, func(ctx context.Context, inv *Inventory, c *vim25.Client) {
+		snapshot, err := inv.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+
+		// Powered on and powered off VMs
+		for _, vm := range []struct{ owner, name string }{{"", "stray"}, {"alice", "alice-db"}} {
+			state, ok := snapshot.Lookup(vm.owner, vm.name)
+			if !ok {
+				t.Fatalf("Expected %s/%s in the snapshot", vm.owner, vm.name)
+			}
+			if err := inv.Destroy(ctx, state); err != nil {
+				t.Fatalf("Destroy(%s) failed: %v", vm.name, err)
+			}
+		}
+
+		// The cache is dropped, so the next read no longer has the VMs
+		snapshot, err = inv.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		if len(snapshot.VMs) != 2 {
+			t.Errorf("Expected 2 VMs after destroying two, got %+v", snapshot.VMs)
+		}
+		if _, ok := snapshot.Lookup("", "stray"); ok {
+			t.Error("Expected stray to be destroyed")
+		}
+	})
+}
+
+// =============================================================================
+// Live State Tests
+// =============================================================================
+
+func TestSnapshot_LiveState(t *testing.T) {
+	readAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	bootTime := readAt.Add(-2 * time.Hour)
+	snapshot := NewSnapshot([]VMState{
+		{Owner: "alice", Name: "alice-web", PowerState: types.VirtualMachinePowerStatePoweredOn, GuestIP: "10.254.0.10",
+			ToolsStatus: "guestToolsRunning", Host: "esxi-01", BootTime: &bootTime},
+		{Owner: "alice", Name: "alice-db", PowerState: types.VirtualMachinePowerStatePoweredOff, Host: "esxi-02", BootTime: &bootTime},
+		{Owner: "alice", Name: "alice-tmpl", PowerState: types.VirtualMachinePowerStatePoweredOff, Template: true},
+	}, readAt)
+	now := readAt.Add(10 * time.Second)
+
+	web := snapshot.LiveState(&model.VM{Name: "web", VsphereVMName: "alice-web", Owner: "alice"}, now)
+	if !web.Found || web.PowerState != "poweredOn" || web.GuestIP != "10.254.0.10" || web.ToolsStatus != "guestToolsRunning" || web.Host != "esxi-01" {
+		t.Errorf("Unexpected live state: %+v", web)
+	}
+	if web.UptimeSeconds != 7210 || web.BootTime == nil || !web.ObservedAt.Equal(readAt) {
+		t.Errorf("Expected uptime 7210s observed at %v, got %+v", readAt, web)
+	}
+
+	// VMs created before vsphere_vm_name was recorded use <owner>-<name>
+	db := snapshot.LiveState(&model.VM{Name: "db", Owner: "alice"}, now)
+	if !db.Found || db.PowerState != "poweredOff" {
+		t.Errorf("Unexpected live state: %+v", db)
+	}
+	if db.UptimeSeconds != 0 || db.BootTime != nil {
+		t.Errorf("Expected no uptime for a powered off VM, got %+v", db)
+	}
+
+	for _, vm := range []*model.VM{
+		{Name: "gone", Owner: "alice"},
+		{Name: "web", VsphereVMName: "alice-web", Owner: "bob"},
+		{Name: "tmpl", Owner: "alice"},
+	} {
+		if live := snapshot.LiveState(vm, now); live.Found {
+			t.Errorf("Expected %s/%s not to be found, got %+v", vm.Owner, vm.Name, live)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package vsphere connects to the vCenter managed by the CLI scripts and reads its inventory
+package vsphere
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/basphere/basphere-api/internal/config"
+)
+
+// Settings are the vsphere and network sections of the CLI config.yaml
+// and the credentials from vsphere.env. Defaults match get_config in create-vm.
+type Settings struct {
+	Server       string `yaml:"server"`
+	Datacenter   string `yaml:"datacenter"`
+	Cluster      string `yaml:"cluster"`
+	Datastore    string `yaml:"datastore"`
+	ResourcePool string `yaml:"resource_pool"`
+	Network      string `yaml:"network"`
+	Folder       string `yaml:"folder"`
+
+	MTU int      `yaml:"-"`
+	DNS []string `yaml:"-"`
+
+	User     string `yaml:"-"`
+	Password string `yaml:"-"`
+	Insecure bool   `yaml:"-"`
+}
+
+// LoadSettings reads the inventory settings and the credentials shared with the scripts
+// A missing config file yields the defaults, like get_config in common.sh.
+func LoadSettings(cfg config.VSphereConfig) (Settings, error) {
+	var file struct {
+		VSphere Settings `yaml:"vsphere"`
+		Network struct {
+			MTU int      `yaml:"mtu"`
+			DNS []string `yaml:"dns"`
+		} `yaml:"network"`
+	}
+	file.VSphere = Settings{
+		Datacenter: "DC1",
+		Cluster:    "Cluster1",
+		Datastore:  "datastore1",
+		Network:    "VM Network",
+		Folder:     "basphere-vms",
+	}
+
+	data, err := os.ReadFile(cfg.ConfigFile)
+	if err != nil && !os.IsNotExist(err) {
+		return Settings{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return Settings{}, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+
+	s := file.VSphere
+	s.MTU = file.Network.MTU
+	s.DNS = file.Network.DNS
+	if s.Server == "" {
+		return Settings{}, fmt.Errorf("vCenter server is not set (vsphere.server in %s)", cfg.ConfigFile)
+	}
+
+	env, err := readEnvFile(cfg.CredentialsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Settings{}, fmt.Errorf("vSphere credentials not found: %s", cfg.CredentialsFile)
+		}
+		return Settings{}, fmt.Errorf("failed to read vSphere credentials: %w", err)
+	}
+	s.User = env["VSPHERE_USER"]
+	s.Password = env["VSPHERE_PASSWORD"]
+	s.Insecure, _ = strconv.ParseBool(env["VSPHERE_ALLOW_UNVERIFIED_SSL"])
+
+	return s, nil
+}
+
+// readEnvFile parses the "export NAME='value'" lines of a shell environment file
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[strings.TrimSpace(name)] = value
+	}
+	return env, scanner.Err()
+}
+
+// FolderPath returns the inventory path of the basphere VM folder: /<dc>/vm/<folder>
+func (s Settings) FolderPath() string {
+	return path.Join("/", s.Datacenter, "vm", s.Folder)
+}
+
+// Login opens a vCenter session with the settings' credentials
+func (s Settings) Login(ctx context.Context) (*vim25.Client, error) {
+	u, err := soap.ParseURL(s.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vCenter server: %w", err)
+	}
+
+	c, err := vim25.NewClient(ctx, soap.NewClient(u, s.Insecure))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to vCenter: %w", err)
+	}
+
+	if err := session.NewManager(c).Login(ctx, url.UserPassword(s.User, s.Password)); err != nil {
+		return nil, fmt.Errorf("failed to log in to vCenter: %w", err)
+	}
+	return c, nil
+}
+
+// Session keeps one vCenter session and logs in again when vCenter expired it
+type Session struct {
+	login func(ctx context.Context) (*vim25.Client, error)
+
+	mu     sync.Mutex
+	client *vim25.Client
+}
+
+// NewSession creates a session that opens connections with login (usually Settings.Login)
+func NewSession(login func(ctx context.Context) (*vim25.Client, error)) *Session {
+	return &Session{login: login}
+}
+
+// Client returns a client with a valid session
+func (s *Session) Client(ctx context.Context) (*vim25.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		if us, err := session.NewManager(s.client).UserSession(ctx); err == nil && us != nil {
+			return s.client, nil
+		}
+	}
+
+	c, err := s.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.client = c
+	return c, nil
+}
@@ -0,0 +1,59 @@
+package vsphere
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/basphere/basphere-api/internal/config"
+)
+
+// =============================================================================
+// Settings Tests
+// =============================================================================
+
+func TestLoadSettings(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	credentialsFile := filepath.Join(dir, "vsphere.env")
+
+	os.WriteFile(configFile, []byte(`
+vsphere:
+  server: "vcenter.example.local"
+  datacenter: "Lab"
+network:
+  mtu: 1450
+  dns:
+    - "10.0.0.53"
+`), 0644)
+	os.WriteFile(credentialsFile, []byte(`# vCenter
+export VSPHERE_USER='administrator@vsphere.local'
+export VSPHERE_PASSWORD='Pa$$w0rd!'
+export VSPHERE_ALLOW_UNVERIFIED_SSL='true'
+`), 0600)
+
+	s, err := LoadSettings(config.VSphereConfig{ConfigFile: configFile, CredentialsFile: credentialsFile})
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if s.Server != "vcenter.example.local" || s.Datacenter != "Lab" || s.Cluster != "Cluster1" || s.Folder != "basphere-vms" {
+		t.Errorf("Unexpected inventory settings: %+v", s)
+	}
+	if s.MTU != 1450 || len(s.DNS) != 1 || s.DNS[0] != "10.0.0.53" {
+		t.Errorf("Unexpected network settings: %+v", s)
+	}
+	if s.User != "administrator@vsphere.local" || s.Password != "Pa$$w0rd!" || !s.Insecure {
+		t.Errorf("Unexpected credentials: %q %q %v", s.User, s.Password, s.Insecure)
+	}
+
+	_, err = LoadSettings(config.VSphereConfig{ConfigFile: configFile, CredentialsFile: filepath.Join(dir, "missing.env")})
+	if err == nil || !strings.Contains(err.Error(), "vSphere credentials not found") {
+		t.Errorf("Expected missing credentials error, got %v", err)
+	}
+
+	_, err = LoadSettings(config.VSphereConfig{ConfigFile: filepath.Join(dir, "missing.yaml"), CredentialsFile: credentialsFile})
+	if err == nil || !strings.Contains(err.Error(), "vCenter server is not set") {
+		t.Errorf("Expected missing server error, got %v", err)
+	}
+}
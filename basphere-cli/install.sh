@@ -62,6 +62,20 @@ install_yq() {
     fi
 }
 
+# govc 설치 (VM 전원 관리)
+install_govc() {
+    log_info "govc 설치 중..."
+
+    local govc_url="https://github.com/vmware/govmomi/releases/latest/download/govc_Linux_x86_64.tar.gz"
+    if wget -qO- "$govc_url" | tar -xz -C /usr/local/bin govc; then
+        chmod +x /usr/local/bin/govc
+        log_success "govc 설치 완료"
+    else
+        log_error "govc 다운로드 실패"
+        return 1
+    fi
+}
+
 # Terraform 설치
 install_terraform() {
     log_info "Terraform 설치 중..."
@@ -136,11 +150,17 @@ check_dependencies() {
         install_terraform
     fi
 
+    # govc 확인 및 설치
+    if ! command -v govc &> /dev/null; then
+        install_govc
+    fi
+
     # 최종 확인
     local missing=()
     command -v jq &> /dev/null || missing+=("jq")
     command -v yq &> /dev/null || missing+=("yq")
     command -v terraform &> /dev/null || missing+=("terraform")
+    command -v govc &> /dev/null || missing+=("govc")
 
     if [[ ${#missing[@]} -gt 0 ]]; then
         log_error "다음 패키지 설치에 실패했습니다: ${missing[*]}"
@@ -326,7 +346,7 @@ install_cli_scripts() {
     fi
 
     # 사용자 CLI (Stage 1: VM)
-    local user_scripts=("create-vm" "delete-vm" "list-vms" "list-resources" "show-quota")
+    local user_scripts=("create-vm" "delete-vm" "power-vm" "resize-vm" "snapshot-vm" "disk-vm" "label-resource" "renew-lease" "list-vms" "list-resources" "show-quota")
     for script in "${user_scripts[@]}"; do
         if [[ -f "$script_dir/scripts/user/$script" ]]; then
             cp "$script_dir/scripts/user/$script" "$bin_dir/"
@@ -414,6 +434,12 @@ setup_sudoers() {
 # basphere-users 그룹: 사용자 CLI 실행 가능 (Stage 1: VM)
 %basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/create-vm
 %basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/delete-vm
+%basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/power-vm
+%basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/resize-vm
+%basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/snapshot-vm
+%basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/disk-vm
+%basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/label-resource
+%basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/renew-lease
 %basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/list-vms
 %basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/list-resources
 %basphere-users ALL=(basphere) NOPASSWD: /usr/local/bin/show-quota
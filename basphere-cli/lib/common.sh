@@ -82,6 +82,33 @@ get_config() {
     fi
 }
 
+# 사용자 할당량 값 읽기 (users > teams > default 순서로 적용)
+# 관리자가 API로 설정한 할당량은 API 서버만 알고 있으므로 --max-ips 등의 옵션으로 전달됩니다.
+get_user_quota() {
+    local user="$1"
+    local key="$2"
+    local default="${3:-}"
+
+    local value
+    value=$(get_config ".quotas.users[\"$user\"].$key" "")
+    if [[ -n "$value" ]]; then
+        echo "$value"
+        return
+    fi
+
+    local team
+    team=$(get_user_metadata "$user" "team")
+    if [[ -n "$team" ]]; then
+        value=$(get_config ".quotas.teams[\"$team\"].$key" "")
+        if [[ -n "$value" ]]; then
+            echo "$value"
+            return
+        fi
+    fi
+
+    get_config ".quotas.default.$key" "$default"
+}
+
 # 스펙 파일에서 값 읽기
 get_spec() {
     local key="$1"
@@ -114,6 +141,34 @@ load_vsphere_env() {
     fi
 }
 
+# govc 환경변수 설정 (vsphere.env 인증 정보 + config.yaml의 vCenter/데이터센터)
+load_govc_env() {
+    load_vsphere_env || return 1
+
+    export GOVC_URL
+    GOVC_URL=$(get_config '.vsphere.server' '')
+    export GOVC_USERNAME="${VSPHERE_USER:-}"
+    export GOVC_PASSWORD="${VSPHERE_PASSWORD:-}"
+    export GOVC_INSECURE="${VSPHERE_ALLOW_UNVERIFIED_SSL:-false}"
+    export GOVC_DATACENTER
+    GOVC_DATACENTER=$(get_config '.vsphere.datacenter' 'DC1')
+
+    if [[ -z "$GOVC_URL" ]]; then
+        log_error "vCenter 서버 주소가 설정되지 않았습니다 (.vsphere.server)"
+        return 1
+    fi
+}
+
+# VM 인벤토리 경로 (create-vm의 폴더 구조: <folder>/<사용자>/<사용자>-<VM 이름>)
+get_vm_inventory_path() {
+    local user="$1"
+    local vsphere_vm_name="$2"
+    local datacenter folder
+    datacenter=$(get_config '.vsphere.datacenter' 'DC1')
+    folder=$(get_config '.vsphere.folder' 'basphere-vms')
+    echo "/$datacenter/vm/$folder/$user/$vsphere_vm_name"
+}
+
 # ============================================
 # 사용자 관련 함수
 # ============================================
@@ -161,6 +216,56 @@ set_user_metadata() {
     jq ".$key = \"$value\"" "$metadata_file" > "$tmp_file" && mv "$tmp_file" "$metadata_file"
 }
 
+# 사용자 등록 SSH 공개키 가져오기
+# 등록 키는 메타데이터 옆(users/<user>/public_key)에 보관합니다. 보관 파일이 없는 기존 사용자는
+# authorized_keys의 첫 번째 키를 사용합니다 (cert-authority 줄 제외).
+get_user_ssh_key() {
+    local user="$1"
+    local key_file="$BASPHERE_DATA_DIR/users/$user/public_key"
+    local ssh_key_file="/home/$user/.ssh/authorized_keys"
+
+    if [[ -f "$key_file" ]]; then
+        head -1 "$key_file"
+    elif [[ -f "$ssh_key_file" ]]; then
+        grep -v -e '^#' -e '^cert-authority' -e '^[[:space:]]*$' "$ssh_key_file" | head -1 || true
+    else
+        log_warn "SSH 공개키를 찾을 수 없습니다"
+        echo ""
+    fi
+}
+
+# 사용자 등록 SSH 공개키 저장
+set_user_ssh_key() {
+    local user="$1"
+    local pubkey_file="$2"
+    local key_file="$BASPHERE_DATA_DIR/users/$user/public_key"
+
+    mkdir -p "$(dirname "$key_file")"
+    tr -d '\r' < "$pubkey_file" | sed '/^[[:space:]]*$/d' | head -1 > "$key_file"
+    chmod 644 "$key_file"
+}
+
+# Bastion authorized_keys 내용 출력
+# ssh_ca.public_key_file이 설정되어 있으면 등록 키 대신 사용자 이름으로 발급된 인증서만 받는
+# cert-authority 줄을 사용합니다 (원본 키로는 Bastion에 접속할 수 없음)
+bastion_authorized_keys() {
+    local user="$1"
+    local pubkey_file="$2"
+    local ca_file
+    ca_file=$(get_config '.ssh_ca.public_key_file' '')
+
+    if [[ -z "$ca_file" ]]; then
+        tr -d '\r' < "$pubkey_file"
+        return 0
+    fi
+
+    if [[ ! -f "$ca_file" ]]; then
+        log_error "SSH CA 공개키 파일을 찾을 수 없습니다: $ca_file"
+        return 1
+    fi
+    echo "cert-authority,principals=\"$user\" $(head -1 "$ca_file")"
+}
+
 # ============================================
 # 검증 함수
 # ============================================
@@ -202,6 +307,31 @@ vm_name_exists() {
     [[ -d "$tf_dir" ]]
 }
 
+# 목록에 값 추가 (줄바꿈 구분)
+append_line() {
+    local list="$1"
+    local value="$2"
+
+    if [[ -z "$list" ]]; then
+        echo "$value"
+    else
+        printf '%s\n%s' "$list" "$value"
+    fi
+}
+
+# 라벨 인자 확인 (key=value 형태, 키/값 형식은 API 서버가 검증)
+is_label_arg() {
+    local arg="$1"
+    [[ "$arg" == *=* && -n "${arg%%=*}" ]]
+}
+
+# key=value 목록 (줄바꿈 구분)을 JSON 객체로 변환
+labels_to_json() {
+    local labels="$1"
+    jq -n --arg labels "$labels" \
+        '$labels | split("\n") | map(select(. != "") | capture("^(?<key>[^=]+)=(?<value>.*)$")) | from_entries'
+}
+
 # ============================================
 # 대화형 입력 함수
 # ============================================
@@ -409,6 +539,19 @@ get_api_url() {
     echo "$url"
 }
 
+# API 토큰 (환경변수 또는 ~/.basphere/api-token)
+get_api_token() {
+    if [[ -n "${BASPHERE_API_TOKEN:-}" ]]; then
+        echo "$BASPHERE_API_TOKEN"
+        return
+    fi
+
+    local token_file="$HOME/.basphere/api-token"
+    if [[ -r "$token_file" ]]; then
+        tr -d '[:space:]' < "$token_file"
+    fi
+}
+
 # API 호출 공통 함수
 api_call() {
     local method="$1"
@@ -416,16 +559,19 @@ api_call() {
     local data="${3:-}"
     local api_url
     api_url=$(get_api_url)
-    local user
-    user=$(get_current_user)
+    local token
+    token=$(get_api_token)
 
     local curl_args=(
         -s
         -X "$method"
         -H "Content-Type: application/json"
-        -H "X-Basphere-User: $user"
     )
 
+    if [[ -n "$token" ]]; then
+        curl_args+=(-H "Authorization: Bearer $token")
+    fi
+
     if [[ -n "$data" ]]; then
         curl_args+=(-d "$data")
     fi
@@ -449,6 +595,48 @@ api_get_error() {
     echo "$response" | jq -r '.errors[]? // .message // "Unknown error"'
 }
 
+# 비동기 작업 완료 대기 (완료된 작업 JSON을 출력, 성공 시 0 반환)
+api_wait_job() {
+    local job_id="$1"
+    local timeout="${2:-3600}"
+    local interval=5
+    local waited=0
+
+    while [[ $waited -lt $timeout ]]; do
+        local response status
+        response=$(api_call "GET" "/api/v1/jobs/$job_id")
+        status=$(echo "$response" | jq -r '.data.status // "unknown"')
+
+        case "$status" in
+            succeeded)
+                printf "\r" >&2
+                echo "$response" | jq '.data'
+                return 0
+                ;;
+            failed)
+                printf "\r" >&2
+                echo "$response" | jq '.data'
+                return 1
+                ;;
+            queued|running)
+                printf "\r${CYAN}*${NC} 작업 진행 중 (%s, %d초 경과)..." "$status" "$waited" >&2
+                ;;
+            *)
+                printf "\r" >&2
+                log_error "작업 상태를 확인할 수 없습니다: $(api_get_error "$response")"
+                return 1
+                ;;
+        esac
+
+        sleep "$interval"
+        waited=$((waited + interval))
+    done
+
+    printf "\r" >&2
+    log_error "작업 대기 시간이 초과되었습니다 (job: $job_id)"
+    return 1
+}
+
 # API 서버 연결 확인
 check_api_connection() {
     local api_url
@@ -9,6 +9,9 @@ readonly ALLOCATIONS_FILE="$IPAM_DIR/allocations.tsv"
 readonly LEASES_FILE="$IPAM_DIR/leases.tsv"
 readonly IPAM_LOCK="$IPAM_DIR/.lock"
 
+# Go IPAM 바이너리 (basphere-api와 함께 설치, 없으면 bash 구현 사용)
+readonly IPAM_BIN="/usr/local/bin/basphere-ipam"
+
 # 공통 라이브러리 로드
 source /usr/local/lib/basphere/common.sh 2>/dev/null || source "$(dirname "$0")/../../lib/common.sh"
 
@@ -18,13 +21,13 @@ load_network_config() {
     NETWORK_GATEWAY=$(get_config '.network.gateway' '10.254.0.1')
     NETWORK_BLOCK_SIZE=$(get_config '.network.block_size' '32')
 
-    # CIDR에서 시작 IP와 끝 IP 계산
+    # CIDR에서 네트워크 주소와 브로드캐스트 주소 계산 (호스트 비트는 버림)
     local base_ip="${NETWORK_CIDR%/*}"
     local prefix="${NETWORK_CIDR#*/}"
 
-    NETWORK_START_INT=$(ip_to_int "$base_ip")
     local host_bits=$((32 - prefix))
     local num_hosts=$((1 << host_bits))
+    NETWORK_START_INT=$(( $(ip_to_int "$base_ip") & ~(num_hosts - 1) & 0xFFFFFFFF ))
     NETWORK_END_INT=$((NETWORK_START_INT + num_hosts - 1))
 
     # 예약된 IP 로드
@@ -71,6 +74,22 @@ get_user_block() {
     return 1
 }
 
+# IP를 VM에 임대할 수 있는지 확인 (Go IPAM과 같은 규칙)
+# 네트워크 주소, 브로드캐스트 주소, 예약된 IP(게이트웨이 포함)는 임대하지 않음
+is_available_ip() {
+    local ip="$1"
+    local ip_int
+    ip_int=$(ip_to_int "$ip")
+
+    if [[ $ip_int -eq $NETWORK_START_INT || $ip_int -eq $NETWORK_END_INT ]]; then
+        return 1
+    fi
+    if is_reserved_ip "$ip"; then
+        return 1
+    fi
+    return 0
+}
+
 # 다음 사용 가능한 블록 찾기
 find_next_available_block() {
     load_network_config
@@ -84,10 +103,10 @@ find_next_available_block() {
         done < "$ALLOCATIONS_FILE"
     fi
 
-    # 첫 번째 블록부터 검색 (예약된 IP 이후)
+    # 첫 번째 블록부터 검색 (예약된 IP 이후), 블록 전체가 네트워크 안에 있어야 함
     local block_start_int=$((NETWORK_START_INT + NETWORK_BLOCK_SIZE))  # 첫 블록 건너뛰기 (예약용)
 
-    while [[ $block_start_int -lt $NETWORK_END_INT ]]; do
+    while [[ $((block_start_int + NETWORK_BLOCK_SIZE - 1)) -le $NETWORK_END_INT ]]; do
         local block_start_ip
         block_start_ip=$(int_to_ip $block_start_int)
 
@@ -122,14 +141,12 @@ find_next_available_ip() {
     block_start_int=$(ip_to_int "$block_start")
     local block_end_int=$((block_start_int + NETWORK_BLOCK_SIZE - 1))
 
-    # 현재 사용 중인 IP 수집
+    # 현재 사용 중인 IP 수집 (블록이 재할당되었을 수 있으므로 모든 사용자의 임대를 확인)
     local used_ips=()
     if [[ -f "$LEASES_FILE" ]]; then
-        while IFS=$'\t' read -r ip lease_user _; do
+        while IFS=$'\t' read -r ip _; do
             [[ "$ip" =~ ^#.*$ || -z "$ip" ]] && continue
-            if [[ "$lease_user" == "$user" ]]; then
-                used_ips+=("$ip")
-            fi
+            used_ips+=("$ip")
         done < "$LEASES_FILE"
     fi
 
@@ -140,8 +157,8 @@ find_next_available_ip() {
         local current_ip
         current_ip=$(int_to_ip $current_int)
 
-        # 예약된 IP인지 확인
-        if is_reserved_ip "$current_ip"; then
+        # 네트워크/브로드캐스트/예약 IP인지 확인
+        if ! is_available_ip "$current_ip"; then
             ((current_int++))
             continue
         fi
@@ -189,3 +206,11 @@ is_user_ip() {
 
     (grep -v '^#' "$LEASES_FILE" 2>/dev/null || true) | awk -F'\t' -v u="$user" -v i="$ip" '$2 == u && $1 == i {found=1} END {exit !found}'
 }
+
+# Go IPAM 바이너리로 위임 (같은 TSV 파일과 락을 사용하므로 bash 구현과 혼용 가능)
+# 설치되어 있으면 현재 프로세스를 대체하고, 없으면 그대로 반환
+delegate_to_ipam_bin() {
+    if [[ -x "$IPAM_BIN" ]]; then
+        exec "$IPAM_BIN" -config "$BASPHERE_CONFIG" -dir "$IPAM_DIR" -audit-log "$BASPHERE_LOG_DIR/audit.log" "$@"
+    fi
+}